@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/labstack/gommon/bytes"
 	"github.com/labstack/gommon/log"
 	"github.com/rostis232/prmv/internal/pkg/app"
 )
@@ -15,8 +18,39 @@ import (
 // @host localhost:8080
 // @BasePath /
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Access token from /auth/login as "Bearer <token>"
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description API key from /api-keys as "ApiKey <key>"
+
 func main() {
-	a, err := app.NewApp(pgConfig())
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		databaseURL = pgConfig()
+	}
+
+	a, err := app.NewApp(app.Config{
+		DatabaseURL:     databaseURL,
+		RequestTimeout:  durationEnv("REQUEST_TIMEOUT", defaultRequestTimeout),
+		TrashRetention:  durationEnv("TRASH_RETENTION", defaultTrashRetention),
+		JWTSecret:       os.Getenv("JWT_SECRET"),
+		AccessTokenTTL:  durationEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL),
+		RefreshTokenTTL: durationEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+
+		TrustedUserHeader:  os.Getenv("TRUSTED_USER_HEADER"),
+		TrustedRolesHeader: os.Getenv("TRUSTED_ROLES_HEADER"),
+
+		AttachmentsDir:    stringEnv("ATTACHMENTS_DIR", defaultAttachmentsDir),
+		MaxAttachmentSize: sizeEnv("ATTACHMENT_MAX_SIZE", defaultAttachmentMaxSize),
+
+		CacheSize: intEnv("CACHE_SIZE", 0),
+		CacheTTL:  durationEnv("CACHE_TTL", defaultCacheTTL),
+	})
 	if err != nil {
 		log.Panic(err)
 	}
@@ -44,3 +78,71 @@ func pgConfig() string {
 	log.Infof("PG_DB_NAME: %s", pgDBname)
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable timezone=UTC connect_timeout=5", pgHost, pgPort, pgUser, pgPass, pgDBname)
 }
+
+const (
+	defaultRequestTimeout  = 10 * time.Second
+	defaultTrashRetention  = 30 * 24 * time.Hour
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	defaultAttachmentsDir    = "attachments"
+	defaultAttachmentMaxSize = 10 << 20
+
+	defaultCacheTTL = time.Minute
+)
+
+func durationEnv(name string, def time.Duration) time.Duration {
+	durationStr := os.Getenv(name)
+	if durationStr == "" {
+		return def
+	}
+
+	duration, err := time.ParseDuration(durationStr)
+	if err != nil {
+		log.Panicf("invalid %s %q: %v", name, durationStr, err)
+	}
+
+	log.Infof("%s: %s", name, duration)
+	return duration
+}
+
+func stringEnv(name string, def string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	log.Infof("%s: %s", name, value)
+	return value
+}
+
+func intEnv(name string, def int) int {
+	valueStr := os.Getenv(name)
+	if valueStr == "" {
+		return def
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil || value < 0 {
+		log.Panicf("invalid %s %q: %v", name, valueStr, err)
+	}
+
+	log.Infof("%s: %d", name, value)
+	return value
+}
+
+// sizeEnv reads a size such as "10MB" or "512KiB".
+func sizeEnv(name string, def int64) int64 {
+	sizeStr := os.Getenv(name)
+	if sizeStr == "" {
+		return def
+	}
+
+	size, err := bytes.Parse(sizeStr)
+	if err != nil || size <= 0 {
+		log.Panicf("invalid %s %q: %v", name, sizeStr, err)
+	}
+
+	log.Infof("%s: %d bytes", name, size)
+	return size
+}
@@ -0,0 +1,94 @@
+// Package apperr defines the domain errors shared by the repository, service
+// and handler layers. The repository tags failures with one of the kinds below,
+// the service passes them through untouched and the handler translates them into
+// HTTP status codes in one place.
+package apperr
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("unavailable")
+	// ErrPreconditionFailed means the caller's expected version of a resource
+	// no longer matches the stored one.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnauthorized means the caller could not be identified: credentials or
+	// tokens are missing, wrong or expired.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden means the caller is known but not allowed to do what it asked.
+	ErrForbidden = errors.New("forbidden")
+	// ErrAborted means an operation was not applied because another operation
+	// it was grouped with failed.
+	ErrAborted = errors.New("aborted")
+)
+
+// Error is a domain error. Kind is one of the sentinel errors above, Message is
+// safe to show to API clients and Err is the underlying cause, if any.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+// Unwrap exposes both the kind and the cause, so errors.Is works for either.
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+
+	return []error{e.Kind}
+}
+
+func NotFound(message string, err error) error {
+	return &Error{Kind: ErrNotFound, Message: message, Err: err}
+}
+
+func Conflict(message string, err error) error {
+	return &Error{Kind: ErrConflict, Message: message, Err: err}
+}
+
+func Validation(message string, err error) error {
+	return &Error{Kind: ErrValidation, Message: message, Err: err}
+}
+
+func Unavailable(message string, err error) error {
+	return &Error{Kind: ErrUnavailable, Message: message, Err: err}
+}
+
+func PreconditionFailed(message string, err error) error {
+	return &Error{Kind: ErrPreconditionFailed, Message: message, Err: err}
+}
+
+func Unauthorized(message string, err error) error {
+	return &Error{Kind: ErrUnauthorized, Message: message, Err: err}
+}
+
+func Forbidden(message string, err error) error {
+	return &Error{Kind: ErrForbidden, Message: message, Err: err}
+}
+
+func Aborted(message string, err error) error {
+	return &Error{Kind: ErrAborted, Message: message, Err: err}
+}
+
+// Message returns the client-facing message of the first *Error in err's chain.
+func Message(err error) (string, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Message, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,75 @@
+package apperr
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	testCases := []struct {
+		err     error
+		kind    error
+		message string
+		text    string
+	}{
+		{
+			err:     NotFound("post not found", fmt.Errorf("error getting post: %w", sql.ErrNoRows)),
+			kind:    ErrNotFound,
+			message: "post not found",
+			text:    "post not found: error getting post: sql: no rows in result set",
+		},
+		{
+			err:     Conflict("post already exists", nil),
+			kind:    ErrConflict,
+			message: "post already exists",
+			text:    "post already exists",
+		},
+		{
+			err:     Validation("title is too long", nil),
+			kind:    ErrValidation,
+			message: "title is too long",
+			text:    "title is too long",
+		},
+		{
+			err:     Unauthorized("invalid access token", nil),
+			kind:    ErrUnauthorized,
+			message: "invalid access token",
+			text:    "invalid access token",
+		},
+		{
+			err:     Forbidden("only the author can change this post", nil),
+			kind:    ErrForbidden,
+			message: "only the author can change this post",
+			text:    "only the author can change this post",
+		},
+		{
+			err:     Aborted("another operation failed", nil),
+			kind:    ErrAborted,
+			message: "another operation failed",
+			text:    "another operation failed",
+		},
+		{
+			err:     fmt.Errorf("outer: %w", Unavailable("database is unavailable", nil)),
+			kind:    ErrUnavailable,
+			message: "database is unavailable",
+			text:    "outer: database is unavailable",
+		},
+	}
+
+	for i, tc := range testCases {
+		assert.ErrorIs(t, tc.err, tc.kind, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.text, tc.err.Error(), fmt.Sprintf("case %d", i))
+
+		message, ok := Message(tc.err)
+		assert.True(t, ok, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.message, message, fmt.Sprintf("case %d", i))
+	}
+
+	assert.ErrorIs(t, testCases[0].err, sql.ErrNoRows)
+
+	_, ok := Message(sql.ErrNoRows)
+	assert.False(t, ok)
+}
@@ -0,0 +1,151 @@
+// Package auth issues and verifies the tokens API clients authenticate with
+// and carries the identity of the caller through request contexts.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rostis232/prmv/models"
+)
+
+// ErrInvalidToken means an access token is malformed, has a bad signature or
+// has expired.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config configures token issuing.
+type Config struct {
+	// Secret signs access tokens with HMAC-SHA256.
+	Secret []byte
+	// AccessTTL is how long an access token is valid.
+	AccessTTL time.Duration
+	// RefreshTTL is how long a refresh token can be exchanged for new tokens.
+	RefreshTTL time.Duration
+}
+
+// Tokens issues signed JWT access tokens and opaque refresh tokens.
+type Tokens struct {
+	config Config
+	now    func() time.Time
+}
+
+func NewTokens(cfg Config) *Tokens {
+	return &Tokens{config: cfg, now: time.Now}
+}
+
+type accessClaims struct {
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+// Access returns a signed access token for user and its expiry time.
+func (t *Tokens) Access(user models.User) (string, time.Time, error) {
+	now := t.now()
+	expires := now.Add(t.config.AccessTTL)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		Username: user.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	})
+
+	signed, err := token.SignedString(t.config.Secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("auth: error signing access token: %w", err)
+	}
+
+	return signed, expires, nil
+}
+
+// ParseAccess verifies an access token and returns the user it was issued to.
+func (t *Tokens) ParseAccess(token string) (models.User, error) {
+	var claims accessClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return t.config.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithTimeFunc(t.now), jwt.WithExpirationRequired())
+	if err != nil {
+		return models.User{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil || id < 1 {
+		return models.User{}, fmt.Errorf("%w: invalid subject", ErrInvalidToken)
+	}
+
+	return models.User{ID: id, Username: claims.Username}, nil
+}
+
+// Refresh returns a new random refresh token, the hash under which it is
+// stored and its expiry time. Only the hash is persisted, so a leaked database
+// does not leak usable tokens.
+func (t *Tokens) Refresh() (string, string, time.Time, error) {
+	buf := make([]byte, 32)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("auth: error generating refresh token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	return token, HashToken(token), t.now().Add(t.config.RefreshTTL), nil
+}
+
+// AccessTTL returns how long access tokens are valid.
+func (t *Tokens) AccessTTL() time.Duration {
+	return t.config.AccessTTL
+}
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognise.
+const APIKeyPrefix = "prmv_"
+
+// apiKeyDisplayLength is how much of a key is kept in clear text to tell keys
+// apart.
+const apiKeyDisplayLength = len(APIKeyPrefix) + 8
+
+// NewAPIKey generates an API key. It returns the key, the prefix shown in
+// listings and the hash to store.
+func NewAPIKey() (string, string, string, error) {
+	buf := make([]byte, 32)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", "", "", fmt.Errorf("auth: error generating API key: %w", err)
+	}
+
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	return key, key[:apiKeyDisplayLength], HashToken(key), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of an opaque token. Tokens carry
+// 256 bits of randomness, so a fast hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx that carries the identity of the caller.
+func NewContext(ctx context.Context, identity models.Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the identity of the caller stored in ctx, if any.
+func FromContext(ctx context.Context) (models.Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(models.Identity)
+	return identity, ok
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessToken(t *testing.T) {
+	tokens := NewTokens(Config{Secret: []byte("secret"), AccessTTL: time.Minute})
+	user := models.User{ID: 7, Username: "gopher"}
+
+	token, expires, err := tokens.Access(user)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expires, 5*time.Second)
+
+	parsed, err := tokens.ParseAccess(token)
+	assert.NoError(t, err)
+	assert.Equal(t, user, parsed)
+
+	other := NewTokens(Config{Secret: []byte("other"), AccessTTL: time.Minute})
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Subject:   "7",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+	noExpiry, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "7"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	noSubject, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	expired := NewTokens(Config{Secret: []byte("secret"), AccessTTL: time.Minute})
+	expired.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	oldToken, _, err := expired.Access(user)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		tokens *Tokens
+		token  string
+	}{
+		{tokens: other, token: token},
+		{tokens: tokens, token: oldToken},
+		{tokens: tokens, token: unsigned},
+		{tokens: tokens, token: noExpiry},
+		{tokens: tokens, token: noSubject},
+		{tokens: tokens, token: "not a token"},
+	}
+
+	for i, tc := range testCases {
+		_, err := tc.tokens.ParseAccess(tc.token)
+		assert.ErrorIs(t, err, ErrInvalidToken, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	tokens := NewTokens(Config{Secret: []byte("secret"), RefreshTTL: time.Hour})
+
+	first, hash, expires, err := tokens.Refresh()
+	assert.NoError(t, err)
+	assert.Equal(t, HashToken(first), hash)
+	assert.Len(t, hash, 64)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, 5*time.Second)
+
+	second, _, _, err := tokens.Refresh()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestAPIKey(t *testing.T) {
+	key, prefix, hash, err := NewAPIKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, APIKeyPrefix))
+	assert.True(t, strings.HasPrefix(key, prefix))
+	assert.Len(t, prefix, len(APIKeyPrefix)+8)
+	assert.Equal(t, HashToken(key), hash)
+
+	other, _, _, err := NewAPIKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	identity := models.Identity{UserID: 1, Username: "gopher", Roles: []string{"admin"}}
+	found, ok := FromContext(NewContext(context.Background(), identity))
+	assert.True(t, ok)
+	assert.Equal(t, identity, found)
+}
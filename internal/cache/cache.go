@@ -0,0 +1,290 @@
+// Package cache keeps recently read posts of a repository in memory, so that
+// popular posts are not read from the database on every request. Posts
+// changed through the cache are dropped from it at once. Posts changed in
+// other ways, such as by another replica, are dropped through Invalidate and
+// InvalidateAll, which the app calls for the changes PostgreSQL announces
+// with NOTIFY. With a backend that announces no changes, such as the
+// in-memory or SQLite ones, those posts may be served stale for up to the TTL
+// of the cache. Reads that writes depend on, through GetPostForUpdate, always
+// go to the repository.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rostis232/prmv/internal/service"
+	"github.com/rostis232/prmv/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// loadTimeout bounds a read of a post shared by concurrent callers, which does
+// not end when the caller that started it goes away.
+const loadTimeout = 30 * time.Second
+
+// Cache is a service.Repository that answers GetPost from a least recently
+// used cache of posts and passes everything else on to the repository it
+// wraps. It is safe for concurrent use.
+type Cache struct {
+	service.Repository
+
+	size int
+	ttl  time.Duration
+	// now returns the current time, which entries expire by.
+	now func() time.Time
+
+	loads singleflight.Group
+
+	mu      sync.Mutex
+	entries map[int]*list.Element
+	// order holds the entries, most recently used first.
+	order *list.List
+	// generation counts invalidations, so that a post loaded while it was
+	// being changed is not cached.
+	generation uint64
+	stats      Stats
+}
+
+// entry is a cached post.
+type entry struct {
+	post      models.Post
+	expiresAt time.Time
+}
+
+// Stats counts how the cache has been used since it was created.
+type Stats struct {
+	// Hits are reads answered from the cache.
+	Hits uint64 `json:"hits"`
+	// Misses are reads passed on to the repository, including those that
+	// waited for a read of the same post by another caller.
+	Misses uint64 `json:"misses"`
+	// Evictions are posts dropped to make room for others.
+	Evictions uint64 `json:"evictions"`
+	// Size is how many posts are cached now.
+	Size int `json:"size"`
+}
+
+// NewCache returns a cache of at most size posts of repo, each kept for at
+// most ttl. A zero ttl keeps posts until they are changed or evicted.
+func NewCache(repo service.Repository, size int, ttl time.Duration) *Cache {
+	return &Cache{
+		Repository: repo,
+		size:       max(size, 1),
+		ttl:        ttl,
+		now:        time.Now,
+		entries:    make(map[int]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// GetPost returns the post with the given id from the cache, or reads it from
+// the repository and caches it. Concurrent reads of a post that is not cached
+// share a single read of the repository, which goes on when callers give up
+// waiting for it, so that their going away does not fail the others. Reads
+// started after the post was invalidated do not share reads started before.
+// Errors are not cached.
+func (c *Cache) GetPost(ctx context.Context, id int) (models.Post, error) {
+	post, generation, ok := c.get(id)
+	if ok {
+		return post, nil
+	}
+
+	key := strconv.Itoa(id) + "@" + strconv.FormatUint(generation, 10)
+
+	loaded := c.loads.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		post, err := c.Repository.GetPost(ctx, id)
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		c.add(post, generation)
+		return post, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return models.Post{}, ctx.Err()
+	case result := <-loaded:
+		if result.Err != nil {
+			return models.Post{}, result.Err
+		}
+
+		return copyPost(result.Val.(models.Post)), nil
+	}
+}
+
+// GetPostForUpdate reads the post from the repository, bypassing the cache, so
+// that writes are not authorized or version checked against a stale post.
+func (c *Cache) GetPostForUpdate(ctx context.Context, id int) (models.Post, error) {
+	return c.Repository.GetPostForUpdate(ctx, id)
+}
+
+// UpdatePost updates the post and drops it from the cache.
+func (c *Cache) UpdatePost(ctx context.Context, post models.Post) (int, error) {
+	defer c.Invalidate(post.ID)
+
+	return c.Repository.UpdatePost(ctx, post)
+}
+
+// DeletePost trashes the post and drops it from the cache.
+func (c *Cache) DeletePost(ctx context.Context, id int, version int) error {
+	defer c.Invalidate(id)
+
+	return c.Repository.DeletePost(ctx, id, version)
+}
+
+// BulkPosts applies ops and drops the posts they change from the cache.
+func (c *Cache) BulkPosts(ctx context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error) {
+	ids := make([]int, 0, len(ops))
+	for _, op := range ops {
+		if op.Op != models.BulkCreate {
+			ids = append(ids, op.Post.ID)
+		}
+	}
+	defer c.Invalidate(ids...)
+
+	return c.Repository.BulkPosts(ctx, ops, atomic)
+}
+
+// RestorePost restores the post from the trash and drops it from the cache.
+func (c *Cache) RestorePost(ctx context.Context, id int) error {
+	defer c.Invalidate(id)
+
+	return c.Repository.RestorePost(ctx, id)
+}
+
+// PurgePost deletes the post for good and drops it from the cache.
+func (c *Cache) PurgePost(ctx context.Context, id int) error {
+	defer c.Invalidate(id)
+
+	return c.Repository.PurgePost(ctx, id)
+}
+
+// PublishScheduledPosts publishes the posts that are due. As it does not tell
+// which posts it published, the whole cache is dropped if it published any.
+func (c *Cache) PublishScheduledPosts(ctx context.Context) (int64, error) {
+	published, err := c.Repository.PublishScheduledPosts(ctx)
+	if published > 0 || err != nil {
+		c.InvalidateAll()
+	}
+
+	return published, err
+}
+
+// Invalidate drops the posts with the given ids from the cache, and keeps
+// reads that are under way from caching what they read, which may be from
+// before the change.
+func (c *Cache) Invalidate(ids ...int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	for _, id := range ids {
+		if element, ok := c.entries[id]; ok {
+			c.remove(element)
+		}
+	}
+}
+
+// InvalidateAll drops every post from the cache.
+func (c *Cache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	clear(c.entries)
+	c.order.Init()
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+
+	return stats
+}
+
+// get returns the cached post with the given id, if there is one that has not
+// expired. Otherwise it returns the generation a read of the post must be
+// started at to be cached.
+func (c *Cache) get(id int) (models.Post, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[id]
+	if ok {
+		e := element.Value.(*entry)
+		if c.ttl == 0 || c.now().Before(e.expiresAt) {
+			c.stats.Hits++
+			c.order.MoveToFront(element)
+			return copyPost(e.post), 0, true
+		}
+
+		c.remove(element)
+	}
+
+	c.stats.Misses++
+	return models.Post{}, c.generation, false
+}
+
+// add caches post read at generation, unless the cache has been invalidated
+// since, evicting the least recently used posts to make room for it.
+func (c *Cache) add(post models.Post, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if element, ok := c.entries[post.ID]; ok {
+		c.remove(element)
+	}
+
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+
+	c.entries[post.ID] = c.order.PushFront(&entry{
+		post:      copyPost(post),
+		expiresAt: c.now().Add(c.ttl),
+	})
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).post.ID)
+}
+
+// copyPost returns post without memory shared with the cached one.
+func copyPost(post models.Post) models.Post {
+	post.AuthorID = copyPtr(post.AuthorID)
+	post.CategoryID = copyPtr(post.CategoryID)
+	post.PublishAt = copyPtr(post.PublishAt)
+	post.DeletedAt = copyPtr(post.DeletedAt)
+	post.Tags = slices.Clone(post.Tags)
+
+	return post
+}
+
+func copyPtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+
+	v := *p
+	return &v
+}
@@ -0,0 +1,392 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/memory"
+	"github.com/rostis232/prmv/internal/repotest"
+	"github.com/rostis232/prmv/internal/service"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ service.Repository = (*Cache)(nil)
+
+// testRepository is a cache in front of the in-memory repository, with the
+// role permissions repotest needs.
+type testRepository struct {
+	*Cache
+	memory *memory.Memory
+}
+
+func (r testRepository) GetRolePermissions(ctx context.Context) (map[string][]string, error) {
+	return r.memory.GetRolePermissions(ctx)
+}
+
+func TestRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		m := memory.NewMemory()
+		return testRepository{Cache: NewCache(m, 100, time.Minute), memory: m}
+	})
+}
+
+// countingRepository counts the posts read from it. Reads return once release
+// is closed, if it is set, with what they read before, unless their context
+// has ended by then.
+type countingRepository struct {
+	service.Repository
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (r *countingRepository) GetPost(ctx context.Context, id int) (models.Post, error) {
+	r.reads.Add(1)
+	post, err := r.Repository.GetPost(ctx, id)
+
+	if r.release != nil {
+		<-r.release
+	}
+
+	if ctx.Err() != nil {
+		return models.Post{}, ctx.Err()
+	}
+
+	return post, err
+}
+
+// addPosts adds n posts to repo and returns their ids.
+func addPosts(t *testing.T, repo service.Repository, n int) []int {
+	var ids []int
+	for i := 0; i < n; i++ {
+		id, err := repo.AddPost(context.Background(), models.Post{Title: fmt.Sprintf("Post %d", i), Content: "Content"})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func TestGetPost(t *testing.T) {
+	repo := &countingRepository{Repository: memory.NewMemory()}
+	c := NewCache(repo, 10, time.Minute)
+	ctx := context.Background()
+
+	ids := addPosts(t, c, 1)
+
+	for i := 0; i < 3; i++ {
+		post, err := c.GetPost(ctx, ids[0])
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, "Post 0", post.Title, fmt.Sprintf("case %d", i))
+
+		// Changing what is returned does not change what is cached.
+		post.Title = "Changed"
+		post.Tags = append(post.Tags, "changed")
+	}
+
+	assert.Equal(t, int32(1), repo.reads.Load())
+	assert.Equal(t, Stats{Hits: 2, Misses: 1, Size: 1}, c.Stats())
+
+	// Errors are not cached.
+	for i := 0; i < 2; i++ {
+		_, err := c.GetPost(ctx, ids[0]+1)
+		assert.ErrorIs(t, err, apperr.ErrNotFound, fmt.Sprintf("case %d", i))
+	}
+
+	assert.Equal(t, int32(3), repo.reads.Load())
+	assert.Equal(t, Stats{Hits: 2, Misses: 3, Size: 1}, c.Stats())
+}
+
+func TestGetPostForUpdate(t *testing.T) {
+	repo := &countingRepository{Repository: memory.NewMemory()}
+	c := NewCache(repo, 10, time.Minute)
+	ctx := context.Background()
+
+	ids := addPosts(t, c, 1)
+
+	_, err := c.GetPost(ctx, ids[0])
+	assert.NoError(t, err)
+
+	// A change the cache does not hear about, as by another replica.
+	_, err = repo.UpdatePost(ctx, models.Post{ID: ids[0], Version: 1, Title: "Changed", Content: "Content"})
+	assert.NoError(t, err)
+
+	cached, err := c.GetPost(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cached.Version)
+
+	// Reads for writes always see the stored post.
+	post, err := c.GetPostForUpdate(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "Changed", post.Title)
+	assert.Equal(t, 2, post.Version)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Size: 1}, c.Stats())
+}
+
+func TestEviction(t *testing.T) {
+	repo := &countingRepository{Repository: memory.NewMemory()}
+	c := NewCache(repo, 2, 0)
+	ctx := context.Background()
+
+	ids := addPosts(t, c, 3)
+
+	// Reading the first post again makes the second the least recently used,
+	// so reading the third evicts the second.
+	for _, id := range []int{ids[0], ids[1], ids[0], ids[2]} {
+		_, err := c.GetPost(ctx, id)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, Stats{Hits: 1, Misses: 3, Evictions: 1, Size: 2}, c.Stats())
+
+	testCases := []struct {
+		id     int
+		cached bool
+	}{
+		{id: ids[0], cached: true},
+		{id: ids[2], cached: true},
+		{id: ids[1], cached: false},
+	}
+
+	for i, tc := range testCases {
+		reads := repo.reads.Load()
+
+		_, err := c.GetPost(ctx, tc.id)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, !tc.cached, repo.reads.Load() > reads, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestTTL(t *testing.T) {
+	repo := &countingRepository{Repository: memory.NewMemory()}
+	c := NewCache(repo, 10, time.Minute)
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	ids := addPosts(t, c, 1)
+
+	testCases := []struct {
+		elapsed time.Duration
+		reads   int32
+	}{
+		{elapsed: 0, reads: 1},
+		{elapsed: 59 * time.Second, reads: 1},
+		{elapsed: time.Minute, reads: 2},
+		{elapsed: time.Second, reads: 2},
+	}
+
+	for i, tc := range testCases {
+		now = now.Add(tc.elapsed)
+
+		_, err := c.GetPost(ctx, ids[0])
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.reads, repo.reads.Load(), fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	past := time.Now().UTC().Add(-time.Hour)
+
+	testCases := []struct {
+		// change changes the post with the given id through the cache.
+		change func(c *Cache, id int) error
+		// check checks the post read after the change.
+		check func(post models.Post, err error)
+	}{
+		{
+			change: func(c *Cache, id int) error {
+				_, err := c.UpdatePost(context.Background(), models.Post{ID: id, Version: 1, Title: "Updated", Content: "Content"})
+				return err
+			},
+			check: func(post models.Post, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "Updated", post.Title)
+			},
+		},
+		{
+			change: func(c *Cache, id int) error { return c.DeletePost(context.Background(), id, 1) },
+			check: func(post models.Post, err error) {
+				assert.ErrorIs(t, err, apperr.ErrNotFound)
+			},
+		},
+		{
+			change: func(c *Cache, id int) error {
+				_, err := c.BulkPosts(context.Background(), []models.BulkOperation{
+					{Op: models.BulkCreate, Post: models.Post{Title: "New", Content: "Content"}},
+					{Op: models.BulkUpdate, Post: models.Post{ID: id, Version: 1, Title: "Bulk", Content: "Content"}},
+				}, true)
+				return err
+			},
+			check: func(post models.Post, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "Bulk", post.Title)
+			},
+		},
+		{
+			change: func(c *Cache, id int) error {
+				_, err := c.UpdatePost(context.Background(), models.Post{ID: id, Version: 1, Title: "Scheduled",
+					Content: "Content", Status: models.StatusScheduled, PublishAt: &past})
+				if err != nil {
+					return err
+				}
+
+				_, err = c.GetPost(context.Background(), id)
+				if err != nil {
+					return err
+				}
+
+				_, err = c.PublishScheduledPosts(context.Background())
+				return err
+			},
+			check: func(post models.Post, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, models.StatusPublished, post.Status)
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		c := NewCache(memory.NewMemory(), 10, 0)
+
+		ids := addPosts(t, c, 1)
+
+		_, err := c.GetPost(context.Background(), ids[0])
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		err = tc.change(c, ids[0])
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		tc.check(c.GetPost(context.Background(), ids[0]))
+	}
+}
+
+func TestSingleflight(t *testing.T) {
+	repo := &countingRepository{Repository: memory.NewMemory(), release: make(chan struct{})}
+	c := NewCache(repo, 10, time.Minute)
+
+	ids := addPosts(t, c, 1)
+
+	const readers = 8
+
+	var wg sync.WaitGroup
+	errs := make([]error, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = c.GetPost(context.Background(), ids[0])
+		}(i)
+	}
+
+	// Let every reader miss and join the first read before it returns.
+	for c.Stats().Misses < readers {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+	}
+	assert.Equal(t, int32(1), repo.reads.Load())
+	assert.Equal(t, Stats{Misses: readers, Size: 1}, c.Stats())
+}
+
+func TestCancelledRead(t *testing.T) {
+	repo := &countingRepository{Repository: memory.NewMemory(), release: make(chan struct{})}
+	c := NewCache(repo, 10, time.Minute)
+
+	ids := addPosts(t, c, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.GetPost(ctx, ids[0])
+		first <- err
+	}()
+	for repo.reads.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	type result struct {
+		post models.Post
+		err  error
+	}
+	second := make(chan result, 1)
+	go func() {
+		post, err := c.GetPost(context.Background(), ids[0])
+		second <- result{post: post, err: err}
+	}()
+	for c.Stats().Misses < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// The caller that started the read gives up without waiting for it, and
+	// the read goes on for the other.
+	cancel()
+	select {
+	case err := <-first:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		assert.Fail(t, "cancelled read did not return")
+	}
+
+	close(repo.release)
+	r := <-second
+	assert.NoError(t, r.err)
+	assert.Equal(t, "Post 0", r.post.Title)
+	assert.Equal(t, int32(1), repo.reads.Load())
+	assert.Equal(t, 1, c.Stats().Size)
+}
+
+func TestInvalidateDuringRead(t *testing.T) {
+	repo := &countingRepository{Repository: memory.NewMemory(), release: make(chan struct{})}
+	c := NewCache(repo, 10, time.Minute)
+	ctx := context.Background()
+
+	ids := addPosts(t, c, 1)
+
+	read := func() <-chan models.Post {
+		result := make(chan models.Post, 1)
+		go func() {
+			post, err := c.GetPost(ctx, ids[0])
+			assert.NoError(t, err)
+			result <- post
+		}()
+		return result
+	}
+
+	before := read()
+	for repo.reads.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := c.UpdatePost(ctx, models.Post{ID: ids[0], Version: 1, Title: "Updated", Content: "Content"})
+	assert.NoError(t, err)
+
+	// A read started after the post changed does not share the read started
+	// before.
+	after := read()
+	for repo.reads.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(repo.release)
+	assert.Equal(t, "Post 0", (<-before).Title)
+	assert.Equal(t, "Updated", (<-after).Title)
+
+	// Only what was read after the change is cached.
+	post, err := c.GetPost(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "Updated", post.Title)
+	assert.Equal(t, int32(2), repo.reads.Load())
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/models"
+)
+
+type apiKeyData struct {
+	Name   string   `json:"name" validate:"required"`
+	Scopes []string `json:"scopes" validate:"required"`
+	// ExpiresAt is optional; keys without it never expire.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create an API key that acts on your behalf, limited to the given scopes (posts:create, posts:update, posts:delete, roles:assign, comments:create, comments:update, comments:delete). The key is only shown in this response. API keys cannot be used to manage API keys.
+// @Tags api-keys
+// @Accept  json
+// @Produce  json
+// @Param key body apiKeyData true "Name, scopes and optional expiry"
+// @Success 201 {object} models.CreatedAPIKey
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api-keys [post]
+func (h *Handler) CreateAPIKey(c echo.Context) error {
+	var data apiKeyData
+
+	err := c.Bind(&data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid API key data")
+	}
+
+	err = h.validate.Struct(data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid API key data")
+	}
+
+	key, err := h.Service.CreateAPIKey(c.Request().Context(), models.APIKey{
+		Name:      data.Name,
+		Scopes:    data.Scopes,
+		ExpiresAt: data.ExpiresAt,
+	})
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error creating API key")
+	}
+
+	return c.JSON(http.StatusCreated, key)
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Description Get your API keys that have not been revoked. Secrets are never included.
+// @Tags api-keys
+// @Produce  json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api-keys [get]
+func (h *Handler) GetAPIKeys(c echo.Context) error {
+	keys, err := h.Service.GetAPIKeys(c.Request().Context())
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting API keys")
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke one of your API keys. It stops working immediately.
+// @Tags api-keys
+// @Produce  json
+// @Param id path int true "API key ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid API key id")
+	}
+
+	err = h.Service.RevokeAPIKey(c.Request().Context(), id)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error revoking API key")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	created := models.CreatedAPIKey{
+		APIKey: models.APIKey{ID: 1, UserID: 1, Name: "ci", Prefix: "prmv_abcdefgh", KeyHash: "hash", Scopes: []string{"posts:create"}},
+		Key:    "prmv_secret",
+	}
+
+	testCases := []struct {
+		body       string
+		serviceErr error
+		status     int
+	}{
+		{body: `{"name":"ci","scopes":["posts:create"]}`, status: http.StatusCreated},
+		{body: `{"name":"ci","scopes":["posts:create"]}`, serviceErr: apperr.Forbidden("scope posts:create is not allowed", nil), status: http.StatusForbidden},
+		{body: `{"name":"ci","scopes":["posts:create"]}`, serviceErr: apperr.Validation("unknown scope", nil), status: http.StatusUnprocessableEntity},
+		{body: `{"name":"ci"}`, status: http.StatusBadRequest},
+		{body: `{"scopes":["posts:create"]}`, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("CreateAPIKey", mock.Anything, models.APIKey{Name: "ci", Scopes: []string{"posts:create"}}).Return(created, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewBufferString(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.CreateAPIKey(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		if tc.status == http.StatusCreated {
+			assert.Contains(t, rec.Body.String(), `"key":"prmv_secret"`, fmt.Sprintf("case %d", i))
+			assert.NotContains(t, rec.Body.String(), "hash", fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	testCases := []struct {
+		id         string
+		serviceErr error
+		status     int
+	}{
+		{id: "1", status: http.StatusNoContent},
+		{id: "1", serviceErr: apperr.NotFound("API key not found", nil), status: http.StatusNotFound},
+		{id: "0", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("RevokeAPIKey", mock.Anything, 1).Return(tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api-keys/:id")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.id)
+
+		err := h.RevokeAPIKey(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// attachmentField is the multipart form field carrying the uploaded file.
+const attachmentField = "file"
+
+// AddAttachment godoc
+// @Summary Attach a file to a post
+// @Description Upload a file as multipart/form-data in the "file" field. The content type is sniffed from the content: images, audio, video, PDF, ZIP and plain text files are accepted, up to the configured size.
+// @Tags attachments
+// @Accept  mpfd
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Param file formData file true "File to attach"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/{id}/attachments [post]
+func (h *Handler) AddAttachment(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	// The file is streamed to storage instead of being buffered by the
+	// multipart parser.
+	reader, err := c.Request().MultipartReader()
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid attachment data")
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return newErrorResponse(c, http.StatusBadRequest, "missing file")
+		}
+		if errors.Is(err, echo.ErrStatusRequestEntityTooLarge) {
+			return newErrorResponse(c, http.StatusRequestEntityTooLarge, "attachment is too large")
+		}
+		if err != nil {
+			return newErrorResponse(c, http.StatusBadRequest, "invalid attachment data")
+		}
+
+		if part.FormName() != attachmentField || part.FileName() == "" {
+			continue
+		}
+
+		attachment, err := h.Service.AddAttachment(c.Request().Context(), id, part.FileName(), part)
+		if errors.Is(err, echo.ErrStatusRequestEntityTooLarge) {
+			return newErrorResponse(c, http.StatusRequestEntityTooLarge, "attachment is too large")
+		}
+		if err != nil {
+			return newServiceErrorResponse(c, err, "error adding attachment")
+		}
+
+		return c.JSON(http.StatusCreated, attachment)
+	}
+}
+
+// GetAttachments godoc
+// @Summary List attachments of a post
+// @Description Get the files attached to a post, oldest first.
+// @Tags attachments
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Success 200 {array} models.Attachment
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /posts/{id}/attachments [get]
+func (h *Handler) GetAttachments(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	attachments, err := h.Service.GetAttachments(c.Request().Context(), id)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting attachments")
+	}
+
+	return c.JSON(http.StatusOK, attachments)
+}
+
+// GetAttachment godoc
+// @Summary Download an attachment
+// @Description Stream the file of an attachment with its content type. Range requests are supported. Images, audio and video are shown inline, other files are downloaded.
+// @Tags attachments
+// @Produce  octet-stream
+// @Param id path int true "Post ID"
+// @Param attachment path int true "Attachment ID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 416 {string} string
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /posts/{id}/attachments/{attachment} [get]
+func (h *Handler) GetAttachment(c echo.Context) error {
+	postID, attachmentID, err := parseAttachmentParams(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	attachment, file, err := h.Service.OpenAttachment(c.Request().Context(), postID, attachmentID)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting attachment")
+	}
+	defer file.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, attachment.ContentType)
+	header.Set(echo.HeaderContentDisposition, contentDisposition(attachment.ContentType, attachment.Filename))
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+
+	http.ServeContent(c.Response(), c.Request(), attachment.Filename, attachment.CreatedAt, file)
+
+	return nil
+}
+
+// DeleteAttachment godoc
+// @Summary Delete an attachment
+// @Description Remove a file from a post. Requires permission to update the post.
+// @Tags attachments
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Param attachment path int true "Attachment ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/{id}/attachments/{attachment} [delete]
+func (h *Handler) DeleteAttachment(c echo.Context) error {
+	postID, attachmentID, err := parseAttachmentParams(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	err = h.Service.DeleteAttachment(c.Request().Context(), postID, attachmentID)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error deleting attachment")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// contentDisposition shows media inline and has browsers download any other
+// file under its original name.
+func contentDisposition(contentType, filename string) string {
+	disposition := "attachment"
+
+	mediaType, _, _ := strings.Cut(contentType, "/")
+	switch mediaType {
+	case "image", "audio", "video":
+		disposition = "inline"
+	}
+
+	return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+}
+
+// parseAttachmentParams reads the post and attachment ids of attachment
+// routes.
+func parseAttachmentParams(c echo.Context) (int, int, error) {
+	postID, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return 0, 0, errors.New("invalid post id")
+	}
+
+	attachmentID, err := parsePositiveParam(c, "attachment")
+	if err != nil {
+		return 0, 0, errors.New("invalid attachment id")
+	}
+
+	return postID, attachmentID, nil
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func multipartBody(t *testing.T, field, filename, content string) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+
+	w := multipart.NewWriter(&body)
+
+	err := w.WriteField("note", "ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part, err := w.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = part.Write([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &body, w.FormDataContentType()
+}
+
+func TestAddAttachment(t *testing.T) {
+	testCases := []struct {
+		field      string
+		content    string
+		serviceErr error
+		status     int
+	}{
+		{field: "file", content: "Hello", status: http.StatusCreated},
+		{field: "file", content: "<html>", serviceErr: apperr.Validation("attachments of type text/html are not allowed", nil), status: http.StatusUnprocessableEntity},
+		{field: "file", content: strings.Repeat("a", 2048), serviceErr: fmt.Errorf("error storing file: %w", echo.ErrStatusRequestEntityTooLarge), status: http.StatusRequestEntityTooLarge},
+		{field: "other", content: "Hello", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("AddAttachment", mock.Anything, 1, "notes.txt", mock.Anything).Run(func(args mock.Arguments) {
+				_, _ = io.ReadAll(args.Get(3).(io.Reader))
+			}).Return(models.Attachment{ID: 1, PostID: 1, Filename: "notes.txt"}, tc.serviceErr).Once()
+		}
+
+		body, contentType := multipartBody(t, tc.field, "notes.txt", tc.content)
+		req := httptest.NewRequest(http.MethodPost, "/", body)
+		req.Header.Set(echo.HeaderContentType, contentType)
+		// Hide the length, so the body limit is only hit while streaming.
+		req.ContentLength = -1
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/attachments")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		err := middleware.BodyLimit("1K")(h.AddAttachment)(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestGetAttachment(t *testing.T) {
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		attachment  models.Attachment
+		rangeHeader string
+		serviceErr  error
+		status      int
+		body        string
+		disposition string
+	}{
+		{
+			attachment:  models.Attachment{ID: 1, PostID: 1, Filename: "notes.txt", ContentType: "text/plain; charset=utf-8", CreatedAt: created},
+			status:      http.StatusOK,
+			body:        "Hello, world",
+			disposition: `attachment; filename=notes.txt`,
+		},
+		{
+			attachment:  models.Attachment{ID: 1, PostID: 1, Filename: "фото.png", ContentType: "image/png", CreatedAt: created},
+			rangeHeader: "bytes=7-11",
+			status:      http.StatusPartialContent,
+			body:        "world",
+			disposition: `inline; filename*=utf-8''%D1%84%D0%BE%D1%82%D0%BE.png`,
+		},
+		{
+			attachment:  models.Attachment{ID: 1, PostID: 1, Filename: "notes.txt", ContentType: "text/plain; charset=utf-8", CreatedAt: created},
+			rangeHeader: "bytes=100-200",
+			status:      http.StatusRequestedRangeNotSatisfiable,
+		},
+		{serviceErr: apperr.NotFound("attachment not found", nil), status: http.StatusNotFound},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		var file io.ReadSeekCloser
+		if tc.serviceErr == nil {
+			file = nopCloser{strings.NewReader("Hello, world")}
+		}
+		mockService.On("OpenAttachment", mock.Anything, 1, 1).Return(tc.attachment, file, tc.serviceErr).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.rangeHeader != "" {
+			req.Header.Set("Range", tc.rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/attachments/:attachment")
+		c.SetParamNames("id", "attachment")
+		c.SetParamValues("1", "1")
+
+		err := h.GetAttachment(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		if tc.body != "" {
+			assert.Equal(t, tc.body, rec.Body.String(), fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.attachment.ContentType, rec.Header().Get(echo.HeaderContentType), fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.disposition, rec.Header().Get(echo.HeaderContentDisposition), fmt.Sprintf("case %d", i))
+			assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions), fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/auth"
+)
+
+// identityContextKey is the echo.Context key of the caller's models.Identity.
+const identityContextKey = "identity"
+
+type credentialsData struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type refreshData struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Register godoc
+// @Summary Register a user
+// @Description Create a user account. Usernames are 3-50 letters and digits, passwords 8-72 characters.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param credentials body credentialsData true "Username and password"
+// @Success 201 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /auth/register [post]
+func (h *Handler) Register(c echo.Context) error {
+	var credentials credentialsData
+
+	err := c.Bind(&credentials)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid user data")
+	}
+
+	err = h.validate.Struct(credentials)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid user data")
+	}
+
+	user, err := h.Service.Register(c.Request().Context(), credentials.Username, credentials.Password)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error registering user")
+	}
+
+	return c.JSON(http.StatusCreated, user)
+}
+
+// Login godoc
+// @Summary Log in
+// @Description Exchange a username and password for an access token and a refresh token
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param credentials body credentialsData true "Username and password"
+// @Success 200 {object} models.TokenPair
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /auth/login [post]
+func (h *Handler) Login(c echo.Context) error {
+	var credentials credentialsData
+
+	err := c.Bind(&credentials)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid credentials")
+	}
+
+	err = h.validate.Struct(credentials)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid credentials")
+	}
+
+	tokens, err := h.Service.Login(c.Request().Context(), credentials.Username, credentials.Password)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error logging in")
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. Every refresh token can be used once.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param token body refreshData true "Refresh token"
+// @Success 200 {object} models.TokenPair
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /auth/refresh [post]
+func (h *Handler) Refresh(c echo.Context) error {
+	var data refreshData
+
+	err := c.Bind(&data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid refresh token")
+	}
+
+	err = h.validate.Struct(data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid refresh token")
+	}
+
+	tokens, err := h.Service.Refresh(c.Request().Context(), data.RefreshToken)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error refreshing tokens")
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// IdentifyUser is a middleware that identifies the caller like RequireUser
+// but lets anonymous requests through, for endpoints that show more to some
+// callers. Invalid credentials are still rejected.
+func (h *Handler) IdentifyUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		identity, ok, err := h.extractIdentity(c)
+		if err != nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return newServiceErrorResponse(c, err, "error authenticating")
+		}
+
+		if ok {
+			c.Set(identityContextKey, identity)
+			c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), identity)))
+		}
+
+		return next(c)
+	}
+}
+
+// RequireUser is a middleware that rejects requests whose caller cannot be
+// identified by the handler's identity extractors. The identity is stored on
+// the echo context under "identity" and in the request context, see
+// auth.FromContext.
+func (h *Handler) RequireUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		identity, ok, err := h.extractIdentity(c)
+		if err != nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return newServiceErrorResponse(c, err, "error authenticating")
+		}
+
+		if !ok {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+			return newErrorResponse(c, http.StatusUnauthorized, "authentication required")
+		}
+
+		c.Set(identityContextKey, identity)
+		c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), identity)))
+
+		return next(c)
+	}
+}
@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRegister(t *testing.T) {
+	testCases := []struct {
+		body       string
+		serviceErr error
+		status     int
+	}{
+		{body: `{"username":"gopher","password":"password1"}`, status: http.StatusCreated},
+		{body: `{"username":"gopher","password":"password1"}`, serviceErr: apperr.Conflict("user already exists", nil), status: http.StatusConflict},
+		{body: `{"username":"gopher","password":"password1"}`, serviceErr: apperr.Validation("invalid user data", nil), status: http.StatusUnprocessableEntity},
+		{body: `{"username":"gopher"}`, status: http.StatusBadRequest},
+		{body: `{`, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("Register", mock.Anything, "gopher", "password1").Return(models.User{ID: 1, Username: "gopher", PasswordHash: "hash"}, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewBufferString(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.Register(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		assert.NotContains(t, rec.Body.String(), "hash", fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	pair := models.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}
+
+	testCases := []struct {
+		body       string
+		serviceErr error
+		status     int
+	}{
+		{body: `{"username":"gopher","password":"password1"}`, status: http.StatusOK},
+		{body: `{"username":"gopher","password":"password1"}`, serviceErr: apperr.Unauthorized("invalid username or password", nil), status: http.StatusUnauthorized},
+		{body: `{"password":"password1"}`, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("Login", mock.Anything, "gopher", "password1").Return(pair, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.Login(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.status == http.StatusOK {
+			var result models.TokenPair
+			err = json.Unmarshal(rec.Body.Bytes(), &result)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, pair, result, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	pair := models.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}
+
+	testCases := []struct {
+		body       string
+		serviceErr error
+		status     int
+	}{
+		{body: `{"refresh_token":"old"}`, status: http.StatusOK},
+		{body: `{"refresh_token":"old"}`, serviceErr: apperr.Unauthorized("invalid refresh token", nil), status: http.StatusUnauthorized},
+		{body: `{}`, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("Refresh", mock.Anything, "old").Return(pair, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.Refresh(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	identity := models.Identity{UserID: 1, Username: "gopher"}
+
+	testCases := []struct {
+		header     string
+		token      string
+		serviceErr error
+		status     int
+	}{
+		{header: "Bearer good", token: "good", status: http.StatusNoContent},
+		{header: "bearer good", token: "good", status: http.StatusNoContent},
+		{header: "Bearer bad", token: "bad", serviceErr: apperr.Unauthorized("invalid access token", nil), status: http.StatusUnauthorized},
+		{header: "", status: http.StatusUnauthorized},
+		{header: "Basic Z29waGVyOnB3", status: http.StatusUnauthorized},
+		{header: "Bearer ", status: http.StatusUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.token != "" {
+			mockService.On("Authenticate", mock.Anything, tc.token).Return(identity, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodDelete, "/posts/1", nil)
+		if tc.header != "" {
+			req.Header.Set(echo.HeaderAuthorization, tc.header)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.RequireUser(func(c echo.Context) error {
+			fromContext, ok := auth.FromContext(c.Request().Context())
+			assert.True(t, ok, fmt.Sprintf("case %d", i))
+			assert.Equal(t, identity, fromContext, fmt.Sprintf("case %d", i))
+			assert.Equal(t, identity, c.Get(identityContextKey), fmt.Sprintf("case %d", i))
+
+			return c.NoContent(http.StatusNoContent)
+		})(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.status == http.StatusUnauthorized {
+			assert.Contains(t, rec.Header().Get(echo.HeaderWWWAuthenticate), "Bearer", fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestIdentifyUser(t *testing.T) {
+	identity := models.Identity{UserID: 1, Username: "gopher"}
+
+	testCases := []struct {
+		header     string
+		token      string
+		serviceErr error
+		identified bool
+		status     int
+	}{
+		{header: "Bearer good", token: "good", identified: true, status: http.StatusNoContent},
+		{header: "", status: http.StatusNoContent},
+		{header: "Bearer bad", token: "bad", serviceErr: apperr.Unauthorized("invalid access token", nil), status: http.StatusUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.token != "" {
+			mockService.On("Authenticate", mock.Anything, tc.token).Return(identity, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/posts/1", nil)
+		if tc.header != "" {
+			req.Header.Set(echo.HeaderAuthorization, tc.header)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.IdentifyUser(func(c echo.Context) error {
+			fromContext, ok := auth.FromContext(c.Request().Context())
+			assert.Equal(t, tc.identified, ok, fmt.Sprintf("case %d", i))
+			if tc.identified {
+				assert.Equal(t, identity, fromContext, fmt.Sprintf("case %d", i))
+			}
+
+			return c.NoContent(http.StatusNoContent)
+		})(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestIdentityExtractors(t *testing.T) {
+	trusted := TrustedHeaderExtractor{UserHeader: "X-User-ID", RolesHeader: "X-User-Roles"}
+
+	testCases := []struct {
+		headers  map[string]string
+		identity models.Identity
+		status   int
+	}{
+		{
+			headers:  map[string]string{"X-User-ID": "5", "X-User-Roles": "admin, editor"},
+			identity: models.Identity{UserID: 5, Roles: []string{"admin", "editor"}},
+			status:   http.StatusNoContent,
+		},
+		{
+			// The trusted header is asked first.
+			headers:  map[string]string{"X-User-ID": "5", echo.HeaderAuthorization: "Bearer good"},
+			identity: models.Identity{UserID: 5},
+			status:   http.StatusNoContent,
+		},
+		{
+			headers:  map[string]string{echo.HeaderAuthorization: "Bearer good"},
+			identity: models.Identity{UserID: 1, Username: "gopher"},
+			status:   http.StatusNoContent,
+		},
+		{
+			headers:  map[string]string{echo.HeaderAuthorization: "ApiKey prmv_good"},
+			identity: models.Identity{UserID: 1, Username: "gopher", Scopes: []string{"posts:create"}, APIKeyID: 3},
+			status:   http.StatusNoContent,
+		},
+		{
+			headers: map[string]string{echo.HeaderAuthorization: "ApiKey prmv_bad"},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			headers: map[string]string{"X-User-ID": "abc", echo.HeaderAuthorization: "Bearer good"},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			headers: map[string]string{},
+			status:  http.StatusUnauthorized,
+		},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService, trusted, BearerTokenExtractor(mockService), APIKeyExtractor(mockService))
+		e := echo.New()
+
+		mockService.On("Authenticate", mock.Anything, "good").Return(models.Identity{UserID: 1, Username: "gopher"}, nil).Maybe()
+		mockService.On("AuthenticateAPIKey", mock.Anything, "prmv_good").Return(models.Identity{UserID: 1, Username: "gopher", Scopes: []string{"posts:create"}, APIKeyID: 3}, nil).Maybe()
+		mockService.On("AuthenticateAPIKey", mock.Anything, "prmv_bad").Return(models.Identity{}, apperr.Unauthorized("invalid API key", nil)).Maybe()
+
+		req := httptest.NewRequest(http.MethodPost, "/posts", nil)
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.RequireUser(func(c echo.Context) error {
+			fromContext, _ := auth.FromContext(c.Request().Context())
+			assert.Equal(t, tc.identity, fromContext, fmt.Sprintf("case %d", i))
+
+			return c.NoContent(http.StatusNoContent)
+		})(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/models"
+)
+
+// bulkModeAtomic applies all operations of a bulk request or none.
+const bulkModeAtomic = "atomic"
+
+type bulkOperationData struct {
+	Op string `json:"op" validate:"required,oneof=create update delete"`
+	// ID is the post to update or delete.
+	ID int `json:"id" validate:"min=0"`
+	// Version, when set, must match the stored version of the post.
+	Version       int        `json:"version" validate:"min=0"`
+	Slug          string     `json:"slug"`
+	Title         string     `json:"title"`
+	Content       string     `json:"content"`
+	ContentFormat string     `json:"content_format"`
+	CategoryID    *int       `json:"category_id"`
+	Tags          []string   `json:"tags"`
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at"`
+}
+
+type bulkData struct {
+	// Mode is "atomic" (the default) to apply all operations or none, or
+	// "partial" to apply every operation that succeeds.
+	Mode       string              `json:"mode" validate:"omitempty,oneof=atomic partial"`
+	Operations []bulkOperationData `json:"operations" validate:"required,min=1,dive"`
+}
+
+type BulkItemResponse struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op"`
+	Status int          `json:"status"`
+	ID     int          `json:"id,omitempty"`
+	Post   *models.Post `json:"post,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+	Mode      string             `json:"mode"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Results   []BulkItemResponse `json:"results"`
+}
+
+// BulkPosts godoc
+// @Summary Create, update and delete posts in bulk
+// @Description Apply up to 1000 create, update and delete operations in one database transaction. Posts are validated like in POST /posts and PUT /posts/{id}. In "atomic" mode (the default) nothing is applied unless every operation succeeds, and operations that were not applied because of another failure report status 424. In "partial" mode every operation succeeds or fails on its own. Every operation gets a status in results; the response is 200 when all succeeded and 207 otherwise.
+// @Tags posts
+// @Accept  json
+// @Produce  json
+// @Param operations body bulkData true "Mode and operations"
+// @Success 200 {object} BulkResponse
+// @Success 207 {object} BulkResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/bulk [post]
+func (h *Handler) BulkPosts(c echo.Context) error {
+	var data bulkData
+
+	err := c.Bind(&data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid bulk data")
+	}
+
+	err = h.validate.Struct(data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid bulk data")
+	}
+
+	if data.Mode == "" {
+		data.Mode = bulkModeAtomic
+	}
+
+	ops := make([]models.BulkOperation, 0, len(data.Operations))
+	for _, op := range data.Operations {
+		ops = append(ops, models.BulkOperation{
+			Op: op.Op,
+			Post: models.Post{
+				ID:            op.ID,
+				Version:       op.Version,
+				Slug:          op.Slug,
+				Title:         op.Title,
+				Content:       op.Content,
+				ContentFormat: op.ContentFormat,
+				CategoryID:    op.CategoryID,
+				Tags:          op.Tags,
+				Status:        op.Status,
+				PublishAt:     op.PublishAt,
+			},
+		})
+	}
+
+	results, err := h.Service.BulkPosts(c.Request().Context(), ops, data.Mode == bulkModeAtomic)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error applying bulk operations")
+	}
+
+	response := BulkResponse{Mode: data.Mode, Results: make([]BulkItemResponse, 0, len(results))}
+
+	for _, result := range results {
+		item := BulkItemResponse{Index: result.Index, Op: result.Op, ID: result.ID, Post: result.Post}
+
+		if result.Err != nil {
+			item.Status, item.Error = serviceError(result.Err, "error applying operation")
+			response.Failed++
+		} else {
+			item.Status = bulkSuccessStatus(result.Op)
+			response.Succeeded++
+		}
+
+		response.Results = append(response.Results, item)
+	}
+
+	status := http.StatusOK
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	return c.JSON(status, response)
+}
+
+// bulkSuccessStatus is the status a successful operation would have had as a
+// single request.
+func bulkSuccessStatus(op string) int {
+	switch op {
+	case models.BulkCreate:
+		return http.StatusCreated
+	case models.BulkDelete:
+		return http.StatusNoContent
+	default:
+		return http.StatusOK
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBulkPosts(t *testing.T) {
+	ops := []models.BulkOperation{
+		{Op: models.BulkCreate, Post: models.Post{Slug: "title", Title: "Title", Content: "Content"}},
+		{Op: models.BulkDelete, Post: models.Post{ID: 3, Version: 2}},
+	}
+	body := `{"operations":[{"op":"create","slug":"title","title":"Title","content":"Content"},{"op":"delete","id":3,"version":2}]}`
+
+	testCases := []struct {
+		body       string
+		atomic     bool
+		results    []models.BulkResult
+		serviceErr error
+		status     int
+		statuses   []int
+	}{
+		{
+			body:   body,
+			atomic: true,
+			results: []models.BulkResult{
+				{Index: 0, Op: models.BulkCreate, ID: 7, Post: &models.Post{ID: 7}},
+				{Index: 1, Op: models.BulkDelete, ID: 3},
+			},
+			status:   http.StatusOK,
+			statuses: []int{http.StatusCreated, http.StatusNoContent},
+		},
+		{
+			body:   `{"mode":"partial",` + body[1:],
+			atomic: false,
+			results: []models.BulkResult{
+				{Index: 0, Op: models.BulkCreate, ID: 7, Post: &models.Post{ID: 7}},
+				{Index: 1, Op: models.BulkDelete, ID: 3, Err: apperr.PreconditionFailed("post has been modified", nil)},
+			},
+			status:   http.StatusMultiStatus,
+			statuses: []int{http.StatusCreated, http.StatusPreconditionFailed},
+		},
+		{
+			body:   body,
+			atomic: true,
+			results: []models.BulkResult{
+				{Index: 0, Op: models.BulkCreate, Err: apperr.Aborted("not applied because another operation failed", nil)},
+				{Index: 1, Op: models.BulkDelete, ID: 3, Err: apperr.NotFound("post not found", nil)},
+			},
+			status:   http.StatusMultiStatus,
+			statuses: []int{http.StatusFailedDependency, http.StatusNotFound},
+		},
+		{body: body, atomic: true, serviceErr: apperr.Validation("at most 1000 operations are allowed", nil), status: http.StatusUnprocessableEntity},
+		{body: `{"mode":"sometimes",` + body[1:], status: http.StatusBadRequest},
+		{body: `{"operations":[{"op":"upsert"}]}`, status: http.StatusBadRequest},
+		{body: `{"operations":[]}`, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("BulkPosts", mock.Anything, ops, tc.atomic).Return(tc.results, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/posts/bulk", bytes.NewBufferString(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.BulkPosts(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.statuses != nil {
+			var response BulkResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), fmt.Sprintf("case %d", i))
+
+			statuses := make([]int, 0, len(response.Results))
+			for _, result := range response.Results {
+				statuses = append(statuses, result.Status)
+			}
+			assert.Equal(t, tc.statuses, statuses, fmt.Sprintf("case %d", i))
+			assert.Equal(t, len(tc.statuses), response.Succeeded+response.Failed, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/service"
+	"github.com/rostis232/prmv/models"
+)
+
+type categoryData struct {
+	// ParentID is the category to nest the new one under. Omit it for
+	// top-level categories.
+	ParentID *int   `json:"parent_id" validate:"omitempty,min=1"`
+	Slug     string `json:"slug" validate:"required,max=100"`
+	Name     string `json:"name" validate:"required,max=100"`
+}
+
+// GetCategories godoc
+// @Summary Get the category tree
+// @Description Get all categories as a tree: top-level categories ordered by name, with subcategories nested under children.
+// @Tags categories
+// @Produce  json
+// @Success 200 {array} models.Category
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /categories [get]
+func (h *Handler) GetCategories(c echo.Context) error {
+	categories, err := h.Service.GetCategories(c.Request().Context())
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting categories")
+	}
+
+	return c.JSON(http.StatusOK, categories)
+}
+
+// AddCategory godoc
+// @Summary Add a category
+// @Description Add a category, below parent_id when it is set.
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param category body categoryData true "Category Data"
+// @Success 201 {object} models.Category
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /categories [post]
+func (h *Handler) AddCategory(c echo.Context) error {
+	var data categoryData
+
+	err := c.Bind(&data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid category data")
+	}
+
+	err = h.validate.Struct(data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid category data")
+	}
+
+	category, err := h.Service.AddCategory(c.Request().Context(), models.Category{
+		ParentID: data.ParentID,
+		Slug:     data.Slug,
+		Name:     data.Name,
+	})
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error adding category")
+	}
+
+	return c.JSON(http.StatusCreated, category)
+}
+
+// GetCategoryPosts godoc
+// @Summary List posts in a category
+// @Description Get a page of the posts in a category and all categories below it. Pagination works like GET /posts.
+// @Tags categories
+// @Produce  json
+// @Param slug path string true "Category slug"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Success 200 {object} models.PostsPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /categories/{slug}/posts [get]
+func (h *Handler) GetCategoryPosts(c echo.Context) error {
+	limit, err := parseLimit(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid limit")
+	}
+
+	page, err := h.Service.GetCategoryPosts(c.Request().Context(), c.Param("slug"), limit, c.QueryParam("cursor"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return newErrorResponse(c, http.StatusBadRequest, "invalid cursor")
+		}
+		return newServiceErrorResponse(c, err, "error getting category posts")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/service"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetCategories(t *testing.T) {
+	parentID := 1
+	tree := []models.Category{
+		{ID: 1, Slug: "tech", Name: "Tech", Children: []models.Category{
+			{ID: 2, ParentID: &parentID, Slug: "go", Name: "Go"},
+		}},
+	}
+
+	mockService := new(MockService)
+	h := NewHandler(mockService)
+	e := echo.New()
+
+	mockService.On("GetCategories", mock.Anything).Return(tree, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/categories", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := h.GetCategories(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"children":[{"id":2`)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAddCategory(t *testing.T) {
+	parentID, missingID := 1, 9
+
+	testCases := []struct {
+		body       string
+		category   models.Category
+		serviceErr error
+		status     int
+	}{
+		{body: `{"slug":"tech","name":"Tech"}`, category: models.Category{Slug: "tech", Name: "Tech"}, status: http.StatusCreated},
+		{body: `{"slug":"go","name":"Go","parent_id":1}`, category: models.Category{ParentID: &parentID, Slug: "go", Name: "Go"}, status: http.StatusCreated},
+		{body: `{"slug":"tech","name":"Tech"}`, category: models.Category{Slug: "tech", Name: "Tech"}, serviceErr: apperr.Conflict("category already exists", nil), status: http.StatusConflict},
+		{body: `{"slug":"go","name":"Go","parent_id":9}`, category: models.Category{ParentID: &missingID, Slug: "go", Name: "Go"}, serviceErr: apperr.Validation("parent category not found", nil), status: http.StatusUnprocessableEntity},
+		{body: `{"name":"Tech"}`, status: http.StatusBadRequest},
+		{body: `{"slug":"go","name":"Go","parent_id":0}`, status: http.StatusBadRequest},
+		{body: `{`, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("AddCategory", mock.Anything, tc.category).Return(models.Category{ID: 5}, tc.serviceErr).Once()
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/categories", bytes.NewBufferString(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.AddCategory(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestGetCategoryPosts(t *testing.T) {
+	page := models.PostsPage{Posts: []models.Post{{ID: 1, Title: "Post 1", Content: "Content 1"}}}
+
+	testCases := []struct {
+		query      string
+		limit      int
+		cursor     string
+		serviceErr error
+		status     int
+	}{
+		{status: http.StatusOK},
+		{query: "?limit=5&cursor=abc", limit: 5, cursor: "abc", status: http.StatusOK},
+		{serviceErr: apperr.NotFound("category not found", nil), status: http.StatusNotFound},
+		{query: "?cursor=broken", cursor: "broken", serviceErr: service.ErrInvalidCursor, status: http.StatusBadRequest},
+		{query: "?limit=abc", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.query != "?limit=abc" {
+			mockService.On("GetCategoryPosts", mock.Anything, "tech", tc.limit, tc.cursor).Return(page, tc.serviceErr).Once()
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/categories/tech/posts"+tc.query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/categories/:slug/posts")
+		c.SetParamNames("slug")
+		c.SetParamValues("tech")
+
+		err := h.GetCategoryPosts(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/models"
+)
+
+type commentData struct {
+	Content string `json:"content" validate:"required"`
+	// ParentID is the comment being replied to. Omit it for top-level
+	// comments.
+	ParentID *int `json:"parent_id"`
+}
+
+type commentUpdateData struct {
+	Content string `json:"content" validate:"required"`
+}
+
+// GetComments godoc
+// @Summary List comments on a post
+// @Description Get the comments on a post as threads: top-level comments oldest first, with replies nested under the comment they answer.
+// @Tags comments
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Success 200 {array} models.Comment
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /posts/{id}/comments [get]
+func (h *Handler) GetComments(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	comments, err := h.Service.GetComments(c.Request().Context(), id)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting comments")
+	}
+
+	return c.JSON(http.StatusOK, comments)
+}
+
+// AddComment godoc
+// @Summary Comment on a post
+// @Description Add a comment to a post, or a reply to another comment on it when parent_id is set.
+// @Tags comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Param comment body commentData true "Comment content and optional parent"
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/{id}/comments [post]
+func (h *Handler) AddComment(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	var data commentData
+
+	err = c.Bind(&data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid comment data")
+	}
+
+	err = h.validate.Struct(data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid comment data")
+	}
+
+	comment, err := h.Service.AddComment(c.Request().Context(), models.Comment{
+		PostID:   id,
+		ParentID: data.ParentID,
+		Content:  data.Content,
+	})
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error adding comment")
+	}
+
+	return c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateComment godoc
+// @Summary Edit a comment
+// @Description Change the content of a comment. Only its author may edit it.
+// @Tags comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Param comment path int true "Comment ID"
+// @Param content body commentUpdateData true "New content"
+// @Success 200 {object} models.Comment
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/{id}/comments/{comment} [put]
+func (h *Handler) UpdateComment(c echo.Context) error {
+	postID, commentID, err := parseCommentParams(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	var data commentUpdateData
+
+	err = c.Bind(&data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid comment data")
+	}
+
+	err = h.validate.Struct(data)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid comment data")
+	}
+
+	comment, err := h.Service.UpdateComment(c.Request().Context(), models.Comment{
+		ID:      commentID,
+		PostID:  postID,
+		Content: data.Content,
+	})
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error updating comment")
+	}
+
+	return c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment godoc
+// @Summary Delete a comment
+// @Description Delete a comment together with all replies to it. Authors may delete their own comments, editors and admins any comment.
+// @Tags comments
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Param comment path int true "Comment ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/{id}/comments/{comment} [delete]
+func (h *Handler) DeleteComment(c echo.Context) error {
+	postID, commentID, err := parseCommentParams(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	err = h.Service.DeleteComment(c.Request().Context(), postID, commentID)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error deleting comment")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// parseCommentParams reads the post and comment ids of comment routes.
+func parseCommentParams(c echo.Context) (int, int, error) {
+	postID, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return 0, 0, errors.New("invalid post id")
+	}
+
+	commentID, err := parsePositiveParam(c, "comment")
+	if err != nil {
+		return 0, 0, errors.New("invalid comment id")
+	}
+
+	return postID, commentID, nil
+}
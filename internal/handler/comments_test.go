@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetComments(t *testing.T) {
+	parentID := 1
+	comments := []models.Comment{
+		{ID: 1, PostID: 1, Content: "first", Replies: []models.Comment{
+			{ID: 2, PostID: 1, ParentID: &parentID, Content: "reply"},
+		}},
+	}
+
+	testCases := []struct {
+		id         string
+		serviceErr error
+		status     int
+	}{
+		{id: "1", status: http.StatusOK},
+		{id: "1", serviceErr: apperr.NotFound("post not found", nil), status: http.StatusNotFound},
+		{id: "abc", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("GetComments", mock.Anything, 1).Return(comments, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/comments")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.id)
+
+		err := h.GetComments(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		if tc.status == http.StatusOK {
+			assert.Contains(t, rec.Body.String(), `"replies":[{"id":2`, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	parentID := 3
+
+	testCases := []struct {
+		body       string
+		comment    models.Comment
+		serviceErr error
+		status     int
+	}{
+		{body: `{"content":"Nice post"}`, comment: models.Comment{PostID: 1, Content: "Nice post"}, status: http.StatusCreated},
+		{body: `{"content":"Agreed","parent_id":3}`, comment: models.Comment{PostID: 1, ParentID: &parentID, Content: "Agreed"}, status: http.StatusCreated},
+		{body: `{"content":"Agreed","parent_id":3}`, comment: models.Comment{PostID: 1, ParentID: &parentID, Content: "Agreed"}, serviceErr: apperr.Validation("parent comment not found on this post", nil), status: http.StatusUnprocessableEntity},
+		{body: `{"content":""}`, status: http.StatusBadRequest},
+		{body: `{`, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("AddComment", mock.Anything, tc.comment).Return(models.Comment{ID: 7, PostID: 1}, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/comments")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		err := h.AddComment(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestUpdateComment(t *testing.T) {
+	testCases := []struct {
+		commentID  string
+		body       string
+		serviceErr error
+		status     int
+	}{
+		{commentID: "2", body: `{"content":"Edited"}`, status: http.StatusOK},
+		{commentID: "2", body: `{"content":"Edited"}`, serviceErr: apperr.Forbidden("not allowed to change this comment", nil), status: http.StatusForbidden},
+		{commentID: "x", body: `{"content":"Edited"}`, status: http.StatusBadRequest},
+		{commentID: "2", body: `{}`, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("UpdateComment", mock.Anything, models.Comment{ID: 2, PostID: 1, Content: "Edited"}).Return(models.Comment{ID: 2, PostID: 1, Content: "Edited"}, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBufferString(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/comments/:comment")
+		c.SetParamNames("id", "comment")
+		c.SetParamValues("1", tc.commentID)
+
+		err := h.UpdateComment(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	mockService := new(MockService)
+	h := NewHandler(mockService)
+	e := echo.New()
+
+	mockService.On("DeleteComment", mock.Anything, 1, 2).Return(nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/posts/:id/comments/:comment")
+	c.SetParamNames("id", "comment")
+	c.SetParamValues("1", "2")
+
+	err := h.DeleteComment(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	headerETag            = "ETag"
+	headerIfMatch         = "If-Match"
+	headerIfNoneMatch     = "If-None-Match"
+	headerIfModifiedSince = "If-Modified-Since"
+	headerLastModified    = "Last-Modified"
+)
+
+var errETagMismatch = errors.New("entity tag does not match")
+
+// postETag returns the strong entity tag of a post. The version is bumped on
+// every write, so it identifies the stored representation.
+func postETag(post models.Post) string {
+	return `"` + strconv.Itoa(post.Version) + `"`
+}
+
+func setPostETag(c echo.Context, post models.Post) {
+	c.Response().Header().Set(headerETag, postETag(post))
+}
+
+// postsETag returns the entity tag of the posts collection. The count catches
+// deletions, the latest updated_at catches inserts and updates.
+func postsETag(stats models.PostsStats) string {
+	return fmt.Sprintf(`"%d-%d"`, stats.Count, stats.LastModified.UnixNano())
+}
+
+// setValidators sets ETag and, when known, Last-Modified on the response.
+func setValidators(c echo.Context, etag string, lastModified time.Time) {
+	c.Response().Header().Set(headerETag, etag)
+
+	if !lastModified.IsZero() {
+		c.Response().Header().Set(headerLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since as described in
+// RFC 9110, section 13.2.2. If-Modified-Since is ignored when If-None-Match is
+// present.
+func notModified(c echo.Context, etag string, lastModified time.Time) bool {
+	if header := c.Request().Header.Get(headerIfNoneMatch); header != "" {
+		return etagListContains(header, etag)
+	}
+
+	header := c.Request().Header.Get(headerIfModifiedSince)
+	if header == "" || lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// etagListContains reports whether a comma separated If-None-Match value
+// contains etag, using the weak comparison function.
+func etagListContains(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionFromIfMatch returns the post version the client expects, or 0 when
+// the request carries no If-Match header or uses "*". Only a single strong
+// entity tag is supported; anything else can never match.
+func versionFromIfMatch(c echo.Context) (int, error) {
+	header := strings.TrimSpace(c.Request().Header.Get(headerIfMatch))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, errETagMismatch
+	}
+
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version < 1 {
+		return 0, errETagMismatch
+	}
+
+	return version, nil
+}
@@ -1,37 +1,104 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
+	"github.com/rostis232/prmv/internal/pkg/jsonpatch"
+	"github.com/rostis232/prmv/internal/service"
 	"github.com/rostis232/prmv/models"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Handler struct {
 	Service  Service
 	validate *validator.Validate
+	identity []IdentityExtractor
 }
 
 type Service interface {
-	AddPost(models.Post) (models.Post, error)
-	GetAllPosts() ([]models.Post, error)
-	UpdatePost(models.Post) (models.Post, error)
-	GetPost(id int) (models.Post, error)
-	DeletePost(id int) error
+	AddPost(ctx context.Context, post models.Post) (models.Post, error)
+	GetAllPosts(ctx context.Context, limit int, cursor string, filter models.PostsFilter) (models.PostsPage, error)
+	GetPostsStats(ctx context.Context, cursor string, filter models.PostsFilter) (models.PostsStats, error)
+	UpdatePost(ctx context.Context, post models.Post) (models.Post, error)
+	PatchPost(ctx context.Context, id int, patch models.PostPatch) (models.Post, error)
+	GetPost(ctx context.Context, id int) (models.Post, error)
+	GetPostBySlug(ctx context.Context, slug string) (models.Post, error)
+	DeletePost(ctx context.Context, id int, version int) error
+	GetTrash(ctx context.Context, limit int, cursor string) (models.PostsPage, error)
+	RestorePost(ctx context.Context, id int) (models.Post, error)
+	PurgePost(ctx context.Context, id int) error
+	GetRevisions(ctx context.Context, id int) ([]models.Revision, error)
+	GetRevision(ctx context.Context, id int, version int) (models.Revision, error)
+	RevertPost(ctx context.Context, id int, revision int, expectedVersion int) (models.Post, error)
+	DiffRevisions(ctx context.Context, id int, from int, to int) (string, error)
+	Register(ctx context.Context, username, password string) (models.User, error)
+	Login(ctx context.Context, username, password string) (models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error)
+	Authenticate(ctx context.Context, accessToken string) (models.Identity, error)
+	Authorize(ctx context.Context, action string) error
+	GetRoles(ctx context.Context) ([]models.Role, error)
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	AssignRole(ctx context.Context, userID int, role string) ([]string, error)
+	RevokeRole(ctx context.Context, userID int, role string) ([]string, error)
+	CreateAPIKey(ctx context.Context, key models.APIKey) (models.CreatedAPIKey, error)
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+	AuthenticateAPIKey(ctx context.Context, key string) (models.Identity, error)
+	GetComments(ctx context.Context, postID int) ([]models.Comment, error)
+	AddComment(ctx context.Context, comment models.Comment) (models.Comment, error)
+	UpdateComment(ctx context.Context, comment models.Comment) (models.Comment, error)
+	DeleteComment(ctx context.Context, postID int, id int) error
+	BulkPosts(ctx context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error)
+	GetTags(ctx context.Context) ([]models.Tag, error)
+	GetCategories(ctx context.Context) ([]models.Category, error)
+	AddCategory(ctx context.Context, category models.Category) (models.Category, error)
+	GetCategoryPosts(ctx context.Context, slug string, limit int, cursor string) (models.PostsPage, error)
+	AddAttachment(ctx context.Context, postID int, filename string, r io.Reader) (models.Attachment, error)
+	GetAttachments(ctx context.Context, postID int) ([]models.Attachment, error)
+	OpenAttachment(ctx context.Context, postID int, id int) (models.Attachment, io.ReadSeekCloser, error)
+	DeleteAttachment(ctx context.Context, postID int, id int) error
 }
 
-func NewHandler(service Service) *Handler {
+// NewHandler creates a handler that identifies callers with the given
+// extractors, tried in order. Without extractors only bearer access tokens
+// are accepted.
+func NewHandler(service Service, identity ...IdentityExtractor) *Handler {
+	if len(identity) == 0 {
+		identity = []IdentityExtractor{BearerTokenExtractor(service)}
+	}
+
 	return &Handler{
 		Service:  service,
 		validate: validator.New(),
+		identity: identity,
 	}
 }
 
 type postData struct {
-	Title   string `db:"title" json:"title" validate:"required,min=3,max=100"`
-	Content string `db:"content" json:"content" validate:"required,min=3"`
+	// Slug replaces the slug generated from the title.
+	Slug       string   `json:"slug" validate:"omitempty,max=100"`
+	Title      string   `db:"title" json:"title" validate:"required,min=3,max=100"`
+	Content    string   `db:"content" json:"content" validate:"required,min=3"`
+	CategoryID *int     `json:"category_id" validate:"omitempty,min=1"`
+	Tags       []string `json:"tags" validate:"max=20"`
+	// ContentFormat is plain, markdown or html. Posts are plain text unless
+	// it is set.
+	ContentFormat string `json:"content_format" validate:"omitempty,oneof=plain markdown html"`
+	// Status is draft, scheduled, published or archived. Posts are published
+	// unless it is set.
+	Status string `json:"status" validate:"omitempty,oneof=draft scheduled published archived"`
+	// PublishAt is when a scheduled post goes live.
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 // AddPost godoc
@@ -42,8 +109,15 @@ type postData struct {
 // @Produce  json
 // @Param post body postData true "Post Data"
 // @Success 200 {object} models.Post
+// @Header 200 {string} ETag "Post version"
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /posts [post]
 func (h *Handler) AddPost(c echo.Context) error {
 	var post postData
@@ -58,48 +132,120 @@ func (h *Handler) AddPost(c echo.Context) error {
 		return newErrorResponse(c, http.StatusBadRequest, "invalid post data")
 	}
 
-	newPost, err := h.Service.AddPost(models.Post{
-		Title:   post.Title,
-		Content: post.Content,
+	newPost, err := h.Service.AddPost(c.Request().Context(), models.Post{
+		Slug:          post.Slug,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: post.ContentFormat,
+		CategoryID:    post.CategoryID,
+		Tags:          post.Tags,
+		Status:        post.Status,
+		PublishAt:     post.PublishAt,
 	})
 	if err != nil {
-		log.Errorf("error adding post: %v", err)
-		return newErrorResponse(c, http.StatusInternalServerError, "error adding post")
+		return newServiceErrorResponse(c, err, "error adding post")
 	}
 
+	setPostETag(c, newPost)
 	return c.JSON(http.StatusCreated, newPost)
 }
 
 // GetAllPosts godoc
 // @Summary Get all posts
-// @Description Get a list of all posts
+// @Description Get a page of published posts ordered by creation time. Pass next_cursor from the previous page as cursor to get the next one. Repeat tag to filter by several tags; match decides whether a post needs all of them or any. Callers allowed to update posts can list posts with another status.
 // @Tags posts
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.Post
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param tag query []string false "Tag to filter by" collectionFormat(multi)
+// @Param match query string false "all (default) or any" Enums(all, any)
+// @Param status query string false "Post status (default published)" Enums(draft, scheduled, published, archived)
+// @Param If-None-Match header string false "ETag of a previously fetched page"
+// @Param If-Modified-Since header string false "Last-Modified of a previously fetched page"
+// @Success 200 {object} models.PostsPage
+// @Success 304
+// @Header 200 {string} ETag "Collection validator"
+// @Header 200 {string} Last-Modified "Latest post modification time"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /posts [get]
 func (h *Handler) GetAllPosts(c echo.Context) error {
-	posts, err := h.Service.GetAllPosts()
+	limit, err := parseLimit(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid limit")
+	}
+
+	filter, err := parsePostsFilter(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid tag filter")
+	}
+
+	filter.Status = c.QueryParam("status")
+	if filter.Status != "" && !isPostStatus(filter.Status) {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid status")
+	}
+
+	// Which posts are listed depends on the caller.
+	c.Response().Header().Add(echo.HeaderVary, strings.Join(h.identityHeaders(), ", "))
+
+	stats, err := h.Service.GetPostsStats(c.Request().Context(), c.QueryParam("cursor"), filter)
 	if err != nil {
-		log.Errorf("error getting all posts: %v", err)
-		return newErrorResponse(c, http.StatusInternalServerError, "error getting all posts")
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return newErrorResponse(c, http.StatusBadRequest, "invalid cursor")
+		}
+		return newServiceErrorResponse(c, err, "error getting all posts")
+	}
+
+	etag, lastModified := postsETag(stats), time.Time{}
+	if stats.Count > 0 {
+		lastModified = stats.LastModified
 	}
 
-	return c.JSON(http.StatusOK, posts)
+	if notModified(c, etag, lastModified) {
+		setValidators(c, etag, lastModified)
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	page, err := h.Service.GetAllPosts(c.Request().Context(), limit, c.QueryParam("cursor"), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return newErrorResponse(c, http.StatusBadRequest, "invalid cursor")
+		}
+		return newServiceErrorResponse(c, err, "error getting all posts")
+	}
+
+	setValidators(c, etag, lastModified)
+	return c.JSON(http.StatusOK, page)
 }
 
 // UpdatePost godoc
-// @Summary Update a post
-// @Description Update a post with the given id
+// @Summary Replace a post
+// @Description Replace the title, content, category, tags and status of a post with the given id. When If-Match is sent, the update only succeeds if it matches the current ETag.
 // @Tags posts
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Post ID"
+// @Param If-Match header string false "ETag of the post version being updated"
 // @Param post body postData true "Post Data"
 // @Success 200 {object} models.Post
+// @Header 200 {string} ETag "Post version"
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /posts/{id} [put]
 func (h *Handler) UpdatePost(c echo.Context) error {
 	idStr := c.Param("id")
@@ -122,33 +268,122 @@ func (h *Handler) UpdatePost(c echo.Context) error {
 		return newErrorResponse(c, http.StatusBadRequest, "invalid post data")
 	}
 
-	if post.Title == "" && post.Content == "" {
+	err = h.validate.Struct(post)
+	if err != nil {
 		return newErrorResponse(c, http.StatusBadRequest, "invalid post data")
 	}
 
-	updatedPost, err := h.Service.UpdatePost(models.Post{
-		ID:      idInt,
-		Title:   post.Title,
-		Content: post.Content,
+	version, err := versionFromIfMatch(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusPreconditionFailed, "post has been modified")
+	}
+
+	updatedPost, err := h.Service.UpdatePost(c.Request().Context(), models.Post{
+		ID:            idInt,
+		Slug:          post.Slug,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: post.ContentFormat,
+		CategoryID:    post.CategoryID,
+		Tags:          post.Tags,
+		Status:        post.Status,
+		PublishAt:     post.PublishAt,
+		Version:       version,
 	})
 	if err != nil {
-		log.Errorf("error updating post: %v", err)
-		return newErrorResponse(c, http.StatusInternalServerError, "error updating post")
+		return newServiceErrorResponse(c, err, "error updating post")
 	}
 
+	setPostETag(c, updatedPost)
 	return c.JSON(http.StatusOK, updatedPost)
 }
 
+// PatchPost godoc
+// @Summary Patch a post
+// @Description Apply a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to the title, content, category and tags of a post. When If-Match is sent, the patch only succeeds if it matches the current ETag.
+// @Tags posts
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Param If-Match header string false "ETag of the post version being patched"
+// @Param patch body object true "Patch document"
+// @Success 200 {object} models.Post
+// @Header 200 {string} ETag "Post version"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/{id} [patch]
+func (h *Handler) PatchPost(c echo.Context) error {
+	idStr := c.Param("id")
+
+	idInt, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Errorf("error converting id to int: %v", err)
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	if idInt < 1 {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	patchType, _, err := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if err != nil || (patchType != jsonpatch.MergePatchType && patchType != jsonpatch.JSONPatchType) {
+		return newErrorResponse(c, http.StatusUnsupportedMediaType, "unsupported patch type")
+	}
+
+	document, err := io.ReadAll(c.Request().Body)
+	if err != nil || !json.Valid(document) {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid patch document")
+	}
+
+	version, err := versionFromIfMatch(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusPreconditionFailed, "post has been modified")
+	}
+
+	patchedPost, err := h.Service.PatchPost(c.Request().Context(), idInt, models.PostPatch{
+		Type:     patchType,
+		Document: document,
+		Version:  version,
+	})
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error patching post")
+	}
+
+	setPostETag(c, patchedPost)
+	return c.JSON(http.StatusOK, patchedPost)
+}
+
 // GetPost godoc
 // @Summary Get a post by ID
-// @Description Get a single post by its ID
+// @Description Get a single post by its ID. Posts that are not published are only found by callers allowed to edit them.
 // @Tags posts
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Post ID"
+// @Param If-None-Match header string false "ETag of a previously fetched version"
+// @Param If-Modified-Since header string false "Last-Modified of a previously fetched version"
 // @Success 200 {object} models.Post
+// @Success 304
+// @Header 200 {string} ETag "Post version"
+// @Header 200 {string} Last-Modified "Post updated_at"
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /posts/{id} [get]
 func (h *Handler) GetPost(c echo.Context) error {
 	idStr := c.Param("id")
@@ -163,10 +398,54 @@ func (h *Handler) GetPost(c echo.Context) error {
 		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
 	}
 
-	post, err := h.Service.GetPost(idInt)
+	post, err := h.Service.GetPost(c.Request().Context(), idInt)
 	if err != nil {
-		log.Errorf("error getting post: %v", err)
-		return newErrorResponse(c, http.StatusInternalServerError, "error getting post")
+		return newServiceErrorResponse(c, err, "error getting post")
+	}
+
+	setValidators(c, postETag(post), post.UpdatedAt)
+
+	if notModified(c, postETag(post), post.UpdatedAt) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSON(http.StatusOK, post)
+}
+
+// GetPostBySlug godoc
+// @Summary Get a post by slug
+// @Description Get a single post by its slug. A slug the post had before redirects permanently to its current slug.
+// @Tags posts
+// @Produce  json
+// @Param slug path string true "Post slug"
+// @Param If-None-Match header string false "ETag of a previously fetched version"
+// @Param If-Modified-Since header string false "Last-Modified of a previously fetched version"
+// @Success 200 {object} models.Post
+// @Success 304
+// @Success 301
+// @Header 200 {string} ETag "Post version"
+// @Header 200 {string} Last-Modified "Post updated_at"
+// @Header 301 {string} Location "URL of the post under its current slug"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /posts/by-slug/{slug} [get]
+func (h *Handler) GetPostBySlug(c echo.Context) error {
+	slug := c.Param("slug")
+
+	post, err := h.Service.GetPostBySlug(c.Request().Context(), slug)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting post")
+	}
+
+	if post.Slug != slug {
+		return c.Redirect(http.StatusMovedPermanently, "/posts/by-slug/"+url.PathEscape(post.Slug))
+	}
+
+	setValidators(c, postETag(post), post.UpdatedAt)
+
+	if notModified(c, postETag(post), post.UpdatedAt) {
+		return c.NoContent(http.StatusNotModified)
 	}
 
 	return c.JSON(http.StatusOK, post)
@@ -174,14 +453,22 @@ func (h *Handler) GetPost(c echo.Context) error {
 
 // DeletePost godoc
 // @Summary Delete a post by ID
-// @Description Delete a single post by its ID
+// @Description Move a single post to the trash. When If-Match is sent, the post is only deleted if it matches the current ETag.
 // @Tags posts
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Post ID"
+// @Param If-Match header string false "ETag of the post version being deleted"
 // @Success 204
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /posts/{id} [delete]
 func (h *Handler) DeletePost(c echo.Context) error {
 	idStr := c.Param("id")
@@ -196,15 +483,44 @@ func (h *Handler) DeletePost(c echo.Context) error {
 		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
 	}
 
-	err = h.Service.DeletePost(idInt)
+	version, err := versionFromIfMatch(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusPreconditionFailed, "post has been modified")
+	}
+
+	err = h.Service.DeletePost(c.Request().Context(), idInt, version)
 	if err != nil {
-		log.Errorf("error deleting post: %v", err)
-		return newErrorResponse(c, http.StatusInternalServerError, "error deleting post")
+		return newServiceErrorResponse(c, err, "error deleting post")
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
+// isPostStatus reports whether status is one a post can have.
+func isPostStatus(status string) bool {
+	switch status {
+	case models.StatusDraft, models.StatusScheduled, models.StatusPublished, models.StatusArchived:
+		return true
+	}
+
+	return false
+}
+
+// parseLimit reads the optional limit query parameter; 0 means the default.
+func parseLimit(c echo.Context) (int, error) {
+	limitStr := c.QueryParam("limit")
+	if limitStr == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return 0, errors.New("invalid limit")
+	}
+
+	return limit, nil
+}
+
 func (h *Handler) Home(c echo.Context) error {
 	return c.Redirect(http.StatusTemporaryRedirect, "/swagger/index.html")
 }
@@ -2,15 +2,22 @@ package handler
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/service"
 	"github.com/rostis232/prmv/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,28 +26,209 @@ type MockService struct {
 	mock.Mock
 }
 
-func (m *MockService) AddPost(post models.Post) (models.Post, error) {
-	args := m.Called(post)
+func (m *MockService) AddPost(ctx context.Context, post models.Post) (models.Post, error) {
+	args := m.Called(ctx, post)
 	return args.Get(0).(models.Post), args.Error(1)
 }
 
-func (m *MockService) GetAllPosts() ([]models.Post, error) {
-	args := m.Called()
-	return args.Get(0).([]models.Post), args.Error(1)
+func (m *MockService) GetAllPosts(ctx context.Context, limit int, cursor string, filter models.PostsFilter) (models.PostsPage, error) {
+	args := m.Called(ctx, limit, cursor, filter)
+	return args.Get(0).(models.PostsPage), args.Error(1)
 }
 
-func (m *MockService) UpdatePost(post models.Post) (models.Post, error) {
-	args := m.Called(post)
+func (m *MockService) GetPostsStats(ctx context.Context, cursor string, filter models.PostsFilter) (models.PostsStats, error) {
+	args := m.Called(ctx, cursor, filter)
+	return args.Get(0).(models.PostsStats), args.Error(1)
+}
+
+func (m *MockService) UpdatePost(ctx context.Context, post models.Post) (models.Post, error) {
+	args := m.Called(ctx, post)
+	return args.Get(0).(models.Post), args.Error(1)
+}
+
+func (m *MockService) PatchPost(ctx context.Context, id int, patch models.PostPatch) (models.Post, error) {
+	args := m.Called(ctx, id, patch)
+	return args.Get(0).(models.Post), args.Error(1)
+}
+
+func (m *MockService) GetPost(ctx context.Context, id int) (models.Post, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Post), args.Error(1)
+}
+
+func (m *MockService) DeletePost(ctx context.Context, id int, version int) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockService) GetTrash(ctx context.Context, limit int, cursor string) (models.PostsPage, error) {
+	args := m.Called(ctx, limit, cursor)
+	return args.Get(0).(models.PostsPage), args.Error(1)
+}
+
+func (m *MockService) RestorePost(ctx context.Context, id int) (models.Post, error) {
+	args := m.Called(ctx, id)
 	return args.Get(0).(models.Post), args.Error(1)
 }
 
-func (m *MockService) GetPost(id int) (models.Post, error) {
-	args := m.Called(id)
+func (m *MockService) PurgePost(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockService) GetRevisions(ctx context.Context, id int) ([]models.Revision, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]models.Revision), args.Error(1)
+}
+
+func (m *MockService) GetRevision(ctx context.Context, id int, version int) (models.Revision, error) {
+	args := m.Called(ctx, id, version)
+	return args.Get(0).(models.Revision), args.Error(1)
+}
+
+func (m *MockService) RevertPost(ctx context.Context, id int, revision int, expectedVersion int) (models.Post, error) {
+	args := m.Called(ctx, id, revision, expectedVersion)
+	return args.Get(0).(models.Post), args.Error(1)
+}
+
+func (m *MockService) DiffRevisions(ctx context.Context, id int, from int, to int) (string, error) {
+	args := m.Called(ctx, id, from, to)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockService) Register(ctx context.Context, username, password string) (models.User, error) {
+	args := m.Called(ctx, username, password)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockService) Login(ctx context.Context, username, password string) (models.TokenPair, error) {
+	args := m.Called(ctx, username, password)
+	return args.Get(0).(models.TokenPair), args.Error(1)
+}
+
+func (m *MockService) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	args := m.Called(ctx, refreshToken)
+	return args.Get(0).(models.TokenPair), args.Error(1)
+}
+
+func (m *MockService) Authenticate(ctx context.Context, accessToken string) (models.Identity, error) {
+	args := m.Called(ctx, accessToken)
+	return args.Get(0).(models.Identity), args.Error(1)
+}
+
+func (m *MockService) Authorize(ctx context.Context, action string) error {
+	args := m.Called(ctx, action)
+	return args.Error(0)
+}
+
+func (m *MockService) GetRoles(ctx context.Context) ([]models.Role, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Role), args.Error(1)
+}
+
+func (m *MockService) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockService) AssignRole(ctx context.Context, userID int, role string) ([]string, error) {
+	args := m.Called(ctx, userID, role)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockService) RevokeRole(ctx context.Context, userID int, role string) ([]string, error) {
+	args := m.Called(ctx, userID, role)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockService) CreateAPIKey(ctx context.Context, key models.APIKey) (models.CreatedAPIKey, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(models.CreatedAPIKey), args.Error(1)
+}
+
+func (m *MockService) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockService) RevokeAPIKey(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockService) AuthenticateAPIKey(ctx context.Context, key string) (models.Identity, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(models.Identity), args.Error(1)
+}
+
+func (m *MockService) GetComments(ctx context.Context, postID int) ([]models.Comment, error) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *MockService) AddComment(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	args := m.Called(ctx, comment)
+	return args.Get(0).(models.Comment), args.Error(1)
+}
+
+func (m *MockService) UpdateComment(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	args := m.Called(ctx, comment)
+	return args.Get(0).(models.Comment), args.Error(1)
+}
+
+func (m *MockService) DeleteComment(ctx context.Context, postID int, id int) error {
+	args := m.Called(ctx, postID, id)
+	return args.Error(0)
+}
+
+func (m *MockService) BulkPosts(ctx context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error) {
+	args := m.Called(ctx, ops, atomic)
+	return args.Get(0).([]models.BulkResult), args.Error(1)
+}
+
+func (m *MockService) GetPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	args := m.Called(ctx, slug)
 	return args.Get(0).(models.Post), args.Error(1)
 }
 
-func (m *MockService) DeletePost(id int) error {
-	args := m.Called(id)
+func (m *MockService) GetTags(ctx context.Context) ([]models.Tag, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Tag), args.Error(1)
+}
+
+func (m *MockService) GetCategories(ctx context.Context) ([]models.Category, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
+func (m *MockService) AddCategory(ctx context.Context, category models.Category) (models.Category, error) {
+	args := m.Called(ctx, category)
+	return args.Get(0).(models.Category), args.Error(1)
+}
+
+func (m *MockService) GetCategoryPosts(ctx context.Context, slug string, limit int, cursor string) (models.PostsPage, error) {
+	args := m.Called(ctx, slug, limit, cursor)
+	return args.Get(0).(models.PostsPage), args.Error(1)
+}
+
+func (m *MockService) AddAttachment(ctx context.Context, postID int, filename string, r io.Reader) (models.Attachment, error) {
+	args := m.Called(ctx, postID, filename, r)
+	return args.Get(0).(models.Attachment), args.Error(1)
+}
+
+func (m *MockService) GetAttachments(ctx context.Context, postID int) ([]models.Attachment, error) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).([]models.Attachment), args.Error(1)
+}
+
+func (m *MockService) OpenAttachment(ctx context.Context, postID int, id int) (models.Attachment, io.ReadSeekCloser, error) {
+	args := m.Called(ctx, postID, id)
+	file, _ := args.Get(1).(io.ReadSeekCloser)
+	return args.Get(0).(models.Attachment), file, args.Error(2)
+}
+
+func (m *MockService) DeleteAttachment(ctx context.Context, postID int, id int) error {
+	args := m.Called(ctx, postID, id)
 	return args.Error(0)
 }
 
@@ -57,6 +245,42 @@ func TestAddPost(t *testing.T) {
 			status:       http.StatusCreated,
 			errorExpects: false,
 		},
+		{
+			reqBody:      `{"title":"Test Post","content":"Test Content","tags":["go","web"]}`,
+			post:         models.Post{ID: 1, Title: "Test Post", Content: "Test Content", Tags: []string{"go", "web"}},
+			status:       http.StatusCreated,
+			errorExpects: false,
+		},
+		{
+			reqBody:      `{"title":"Test Post","content":"Test Content","status":"scheduled","publish_at":"2030-01-01T09:00:00Z"}`,
+			post:         models.Post{ID: 1, Title: "Test Post", Content: "Test Content", Status: models.StatusScheduled},
+			status:       http.StatusCreated,
+			errorExpects: false,
+		},
+		{
+			reqBody:      `{"title":"Test Post","content":"Test Content","content_format":"markdown"}`,
+			post:         models.Post{ID: 1, Title: "Test Post", Content: "Test Content", ContentFormat: models.FormatMarkdown, ContentHTML: "<p>Test Content</p>\n"},
+			status:       http.StatusCreated,
+			errorExpects: false,
+		},
+		{
+			reqBody:      `{"title":"Test Post","content":"Test Content","content_format":"rst"}`,
+			post:         models.Post{},
+			status:       http.StatusBadRequest,
+			errorExpects: true,
+		},
+		{
+			reqBody:      `{"title":"Test Post","content":"Test Content","status":"hidden"}`,
+			post:         models.Post{},
+			status:       http.StatusBadRequest,
+			errorExpects: true,
+		},
+		{
+			reqBody:      `{"title":"Test Post","content":"Test Content","tags":["1","2","3","4","5","6","7","8","9","10","11","12","13","14","15","16","17","18","19","20","21"]}`,
+			post:         models.Post{},
+			status:       http.StatusBadRequest,
+			errorExpects: true,
+		},
 		{
 			reqBody:      `{"title":"Test Post","content":""}`,
 			post:         models.Post{},
@@ -95,7 +319,7 @@ func TestAddPost(t *testing.T) {
 		e := echo.New()
 
 		if !tc.errorExpects {
-			mockService.On("AddPost", mock.Anything).Return(tc.post, nil)
+			mockService.On("AddPost", mock.Anything, mock.Anything).Return(tc.post, nil)
 		}
 
 		req := httptest.NewRequest(http.MethodPost, "/posts", bytes.NewBufferString(tc.reqBody))
@@ -134,15 +358,66 @@ func TestAddPost(t *testing.T) {
 }
 
 func TestGetAllPosts(t *testing.T) {
-	testCases := [][]models.Post{
+	testCases := []struct {
+		query  string
+		limit  int
+		cursor string
+		filter models.PostsFilter
+		page   models.PostsPage
+	}{
+		{
+			filter: models.PostsFilter{AllTags: true},
+			page: models.PostsPage{
+				Posts: []models.Post{
+					{ID: 1, Title: "Post 1", Content: "Content 1"},
+					{ID: 2, Title: "Post 2", Content: "Content 2"},
+				},
+				NextCursor: "next",
+			},
+		},
 		{
-			{ID: 1, Title: "Post 1", Content: "Content 1"},
-			{ID: 2, Title: "Post 2", Content: "Content 2"},
+			query:  "?limit=1&cursor=abc",
+			limit:  1,
+			cursor: "abc",
+			filter: models.PostsFilter{AllTags: true},
+			page: models.PostsPage{
+				Posts: []models.Post{
+					{ID: 1, Title: "Post 1", Content: "Content 1"},
+				},
+			},
 		},
 		{
-			{ID: 1, Title: "Post 1", Content: "Content 1"},
+			filter: models.PostsFilter{AllTags: true},
+			page:   models.PostsPage{Posts: []models.Post{}},
+		},
+		{
+			query:  "?tag=go&tag=web",
+			filter: models.PostsFilter{Tags: []string{"go", "web"}, AllTags: true},
+			page: models.PostsPage{
+				Posts: []models.Post{
+					{ID: 1, Title: "Post 1", Content: "Content 1", Tags: []string{"go", "web"}},
+				},
+			},
+		},
+		{
+			query:  "?tag=go&tag=web&match=any",
+			filter: models.PostsFilter{Tags: []string{"go", "web"}},
+			page: models.PostsPage{
+				Posts: []models.Post{
+					{ID: 1, Title: "Post 1", Content: "Content 1", Tags: []string{"go"}},
+					{ID: 2, Title: "Post 2", Content: "Content 2", Tags: []string{"web"}},
+				},
+			},
+		},
+		{
+			query:  "?status=draft",
+			filter: models.PostsFilter{AllTags: true, Status: models.StatusDraft},
+			page: models.PostsPage{
+				Posts: []models.Post{
+					{ID: 3, Title: "Post 3", Content: "Content 3", Status: models.StatusDraft},
+				},
+			},
 		},
-		{},
 	}
 
 	for i, tc := range testCases {
@@ -150,9 +425,10 @@ func TestGetAllPosts(t *testing.T) {
 		h := NewHandler(mockService)
 		e := echo.New()
 
-		mockService.On("GetAllPosts").Return(tc, nil).Once()
+		mockService.On("GetPostsStats", mock.Anything, tc.cursor, tc.filter).Return(models.PostsStats{Count: len(tc.page.Posts)}, nil).Once()
+		mockService.On("GetAllPosts", mock.Anything, tc.limit, tc.cursor, tc.filter).Return(tc.page, nil).Once()
 
-		req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+		req := httptest.NewRequest(http.MethodGet, "/posts"+tc.query, nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
@@ -161,17 +437,19 @@ func TestGetAllPosts(t *testing.T) {
 		assert.NoError(t, err, fmt.Sprintf("case %d", i))
 		assert.Equal(t, http.StatusOK, rec.Code, fmt.Sprintf("case %d", i))
 
-		resp := []models.Post{}
+		resp := models.PostsPage{}
 		err = json.Unmarshal([]byte(rec.Body.String()), &resp)
 		if err != nil {
 			assert.NoError(t, err, fmt.Sprintf("case %d", i))
 		}
 
-		assert.Len(t, resp, len(tc), fmt.Sprintf("case %d", i))
+		assert.Len(t, resp.Posts, len(tc.page.Posts), fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.page.NextCursor, resp.NextCursor, fmt.Sprintf("case %d", i))
 
-		for j, post := range resp {
-			assert.Equal(t, tc[j].Title, post.Title, fmt.Sprintf("case %d", i))
-			assert.Equal(t, tc[j].Content, post.Content, fmt.Sprintf("case %d", i))
+		for j, post := range resp.Posts {
+			assert.Equal(t, tc.page.Posts[j].Title, post.Title, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.page.Posts[j].Content, post.Content, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.page.Posts[j].Tags, post.Tags, fmt.Sprintf("case %d", i))
 		}
 
 		mockService.AssertExpectations(t)
@@ -179,6 +457,62 @@ func TestGetAllPosts(t *testing.T) {
 
 }
 
+func TestGetAllPostsInvalidParams(t *testing.T) {
+	testCases := []struct {
+		query        string
+		mockCursor   bool
+		errorMessage string
+	}{
+		{
+			query:        "?limit=abc",
+			errorMessage: "invalid limit",
+		},
+		{
+			query:        "?limit=0",
+			errorMessage: "invalid limit",
+		},
+		{
+			query:        "?cursor=broken",
+			mockCursor:   true,
+			errorMessage: "invalid cursor",
+		},
+		{
+			query:        "?tag=go&match=some",
+			errorMessage: "invalid tag filter",
+		},
+		{
+			query:        "?status=deleted",
+			errorMessage: "invalid status",
+		},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.mockCursor {
+			mockService.On("GetPostsStats", mock.Anything, "broken", models.PostsFilter{AllTags: true}).Return(models.PostsStats{}, service.ErrInvalidCursor).Once()
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/posts"+tc.query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetAllPosts(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, fmt.Sprintf("case %d", i))
+
+		resp := ErrorResponse{}
+		err = json.Unmarshal([]byte(rec.Body.String()), &resp)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.errorMessage, resp.Error, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
 func TestUpdatePost(t *testing.T) {
 	testCases := []struct {
 		id           string
@@ -205,16 +539,18 @@ func TestUpdatePost(t *testing.T) {
 		{
 			id:           "1",
 			reqBody:      `{"content":"Updated Content"}`,
-			post:         models.Post{ID: 1, Title: "Post", Content: "Updated Content"},
-			status:       http.StatusOK,
-			errorExpects: false,
+			post:         models.Post{},
+			status:       http.StatusBadRequest,
+			errorExpects: true,
+			errorMessage: "invalid post data",
 		},
 		{
 			id:           "1",
 			reqBody:      `{"title":"Updated Post"}`,
-			post:         models.Post{ID: 1, Title: "Updated Post", Content: "Content"},
-			status:       http.StatusOK,
-			errorExpects: false,
+			post:         models.Post{},
+			status:       http.StatusBadRequest,
+			errorExpects: true,
+			errorMessage: "invalid post data",
 		},
 		{
 			id:           "1",
@@ -248,7 +584,7 @@ func TestUpdatePost(t *testing.T) {
 		e := echo.New()
 
 		if !tc.errorExpects {
-			mockService.On("UpdatePost", mock.Anything).Return(tc.post, nil).Once()
+			mockService.On("UpdatePost", mock.Anything, mock.Anything).Return(tc.post, nil).Once()
 		}
 
 		req := httptest.NewRequest(http.MethodPut, "/posts/"+tc.id, bytes.NewBufferString(tc.reqBody))
@@ -325,7 +661,7 @@ func TestGetPost(t *testing.T) {
 		e := echo.New()
 
 		if !tc.errorExpects {
-			mockService.On("GetPost", 1).Return(tc.post, nil)
+			mockService.On("GetPost", mock.Anything, 1).Return(tc.post, nil)
 		}
 
 		req := httptest.NewRequest(http.MethodGet, "/posts/"+tc.id, nil)
@@ -364,6 +700,48 @@ func TestGetPost(t *testing.T) {
 	}
 }
 
+func TestGetPostBySlug(t *testing.T) {
+	post := models.Post{ID: 1, Slug: "current-slug", Title: "Test Post", Content: "Test Content", Version: 2}
+
+	testCases := []struct {
+		slug       string
+		serviceErr error
+		status     int
+		location   string
+	}{
+		{slug: "current-slug", status: http.StatusOK},
+		{slug: "old-slug", status: http.StatusMovedPermanently, location: "/posts/by-slug/current-slug"},
+		{slug: "missing", serviceErr: apperr.NotFound("post not found", nil), status: http.StatusNotFound},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		mockService.On("GetPostBySlug", mock.Anything, tc.slug).Return(post, tc.serviceErr).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/posts/by-slug/"+tc.slug, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/by-slug/:slug")
+		c.SetParamNames("slug")
+		c.SetParamValues(tc.slug)
+
+		err := h.GetPostBySlug(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.location, rec.Header().Get(echo.HeaderLocation), fmt.Sprintf("case %d", i))
+		if tc.status == http.StatusOK {
+			assert.Equal(t, `"2"`, rec.Header().Get(headerETag), fmt.Sprintf("case %d", i))
+			assert.Contains(t, rec.Body.String(), `"slug":"current-slug"`, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
 func TestDeletePost(t *testing.T) {
 	testCases := []struct {
 		id           string
@@ -397,7 +775,7 @@ func TestDeletePost(t *testing.T) {
 		e := echo.New()
 
 		if !tc.errorExpects {
-			mockService.On("DeletePost", 1).Return(nil)
+			mockService.On("DeletePost", mock.Anything, 1, 0).Return(nil)
 		}
 
 		req := httptest.NewRequest(http.MethodDelete, "/posts/"+tc.id, nil)
@@ -416,3 +794,853 @@ func TestDeletePost(t *testing.T) {
 	}
 
 }
+
+func TestServiceErrors(t *testing.T) {
+	testCases := []struct {
+		method       string
+		handler      func(h *Handler) echo.HandlerFunc
+		mockMethod   string
+		mockArgs     []interface{}
+		mockReturn   []interface{}
+		reqBody      string
+		status       int
+		errorMessage string
+	}{
+		{
+			method:       http.MethodGet,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.GetPost },
+			mockMethod:   "GetPost",
+			mockArgs:     []interface{}{mock.Anything, 1},
+			mockReturn:   []interface{}{models.Post{}, apperr.NotFound("post not found", sql.ErrNoRows)},
+			status:       http.StatusNotFound,
+			errorMessage: "post not found",
+		},
+		{
+			method:       http.MethodGet,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.GetPost },
+			mockMethod:   "GetPost",
+			mockArgs:     []interface{}{mock.Anything, 1},
+			mockReturn:   []interface{}{models.Post{}, apperr.Unavailable("database is unavailable", nil)},
+			status:       http.StatusServiceUnavailable,
+			errorMessage: "database is unavailable",
+		},
+		{
+			method:       http.MethodGet,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.GetPost },
+			mockMethod:   "GetPost",
+			mockArgs:     []interface{}{mock.Anything, 1},
+			mockReturn:   []interface{}{models.Post{}, errors.New("boom")},
+			status:       http.StatusInternalServerError,
+			errorMessage: "error getting post",
+		},
+		{
+			method:       http.MethodPut,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.UpdatePost },
+			mockMethod:   "UpdatePost",
+			mockArgs:     []interface{}{mock.Anything, mock.Anything},
+			mockReturn:   []interface{}{models.Post{}, apperr.NotFound("post not found", sql.ErrNoRows)},
+			reqBody:      `{"title":"Updated Post","content":"Updated Content"}`,
+			status:       http.StatusNotFound,
+			errorMessage: "post not found",
+		},
+		{
+			method:       http.MethodPut,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.UpdatePost },
+			mockMethod:   "UpdatePost",
+			mockArgs:     []interface{}{mock.Anything, mock.Anything},
+			mockReturn:   []interface{}{models.Post{}, apperr.Forbidden("only the author can change this post", nil)},
+			reqBody:      `{"title":"Updated Post","content":"Updated Content"}`,
+			status:       http.StatusForbidden,
+			errorMessage: "only the author can change this post",
+		},
+		{
+			method:       http.MethodDelete,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.DeletePost },
+			mockMethod:   "DeletePost",
+			mockArgs:     []interface{}{mock.Anything, 1, 0},
+			mockReturn:   []interface{}{apperr.Forbidden("only the author can change this post", nil)},
+			status:       http.StatusForbidden,
+			errorMessage: "only the author can change this post",
+		},
+		{
+			method:       http.MethodPut,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.UpdatePost },
+			mockMethod:   "UpdatePost",
+			mockArgs:     []interface{}{mock.Anything, mock.Anything},
+			mockReturn:   []interface{}{models.Post{}, apperr.Conflict("post already exists", nil)},
+			reqBody:      `{"title":"Updated Post","content":"Updated Content"}`,
+			status:       http.StatusConflict,
+			errorMessage: "post already exists",
+		},
+		{
+			method:       http.MethodPut,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.UpdatePost },
+			mockMethod:   "UpdatePost",
+			mockArgs:     []interface{}{mock.Anything, mock.Anything},
+			mockReturn:   []interface{}{models.Post{}, apperr.Validation("post data is invalid", nil)},
+			reqBody:      `{"title":"Updated Post","content":"Updated Content"}`,
+			status:       http.StatusUnprocessableEntity,
+			errorMessage: "post data is invalid",
+		},
+		{
+			method:       http.MethodPost,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.AddPost },
+			mockMethod:   "AddPost",
+			mockArgs:     []interface{}{mock.Anything, mock.Anything},
+			mockReturn:   []interface{}{models.Post{}, apperr.Conflict("post already exists", nil)},
+			reqBody:      `{"title":"Test Post","content":"Test Content"}`,
+			status:       http.StatusConflict,
+			errorMessage: "post already exists",
+		},
+		{
+			method:       http.MethodDelete,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.DeletePost },
+			mockMethod:   "DeletePost",
+			mockArgs:     []interface{}{mock.Anything, 1, 0},
+			mockReturn:   []interface{}{apperr.NotFound("post not found", sql.ErrNoRows)},
+			status:       http.StatusNotFound,
+			errorMessage: "post not found",
+		},
+		{
+			method:       http.MethodDelete,
+			handler:      func(h *Handler) echo.HandlerFunc { return h.DeletePost },
+			mockMethod:   "DeletePost",
+			mockArgs:     []interface{}{mock.Anything, 1, 0},
+			mockReturn:   []interface{}{apperr.Unavailable("database is unavailable", nil)},
+			status:       http.StatusServiceUnavailable,
+			errorMessage: "database is unavailable",
+		},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		mockService.On(tc.mockMethod, tc.mockArgs...).Return(tc.mockReturn...).Once()
+
+		req := httptest.NewRequest(tc.method, "/posts/1", bytes.NewBufferString(tc.reqBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		err := tc.handler(h)(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		resp := ErrorResponse{}
+		err = json.Unmarshal([]byte(rec.Body.String()), &resp)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.errorMessage, resp.Error, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestRequestContextPropagation(t *testing.T) {
+	type ctxKey struct{}
+
+	mockService := new(MockService)
+	h := NewHandler(mockService)
+	e := echo.New()
+
+	fromRequest := mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(ctxKey{}) == "request"
+	})
+	mockService.On("GetPost", fromRequest, 1).Return(models.Post{ID: 1}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/1", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "request"))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/posts/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	err := h.GetPost(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestIfMatch(t *testing.T) {
+	testCases := []struct {
+		method       string
+		ifMatch      string
+		mockArgs     []interface{}
+		mockReturn   []interface{}
+		status       int
+		etag         string
+		errorMessage string
+	}{
+		{
+			method:     http.MethodPut,
+			ifMatch:    `"3"`,
+			mockArgs:   []interface{}{mock.Anything, models.Post{ID: 1, Title: "Updated Post", Content: "Updated Content", Version: 3}},
+			mockReturn: []interface{}{models.Post{ID: 1, Title: "Updated Post", Content: "Updated Content", Version: 4}, nil},
+			status:     http.StatusOK,
+			etag:       `"4"`,
+		},
+		{
+			method:     http.MethodPut,
+			ifMatch:    "*",
+			mockArgs:   []interface{}{mock.Anything, models.Post{ID: 1, Title: "Updated Post", Content: "Updated Content"}},
+			mockReturn: []interface{}{models.Post{ID: 1, Title: "Updated Post", Content: "Updated Content", Version: 2}, nil},
+			status:     http.StatusOK,
+			etag:       `"2"`,
+		},
+		{
+			method:       http.MethodPut,
+			ifMatch:      `"3"`,
+			mockArgs:     []interface{}{mock.Anything, mock.Anything},
+			mockReturn:   []interface{}{models.Post{}, apperr.PreconditionFailed("post has been modified", nil)},
+			status:       http.StatusPreconditionFailed,
+			errorMessage: "post has been modified",
+		},
+		{
+			method:       http.MethodPut,
+			ifMatch:      `W/"3"`,
+			status:       http.StatusPreconditionFailed,
+			errorMessage: "post has been modified",
+		},
+		{
+			method:     http.MethodDelete,
+			ifMatch:    `"2"`,
+			mockArgs:   []interface{}{mock.Anything, 1, 2},
+			mockReturn: []interface{}{nil},
+			status:     http.StatusNoContent,
+		},
+		{
+			method:       http.MethodDelete,
+			ifMatch:      `"2"`,
+			mockArgs:     []interface{}{mock.Anything, 1, 2},
+			mockReturn:   []interface{}{apperr.PreconditionFailed("post has been modified", nil)},
+			status:       http.StatusPreconditionFailed,
+			errorMessage: "post has been modified",
+		},
+		{
+			method:       http.MethodDelete,
+			ifMatch:      `"abc"`,
+			status:       http.StatusPreconditionFailed,
+			errorMessage: "post has been modified",
+		},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		handlerFunc := h.UpdatePost
+		mockMethod := "UpdatePost"
+		if tc.method == http.MethodDelete {
+			handlerFunc = h.DeletePost
+			mockMethod = "DeletePost"
+		}
+
+		if tc.mockArgs != nil {
+			mockService.On(mockMethod, tc.mockArgs...).Return(tc.mockReturn...).Once()
+		}
+
+		req := httptest.NewRequest(tc.method, "/posts/1", bytes.NewBufferString(`{"title":"Updated Post","content":"Updated Content"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("If-Match", tc.ifMatch)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		err := handlerFunc(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.etag, rec.Header().Get("ETag"), fmt.Sprintf("case %d", i))
+
+		if tc.errorMessage != "" {
+			resp := ErrorResponse{}
+			err = json.Unmarshal([]byte(rec.Body.String()), &resp)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.errorMessage, resp.Error, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	updatedAt := time.Date(2024, 6, 1, 12, 30, 15, 500000000, time.UTC)
+	post := models.Post{ID: 1, Title: "Test Post", Content: "Test Content", Version: 3, UpdatedAt: updatedAt}
+	stats := models.PostsStats{Count: 2, LastModified: updatedAt}
+	postsETag := fmt.Sprintf(`"2-%d"`, updatedAt.UnixNano())
+
+	testCases := []struct {
+		path    string
+		headers map[string]string
+		status  int
+		etag    string
+	}{
+		{path: "/posts/1", status: http.StatusOK, etag: `"3"`},
+		{path: "/posts/1", headers: map[string]string{"If-None-Match": `"3"`}, status: http.StatusNotModified, etag: `"3"`},
+		{path: "/posts/1", headers: map[string]string{"If-None-Match": `"1", W/"3"`}, status: http.StatusNotModified, etag: `"3"`},
+		{path: "/posts/1", headers: map[string]string{"If-None-Match": `"2"`}, status: http.StatusOK, etag: `"3"`},
+		{path: "/posts/1", headers: map[string]string{"If-Modified-Since": "Sat, 01 Jun 2024 12:30:15 GMT"}, status: http.StatusNotModified, etag: `"3"`},
+		{path: "/posts/1", headers: map[string]string{"If-Modified-Since": "Sat, 01 Jun 2024 12:30:14 GMT"}, status: http.StatusOK, etag: `"3"`},
+		{
+			path:    "/posts/1",
+			headers: map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": "Sat, 01 Jun 2024 12:30:15 GMT"},
+			status:  http.StatusOK,
+			etag:    `"3"`,
+		},
+		{path: "/posts", status: http.StatusOK, etag: postsETag},
+		{path: "/posts", headers: map[string]string{"If-None-Match": postsETag}, status: http.StatusNotModified, etag: postsETag},
+		{path: "/posts", headers: map[string]string{"If-Modified-Since": "Sat, 01 Jun 2024 13:00:00 GMT"}, status: http.StatusNotModified, etag: postsETag},
+		{path: "/posts", headers: map[string]string{"If-None-Match": `"1-1"`}, status: http.StatusOK, etag: postsETag},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		var err error
+
+		if tc.path == "/posts" {
+			mockService.On("GetPostsStats", mock.Anything, "", models.PostsFilter{AllTags: true}).Return(stats, nil).Once()
+			if tc.status == http.StatusOK {
+				mockService.On("GetAllPosts", mock.Anything, 0, "", models.PostsFilter{AllTags: true}).Return(models.PostsPage{Posts: []models.Post{post}}, nil).Once()
+			}
+
+			err = h.GetAllPosts(c)
+		} else {
+			mockService.On("GetPost", mock.Anything, 1).Return(post, nil).Once()
+			c.SetPath("/posts/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+
+			err = h.GetPost(c)
+		}
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.etag, rec.Header().Get("ETag"), fmt.Sprintf("case %d", i))
+		assert.Equal(t, "Sat, 01 Jun 2024 12:30:15 GMT", rec.Header().Get("Last-Modified"), fmt.Sprintf("case %d", i))
+
+		if tc.path == "/posts" {
+			assert.Equal(t, "Authorization", rec.Header().Get("Vary"), fmt.Sprintf("case %d", i))
+		}
+
+		if tc.status == http.StatusNotModified {
+			assert.Empty(t, rec.Body.String(), fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestConditionalGetVary(t *testing.T) {
+	testCases := []struct {
+		identity []IdentityExtractor
+		vary     string
+	}{
+		{vary: "Authorization"},
+		{identity: []IdentityExtractor{TrustedHeaderExtractor{UserHeader: "X-User-Id"}}, vary: "Authorization, X-User-Id"},
+		{
+			identity: []IdentityExtractor{TrustedHeaderExtractor{UserHeader: "X-User-Id", RolesHeader: "X-User-Roles"}},
+			vary:     "Authorization, X-User-Id, X-User-Roles",
+		},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService, tc.identity...)
+		e := echo.New()
+
+		// Shared caches must not answer one caller with the listing of another.
+		mockService.On("GetPostsStats", mock.Anything, "", models.PostsFilter{AllTags: true}).Return(models.PostsStats{}, nil).Once()
+		mockService.On("GetAllPosts", mock.Anything, 0, "", models.PostsFilter{AllTags: true}).Return(models.PostsPage{}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetAllPosts(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, http.StatusOK, rec.Code, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.vary, rec.Header().Get("Vary"), fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestConditionalGetAccess(t *testing.T) {
+	stats := models.PostsStats{Count: 2, LastModified: time.Date(2024, 6, 1, 12, 30, 15, 0, time.UTC)}
+	drafts := models.PostsFilter{AllTags: true, Status: models.StatusDraft}
+
+	testCases := []struct {
+		err    error
+		status int
+	}{
+		{err: apperr.Unauthorized("authentication required", nil), status: http.StatusUnauthorized},
+		{err: apperr.Forbidden("permission denied", nil), status: http.StatusForbidden},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		// A validator matching the drafts must not tell callers who may not
+		// list them anything about them.
+		mockService.On("GetPostsStats", mock.Anything, "", drafts).Return(models.PostsStats{}, tc.err).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/posts?status=draft", nil)
+		req.Header.Set("If-None-Match", fmt.Sprintf(`"%d-%d"`, stats.Count, stats.LastModified.UnixNano()))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetAllPosts(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		assert.Empty(t, rec.Header().Get("ETag"), fmt.Sprintf("case %d", i))
+		assert.Equal(t, "Authorization", rec.Header().Get("Vary"), fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestPatchPost(t *testing.T) {
+	patched := models.Post{ID: 1, Title: "Patched Post", Content: "Content", Version: 3}
+
+	testCases := []struct {
+		id           string
+		contentType  string
+		ifMatch      string
+		reqBody      string
+		patch        models.PostPatch
+		serviceError error
+		status       int
+		errorMessage string
+	}{
+		{
+			id:          "1",
+			contentType: "application/merge-patch+json",
+			reqBody:     `{"title":"Patched Post"}`,
+			patch:       models.PostPatch{Type: "application/merge-patch+json", Document: []byte(`{"title":"Patched Post"}`)},
+			status:      http.StatusOK,
+		},
+		{
+			id:          "1",
+			contentType: "application/json-patch+json; charset=utf-8",
+			ifMatch:     `"2"`,
+			reqBody:     `[{"op":"replace","path":"/title","value":"Patched Post"}]`,
+			patch:       models.PostPatch{Type: "application/json-patch+json", Document: []byte(`[{"op":"replace","path":"/title","value":"Patched Post"}]`), Version: 2},
+			status:      http.StatusOK,
+		},
+		{
+			id:           "1",
+			contentType:  "application/merge-patch+json",
+			reqBody:      `{"title":null}`,
+			patch:        models.PostPatch{Type: "application/merge-patch+json", Document: []byte(`{"title":null}`)},
+			serviceError: apperr.Validation("invalid post data", nil),
+			status:       http.StatusUnprocessableEntity,
+			errorMessage: "invalid post data",
+		},
+		{
+			id:           "1",
+			contentType:  "application/json-patch+json",
+			reqBody:      `[{"op":"test","path":"/title","value":"Other"}]`,
+			patch:        models.PostPatch{Type: "application/json-patch+json", Document: []byte(`[{"op":"test","path":"/title","value":"Other"}]`)},
+			serviceError: apperr.Conflict("patch test operation failed", nil),
+			status:       http.StatusConflict,
+			errorMessage: "patch test operation failed",
+		},
+		{
+			id:           "1",
+			contentType:  echo.MIMEApplicationJSON,
+			reqBody:      `{"title":"Patched Post"}`,
+			status:       http.StatusUnsupportedMediaType,
+			errorMessage: "unsupported patch type",
+		},
+		{
+			id:           "1",
+			contentType:  "application/merge-patch+json",
+			reqBody:      `{"title":`,
+			status:       http.StatusBadRequest,
+			errorMessage: "invalid patch document",
+		},
+		{
+			id:           "0",
+			contentType:  "application/merge-patch+json",
+			reqBody:      `{"title":"Patched Post"}`,
+			status:       http.StatusBadRequest,
+			errorMessage: "invalid post id",
+		},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.patch.Type != "" {
+			mockService.On("PatchPost", mock.Anything, 1, tc.patch).Return(patched, tc.serviceError).Once()
+		}
+
+		req := httptest.NewRequest(http.MethodPatch, "/posts/"+tc.id, bytes.NewBufferString(tc.reqBody))
+		req.Header.Set(echo.HeaderContentType, tc.contentType)
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.id)
+
+		err := h.PatchPost(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.errorMessage != "" {
+			resp := ErrorResponse{}
+			err = json.Unmarshal([]byte(rec.Body.String()), &resp)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.errorMessage, resp.Error, fmt.Sprintf("case %d", i))
+		} else {
+			resp := models.Post{}
+			err = json.Unmarshal([]byte(rec.Body.String()), &resp)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, patched, resp, fmt.Sprintf("case %d", i))
+			assert.Equal(t, `"3"`, rec.Header().Get("ETag"), fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestGetTrash(t *testing.T) {
+	deleted := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	page := models.PostsPage{
+		Posts:      []models.Post{{ID: 1, Title: "Test Title", Content: "Test Content", DeletedAt: &deleted}},
+		NextCursor: "next",
+	}
+
+	testCases := []struct {
+		query      string
+		limit      int
+		cursor     string
+		serviceErr error
+		status     int
+	}{
+		{query: "", status: http.StatusOK},
+		{query: "?limit=1&cursor=abc", limit: 1, cursor: "abc", status: http.StatusOK},
+		{query: "?limit=x", status: http.StatusBadRequest},
+		{query: "?cursor=bad", cursor: "bad", serviceErr: service.ErrInvalidCursor, status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status == http.StatusOK {
+			mockService.On("GetTrash", mock.Anything, tc.limit, tc.cursor).Return(page, nil)
+		} else if tc.serviceErr != nil {
+			mockService.On("GetTrash", mock.Anything, tc.limit, tc.cursor).Return(models.PostsPage{}, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/trash"+tc.query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetTrash(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.status == http.StatusOK {
+			var result models.PostsPage
+			err = json.Unmarshal(rec.Body.Bytes(), &result)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, page.NextCursor, result.NextCursor, fmt.Sprintf("case %d", i))
+			assert.Len(t, result.Posts, 1, fmt.Sprintf("case %d", i))
+			assert.NotNil(t, result.Posts[0].DeletedAt, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestRestorePost(t *testing.T) {
+	post := models.Post{ID: 1, Title: "Test Title", Content: "Test Content", Version: 3}
+
+	testCases := []struct {
+		id         string
+		serviceErr error
+		status     int
+	}{
+		{id: "1", status: http.StatusOK},
+		{id: "1", serviceErr: apperr.NotFound("post not found in trash", nil), status: http.StatusNotFound},
+		{id: "0", status: http.StatusBadRequest},
+		{id: "a", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.id == "1" {
+			mockService.On("RestorePost", mock.Anything, 1).Return(post, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/posts/"+tc.id+"/restore", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/restore")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.id)
+
+		err := h.RestorePost(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.status == http.StatusOK {
+			assert.Equal(t, `"3"`, rec.Header().Get(headerETag), fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestPurgePost(t *testing.T) {
+	testCases := []struct {
+		id         string
+		serviceErr error
+		status     int
+	}{
+		{id: "1", status: http.StatusNoContent},
+		{id: "1", serviceErr: apperr.NotFound("post not found in trash", nil), status: http.StatusNotFound},
+		{id: "0", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.id == "1" {
+			mockService.On("PurgePost", mock.Anything, 1).Return(tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodDelete, "/trash/"+tc.id, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/trash/:id")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.id)
+
+		err := h.PurgePost(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestGetRevisions(t *testing.T) {
+	revisions := []models.Revision{
+		{PostID: 1, Version: 2, Title: "Second Title", Content: "Content"},
+		{PostID: 1, Version: 1, Title: "First Title", Content: "Content"},
+	}
+
+	testCases := []struct {
+		id         string
+		serviceErr error
+		status     int
+	}{
+		{id: "1", status: http.StatusOK},
+		{id: "1", serviceErr: apperr.NotFound("post not found", nil), status: http.StatusNotFound},
+		{id: "a", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.id == "1" {
+			mockService.On("GetRevisions", mock.Anything, 1).Return(revisions, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/posts/"+tc.id+"/revisions", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/revisions")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.id)
+
+		err := h.GetRevisions(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.status == http.StatusOK {
+			var result []models.Revision
+			err = json.Unmarshal(rec.Body.Bytes(), &result)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, revisions, result, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestGetRevision(t *testing.T) {
+	revision := models.Revision{PostID: 1, Version: 1, Title: "First Title", Content: "Content"}
+
+	testCases := []struct {
+		rev        string
+		serviceErr error
+		status     int
+	}{
+		{rev: "1", status: http.StatusOK},
+		{rev: "1", serviceErr: apperr.NotFound("revision not found", nil), status: http.StatusNotFound},
+		{rev: "0", status: http.StatusBadRequest},
+		{rev: "a", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.rev == "1" {
+			mockService.On("GetRevision", mock.Anything, 1, 1).Return(revision, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/posts/1/revisions/"+tc.rev, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/revisions/:rev")
+		c.SetParamNames("id", "rev")
+		c.SetParamValues("1", tc.rev)
+
+		err := h.GetRevision(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestRevertPost(t *testing.T) {
+	post := models.Post{ID: 1, Title: "First Title", Content: "Content", Version: 3}
+
+	testCases := []struct {
+		ifMatch    string
+		version    int
+		serviceErr error
+		status     int
+	}{
+		{status: http.StatusOK},
+		{ifMatch: `"2"`, version: 2, status: http.StatusOK},
+		{ifMatch: `"1"`, version: 1, serviceErr: apperr.PreconditionFailed("post has been modified", nil), status: http.StatusPreconditionFailed},
+		{ifMatch: `W/"2"`, status: http.StatusPreconditionFailed},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.version != 0 || tc.ifMatch == "" {
+			mockService.On("RevertPost", mock.Anything, 1, 1, tc.version).Return(post, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/posts/1/revisions/1/revert", nil)
+		if tc.ifMatch != "" {
+			req.Header.Set(headerIfMatch, tc.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/revisions/:rev/revert")
+		c.SetParamNames("id", "rev")
+		c.SetParamValues("1", "1")
+
+		err := h.RevertPost(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.status == http.StatusOK {
+			assert.Equal(t, `"3"`, rec.Header().Get(headerETag), fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	diff := "--- posts/1/revisions/1\n+++ posts/1/revisions/2\n@@ -1 +1 @@\n-a\n+b\n"
+
+	testCases := []struct {
+		query  string
+		to     int
+		status int
+	}{
+		{query: "", to: 0, status: http.StatusOK},
+		{query: "?to=2", to: 2, status: http.StatusOK},
+		{query: "?to=0", status: http.StatusBadRequest},
+		{query: "?to=x", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status == http.StatusOK {
+			mockService.On("DiffRevisions", mock.Anything, 1, 1, tc.to).Return(diff, nil)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/posts/1/revisions/1/diff"+tc.query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/posts/:id/revisions/:rev/diff")
+		c.SetParamNames("id", "rev")
+		c.SetParamValues("1", "1")
+
+		err := h.DiffRevisions(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.status == http.StatusOK {
+			assert.Equal(t, diff, rec.Body.String(), fmt.Sprintf("case %d", i))
+			assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMETextPlain, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
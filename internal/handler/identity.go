@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+)
+
+// IdentityExtractor finds out who is making a request. It returns false when
+// the request carries no credentials it understands, and an error when it
+// does but they are not valid.
+type IdentityExtractor interface {
+	Extract(c echo.Context) (models.Identity, bool, error)
+}
+
+// IdentityExtractorFunc adapts a function to IdentityExtractor.
+type IdentityExtractorFunc func(c echo.Context) (models.Identity, bool, error)
+
+func (f IdentityExtractorFunc) Extract(c echo.Context) (models.Identity, bool, error) {
+	return f(c)
+}
+
+// TrustedHeaderExtractor reads the identity from headers set by a trusted
+// proxy in front of the API, e.g. an API gateway that already authenticated
+// the caller. Only use it when clients cannot reach the API directly,
+// otherwise anyone can claim to be anyone.
+type TrustedHeaderExtractor struct {
+	// UserHeader holds the numeric user id.
+	UserHeader string
+	// RolesHeader holds a comma separated list of roles. Optional.
+	RolesHeader string
+}
+
+func (e TrustedHeaderExtractor) Extract(c echo.Context) (models.Identity, bool, error) {
+	value := c.Request().Header.Get(e.UserHeader)
+	if value == "" {
+		return models.Identity{}, false, nil
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 1 {
+		return models.Identity{}, false, apperr.Unauthorized("invalid identity header", err)
+	}
+
+	identity := models.Identity{UserID: id}
+
+	if e.RolesHeader != "" {
+		for _, role := range strings.Split(c.Request().Header.Get(e.RolesHeader), ",") {
+			role = strings.TrimSpace(role)
+			if role != "" {
+				identity.Roles = append(identity.Roles, role)
+			}
+		}
+	}
+
+	return identity, true, nil
+}
+
+// BearerTokenExtractor authenticates "Authorization: Bearer <token>" headers
+// with the access tokens issued on login.
+func BearerTokenExtractor(service Service) IdentityExtractor {
+	return IdentityExtractorFunc(func(c echo.Context) (models.Identity, bool, error) {
+		token, ok := authorizationCredentials(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer")
+		if !ok {
+			return models.Identity{}, false, nil
+		}
+
+		identity, err := service.Authenticate(c.Request().Context(), token)
+		if err != nil {
+			return models.Identity{}, false, err
+		}
+
+		return identity, true, nil
+	})
+}
+
+// APIKeyExtractor authenticates "Authorization: ApiKey <key>" headers with the
+// API keys users create for machine clients.
+func APIKeyExtractor(service Service) IdentityExtractor {
+	return IdentityExtractorFunc(func(c echo.Context) (models.Identity, bool, error) {
+		key, ok := authorizationCredentials(c.Request().Header.Get(echo.HeaderAuthorization), "ApiKey")
+		if !ok {
+			return models.Identity{}, false, nil
+		}
+
+		identity, err := service.AuthenticateAPIKey(c.Request().Context(), key)
+		if err != nil {
+			return models.Identity{}, false, err
+		}
+
+		return identity, true, nil
+	})
+}
+
+// extractIdentity asks the extractors in order and returns the first identity
+// found. An invalid credential stops the search.
+func (h *Handler) extractIdentity(c echo.Context) (models.Identity, bool, error) {
+	for _, extractor := range h.identity {
+		identity, ok, err := extractor.Extract(c)
+		if err != nil || ok {
+			return identity, ok, err
+		}
+	}
+
+	return models.Identity{}, false, nil
+}
+
+// identityHeaders returns the request headers the extractors identify callers
+// by, which responses that depend on the caller vary by.
+func (h *Handler) identityHeaders() []string {
+	headers := []string{echo.HeaderAuthorization}
+
+	for _, extractor := range h.identity {
+		if e, ok := extractor.(TrustedHeaderExtractor); ok {
+			headers = append(headers, e.UserHeader)
+			if e.RolesHeader != "" {
+				headers = append(headers, e.RolesHeader)
+			}
+		}
+	}
+
+	return headers
+}
+
+// authorizationCredentials extracts the credentials from an
+// "Authorization: <scheme> <credentials>" header. The scheme is
+// case-insensitive.
+func authorizationCredentials(header string, want string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, want) {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
@@ -1,7 +1,12 @@
 package handler
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/rostis232/prmv/internal/apperr"
 )
 
 type ErrorResponse struct {
@@ -11,3 +16,52 @@ type ErrorResponse struct {
 func newErrorResponse(c echo.Context, status int, err string) error {
 	return c.JSON(status, ErrorResponse{Error: err})
 }
+
+// newServiceErrorResponse translates an error returned by the service into a
+// response. Domain errors are answered with their own status and message, any
+// other error is logged and answered with 500 and the fallback message.
+func newServiceErrorResponse(c echo.Context, err error, fallback string) error {
+	status, message := serviceError(err, fallback)
+
+	return newErrorResponse(c, status, message)
+}
+
+// serviceError returns the status and client-facing message for an error
+// returned by the service, logging unexpected errors.
+func serviceError(err error, fallback string) (int, string) {
+	status := statusFromError(err)
+
+	if status >= http.StatusInternalServerError {
+		log.Errorf("%s: %v", fallback, err)
+	}
+
+	message, ok := apperr.Message(err)
+	if !ok || status == http.StatusInternalServerError {
+		message = fallback
+	}
+
+	return status, message
+}
+
+func statusFromError(err error) int {
+	switch {
+	case errors.Is(err, apperr.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, apperr.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, apperr.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, apperr.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, apperr.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, apperr.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, apperr.ErrAborted):
+		return http.StatusFailedDependency
+	case errors.Is(err, apperr.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
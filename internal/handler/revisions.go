@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetRevisions godoc
+// @Summary List post revisions
+// @Description Get every stored version of a post, newest first. The first revision is the current post.
+// @Tags revisions
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Success 200 {array} models.Revision
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /posts/{id}/revisions [get]
+func (h *Handler) GetRevisions(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	revisions, err := h.Service.GetRevisions(c.Request().Context(), id)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting revisions")
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// GetRevision godoc
+// @Summary Get a post revision
+// @Description Get the title and content a post had at the given version
+// @Tags revisions
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Param rev path int true "Post version"
+// @Success 200 {object} models.Revision
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /posts/{id}/revisions/{rev} [get]
+func (h *Handler) GetRevision(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	rev, err := parsePositiveParam(c, "rev")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid revision")
+	}
+
+	revision, err := h.Service.GetRevision(c.Request().Context(), id, rev)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting revision")
+	}
+
+	return c.JSON(http.StatusOK, revision)
+}
+
+// RevertPost godoc
+// @Summary Revert a post to a revision
+// @Description Restore the title and content of a revision as a new version of the post. When If-Match is sent, the revert only succeeds if it matches the current ETag.
+// @Tags revisions
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Param rev path int true "Post version to revert to"
+// @Param If-Match header string false "ETag of the post version being replaced"
+// @Success 200 {object} models.Post
+// @Header 200 {string} ETag "Post version"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/{id}/revisions/{rev}/revert [post]
+func (h *Handler) RevertPost(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	rev, err := parsePositiveParam(c, "rev")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid revision")
+	}
+
+	version, err := versionFromIfMatch(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusPreconditionFailed, "post has been modified")
+	}
+
+	post, err := h.Service.RevertPost(c.Request().Context(), id, rev, version)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error reverting post")
+	}
+
+	setPostETag(c, post)
+	return c.JSON(http.StatusOK, post)
+}
+
+// DiffRevisions godoc
+// @Summary Diff two post revisions
+// @Description Get a unified diff from one revision of a post to another. The diffed text is the title, a blank line and the content. An empty body means the revisions are identical.
+// @Tags revisions
+// @Produce  plain
+// @Param id path int true "Post ID"
+// @Param rev path int true "Post version to diff from"
+// @Param to query int false "Post version to diff to (default is the current version)"
+// @Success 200 {string} string "Unified diff"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /posts/{id}/revisions/{rev}/diff [get]
+func (h *Handler) DiffRevisions(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	rev, err := parsePositiveParam(c, "rev")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid revision")
+	}
+
+	var to int
+
+	if toStr := c.QueryParam("to"); toStr != "" {
+		to, err = strconv.Atoi(toStr)
+		if err != nil || to < 1 {
+			return newErrorResponse(c, http.StatusBadRequest, "invalid revision")
+		}
+	}
+
+	diff, err := h.Service.DiffRevisions(c.Request().Context(), id, rev, to)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error diffing revisions")
+	}
+
+	return c.String(http.StatusOK, diff)
+}
+
+// parsePositiveParam reads a path parameter that must be a positive integer.
+func parsePositiveParam(c echo.Context, name string) (int, error) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil || value < 1 {
+		return 0, errors.New("invalid " + name)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/models"
+)
+
+// RequirePermission returns a middleware that rejects callers who may not
+// perform action on any resource. It must run after RequireUser.
+func (h *Handler) RequirePermission(action string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := h.Service.Authorize(c.Request().Context(), action)
+			if err != nil {
+				return newServiceErrorResponse(c, err, "error authorizing request")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// GetRoles godoc
+// @Summary List roles
+// @Description Get every role with the permissions it grants
+// @Tags roles
+// @Produce  json
+// @Success 200 {array} models.Role
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /roles [get]
+func (h *Handler) GetRoles(c echo.Context) error {
+	roles, err := h.Service.GetRoles(c.Request().Context())
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting roles")
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+// GetUserRoles godoc
+// @Summary List roles of a user
+// @Description Get the roles granted to a user
+// @Tags roles
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.UserRoles
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /users/{id}/roles [get]
+func (h *Handler) GetUserRoles(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid user id")
+	}
+
+	roles, err := h.Service.GetUserRoles(c.Request().Context(), id)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting user roles")
+	}
+
+	return c.JSON(http.StatusOK, models.UserRoles{UserID: id, Roles: roles})
+}
+
+// AssignRole godoc
+// @Summary Assign a role
+// @Description Grant a role to a user. Granting a role the user already has changes nothing.
+// @Tags roles
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param role path string true "Role name"
+// @Success 200 {object} models.UserRoles
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /users/{id}/roles/{role} [put]
+func (h *Handler) AssignRole(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid user id")
+	}
+
+	roles, err := h.Service.AssignRole(c.Request().Context(), id, c.Param("role"))
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error assigning role")
+	}
+
+	return c.JSON(http.StatusOK, models.UserRoles{UserID: id, Roles: roles})
+}
+
+// RevokeRole godoc
+// @Summary Revoke a role
+// @Description Take a role away from a user. Admins cannot revoke their own admin role.
+// @Tags roles
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param role path string true "Role name"
+// @Success 200 {object} models.UserRoles
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /users/{id}/roles/{role} [delete]
+func (h *Handler) RevokeRole(c echo.Context) error {
+	id, err := parsePositiveParam(c, "id")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid user id")
+	}
+
+	roles, err := h.Service.RevokeRole(c.Request().Context(), id, c.Param("role"))
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error revoking role")
+	}
+
+	return c.JSON(http.StatusOK, models.UserRoles{UserID: id, Roles: roles})
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRequirePermission(t *testing.T) {
+	testCases := []struct {
+		serviceErr error
+		status     int
+	}{
+		{status: http.StatusNoContent},
+		{serviceErr: apperr.Forbidden("permission denied", nil), status: http.StatusForbidden},
+		{serviceErr: apperr.Unauthorized("authentication required", nil), status: http.StatusUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		mockService.On("Authorize", mock.Anything, "posts:create").Return(tc.serviceErr)
+
+		req := httptest.NewRequest(http.MethodPost, "/posts", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.RequirePermission("posts:create")(func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		})(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestAssignRole(t *testing.T) {
+	testCases := []struct {
+		id         string
+		serviceErr error
+		status     int
+		body       string
+	}{
+		{id: "2", status: http.StatusOK, body: `{"user_id":2,"roles":["author","editor"]}`},
+		{id: "2", serviceErr: apperr.NotFound("role not found", nil), status: http.StatusNotFound},
+		{id: "2", serviceErr: apperr.Forbidden("permission denied", nil), status: http.StatusForbidden},
+		{id: "abc", status: http.StatusBadRequest},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		if tc.status != http.StatusBadRequest {
+			mockService.On("AssignRole", mock.Anything, 2, "editor").Return([]string{"author", "editor"}, tc.serviceErr)
+		}
+
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/users/:id/roles/:role")
+		c.SetParamNames("id", "role")
+		c.SetParamValues(tc.id, "editor")
+
+		err := h.AssignRole(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		if tc.body != "" {
+			assert.JSONEq(t, tc.body, rec.Body.String(), fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestRevokeRole(t *testing.T) {
+	mockService := new(MockService)
+	h := NewHandler(mockService)
+	e := echo.New()
+
+	mockService.On("RevokeRole", mock.Anything, 1, "admin").Return([]string(nil), apperr.Conflict("cannot revoke own admin role", nil))
+
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/users/:id/roles/:role")
+	c.SetParamNames("id", "role")
+	c.SetParamValues("1", "admin")
+
+	err := h.RevokeRole(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	mockService.AssertExpectations(t)
+}
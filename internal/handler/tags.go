@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/models"
+)
+
+// GetTags godoc
+// @Summary List tags
+// @Description Get every tag in use with the number of posts carrying it, most used first
+// @Tags tags
+// @Produce  json
+// @Success 200 {array} models.Tag
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /tags [get]
+func (h *Handler) GetTags(c echo.Context) error {
+	tags, err := h.Service.GetTags(c.Request().Context())
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error getting tags")
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
+
+// parsePostsFilter reads the repeated tag parameter and the match mode, which
+// is "all" when omitted.
+func parsePostsFilter(c echo.Context) (models.PostsFilter, error) {
+	filter := models.PostsFilter{Tags: c.QueryParams()["tag"], AllTags: true}
+
+	switch c.QueryParam("match") {
+	case "", "all":
+	case "any":
+		filter.AllTags = false
+	default:
+		return models.PostsFilter{}, errors.New("invalid match")
+	}
+
+	return filter, nil
+}
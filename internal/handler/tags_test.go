@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTags(t *testing.T) {
+	testCases := []struct {
+		tags   []models.Tag
+		err    error
+		status int
+	}{
+		{
+			tags:   []models.Tag{{Name: "go", Count: 3}, {Name: "web", Count: 1}},
+			status: http.StatusOK,
+		},
+		{
+			tags:   []models.Tag{},
+			status: http.StatusOK,
+		},
+		{
+			tags:   []models.Tag{},
+			err:    apperr.Unavailable("database is unavailable", nil),
+			status: http.StatusServiceUnavailable,
+		},
+	}
+
+	for i, tc := range testCases {
+		mockService := new(MockService)
+		h := NewHandler(mockService)
+		e := echo.New()
+
+		mockService.On("GetTags", mock.Anything).Return(tc.tags, tc.err).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/tags", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetTags(c)
+
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		if tc.err == nil {
+			var tags []models.Tag
+			err = json.Unmarshal(rec.Body.Bytes(), &tags)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.tags, tags, fmt.Sprintf("case %d", i))
+		}
+
+		mockService.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/rostis232/prmv/internal/service"
+)
+
+// GetTrash godoc
+// @Summary List deleted posts
+// @Description Get a page of posts in the trash. Pagination works like GET /posts.
+// @Tags trash
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Success 200 {object} models.PostsPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /trash [get]
+func (h *Handler) GetTrash(c echo.Context) error {
+	limit, err := parseLimit(c)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid limit")
+	}
+
+	page, err := h.Service.GetTrash(c.Request().Context(), limit, c.QueryParam("cursor"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return newErrorResponse(c, http.StatusBadRequest, "invalid cursor")
+		}
+		return newServiceErrorResponse(c, err, "error getting trash")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// RestorePost godoc
+// @Summary Restore a deleted post
+// @Description Take a post out of the trash
+// @Tags trash
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Success 200 {object} models.Post
+// @Header 200 {string} ETag "Post version"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /posts/{id}/restore [post]
+func (h *Handler) RestorePost(c echo.Context) error {
+	idStr := c.Param("id")
+
+	idInt, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Errorf("error converting id to int: %v", err)
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	if idInt < 1 {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	post, err := h.Service.RestorePost(c.Request().Context(), idInt)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error restoring post")
+	}
+
+	setPostETag(c, post)
+	return c.JSON(http.StatusOK, post)
+}
+
+// PurgePost godoc
+// @Summary Permanently delete a post
+// @Description Permanently delete a post that is in the trash
+// @Tags trash
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Post ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /trash/{id} [delete]
+func (h *Handler) PurgePost(c echo.Context) error {
+	idStr := c.Param("id")
+
+	idInt, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Errorf("error converting id to int: %v", err)
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	if idInt < 1 {
+		return newErrorResponse(c, http.StatusBadRequest, "invalid post id")
+	}
+
+	err = h.Service.PurgePost(c.Request().Context(), idInt)
+	if err != nil {
+		return newServiceErrorResponse(c, err, "error purging post")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
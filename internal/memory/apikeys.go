@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// AddAPIKey stores a new API key and returns its id.
+func (m *Memory) AddAPIKey(_ context.Context, key models.APIKey) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := checkLength("API key", "error adding API key", 100, key.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	err = checkLength("API key", "error adding API key", 16, key.Prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, other := range m.data.apiKeys {
+		if other.KeyHash == key.KeyHash {
+			return 0, duplicate("API key", "error adding API key")
+		}
+	}
+
+	if _, ok := m.data.users[key.UserID]; !ok {
+		return 0, missingReference("API key", "error adding API key")
+	}
+
+	stored := models.APIKey{
+		ID:        m.data.next("api_keys"),
+		UserID:    key.UserID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		KeyHash:   key.KeyHash,
+		Scopes:    slices.Clone(key.Scopes),
+		ExpiresAt: dbTimePtr(key.ExpiresAt),
+		CreatedAt: m.now(),
+	}
+	m.data.apiKeys[stored.ID] = apiKey{APIKey: stored}
+
+	return stored.ID, nil
+}
+
+// GetAPIKey returns a key of a user that has not been revoked.
+func (m *Memory) GetAPIKey(_ context.Context, id int, userID int) (models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.data.apiKeys[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return models.APIKey{}, notFound("API key", "error getting API key")
+	}
+
+	return copyAPIKey(key.APIKey), nil
+}
+
+// GetAPIKeys lists the keys of a user that have not been revoked, newest
+// first. Expired keys are included.
+func (m *Memory) GetAPIKeys(_ context.Context, userID int) ([]models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := []models.APIKey{}
+
+	for _, key := range m.data.apiKeys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			keys = append(keys, copyAPIKey(key.APIKey))
+		}
+	}
+
+	slices.SortFunc(keys, func(a, b models.APIKey) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return keys, nil
+}
+
+// UseAPIKey looks up a live key by the hash of its secret and records that it
+// was used.
+func (m *Memory) UseAPIKey(_ context.Context, keyHash string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	for id, key := range m.data.apiKeys {
+		if key.KeyHash != keyHash {
+			continue
+		}
+
+		if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(now)) {
+			break
+		}
+
+		key.LastUsedAt = &now
+		m.data.apiKeys[id] = key
+
+		return copyAPIKey(key.APIKey), nil
+	}
+
+	return models.APIKey{}, notFound("API key", "error using API key")
+}
+
+// RevokeAPIKey revokes a key of a user. Revoked keys stop working at once.
+func (m *Memory) RevokeAPIKey(_ context.Context, id int, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.data.apiKeys[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return notFound("API key", "error revoking API key")
+	}
+
+	now := m.now()
+	key.RevokedAt = &now
+	m.data.apiKeys[id] = key
+
+	return nil
+}
+
+// copyAPIKey returns key without memory shared with the stored one.
+func copyAPIKey(key models.APIKey) models.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	key.ExpiresAt = dbTimePtr(key.ExpiresAt)
+	key.LastUsedAt = dbTimePtr(key.LastUsedAt)
+
+	return key
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/rostis232/prmv/models"
+)
+
+func (m *Memory) AddAttachment(_ context.Context, attachment models.Attachment) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := checkLength("attachment", "error adding attachment", 255, attachment.Filename)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, other := range m.data.attachments {
+		if other.StorageKey == attachment.StorageKey {
+			return 0, duplicate("attachment", "error adding attachment")
+		}
+	}
+
+	_, postExists := m.data.posts[attachment.PostID]
+	if !postExists {
+		return 0, missingReference("attachment", "error adding attachment")
+	}
+
+	if attachment.UploaderID != nil {
+		if _, ok := m.data.users[*attachment.UploaderID]; !ok {
+			return 0, missingReference("attachment", "error adding attachment")
+		}
+	}
+
+	stored := attachment
+	stored.ID = m.data.next("attachments")
+	stored.UploaderID = intPtr(attachment.UploaderID)
+	stored.CreatedAt = m.now()
+	m.data.attachments[stored.ID] = stored
+
+	return stored.ID, nil
+}
+
+// GetAttachments lists the attachments of a post, oldest first.
+func (m *Memory) GetAttachments(_ context.Context, postID int) ([]models.Attachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.data.selectAttachments(postID), nil
+}
+
+func (m *Memory) GetAttachment(_ context.Context, postID int, id int) (models.Attachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	attachment, ok := m.data.attachments[id]
+	if !ok || attachment.PostID != postID {
+		return models.Attachment{}, notFound("attachment", "error getting attachment")
+	}
+
+	return copyAttachment(attachment), nil
+}
+
+// DetachAttachment unlinks an attachment from its post. Its file is removed
+// later, together with the files of purged posts.
+func (m *Memory) DetachAttachment(_ context.Context, postID int, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attachment, ok := m.data.attachments[id]
+	if !ok || postID == 0 || attachment.PostID != postID {
+		return notFound("attachment", "error detaching attachment")
+	}
+
+	attachment.PostID = 0
+	m.data.attachments[id] = attachment
+
+	return nil
+}
+
+// GetDetachedAttachments lists attachments that no longer belong to a post,
+// either deleted or left behind by a purged post. Their PostID is zero.
+func (m *Memory) GetDetachedAttachments(_ context.Context) ([]models.Attachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.data.selectAttachments(0), nil
+}
+
+// DeleteAttachment deletes a detached attachment once its file is gone.
+func (m *Memory) DeleteAttachment(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attachment, ok := m.data.attachments[id]
+	if !ok || attachment.PostID != 0 {
+		return notFound("attachment", "error deleting attachment")
+	}
+
+	delete(m.data.attachments, id)
+
+	return nil
+}
+
+// selectAttachments lists the attachments of a post by id, or the detached
+// ones for post 0.
+func (d *data) selectAttachments(postID int) []models.Attachment {
+	attachments := []models.Attachment{}
+
+	for _, id := range sortedIDs(d.attachments) {
+		attachment := d.attachments[id]
+		if attachment.PostID == postID {
+			attachments = append(attachments, copyAttachment(attachment))
+		}
+	}
+
+	return attachments
+}
+
+// copyAttachment returns attachment without memory shared with the stored
+// one.
+func copyAttachment(attachment models.Attachment) models.Attachment {
+	attachment.UploaderID = intPtr(attachment.UploaderID)
+
+	return attachment
+}
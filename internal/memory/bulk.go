@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+)
+
+// BulkPosts applies ops in order and returns one result per operation. When
+// atomic is true the first failing operation undoes every other one, which
+// is then reported as aborted. Otherwise failures only skip the failing
+// operation.
+func (m *Memory) BulkPosts(_ context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]models.BulkResult, len(ops))
+	for i, op := range ops {
+		results[i] = models.BulkResult{Index: i, Op: op.Op, ID: op.Post.ID}
+	}
+
+	// Atomic operations run on a snapshot that only replaces the data once
+	// all of them succeeded.
+	d := m.data
+	if atomic {
+		d = m.data.clone()
+	}
+
+	now := m.now()
+
+	for i, op := range ops {
+		post, err := d.applyBulkOperation(op, now)
+		if err != nil {
+			results[i].Err = err
+
+			if atomic {
+				return abortBulk(results, i), nil
+			}
+			continue
+		}
+
+		results[i].ID = post.ID
+		if op.Op != models.BulkDelete {
+			results[i].Post = &post
+		}
+	}
+
+	m.data = d
+
+	return results, nil
+}
+
+// abortBulk marks every operation except the failed one as aborted.
+func abortBulk(results []models.BulkResult, failed int) []models.BulkResult {
+	for i := range results {
+		if i == failed {
+			continue
+		}
+		results[i].Post = nil
+		results[i].Err = apperr.Aborted("not applied because another operation failed", nil)
+	}
+
+	return results
+}
+
+func (d *data) applyBulkOperation(op models.BulkOperation, now time.Time) (models.Post, error) {
+	switch op.Op {
+	case models.BulkCreate:
+		return d.insertPost(op.Post, now, "error adding post")
+	case models.BulkUpdate:
+		return d.updatePost(op.Post, op.Post.Version, now, "error updating post")
+	case models.BulkDelete:
+		return d.deletePost(op.Post.ID, op.Post.Version, now, "error deleting post")
+	default:
+		return models.Post{}, apperr.Validation("unknown operation "+op.Op, nil)
+	}
+}
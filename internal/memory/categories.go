@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// AddCategory stores a category under its parent, or at the top of the tree
+// when it has none.
+func (m *Memory) AddCategory(_ context.Context, category models.Category) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := checkLength("category", "error adding category", 100, category.Slug, category.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, other := range m.data.categories {
+		if other.Slug == category.Slug {
+			return 0, duplicate("category", "error adding category")
+		}
+	}
+
+	if category.ParentID != nil {
+		if _, ok := m.data.categories[*category.ParentID]; !ok {
+			return 0, missingReference("category", "error adding category")
+		}
+	}
+
+	stored := models.Category{
+		ID:        m.data.next("categories"),
+		ParentID:  intPtr(category.ParentID),
+		Slug:      category.Slug,
+		Name:      category.Name,
+		CreatedAt: m.now(),
+	}
+	m.data.categories[stored.ID] = stored
+
+	return stored.ID, nil
+}
+
+// GetCategories lists every category, ordered by name, without building the
+// tree.
+func (m *Memory) GetCategories(_ context.Context) ([]models.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	categories := make([]models.Category, 0, len(m.data.categories))
+	for _, category := range m.data.categories {
+		categories = append(categories, copyCategory(category))
+	}
+
+	slices.SortFunc(categories, func(a, b models.Category) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+
+	return categories, nil
+}
+
+func (m *Memory) GetCategory(_ context.Context, id int) (models.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	category, ok := m.data.categories[id]
+	if !ok {
+		return models.Category{}, notFound("category", "error getting category")
+	}
+
+	return copyCategory(category), nil
+}
+
+func (m *Memory) GetCategoryBySlug(_ context.Context, slug string) (models.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, category := range m.data.categories {
+		if category.Slug == slug {
+			return copyCategory(category), nil
+		}
+	}
+
+	return models.Category{}, notFound("category", "error getting category")
+}
+
+// subtree returns the ids of the category and every category below it.
+// Categories already seen are skipped, so a cycle cannot loop forever.
+func (d *data) subtree(categoryID int) map[int]bool {
+	ids := map[int]bool{}
+	if _, ok := d.categories[categoryID]; !ok {
+		return ids
+	}
+
+	ids[categoryID] = true
+
+	for queue := []int{categoryID}; len(queue) > 0; queue = queue[1:] {
+		for id, category := range d.categories {
+			if category.ParentID != nil && *category.ParentID == queue[0] && !ids[id] {
+				ids[id] = true
+				queue = append(queue, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// copyCategory returns category without memory shared with the stored one.
+func copyCategory(category models.Category) models.Category {
+	category.ParentID = intPtr(category.ParentID)
+
+	return category
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/rostis232/prmv/models"
+)
+
+func (m *Memory) AddComment(_ context.Context, comment models.Comment) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, postExists := m.data.posts[comment.PostID]
+	if !postExists {
+		return 0, missingReference("comment", "error adding comment")
+	}
+
+	if comment.ParentID != nil {
+		if _, ok := m.data.comments[*comment.ParentID]; !ok {
+			return 0, missingReference("comment", "error adding comment")
+		}
+	}
+
+	if comment.AuthorID != nil {
+		if _, ok := m.data.users[*comment.AuthorID]; !ok {
+			return 0, missingReference("comment", "error adding comment")
+		}
+	}
+
+	now := m.now()
+	stored := models.Comment{
+		ID:        m.data.next("comments"),
+		PostID:    comment.PostID,
+		ParentID:  intPtr(comment.ParentID),
+		AuthorID:  intPtr(comment.AuthorID),
+		Content:   comment.Content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.data.comments[stored.ID] = stored
+
+	return stored.ID, nil
+}
+
+// GetComments lists every comment on a post, oldest first. Replies come after
+// the comments they answer because ids only grow.
+func (m *Memory) GetComments(_ context.Context, postID int) ([]models.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comments := []models.Comment{}
+
+	for _, id := range sortedIDs(m.data.comments) {
+		comment := m.data.comments[id]
+		if comment.PostID == postID {
+			comments = append(comments, copyComment(comment))
+		}
+	}
+
+	return comments, nil
+}
+
+func (m *Memory) GetComment(_ context.Context, postID int, id int) (models.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comment, ok := m.data.comments[id]
+	if !ok || comment.PostID != postID {
+		return models.Comment{}, notFound("comment", "error getting comment")
+	}
+
+	return copyComment(comment), nil
+}
+
+// UpdateComment stores the content of comment.
+func (m *Memory) UpdateComment(_ context.Context, comment models.Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.data.comments[comment.ID]
+	if !ok || stored.PostID != comment.PostID {
+		return notFound("comment", "error updating comment")
+	}
+
+	stored.Content = comment.Content
+	stored.UpdatedAt = m.now()
+	m.data.comments[comment.ID] = stored
+
+	return nil
+}
+
+// DeleteComment deletes a comment together with all replies to it.
+func (m *Memory) DeleteComment(_ context.Context, postID int, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment, ok := m.data.comments[id]
+	if !ok || comment.PostID != postID {
+		return notFound("comment", "error deleting comment")
+	}
+
+	for queue := []int{id}; len(queue) > 0; queue = queue[1:] {
+		delete(m.data.comments, queue[0])
+
+		for replyID, reply := range m.data.comments {
+			if reply.ParentID != nil && *reply.ParentID == queue[0] {
+				queue = append(queue, replyID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyComment returns comment without memory shared with the stored one.
+func copyComment(comment models.Comment) models.Comment {
+	comment.ParentID = intPtr(comment.ParentID)
+	comment.AuthorID = intPtr(comment.AuthorID)
+
+	return comment
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/rostis232/prmv/internal/apperr"
+)
+
+// Causes of the errors returned, matching what the database reports to the
+// postgres package.
+var (
+	errNoRows     = errors.New("no rows in result set")
+	errUnique     = errors.New("duplicate key value violates unique constraint")
+	errForeignKey = errors.New("insert or update violates foreign key constraint")
+	errCheck      = errors.New("new row violates check constraint")
+	errTooLong    = errors.New("value too long for type character varying")
+)
+
+// notFound is the error for a row that does not exist. resource names the
+// entity in client-facing messages, e.g. "post not found".
+func notFound(resource, op string) error {
+	return apperr.NotFound(resource+" not found", fmt.Errorf("%s: %w", op, errNoRows))
+}
+
+// duplicate is the error for a row that would break a unique constraint.
+func duplicate(resource, op string) error {
+	return apperr.Conflict(resource+" already exists", fmt.Errorf("%s: %w", op, errUnique))
+}
+
+// missingReference is the error for a row referencing one that does not
+// exist.
+func missingReference(resource, op string) error {
+	return apperr.Conflict(resource+" references missing data", fmt.Errorf("%s: %w", op, errForeignKey))
+}
+
+// invalid is the error for a row that breaks a check constraint.
+func invalid(resource, op string, cause error) error {
+	return apperr.Validation(resource+" data is invalid", fmt.Errorf("%s: %w", op, cause))
+}
+
+// checkLength fails like a VARCHAR(limit) column would for values longer than
+// limit characters.
+func checkLength(resource, op string, limit int, values ...string) error {
+	for _, value := range values {
+		if utf8.RuneCountInString(value) > limit {
+			return invalid(resource, op, errTooLong)
+		}
+	}
+
+	return nil
+}
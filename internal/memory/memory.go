@@ -0,0 +1,171 @@
+// Package memory is a repository kept in memory, for running the API and its
+// tests without a database. It behaves like the postgres package: ids count
+// up from 1, timestamps are maintained by the repository, missing rows are
+// NotFound errors and broken constraints Conflict or Validation errors. All
+// data is lost when the process exits.
+package memory
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// Memory is safe for concurrent use. Every method runs under a single lock,
+// so each call sees and leaves consistent data like a transaction would.
+type Memory struct {
+	mu   sync.RWMutex
+	data *data
+	// now returns the current time at the precision of the database.
+	now func() time.Time
+}
+
+// data is everything the repository stores. Values in the maps are never
+// changed in place, only replaced, so a shallow copy of the maps is a
+// snapshot.
+type data struct {
+	seq           map[string]int
+	posts         map[int]models.Post
+	postSlugs     map[string]postSlug
+	revisions     map[revisionKey]models.Revision
+	users         map[int]models.User
+	roles         map[string][]string
+	userRoles     map[userRole]bool
+	refreshTokens map[string]refreshToken
+	apiKeys       map[int]apiKey
+	comments      map[int]models.Comment
+	categories    map[int]models.Category
+	attachments   map[int]models.Attachment
+}
+
+// postSlug is a slug a post had before.
+type postSlug struct {
+	PostID    int
+	CreatedAt time.Time
+}
+
+type revisionKey struct {
+	PostID  int
+	Version int
+}
+
+type userRole struct {
+	UserID int
+	Role   string
+}
+
+type refreshToken struct {
+	models.RefreshToken
+	RevokedAt *time.Time
+}
+
+type apiKey struct {
+	models.APIKey
+	RevokedAt *time.Time
+}
+
+// rolePermissions are the roles and permissions the migrations of the
+// postgres package seed.
+var rolePermissions = map[string][]string{
+	"admin": {"categories:manage", "comments:create", "comments:delete:any", "comments:update:own",
+		"posts:create", "posts:delete:any", "posts:update:any", "roles:assign"},
+	"editor": {"categories:manage", "comments:create", "comments:delete:any", "comments:update:own",
+		"posts:create", "posts:delete:own", "posts:update:any", "posts:update:own"},
+	"author": {"comments:create", "comments:delete:own", "comments:update:own",
+		"posts:create", "posts:delete:own", "posts:update:own"},
+	"viewer": {"comments:create", "comments:delete:own", "comments:update:own"},
+}
+
+// NewMemory returns an empty repository with the default roles.
+func NewMemory() *Memory {
+	d := &data{
+		seq:           make(map[string]int),
+		posts:         make(map[int]models.Post),
+		postSlugs:     make(map[string]postSlug),
+		revisions:     make(map[revisionKey]models.Revision),
+		users:         make(map[int]models.User),
+		roles:         make(map[string][]string, len(rolePermissions)),
+		userRoles:     make(map[userRole]bool),
+		refreshTokens: make(map[string]refreshToken),
+		apiKeys:       make(map[int]apiKey),
+		comments:      make(map[int]models.Comment),
+		categories:    make(map[int]models.Category),
+		attachments:   make(map[int]models.Attachment),
+	}
+
+	for role, permissions := range rolePermissions {
+		d.roles[role] = slices.Clone(permissions)
+	}
+
+	return &Memory{
+		data: d,
+		now: func() time.Time {
+			return dbTime(time.Now())
+		},
+	}
+}
+
+// next returns the next id of table.
+func (d *data) next(table string) int {
+	d.seq[table]++
+	return d.seq[table]
+}
+
+// clone returns a snapshot of d that can be changed without affecting d.
+func (d *data) clone() *data {
+	return &data{
+		seq:           maps.Clone(d.seq),
+		posts:         maps.Clone(d.posts),
+		postSlugs:     maps.Clone(d.postSlugs),
+		revisions:     maps.Clone(d.revisions),
+		users:         maps.Clone(d.users),
+		roles:         maps.Clone(d.roles),
+		userRoles:     maps.Clone(d.userRoles),
+		refreshTokens: maps.Clone(d.refreshTokens),
+		apiKeys:       maps.Clone(d.apiKeys),
+		comments:      maps.Clone(d.comments),
+		categories:    maps.Clone(d.categories),
+		attachments:   maps.Clone(d.attachments),
+	}
+}
+
+// dbTime returns t as the database stores it: in UTC, to the microsecond.
+func dbTime(t time.Time) time.Time {
+	return t.UTC().Round(time.Microsecond)
+}
+
+// dbTimePtr is dbTime for optional times.
+func dbTimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	stored := dbTime(*t)
+	return &stored
+}
+
+// intPtr copies an optional id, so stored values do not share memory with
+// callers.
+func intPtr(i *int) *int {
+	if i == nil {
+		return nil
+	}
+
+	stored := *i
+	return &stored
+}
+
+// sortedIDs returns the ids of rows in ascending order.
+func sortedIDs[V any](rows map[int]V) []int {
+	ids := make([]int, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
@@ -0,0 +1,589 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/repotest"
+	"github.com/rostis232/prmv/internal/service"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ service.Repository = (*Memory)(nil)
+
+// newTestMemory returns a repository whose clock starts at a fixed time and
+// moves one second per call, so every write gets a distinct timestamp.
+func newTestMemory() *Memory {
+	m := NewMemory()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	return m
+}
+
+func TestAddPost(t *testing.T) {
+	testCases := []struct {
+		post     models.Post
+		expected models.Post
+		kind     error
+	}{
+		{
+			post:     models.Post{Title: "Test Title", Content: "Test Content", Tags: []string{"go", "api", "go"}},
+			expected: models.Post{ID: 1, Slug: "post-1", Title: "Test Title", Content: "Test Content", ContentFormat: models.FormatPlain, Version: 1, Tags: []string{"api", "go"}, Status: models.StatusPublished},
+		},
+		{
+			post:     models.Post{Slug: "draft", Title: "Test Title", Content: "Test Content", Status: models.StatusDraft},
+			expected: models.Post{ID: 1, Slug: "draft", Title: "Test Title", Content: "Test Content", ContentFormat: models.FormatPlain, Version: 1, Tags: []string{}, Status: models.StatusDraft},
+		},
+		{
+			post: models.Post{Title: "Test Title", Content: "Test Content", Status: models.StatusScheduled},
+			kind: apperr.ErrValidation,
+		},
+		{
+			post: models.Post{Title: "Test Title", Content: "Test Content", CategoryID: new(int)},
+			kind: apperr.ErrConflict,
+		},
+	}
+
+	for i, tc := range testCases {
+		m := newTestMemory()
+
+		id, err := m.AddPost(context.Background(), tc.post)
+		if tc.kind != nil {
+			assert.True(t, errors.Is(err, tc.kind), fmt.Sprintf("case %d", i))
+			continue
+		}
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		post, err := m.GetPost(context.Background(), id)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		assert.NotZero(t, post.CreatedAt, fmt.Sprintf("case %d", i))
+		assert.Equal(t, post.CreatedAt, post.UpdatedAt, fmt.Sprintf("case %d", i))
+
+		// Published posts are stamped with the time they went live.
+		if post.Status == models.StatusPublished {
+			assert.Equal(t, post.CreatedAt, *post.PublishAt, fmt.Sprintf("case %d", i))
+		}
+
+		post.CreatedAt, post.UpdatedAt, post.PublishAt = time.Time{}, time.Time{}, nil
+		assert.Equal(t, tc.expected, post, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestGetAllPosts(t *testing.T) {
+	m := newTestMemory()
+
+	category, err := m.AddCategory(context.Background(), models.Category{Slug: "news", Name: "News"})
+	assert.NoError(t, err)
+
+	child, err := m.AddCategory(context.Background(), models.Category{ParentID: &category, Slug: "local", Name: "Local"})
+	assert.NoError(t, err)
+
+	posts := []models.Post{
+		{Title: "First", Content: "Content", Tags: []string{"go"}, CategoryID: &category},
+		{Title: "Second", Content: "Content", Tags: []string{"go", "api"}, CategoryID: &child},
+		{Title: "Third", Content: "Content", Status: models.StatusDraft},
+	}
+
+	for _, post := range posts {
+		_, err := m.AddPost(context.Background(), post)
+		assert.NoError(t, err)
+	}
+
+	testCases := []struct {
+		query    models.PostsQuery
+		expected []int
+	}{
+		{query: models.PostsQuery{Limit: 10}, expected: []int{1, 2, 3}},
+		{query: models.PostsQuery{Limit: 2}, expected: []int{1, 2}},
+		{query: models.PostsQuery{Limit: 10, CategoryID: &category}, expected: []int{1, 2}},
+		{query: models.PostsQuery{Limit: 10, CategoryID: &child}, expected: []int{2}},
+		{query: models.PostsQuery{Limit: 10, PostsFilter: models.PostsFilter{Status: models.StatusDraft}}, expected: []int{3}},
+		{query: models.PostsQuery{Limit: 10, PostsFilter: models.PostsFilter{Tags: []string{"go", "api"}}}, expected: []int{1, 2}},
+		{query: models.PostsQuery{Limit: 10, PostsFilter: models.PostsFilter{Tags: []string{"go", "api"}, AllTags: true}}, expected: []int{2}},
+	}
+
+	for i, tc := range testCases {
+		page, err := m.GetAllPosts(context.Background(), tc.query)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		ids := []int{}
+		for _, post := range page {
+			ids = append(ids, post.ID)
+		}
+		assert.Equal(t, tc.expected, ids, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestGetAllPostsKeyset(t *testing.T) {
+	m := newTestMemory()
+
+	for i := 0; i < 5; i++ {
+		_, err := m.AddPost(context.Background(), models.Post{Title: fmt.Sprintf("Title %d", i), Content: "Content"})
+		assert.NoError(t, err)
+	}
+
+	var seen []int
+	query := models.PostsQuery{Limit: 2}
+
+	for {
+		page, err := m.GetAllPosts(context.Background(), query)
+		assert.NoError(t, err)
+
+		if len(page) == 0 {
+			break
+		}
+
+		for _, post := range page {
+			seen = append(seen, post.ID)
+		}
+
+		last := page[len(page)-1]
+		query.After = &models.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, seen)
+}
+
+func TestUpdatePost(t *testing.T) {
+	m := newTestMemory()
+
+	id, err := m.AddPost(context.Background(), models.Post{Slug: "first", Title: "First", Content: "Content", Tags: []string{"go"}})
+	assert.NoError(t, err)
+
+	created, err := m.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		post models.Post
+		kind error
+	}{
+		{post: models.Post{ID: id, Title: "Second", Content: "Content", Version: 1}},
+		{post: models.Post{ID: id, Title: "Stale", Content: "Content", Version: 1}, kind: apperr.ErrPreconditionFailed},
+		{post: models.Post{ID: id, Title: "Unversioned", Content: "Content"}, kind: apperr.ErrPreconditionFailed},
+		{post: models.Post{ID: id + 1, Title: "Missing", Content: "Content", Version: 1}, kind: apperr.ErrNotFound},
+		{post: models.Post{ID: id, Title: "Archived", Content: "Content", Version: 2, Status: models.StatusArchived}},
+	}
+
+	for i, tc := range testCases {
+		_, err := m.UpdatePost(context.Background(), tc.post)
+		if tc.kind != nil {
+			assert.True(t, errors.Is(err, tc.kind), fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		}
+	}
+
+	post, err := m.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Archived", post.Title)
+	assert.Equal(t, 3, post.Version)
+	assert.Equal(t, models.StatusArchived, post.Status)
+	// Empty slugs keep the current one and updates replace the tags.
+	assert.Equal(t, "first", post.Slug)
+	assert.Equal(t, []string{}, post.Tags)
+	assert.Equal(t, created.CreatedAt, post.CreatedAt)
+	assert.True(t, post.UpdatedAt.After(created.UpdatedAt))
+
+	revisions, err := m.GetRevisions(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+	assert.Equal(t, "Archived", revisions[0].Title)
+	assert.Equal(t, post.UpdatedAt, revisions[0].CreatedAt)
+
+	_, err = m.GetRevision(context.Background(), id, 4)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func TestGetPostsStats(t *testing.T) {
+	m := newTestMemory()
+
+	stats, err := m.GetPostsStats(context.Background(), models.PostsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, models.PostsStats{LastModified: time.Unix(0, 0).UTC()}, stats)
+
+	first, err := m.AddPost(context.Background(), models.Post{Title: "First", Content: "Content"})
+	assert.NoError(t, err)
+
+	second, err := m.AddPost(context.Background(), models.Post{Title: "Second", Content: "Content"})
+	assert.NoError(t, err)
+
+	err = m.DeletePost(context.Background(), second, 0)
+	assert.NoError(t, err)
+
+	post, err := m.GetPost(context.Background(), first)
+	assert.NoError(t, err)
+
+	stats, err = m.GetPostsStats(context.Background(), models.PostsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, models.PostsStats{Count: 1, LastModified: post.UpdatedAt}, stats)
+}
+
+func TestTrash(t *testing.T) {
+	m := newTestMemory()
+
+	id, err := m.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	comment, err := m.AddComment(context.Background(), models.Comment{PostID: id, Content: "Comment"})
+	assert.NoError(t, err)
+
+	_, err = m.AddAttachment(context.Background(), models.Attachment{PostID: id, Filename: "a.txt", StorageKey: "1/a"})
+	assert.NoError(t, err)
+
+	err = m.RestorePost(context.Background(), id)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	err = m.DeletePost(context.Background(), id, 2)
+	assert.True(t, errors.Is(err, apperr.ErrPreconditionFailed))
+
+	err = m.DeletePost(context.Background(), id, 1)
+	assert.NoError(t, err)
+
+	err = m.DeletePost(context.Background(), id, 0)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	trash, err := m.GetTrash(context.Background(), models.PostsQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, trash, 1)
+	assert.NotNil(t, trash[0].DeletedAt)
+
+	err = m.RestorePost(context.Background(), id)
+	assert.NoError(t, err)
+
+	_, err = m.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+
+	err = m.DeletePost(context.Background(), id, 0)
+	assert.NoError(t, err)
+
+	purged, err := m.PurgeTrash(context.Background(), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+
+	purged, err = m.PurgeTrash(context.Background(), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	// Purging takes comments and revisions along and leaves the attachments
+	// behind for their files to be removed.
+	_, err = m.GetComment(context.Background(), id, comment)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	revisions, err := m.GetRevisions(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 0)
+
+	detached, err := m.GetDetachedAttachments(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, detached, 1)
+	assert.Zero(t, detached[0].PostID)
+}
+
+func TestSlugs(t *testing.T) {
+	m := newTestMemory()
+
+	id, err := m.AddPost(context.Background(), models.Post{Slug: "hello", Title: "Hello", Content: "Content"})
+	assert.NoError(t, err)
+
+	_, err = m.AddPost(context.Background(), models.Post{Slug: "hello", Title: "Hello", Content: "Content"})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	_, err = m.UpdatePost(context.Background(), models.Post{ID: id, Slug: "hello-world", Title: "Hello", Content: "Content", Version: 1})
+	assert.NoError(t, err)
+
+	// The old slug still finds the post.
+	post, err := m.GetPostBySlug(context.Background(), "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello-world", post.Slug)
+
+	taken, err := m.GetTakenSlugs(context.Background(), "hello", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hello", "hello-world"}, taken)
+
+	taken, err = m.GetTakenSlugs(context.Background(), "hello", id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, taken)
+
+	// Taking a slug back removes it from the history.
+	_, err = m.UpdatePost(context.Background(), models.Post{ID: id, Slug: "hello", Title: "Hello", Content: "Content", Version: 2})
+	assert.NoError(t, err)
+
+	post, err = m.GetPostBySlug(context.Background(), "hello-world")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", post.Slug)
+
+	_, err = m.GetPostBySlug(context.Background(), "missing")
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func TestBulkPosts(t *testing.T) {
+	m := newTestMemory()
+
+	id, err := m.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	// A failing operation in atomic mode undoes the others.
+	results, err := m.BulkPosts(context.Background(), []models.BulkOperation{
+		{Op: models.BulkCreate, Post: models.Post{Title: "Bulk", Content: "Content"}},
+		{Op: models.BulkUpdate, Post: models.Post{ID: id, Version: 5, Title: "Stale", Content: "Content"}},
+	}, true)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(results[0].Err, apperr.ErrAborted))
+	assert.True(t, errors.Is(results[1].Err, apperr.ErrPreconditionFailed))
+
+	all, err := m.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	// In partial mode the failing operation does not affect the others.
+	results, err = m.BulkPosts(context.Background(), []models.BulkOperation{
+		{Op: models.BulkCreate, Post: models.Post{Title: "Bulk", Content: "Content"}},
+		{Op: models.BulkUpdate, Post: models.Post{ID: id + 100, Title: "Missing", Content: "Content"}},
+		{Op: models.BulkUpdate, Post: models.Post{ID: id, Version: 1, Title: "Updated", Content: "Content"}},
+		{Op: models.BulkDelete, Post: models.Post{ID: id}},
+	}, false)
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "Bulk", results[0].Post.Title)
+	assert.True(t, errors.Is(results[1].Err, apperr.ErrNotFound))
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, 2, results[2].Post.Version)
+	assert.NoError(t, results[3].Err)
+	assert.Nil(t, results[3].Post)
+
+	all, err = m.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, results[0].ID, all[0].ID)
+}
+
+func TestPublishScheduledPosts(t *testing.T) {
+	m := newTestMemory()
+
+	due := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := due.Add(time.Hour)
+
+	dueID, err := m.AddPost(context.Background(), models.Post{Title: "Due", Content: "Content", Status: models.StatusScheduled, PublishAt: &due})
+	assert.NoError(t, err)
+
+	_, err = m.AddPost(context.Background(), models.Post{Title: "Later", Content: "Content", Status: models.StatusScheduled, PublishAt: &later})
+	assert.NoError(t, err)
+
+	next, err := m.GetNextPublishAt(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, due, next)
+
+	published, err := m.PublishScheduledPosts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), published)
+
+	post, err := m.GetPost(context.Background(), dueID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusPublished, post.Status)
+	assert.Equal(t, 2, post.Version)
+	assert.Equal(t, due, *post.PublishAt)
+
+	next, err = m.GetNextPublishAt(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, later, next)
+}
+
+func TestUsers(t *testing.T) {
+	m := newTestMemory()
+
+	id, err := m.AddUser(context.Background(), models.User{Username: "gopher", PasswordHash: "hash", Roles: []string{"author", "viewer"}})
+	assert.NoError(t, err)
+
+	_, err = m.AddUser(context.Background(), models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	_, err = m.AddUser(context.Background(), models.User{Username: "other", PasswordHash: "hash", Roles: []string{"missing"}})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	user, err := m.GetUserByUsername(context.Background(), "gopher")
+	assert.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Nil(t, user.Roles)
+
+	err = m.AssignRole(context.Background(), id, "editor")
+	assert.NoError(t, err)
+
+	err = m.RevokeRole(context.Background(), id, "viewer")
+	assert.NoError(t, err)
+
+	roles, err := m.GetUserRoles(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"author", "editor"}, roles)
+
+	err = m.AddRefreshToken(context.Background(), models.RefreshToken{TokenHash: "token", UserID: id, ExpiresAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.NoError(t, err)
+
+	userID, err := m.ConsumeRefreshToken(context.Background(), "token")
+	assert.NoError(t, err)
+	assert.Equal(t, id, userID)
+
+	// Refresh tokens can be used only once.
+	_, err = m.ConsumeRefreshToken(context.Background(), "token")
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func TestAPIKeys(t *testing.T) {
+	m := newTestMemory()
+
+	userID, err := m.AddUser(context.Background(), models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	expired := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	live, err := m.AddAPIKey(context.Background(), models.APIKey{UserID: userID, Name: "live", KeyHash: "live", Scopes: []string{"posts:read"}})
+	assert.NoError(t, err)
+
+	_, err = m.AddAPIKey(context.Background(), models.APIKey{UserID: userID, Name: "expired", KeyHash: "expired", Scopes: []string{"posts:read"}, ExpiresAt: &expired})
+	assert.NoError(t, err)
+
+	key, err := m.UseAPIKey(context.Background(), "live")
+	assert.NoError(t, err)
+	assert.Equal(t, live, key.ID)
+	assert.NotNil(t, key.LastUsedAt)
+
+	_, err = m.UseAPIKey(context.Background(), "expired")
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	keys, err := m.GetAPIKeys(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, "expired", keys[0].Name)
+
+	err = m.RevokeAPIKey(context.Background(), live, userID)
+	assert.NoError(t, err)
+
+	err = m.RevokeAPIKey(context.Background(), live, userID)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	_, err = m.UseAPIKey(context.Background(), "live")
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func TestComments(t *testing.T) {
+	m := newTestMemory()
+
+	postID, err := m.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	_, err = m.AddComment(context.Background(), models.Comment{PostID: postID + 1, Content: "Comment"})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	parent, err := m.AddComment(context.Background(), models.Comment{PostID: postID, Content: "Parent"})
+	assert.NoError(t, err)
+
+	reply, err := m.AddComment(context.Background(), models.Comment{PostID: postID, ParentID: &parent, Content: "Reply"})
+	assert.NoError(t, err)
+
+	_, err = m.AddComment(context.Background(), models.Comment{PostID: postID, ParentID: &reply, Content: "Nested"})
+	assert.NoError(t, err)
+
+	other, err := m.AddComment(context.Background(), models.Comment{PostID: postID, Content: "Other"})
+	assert.NoError(t, err)
+
+	err = m.UpdateComment(context.Background(), models.Comment{ID: other, PostID: postID + 1, Content: "Moved"})
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	err = m.UpdateComment(context.Background(), models.Comment{ID: other, PostID: postID, Content: "Edited"})
+	assert.NoError(t, err)
+
+	// Deleting a comment deletes every reply below it.
+	err = m.DeleteComment(context.Background(), postID, parent)
+	assert.NoError(t, err)
+
+	comments, err := m.GetComments(context.Background(), postID)
+	assert.NoError(t, err)
+	assert.Len(t, comments, 1)
+	assert.Equal(t, "Edited", comments[0].Content)
+	assert.True(t, comments[0].UpdatedAt.After(comments[0].CreatedAt))
+}
+
+func TestAttachments(t *testing.T) {
+	m := newTestMemory()
+
+	postID, err := m.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	id, err := m.AddAttachment(context.Background(), models.Attachment{PostID: postID, Filename: "a.txt", StorageKey: "1/a"})
+	assert.NoError(t, err)
+
+	_, err = m.AddAttachment(context.Background(), models.Attachment{PostID: postID, Filename: "b.txt", StorageKey: "1/a"})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	// Attachments are only deleted once detached.
+	err = m.DeleteAttachment(context.Background(), id)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	err = m.DetachAttachment(context.Background(), postID, id)
+	assert.NoError(t, err)
+
+	attachments, err := m.GetAttachments(context.Background(), postID)
+	assert.NoError(t, err)
+	assert.Len(t, attachments, 0)
+
+	err = m.DeleteAttachment(context.Background(), id)
+	assert.NoError(t, err)
+
+	detached, err := m.GetDetachedAttachments(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, detached, 0)
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	m := NewMemory()
+
+	id, err := m.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	updated := 0
+
+	// Writers racing on the same version: exactly one of them wins.
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := m.UpdatePost(context.Background(), models.Post{ID: id, Title: "Updated", Content: "Content", Version: 1})
+			if err == nil {
+				mu.Lock()
+				updated++
+				mu.Unlock()
+			}
+
+			_, err = m.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1, updated)
+
+	stats, err := m.GetPostsStats(context.Background(), models.PostsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 11, stats.Count)
+}
+
+func TestRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return NewMemory()
+	})
+}
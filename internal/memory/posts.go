@@ -0,0 +1,486 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+)
+
+// AddPost stores a post together with its tags. A post without a status is
+// published, one without a content format is plain text.
+func (m *Memory) AddPost(_ context.Context, post models.Post) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, err := m.data.insertPost(post, m.now(), "error adding post")
+	if err != nil {
+		return 0, err
+	}
+
+	return stored.ID, nil
+}
+
+func (m *Memory) GetAllPosts(_ context.Context, query models.PostsQuery) ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.data.selectPosts(query, false), nil
+}
+
+// GetTrash lists soft-deleted posts in the same order as GetAllPosts.
+func (m *Memory) GetTrash(_ context.Context, query models.PostsQuery) ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.data.selectPosts(query, true), nil
+}
+
+// GetPostsStats summarises the posts outside the trash matching the filters
+// of query. Its limit and cursor are ignored.
+func (m *Memory) GetPostsStats(_ context.Context, query models.PostsQuery) (models.PostsStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := models.PostsStats{LastModified: time.Unix(0, 0).UTC()}
+	matches := m.data.postMatcher(query, false)
+
+	for _, post := range m.data.posts {
+		if !matches(post) {
+			continue
+		}
+
+		stats.Count++
+		if post.UpdatedAt.After(stats.LastModified) {
+			stats.LastModified = post.UpdatedAt
+		}
+	}
+
+	return stats, nil
+}
+
+// UpdatePost stores the slug, title, content, category, status, publication
+// time and tags of post and bumps its version, but only if the stored version
+// still equals post.Version. An empty slug, content format or status keeps
+// the current one.
+func (m *Memory) UpdatePost(_ context.Context, post models.Post) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Versions start at 1, so a zero version matches no post instead of any.
+	if post.Version == 0 {
+		return 0, m.data.versionMismatchError(post.ID, "error updating post")
+	}
+
+	stored, err := m.data.updatePost(post, post.Version, m.now(), "error updating post")
+	if err != nil {
+		return 0, err
+	}
+
+	return stored.ID, nil
+}
+
+func (m *Memory) GetPost(_ context.Context, id int) (models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	post, ok := m.data.posts[id]
+	if !ok || post.DeletedAt != nil {
+		return models.Post{}, notFound("post", "error getting post")
+	}
+
+	return copyPost(post), nil
+}
+
+// GetPostForUpdate returns the post with the given id like GetPost does. It is
+// used for reads a write depends on.
+func (m *Memory) GetPostForUpdate(ctx context.Context, id int) (models.Post, error) {
+	return m.GetPost(ctx, id)
+}
+
+// GetTrashedPost returns a post that is in the trash.
+func (m *Memory) GetTrashedPost(_ context.Context, id int) (models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	post, ok := m.data.posts[id]
+	if !ok || post.DeletedAt == nil {
+		return models.Post{}, notFound("post", "error getting trashed post")
+	}
+
+	return copyPost(post), nil
+}
+
+// GetPostsByIDs returns the posts with the given ids that are not in the
+// trash, in no particular order.
+func (m *Memory) GetPostsByIDs(_ context.Context, ids []int) ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := []models.Post{}
+
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+
+	for _, id := range slices.Compact(ids) {
+		post, ok := m.data.posts[id]
+		if ok && post.DeletedAt == nil {
+			posts = append(posts, copyPost(post))
+		}
+	}
+
+	return posts, nil
+}
+
+// DeletePost moves the post with the given id to the trash. A non-zero version
+// makes the delete conditional on the stored version.
+func (m *Memory) DeletePost(_ context.Context, id int, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.data.deletePost(id, version, m.now(), "error deleting post")
+
+	return err
+}
+
+// RestorePost takes a post out of the trash.
+func (m *Memory) RestorePost(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.data.posts[id]
+	if !ok || post.DeletedAt == nil {
+		return notFound("post", "error restoring post")
+	}
+
+	post.DeletedAt = nil
+	post.UpdatedAt = m.now()
+	m.data.posts[id] = post
+
+	return nil
+}
+
+// PurgePost permanently deletes a post that is in the trash.
+func (m *Memory) PurgePost(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.data.posts[id]
+	if !ok || post.DeletedAt == nil {
+		return notFound("post", "error purging post")
+	}
+
+	m.data.removePost(id)
+
+	return nil
+}
+
+// PurgeTrash permanently deletes posts trashed before the given time and
+// returns how many were removed.
+func (m *Memory) PurgeTrash(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+
+	for id, post := range m.data.posts {
+		if post.DeletedAt != nil && post.DeletedAt.Before(before) {
+			m.data.removePost(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// insertPost stores a new post the way the posts table and its triggers do.
+func (d *data) insertPost(post models.Post, now time.Time, op string) (models.Post, error) {
+	stored := models.Post{
+		Slug:          post.Slug,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: cmp.Or(post.ContentFormat, models.FormatPlain),
+		ContentHTML:   post.ContentHTML,
+		Version:       1,
+		AuthorID:      intPtr(post.AuthorID),
+		CategoryID:    intPtr(post.CategoryID),
+		Tags:          tagSet(post.Tags),
+		Status:        cmp.Or(post.Status, models.StatusPublished),
+		PublishAt:     dbTimePtr(post.PublishAt),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if post.AuthorID != nil {
+		if _, ok := d.users[*post.AuthorID]; !ok {
+			return models.Post{}, missingReference("post", op)
+		}
+	}
+
+	err := d.checkPost(stored, op)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	stored.ID = d.next("posts")
+
+	if stored.Slug == "" {
+		stored.Slug = fmt.Sprintf("post-%d", stored.ID)
+	}
+
+	err = d.checkSlug(stored, op)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	d.savePost(stored, models.Post{})
+
+	return copyPost(stored), nil
+}
+
+// updatePost changes a post outside the trash the way an update of the posts
+// table and its triggers do. A zero version updates any version.
+func (d *data) updatePost(post models.Post, version int, now time.Time, op string) (models.Post, error) {
+	current, ok := d.posts[post.ID]
+	if !ok || current.DeletedAt != nil || (version != 0 && current.Version != version) {
+		return models.Post{}, d.versionMismatchError(post.ID, op)
+	}
+
+	stored := current
+	stored.Slug = cmp.Or(post.Slug, current.Slug)
+	stored.Title = post.Title
+	stored.Content = post.Content
+	stored.ContentFormat = cmp.Or(post.ContentFormat, current.ContentFormat)
+	stored.ContentHTML = post.ContentHTML
+	stored.CategoryID = intPtr(post.CategoryID)
+	stored.Status = cmp.Or(post.Status, current.Status)
+	stored.PublishAt = dbTimePtr(post.PublishAt)
+	stored.Tags = tagSet(post.Tags)
+	stored.Version++
+	stored.UpdatedAt = now
+
+	err := d.checkPost(stored, op)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = d.checkSlug(stored, op)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	d.savePost(stored, current)
+
+	return copyPost(stored), nil
+}
+
+// deletePost moves a post to the trash. A zero version deletes any version.
+func (d *data) deletePost(id int, version int, now time.Time, op string) (models.Post, error) {
+	post, ok := d.posts[id]
+	if !ok || post.DeletedAt != nil || (version != 0 && post.Version != version) {
+		return models.Post{}, d.versionMismatchError(id, op)
+	}
+
+	post.DeletedAt = &now
+	post.UpdatedAt = now
+	d.posts[id] = post
+
+	return copyPost(post), nil
+}
+
+// checkPost enforces the column types, check constraints and foreign keys of
+// the posts table.
+func (d *data) checkPost(post models.Post, op string) error {
+	err := checkLength("post", op, 255, post.Title)
+	if err != nil {
+		return err
+	}
+
+	err = checkLength("post", op, 100, post.Slug)
+	if err != nil {
+		return err
+	}
+
+	switch post.ContentFormat {
+	case models.FormatPlain, models.FormatMarkdown, models.FormatHTML:
+	default:
+		return invalid("post", op, errCheck)
+	}
+
+	switch post.Status {
+	case models.StatusDraft, models.StatusPublished, models.StatusArchived:
+	case models.StatusScheduled:
+		if post.PublishAt == nil {
+			return invalid("post", op, errCheck)
+		}
+	default:
+		return invalid("post", op, errCheck)
+	}
+
+	for _, tag := range post.Tags {
+		err = checkLength("tag", op, 50, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	if post.CategoryID != nil {
+		if _, ok := d.categories[*post.CategoryID]; !ok {
+			return missingReference("post", op)
+		}
+	}
+
+	return nil
+}
+
+// checkSlug enforces the unique index on post slugs.
+func (d *data) checkSlug(post models.Post, op string) error {
+	for id, other := range d.posts {
+		if id != post.ID && other.Slug == post.Slug {
+			return duplicate("post", op)
+		}
+	}
+
+	return nil
+}
+
+// savePost stores post, which was current before, keeping the slug history
+// and the revisions like the triggers of the posts table. current is the
+// zero post for new posts.
+func (d *data) savePost(post models.Post, current models.Post) {
+	if post.Status == models.StatusPublished && post.PublishAt == nil {
+		publishAt := post.UpdatedAt
+		post.PublishAt = &publishAt
+	}
+
+	if current.ID != 0 && post.Slug != current.Slug {
+		delete(d.postSlugs, post.Slug)
+		d.postSlugs[current.Slug] = postSlug{PostID: post.ID, CreatedAt: post.UpdatedAt}
+	}
+
+	d.posts[post.ID] = post
+
+	if post.Version != current.Version {
+		d.addRevision(post)
+	}
+}
+
+// removePost deletes a post and everything that belongs to it. Its
+// attachments are detached.
+func (d *data) removePost(id int) {
+	delete(d.posts, id)
+
+	for slug, old := range d.postSlugs {
+		if old.PostID == id {
+			delete(d.postSlugs, slug)
+		}
+	}
+
+	for key := range d.revisions {
+		if key.PostID == id {
+			delete(d.revisions, key)
+		}
+	}
+
+	for commentID, comment := range d.comments {
+		if comment.PostID == id {
+			delete(d.comments, commentID)
+		}
+	}
+
+	for attachmentID, attachment := range d.attachments {
+		if attachment.PostID == id {
+			attachment.PostID = 0
+			d.attachments[attachmentID] = attachment
+		}
+	}
+}
+
+// versionMismatchError explains why a conditional write changed nothing: the
+// post is either gone or was changed by someone else in the meantime.
+func (d *data) versionMismatchError(id int, op string) error {
+	post, ok := d.posts[id]
+	if !ok || post.DeletedAt != nil {
+		return notFound("post", op)
+	}
+
+	return apperr.PreconditionFailed("post has been modified", fmt.Errorf("%s: version mismatch", op))
+}
+
+// selectPosts returns one page of the posts in or outside the trash matching
+// query, ordered by creation time and id.
+func (d *data) selectPosts(query models.PostsQuery, trashed bool) []models.Post {
+	matches := d.postMatcher(query, trashed)
+
+	posts := []models.Post{}
+
+	for _, post := range d.posts {
+		if matches(post) && (query.After == nil || after(post, *query.After)) {
+			posts = append(posts, post)
+		}
+	}
+
+	slices.SortFunc(posts, comparePosts)
+
+	if len(posts) > query.Limit {
+		posts = posts[:query.Limit]
+	}
+
+	for i := range posts {
+		posts[i] = copyPost(posts[i])
+	}
+
+	return posts
+}
+
+// postMatcher returns a function reporting whether a post in or outside the
+// trash matches the filters of query. Its limit and cursor are not looked at.
+func (d *data) postMatcher(query models.PostsQuery, trashed bool) func(models.Post) bool {
+	var categories map[int]bool
+	if query.CategoryID != nil {
+		categories = d.subtree(*query.CategoryID)
+	}
+
+	return func(post models.Post) bool {
+		switch {
+		case (post.DeletedAt != nil) != trashed:
+		case query.AuthorID != nil && (post.AuthorID == nil || *post.AuthorID != *query.AuthorID):
+		case query.CategoryID != nil && (post.CategoryID == nil || !categories[*post.CategoryID]):
+		case query.Status != "" && post.Status != query.Status:
+		case !matchesTags(post.Tags, query.PostsFilter):
+		default:
+			return true
+		}
+
+		return false
+	}
+}
+
+// after reports whether post comes after cursor in the (created_at, id)
+// order.
+func after(post models.Post, cursor models.Cursor) bool {
+	if post.CreatedAt.Equal(cursor.CreatedAt) {
+		return post.ID > cursor.ID
+	}
+
+	return post.CreatedAt.After(cursor.CreatedAt)
+}
+
+func comparePosts(a, b models.Post) int {
+	return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+}
+
+// copyPost returns post without memory shared with the stored one.
+func copyPost(post models.Post) models.Post {
+	post.AuthorID = intPtr(post.AuthorID)
+	post.CategoryID = intPtr(post.CategoryID)
+	post.PublishAt = dbTimePtr(post.PublishAt)
+	post.DeletedAt = dbTimePtr(post.DeletedAt)
+	post.Tags = slices.Clone(post.Tags)
+
+	return post
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"slices"
+)
+
+// GetRolePermissions returns the permissions of every role. Roles without
+// permissions are included with an empty list.
+func (m *Memory) GetRolePermissions(_ context.Context) (map[string][]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	permissions := make(map[string][]string, len(m.data.roles))
+	for role, granted := range m.data.roles {
+		permissions[role] = append([]string{}, granted...)
+		slices.Sort(permissions[role])
+	}
+
+	return permissions, nil
+}
+
+// GetUserRoles returns the roles granted to a user, sorted by name.
+func (m *Memory) GetUserRoles(_ context.Context, userID int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	roles := []string{}
+
+	for granted := range m.data.userRoles {
+		if granted.UserID == userID {
+			roles = append(roles, granted.Role)
+		}
+	}
+
+	slices.Sort(roles)
+
+	return roles, nil
+}
+
+// AssignRole grants role to a user. Granting a role twice is not an error.
+func (m *Memory) AssignRole(_ context.Context, userID int, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, userExists := m.data.users[userID]
+	_, roleExists := m.data.roles[role]
+	if !userExists || !roleExists {
+		return missingReference("role", "error assigning role")
+	}
+
+	m.data.userRoles[userRole{UserID: userID, Role: role}] = true
+
+	return nil
+}
+
+// RevokeRole takes role away from a user. Revoking a role the user does not
+// have is not an error.
+func (m *Memory) RevokeRole(_ context.Context, userID int, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.data.userRoles, userRole{UserID: userID, Role: role})
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// GetRevisions lists the revisions of a post, newest first. Revisions are
+// recorded whenever a post gets a new version.
+func (m *Memory) GetRevisions(_ context.Context, postID int) ([]models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := []models.Revision{}
+
+	for key, revision := range m.data.revisions {
+		if key.PostID == postID {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	slices.SortFunc(revisions, func(a, b models.Revision) int {
+		return cmp.Compare(b.Version, a.Version)
+	})
+
+	return revisions, nil
+}
+
+func (m *Memory) GetRevision(_ context.Context, postID int, version int) (models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revision, ok := m.data.revisions[revisionKey{PostID: postID, Version: version}]
+	if !ok {
+		return models.Revision{}, notFound("revision", "error getting revision")
+	}
+
+	return revision, nil
+}
+
+// addRevision records the current version of post unless it already has a
+// revision.
+func (d *data) addRevision(post models.Post) {
+	key := revisionKey{PostID: post.ID, Version: post.Version}
+	if _, ok := d.revisions[key]; ok {
+		return
+	}
+
+	d.revisions[key] = models.Revision{
+		PostID:        post.ID,
+		Version:       post.Version,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: post.ContentFormat,
+		CreatedAt:     post.UpdatedAt,
+	}
+}
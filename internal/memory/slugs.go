@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// GetPostBySlug returns the post that has slug now or had it before. Callers
+// tell the two apart by comparing slug with the Slug of the result.
+func (m *Memory) GetPostBySlug(_ context.Context, slug string) (models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, post := range m.data.posts {
+		if post.DeletedAt == nil && post.Slug == slug {
+			return copyPost(post), nil
+		}
+	}
+
+	if old, ok := m.data.postSlugs[slug]; ok {
+		post, ok := m.data.posts[old.PostID]
+		if ok && post.DeletedAt == nil {
+			return copyPost(post), nil
+		}
+	}
+
+	return models.Post{}, notFound("post", "error getting post by slug")
+}
+
+// GetTakenSlugs lists the slugs equal to base or of the form base-suffix that
+// are used, now or before, by posts other than postID.
+func (m *Memory) GetTakenSlugs(_ context.Context, base string, postID int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	taken := func(slug string) bool {
+		return slug == base || strings.HasPrefix(slug, base+"-")
+	}
+
+	slugs := []string{}
+
+	for id, post := range m.data.posts {
+		if id != postID && taken(post.Slug) {
+			slugs = append(slugs, post.Slug)
+		}
+	}
+
+	for slug, old := range m.data.postSlugs {
+		if old.PostID != postID && taken(slug) {
+			slugs = append(slugs, slug)
+		}
+	}
+
+	slices.Sort(slugs)
+
+	return slices.Compact(slugs), nil
+}
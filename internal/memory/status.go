@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// PublishScheduledPosts publishes the scheduled posts outside the trash whose
+// publication time has come, as a new version, and returns how many there
+// were.
+func (m *Memory) PublishScheduledPosts(_ context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	var published int64
+
+	for _, post := range m.data.posts {
+		if post.Status != models.StatusScheduled || post.DeletedAt != nil || post.PublishAt.After(now) {
+			continue
+		}
+
+		current := post
+		post.Status = models.StatusPublished
+		post.Version++
+		post.UpdatedAt = now
+
+		m.data.savePost(post, current)
+		published++
+	}
+
+	return published, nil
+}
+
+// GetNextPublishAt returns the earliest publication time of the scheduled
+// posts outside the trash, or the zero time if there are none.
+func (m *Memory) GetNextPublishAt(_ context.Context) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var next time.Time
+
+	for _, post := range m.data.posts {
+		if post.Status != models.StatusScheduled || post.DeletedAt != nil {
+			continue
+		}
+
+		if next.IsZero() || post.PublishAt.Before(next) {
+			next = *post.PublishAt
+		}
+	}
+
+	return next, nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// GetTags lists the tags used by published posts outside the trash, most used
+// first.
+func (m *Memory) GetTags(_ context.Context) ([]models.Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+
+	for _, post := range m.data.posts {
+		if post.DeletedAt != nil || post.Status != models.StatusPublished {
+			continue
+		}
+
+		for _, tag := range post.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]models.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, models.Tag{Name: name, Count: count})
+	}
+
+	slices.SortFunc(tags, func(a, b models.Tag) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name))
+	})
+
+	return tags, nil
+}
+
+// tagSet returns tags sorted by name without duplicates, the way they are
+// loaded back from the tags table.
+func tagSet(tags []string) []string {
+	set := append([]string{}, tags...)
+	slices.Sort(set)
+
+	return slices.Compact(set)
+}
+
+// matchesTags reports whether tags contain any, or with AllTags all, of the
+// tags of filter.
+func matchesTags(tags []string, filter models.PostsFilter) bool {
+	if len(filter.Tags) == 0 {
+		return true
+	}
+
+	for _, tag := range filter.Tags {
+		found := slices.Contains(tags, tag)
+		if found && !filter.AllTags {
+			return true
+		}
+		if !found && filter.AllTags {
+			return false
+		}
+	}
+
+	return filter.AllTags
+}
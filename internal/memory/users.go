@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// AddUser stores a user together with its roles.
+func (m *Memory) AddUser(_ context.Context, user models.User) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := checkLength("user", "error adding user", 50, user.Username)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, other := range m.data.users {
+		if other.Username == user.Username {
+			return 0, duplicate("user", "error adding user")
+		}
+	}
+
+	for _, role := range user.Roles {
+		if _, ok := m.data.roles[role]; !ok {
+			return 0, missingReference("user", "error adding user roles")
+		}
+	}
+
+	stored := models.User{
+		ID:           m.data.next("users"),
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		CreatedAt:    m.now(),
+	}
+	m.data.users[stored.ID] = stored
+
+	for _, role := range user.Roles {
+		m.data.userRoles[userRole{UserID: stored.ID, Role: role}] = true
+	}
+
+	return stored.ID, nil
+}
+
+func (m *Memory) GetUser(_ context.Context, id int) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return models.User{}, notFound("user", "error getting user")
+	}
+
+	return user, nil
+}
+
+func (m *Memory) GetUserByUsername(_ context.Context, username string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.data.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+
+	return models.User{}, notFound("user", "error getting user")
+}
+
+func (m *Memory) AddRefreshToken(_ context.Context, token models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.refreshTokens[token.TokenHash]; ok {
+		return duplicate("refresh token", "error adding refresh token")
+	}
+
+	if _, ok := m.data.users[token.UserID]; !ok {
+		return missingReference("refresh token", "error adding refresh token")
+	}
+
+	token.ExpiresAt = dbTime(token.ExpiresAt)
+	m.data.refreshTokens[token.TokenHash] = refreshToken{RefreshToken: token}
+
+	return nil
+}
+
+// ConsumeRefreshToken revokes a live refresh token and returns the id of the
+// user it belongs to. Each refresh token can be used only once.
+func (m *Memory) ConsumeRefreshToken(_ context.Context, tokenHash string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	token, ok := m.data.refreshTokens[tokenHash]
+	if !ok || token.RevokedAt != nil || !token.ExpiresAt.After(now) {
+		return 0, notFound("refresh token", "error consuming refresh token")
+	}
+
+	token.RevokedAt = &now
+	m.data.refreshTokens[tokenHash] = token
+
+	return token.UserID, nil
+}
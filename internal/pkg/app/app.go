@@ -1,57 +1,386 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
 	_ "github.com/rostis232/prmv/docs"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/cache"
 	"github.com/rostis232/prmv/internal/handler"
+	"github.com/rostis232/prmv/internal/memory"
+	"github.com/rostis232/prmv/internal/pkg/storage"
+	"github.com/rostis232/prmv/internal/policy"
 	"github.com/rostis232/prmv/internal/postgres"
 	"github.com/rostis232/prmv/internal/service"
+	"github.com/rostis232/prmv/internal/sqlite"
+	"github.com/rostis232/prmv/models"
 	_ "github.com/swaggo/echo-swagger"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
+// Config holds everything NewApp needs to wire the application.
+type Config struct {
+	// DatabaseURL selects where data is kept by its scheme:
+	// "postgres://..." or "postgresql://..." for PostgreSQL, "sqlite://path"
+	// for a SQLite database file, or "memory://", which needs no database and
+	// loses everything on exit. A connection string without a scheme, such as
+	// "host=... dbname=...", is for PostgreSQL.
+	DatabaseURL string
+	// RequestTimeout bounds the context of every request, so slow queries are
+	// cancelled instead of piling up. Zero disables the deadline.
+	RequestTimeout time.Duration
+	// TrashRetention is how long deleted posts stay in the trash before they
+	// are purged. Zero keeps them forever.
+	TrashRetention time.Duration
+	// JWTSecret signs access tokens. It must be set.
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// TrustedUserHeader, when set, names a header carrying the caller's user
+	// id, set by a trusted proxy. It is checked before bearer tokens.
+	// TrustedRolesHeader optionally carries the caller's roles.
+	TrustedUserHeader  string
+	TrustedRolesHeader string
+	// AttachmentsDir is the directory attachment files are kept in. Replicas
+	// must share it.
+	AttachmentsDir string
+	// MaxAttachmentSize is the size in bytes of the largest file that can be
+	// attached to a post.
+	MaxAttachmentSize int64
+	// CacheSize is how many posts are kept in memory to answer reads by id.
+	// Zero disables the cache. CacheTTL is the longest a post is served from
+	// the cache; zero keeps posts until they are changed or evicted. With
+	// PostgreSQL posts changed by other replicas are dropped from the cache
+	// as soon as the database announces the change, otherwise CacheTTL bounds
+	// how stale they can be.
+	CacheSize int
+	CacheTTL  time.Duration
+}
+
+const (
+	// trashPurgeInterval is how often the trash is checked for expired posts.
+	trashPurgeInterval = time.Hour
+	// publishInterval is the longest the scheduler waits before looking for
+	// due posts again, which picks up posts scheduled by other replicas.
+	publishInterval = time.Minute
+	// multipartOverhead is room in upload bodies for the multipart framing
+	// around the file.
+	multipartOverhead = 64 << 10
+	// cacheStatsInterval is how often the statistics of the post cache are
+	// logged.
+	cacheStatsInterval = 5 * time.Minute
+)
+
+// repository is what the app needs from a storage backend: the service's
+// repository and the role permissions the policy is built from.
+type repository interface {
+	service.Repository
+	GetRolePermissions(ctx context.Context) (map[string][]string, error)
+}
+
 type App struct {
 	Server  *echo.Echo
 	Handler *handler.Handler
 	Service *service.Service
+	// Cache is the cache of posts in front of the repository, whose Stats
+	// tell how well it works. Run logs them every cacheStatsInterval. It is
+	// nil if Config.CacheSize is zero.
+	Cache *cache.Cache
+	// Listener announces the changes to posts made by any replica while the
+	// app runs. Subscribe to it before calling Run to miss none. It is nil
+	// unless the data is kept in PostgreSQL.
+	Listener *postgres.Listener
+	config   Config
 }
 
-func NewApp(pgConfig string) (*App, error) {
-	var a App
+func NewApp(cfg Config) (*App, error) {
+	a := App{config: cfg}
+
+	if cfg.JWTSecret == "" {
+		return nil, fmt.Errorf("app: JWT secret is not set")
+	}
 
-	pg, err := postgres.NewPostgres(pgConfig)
+	repo, err := openRepository(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("app: failed to connect to postgres: %w", err)
+		return nil, err
 	}
 
-	err = pg.Migrate()
+	rolePermissions, err := repo.GetRolePermissions(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("failed to migrate postgres schema: %w", err)
+		return nil, fmt.Errorf("failed to load role permissions: %w", err)
+	}
+
+	if _, ok := repo.(*postgres.Postgres); ok {
+		a.Listener = postgres.NewListener(cfg.DatabaseURL)
+	}
+
+	var postRepo service.Repository = repo
+	if cfg.CacheSize > 0 {
+		a.Cache = cache.NewCache(repo, cfg.CacheSize, cfg.CacheTTL)
+		postRepo = a.Cache
+	}
+
+	files, err := storage.NewLocal(cfg.AttachmentsDir)
+	if err != nil {
+		return nil, fmt.Errorf("app: failed to open attachment storage: %w", err)
 	}
 
 	a.Server = echo.New()
-	a.Service = service.NewService(pg)
-	a.Handler = handler.NewHandler(a.Service)
+	a.Service = service.NewService(postRepo, auth.NewTokens(auth.Config{
+		Secret:     []byte(cfg.JWTSecret),
+		AccessTTL:  cfg.AccessTokenTTL,
+		RefreshTTL: cfg.RefreshTokenTTL,
+	}), policy.New(rolePermissions))
+	a.Service.SetStorage(files, cfg.MaxAttachmentSize)
+	a.Handler = handler.NewHandler(a.Service, identityExtractors(cfg, a.Service)...)
 	a.Server.Use(middleware.Logger())
 	a.Server.Use(middleware.Recover())
+	if cfg.RequestTimeout > 0 {
+		a.Server.Use(middleware.ContextTimeout(cfg.RequestTimeout))
+	}
+
+	identify := a.Handler.IdentifyUser
+	requireUser := a.Handler.RequireUser
+	can := a.Handler.RequirePermission
 
 	//endpoints
 	a.Server.Any("/", a.Handler.Home)
-	a.Server.POST("/posts", a.Handler.AddPost)
-	a.Server.GET("/posts", a.Handler.GetAllPosts)
-	a.Server.PUT("/posts/:id", a.Handler.UpdatePost)
-	a.Server.GET("/posts/:id", a.Handler.GetPost)
-	a.Server.DELETE("/posts/:id", a.Handler.DeletePost)
+	a.Server.POST("/auth/register", a.Handler.Register)
+	a.Server.POST("/auth/login", a.Handler.Login)
+	a.Server.POST("/auth/refresh", a.Handler.Refresh)
+	a.Server.POST("/posts", a.Handler.AddPost, requireUser, can(policy.PostsCreate))
+	a.Server.GET("/posts", a.Handler.GetAllPosts, identify)
+	a.Server.POST("/posts/bulk", a.Handler.BulkPosts, requireUser)
+	a.Server.PUT("/posts/:id", a.Handler.UpdatePost, requireUser, can(policy.PostsUpdate))
+	a.Server.PATCH("/posts/:id", a.Handler.PatchPost, requireUser, can(policy.PostsUpdate))
+	a.Server.GET("/posts/:id", a.Handler.GetPost, identify)
+	a.Server.GET("/posts/by-slug/:slug", a.Handler.GetPostBySlug, identify)
+	a.Server.DELETE("/posts/:id", a.Handler.DeletePost, requireUser, can(policy.PostsDelete))
+	a.Server.POST("/posts/:id/restore", a.Handler.RestorePost, requireUser, can(policy.PostsDelete))
+	a.Server.GET("/posts/:id/revisions", a.Handler.GetRevisions, identify)
+	a.Server.GET("/posts/:id/revisions/:rev", a.Handler.GetRevision, identify)
+	a.Server.GET("/posts/:id/revisions/:rev/diff", a.Handler.DiffRevisions, identify)
+	a.Server.POST("/posts/:id/revisions/:rev/revert", a.Handler.RevertPost, requireUser, can(policy.PostsUpdate))
+	a.Server.GET("/posts/:id/comments", a.Handler.GetComments, identify)
+	a.Server.POST("/posts/:id/comments", a.Handler.AddComment, requireUser, can(policy.CommentsCreate))
+	a.Server.PUT("/posts/:id/comments/:comment", a.Handler.UpdateComment, requireUser, can(policy.CommentsUpdate))
+	a.Server.DELETE("/posts/:id/comments/:comment", a.Handler.DeleteComment, requireUser, can(policy.CommentsDelete))
+	a.Server.GET("/posts/:id/attachments", a.Handler.GetAttachments, identify)
+	a.Server.POST("/posts/:id/attachments", a.Handler.AddAttachment, requireUser, can(policy.PostsUpdate),
+		middleware.BodyLimit(fmt.Sprintf("%dB", cfg.MaxAttachmentSize+multipartOverhead)))
+	a.Server.GET("/posts/:id/attachments/:attachment", a.Handler.GetAttachment, identify)
+	a.Server.DELETE("/posts/:id/attachments/:attachment", a.Handler.DeleteAttachment, requireUser, can(policy.PostsUpdate))
+	a.Server.GET("/tags", a.Handler.GetTags)
+	a.Server.GET("/categories", a.Handler.GetCategories)
+	a.Server.POST("/categories", a.Handler.AddCategory, requireUser, can(policy.CategoriesManage))
+	a.Server.GET("/categories/:slug/posts", a.Handler.GetCategoryPosts)
+	a.Server.GET("/trash", a.Handler.GetTrash, requireUser, can(policy.PostsDelete))
+	a.Server.DELETE("/trash/:id", a.Handler.PurgePost, requireUser, can(policy.PostsDelete))
+	a.Server.GET("/roles", a.Handler.GetRoles, requireUser, can(policy.RolesAssign))
+	a.Server.GET("/users/:id/roles", a.Handler.GetUserRoles, requireUser, can(policy.RolesAssign))
+	a.Server.PUT("/users/:id/roles/:role", a.Handler.AssignRole, requireUser, can(policy.RolesAssign))
+	a.Server.DELETE("/users/:id/roles/:role", a.Handler.RevokeRole, requireUser, can(policy.RolesAssign))
+	a.Server.POST("/api-keys", a.Handler.CreateAPIKey, requireUser)
+	a.Server.GET("/api-keys", a.Handler.GetAPIKeys, requireUser)
+	a.Server.DELETE("/api-keys/:id", a.Handler.RevokeAPIKey, requireUser)
 	//swagger
 	a.Server.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	return &a, nil
 }
 
+// openRepository connects to the repository selected by cfg.DatabaseURL and
+// brings its schema up to date.
+func openRepository(cfg Config) (repository, error) {
+	scheme, path, found := strings.Cut(cfg.DatabaseURL, "://")
+	if !found {
+		scheme = "postgres"
+	}
+
+	switch scheme {
+	case "postgres", "postgresql":
+		pg, err := postgres.NewPostgres(cfg.DatabaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("app: failed to connect to postgres: %w", err)
+		}
+
+		err = pg.Migrate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate postgres schema: %w", err)
+		}
+
+		return pg, nil
+	case "sqlite":
+		db, err := sqlite.NewSQLite(path)
+		if err != nil {
+			return nil, fmt.Errorf("app: failed to open sqlite database: %w", err)
+		}
+
+		err = db.Migrate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate sqlite schema: %w", err)
+		}
+
+		return db, nil
+	case "memory":
+		log.Warn("using the in-memory repository, data is lost on exit")
+		return memory.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("app: unknown database URL scheme %q", scheme)
+	}
+}
+
+// identityExtractors returns the ways callers are identified, in order.
+func identityExtractors(cfg Config, svc handler.Service) []handler.IdentityExtractor {
+	var extractors []handler.IdentityExtractor
+
+	if cfg.TrustedUserHeader != "" {
+		extractors = append(extractors, handler.TrustedHeaderExtractor{
+			UserHeader:  cfg.TrustedUserHeader,
+			RolesHeader: cfg.TrustedRolesHeader,
+		})
+	}
+
+	return append(extractors, handler.BearerTokenExtractor(svc), handler.APIKeyExtractor(svc))
+}
+
 func (a *App) Run(port string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go a.purgeTrash(ctx, a.config.TrashRetention)
+
+	go a.publishScheduled(ctx)
+
+	if a.Cache != nil {
+		go a.logCacheStats(ctx)
+	}
+
+	if a.Listener != nil {
+		if a.Cache != nil {
+			changes, unsubscribe := a.Listener.Subscribe()
+			defer unsubscribe()
+
+			go a.invalidateCache(ctx, changes)
+		}
+
+		go a.listen(ctx)
+	}
+
 	log.Info("app starting")
 	return a.Server.Start(":" + port)
 }
+
+// purgeTrash periodically deletes posts that have outlived the trash
+// retention, and the files of deleted attachments, until ctx is cancelled.
+func (a *App) purgeTrash(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := a.Service.PurgeTrash(ctx, retention)
+		if err != nil {
+			log.Errorf("error purging trash: %v", err)
+		} else if purged > 0 {
+			log.Infof("purged %d posts from trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishScheduled publishes scheduled posts when they are due until ctx is
+// cancelled. It wakes up at the publication time of the next scheduled post,
+// when this replica schedules a post, and at least every publishInterval.
+// Replicas running it at the same time publish every post once, see
+// Service.PublishScheduledPosts.
+func (a *App) publishScheduled(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-a.Service.Scheduled():
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		wait := publishInterval
+
+		published, next, err := a.Service.PublishScheduledPosts(ctx)
+		if err != nil {
+			log.Errorf("error publishing scheduled posts: %v", err)
+		} else if published > 0 {
+			log.Infof("published %d scheduled posts", published)
+		}
+
+		if !next.IsZero() && time.Until(next) < wait {
+			wait = max(time.Until(next), 0)
+		}
+
+		timer.Reset(wait)
+	}
+}
+
+// listen passes the changes to posts on to the subscribers of a.Listener
+// until ctx is cancelled.
+func (a *App) listen(ctx context.Context) {
+	err := a.Listener.Run(ctx)
+	if err != nil {
+		log.Errorf("error listening for post changes: %v", err)
+	}
+}
+
+// invalidateCache drops the posts changes tells of from a.Cache until ctx is
+// cancelled or changes is closed.
+func (a *App) invalidateCache(ctx context.Context, changes <-chan models.PostChange) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+
+			if change.Op == models.PostsMissed {
+				a.Cache.InvalidateAll()
+			} else {
+				a.Cache.Invalidate(change.ID)
+			}
+		}
+	}
+}
+
+// logCacheStats logs the statistics of a.Cache every cacheStatsInterval until
+// ctx is cancelled.
+func (a *App) logCacheStats(ctx context.Context) {
+	ticker := time.NewTicker(cacheStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats := a.Cache.Stats()
+		log.Infof("post cache: %d hits, %d misses, %d evictions, %d posts cached",
+			stats.Hits, stats.Misses, stats.Evictions, stats.Size)
+	}
+}
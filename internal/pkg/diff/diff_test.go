@@ -0,0 +1,69 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	testCases := []struct {
+		a        string
+		b        string
+		expected string
+	}{
+		{a: "", b: "", expected: ""},
+		{a: "a\nb\n", b: "a\nb", expected: ""},
+		{
+			a:        "",
+			b:        "a\nb\n",
+			expected: "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			a:        "a\nb\n",
+			b:        "",
+			expected: "--- from\n+++ to\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			a:        "a\nb\nc\n",
+			b:        "a\nx\nc\n",
+			expected: "--- from\n+++ to\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:        "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			expected: "--- from\n+++ to\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:        "one\n2\n3\n4\n5\n6\n7\neight\n",
+			expected: "--- from\n+++ to\n@@ -1,8 +1,8 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n",
+		},
+		{
+			a:        "a\nb\n",
+			b:        "a\nb\nc\n",
+			expected: "--- from\n+++ to\n@@ -1,2 +1,3 @@\n a\n b\n+c\n",
+		},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, Unified("from", "to", tc.a, tc.b), fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestUnifiedMinimal(t *testing.T) {
+	a := "a\nb\nc\na\nb\nb\na\n"
+	b := "c\nb\na\nb\na\nc\n"
+
+	// The Myers paper example needs five edits.
+	changes := 0
+	for _, line := range strings.Split(Unified("from", "to", a, b), "\n")[3:] {
+		if strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+") {
+			changes++
+		}
+	}
+
+	assert.Equal(t, 5, changes)
+}
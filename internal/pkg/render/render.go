@@ -0,0 +1,62 @@
+// Package render turns post content into HTML that is safe to embed in a
+// page as is.
+package render
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// paragraphBreak separates paragraphs of plain text: a line break followed by
+// a blank line.
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+)
+
+// Plain escapes text and wraps every paragraph in a p element. Line breaks
+// within a paragraph become br elements.
+func Plain(text string) string {
+	var sb strings.Builder
+
+	text = strings.Trim(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for _, paragraph := range paragraphBreak.Split(text, -1) {
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+
+		sb.WriteString("<p>")
+		sb.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		sb.WriteString("</p>")
+	}
+
+	return sb.String()
+}
+
+// Markdown renders CommonMark with GitHub style tables, strikethrough and
+// autolinks, and sanitizes the result. Raw HTML in the source is dropped.
+func Markdown(source string) (string, error) {
+	var buf bytes.Buffer
+
+	err := markdown.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return Sanitize(buf.String())
+}
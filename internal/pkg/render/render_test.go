@@ -0,0 +1,72 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlain(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{text: "", expected: ""},
+		{text: "Hello, world", expected: "<p>Hello, world</p>"},
+		{text: "First\r\nline\n\n\nSecond", expected: "<p>First<br>\nline</p>\n<p>Second</p>"},
+		{text: "<b>not bold</b> & \"quoted\"", expected: "<p>&lt;b&gt;not bold&lt;/b&gt; &amp; &#34;quoted&#34;</p>"},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, Plain(tc.text), fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{source: "# Title\n\nSome *emphasis* and ~~old~~ text.", expected: "<h1>Title</h1>\n<p>Some <em>emphasis</em> and <del>old</del> text.</p>\n"},
+		{source: "```go\nfmt.Println(\"hi\")\n```", expected: "<pre><code class=\"language-go\">fmt.Println(&#34;hi&#34;)\n</code></pre>\n"},
+		{source: "See https://example.com", expected: "<p>See <a href=\"https://example.com\" rel=\"nofollow\">https://example.com</a></p>\n"},
+		{source: "[click](javascript:alert(1))", expected: "<p><a rel=\"nofollow\">click</a></p>\n"},
+		{source: "Hi <script>alert(1)</script>", expected: "<p>Hi alert(1)</p>\n"},
+		{source: "| a | b |\n|:--|--:|\n| 1 | 2 |", expected: "<table>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td align=\"left\">1</td>\n<td align=\"right\">2</td>\n</tr>\n</tbody>\n</table>\n"},
+	}
+
+	for i, tc := range testCases {
+		rendered, err := Markdown(tc.source)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.expected, rendered, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	testCases := []struct {
+		fragment string
+		expected string
+	}{
+		{fragment: `<p>Hello <strong>world</strong></p>`, expected: `<p>Hello <strong>world</strong></p>`},
+		{fragment: `<p onclick="alert(1)" style="color:red">Hi</p>`, expected: `<p>Hi</p>`},
+		{fragment: `<script>alert(1)</script><style>p{}</style>Text`, expected: `Text`},
+		{fragment: `<div><span>Unwrapped</span></div>`, expected: `Unwrapped`},
+		{fragment: `<a href="https://example.com" target="_blank">Link</a>`, expected: `<a href="https://example.com" rel="nofollow">Link</a>`},
+		{fragment: `<a href="/posts/1">Relative</a>`, expected: `<a href="/posts/1" rel="nofollow">Relative</a>`},
+		{fragment: `<a href=" JavaScript:alert(1)">Bad</a>`, expected: `<a rel="nofollow">Bad</a>`},
+		{fragment: `<a href="jav&#x09;ascript:alert(1)">Bad</a>`, expected: `<a rel="nofollow">Bad</a>`},
+		{fragment: `<img src="https://example.com/a.png" alt="A" onerror="alert(1)">`, expected: `<img src="https://example.com/a.png" alt="A"/>`},
+		{fragment: `<img src="data:image/png;base64,AAAA">`, expected: ``},
+		{fragment: `<code class="language-go evil">x</code>`, expected: `<code>x</code>`},
+		{fragment: `<svg><a href="https://example.com">x</a></svg>`, expected: ``},
+		{fragment: `<!-- comment --><p>Kept</p>`, expected: `<p>Kept</p>`},
+		{fragment: `<p>Unclosed <em>tags`, expected: `<p>Unclosed <em>tags</em></p>`},
+	}
+
+	for i, tc := range testCases {
+		sanitized, err := Sanitize(tc.fragment)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.expected, sanitized, fmt.Sprintf("case %d", i))
+	}
+}
@@ -0,0 +1,209 @@
+package render
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowed maps the elements Sanitize keeps to the attributes they may carry.
+// Other elements are replaced by their content.
+var allowed = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.B:          nil,
+	atom.Blockquote: nil,
+	atom.Br:         nil,
+	atom.Code:       {"class"},
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title"},
+	atom.Li:         nil,
+	atom.Ol:         {"start"},
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.S:          nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"align"},
+	atom.Th:         {"align"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// dropped holds the elements removed together with their content, which is
+// either code or not meant to be shown as text.
+var dropped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Textarea: true,
+	atom.Select:   true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Title:    true,
+}
+
+var (
+	codeLanguage = regexp.MustCompile(`^language-[a-zA-Z0-9_+-]+$`)
+	number       = regexp.MustCompile(`^[0-9]{1,9}$`)
+)
+
+// Sanitize parses an HTML fragment and returns it with only allowed elements
+// and attributes left. Links and images must point to http or https URLs, or
+// be relative; links may also be mailto URLs. Links get rel="nofollow".
+func Sanitize(fragment string) (string, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), body)
+	if err != nil {
+		return "", err
+	}
+
+	root := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	for _, n := range nodes {
+		sanitizeNode(root, n)
+	}
+
+	var buf bytes.Buffer
+	for n := root.FirstChild; n != nil; n = n.NextSibling {
+		err = html.Render(&buf, n)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// sanitizeNode appends the allowed part of n to parent.
+func sanitizeNode(parent *html.Node, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		parent.AppendChild(&html.Node{Type: html.TextNode, Data: n.Data})
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if dropped[n.DataAtom] {
+		return
+	}
+
+	names, ok := allowed[n.DataAtom]
+	if !ok || n.Namespace != "" {
+		sanitizeChildren(parent, n)
+		return
+	}
+
+	if n.DataAtom == atom.Img && !hasAttr(n, "src", safeImageURL) {
+		return
+	}
+
+	element := &html.Node{Type: html.ElementNode, Data: n.Data, DataAtom: n.DataAtom}
+
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && contains(names, attr.Key) && allowedValue(n.DataAtom, attr.Key, attr.Val) {
+			element.Attr = append(element.Attr, html.Attribute{Key: attr.Key, Val: attr.Val})
+		}
+	}
+
+	if n.DataAtom == atom.A {
+		element.Attr = append(element.Attr, html.Attribute{Key: "rel", Val: "nofollow"})
+	}
+
+	parent.AppendChild(element)
+	sanitizeChildren(element, n)
+}
+
+func sanitizeChildren(parent *html.Node, n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sanitizeNode(parent, child)
+	}
+}
+
+// allowedValue checks the values of attributes that could carry code or
+// break the layout.
+func allowedValue(element atom.Atom, key, value string) bool {
+	switch key {
+	case "href":
+		return safeLinkURL(value)
+	case "src":
+		return safeImageURL(value)
+	case "class":
+		return element == atom.Code && codeLanguage.MatchString(value)
+	case "start":
+		return number.MatchString(value)
+	case "align":
+		return value == "left" || value == "center" || value == "right"
+	}
+
+	return true
+}
+
+func safeLinkURL(value string) bool {
+	return safeURL(value, "http", "https", "mailto")
+}
+
+func safeImageURL(value string) bool {
+	return safeURL(value, "http", "https")
+}
+
+// safeURL reports whether value is a non-empty relative URL or an absolute
+// one with one of schemes.
+func safeURL(value string, schemes ...string) bool {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || u.String() == "" {
+		return false
+	}
+
+	if u.Scheme == "" {
+		return !strings.Contains(u.Path, ":")
+	}
+
+	return contains(schemes, strings.ToLower(u.Scheme))
+}
+
+func hasAttr(n *html.Node, key string, valid func(string) bool) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return valid(attr.Val)
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
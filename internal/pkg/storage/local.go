@@ -0,0 +1,129 @@
+// Package storage keeps the files attached to posts.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/rostis232/prmv/internal/apperr"
+)
+
+// Local keeps files in a directory on the local disk. Every replica of the
+// API must see the same directory, e.g. a shared volume.
+type Local struct {
+	dir string
+}
+
+// NewLocal returns storage that keeps files under dir, creating it when it
+// does not exist.
+func NewLocal(dir string) (*Local, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("storage: could not create %s: %w", dir, err)
+	}
+
+	return &Local{dir: dir}, nil
+}
+
+// Put stores the content of r under key and returns the number of bytes
+// written. The file only appears under key once it is complete.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return 0, fmt.Errorf("error storing file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("error storing file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error storing file: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return 0, fmt.Errorf("error storing file: %w", err)
+	}
+
+	return written, nil
+}
+
+// Open returns the file stored under key.
+func (l *Local) Open(_ context.Context, key string) (io.ReadSeekCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, apperr.NotFound("file not found", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	return f, nil
+}
+
+// Delete removes the file stored under key. Deleting a missing file is not
+// an error.
+func (l *Local) Delete(_ context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error deleting file: %w", err)
+	}
+
+	return nil
+}
+
+// path maps key to a file under the storage directory. Keys that would
+// leave it are rejected.
+func (l *Local) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", apperr.Validation("invalid storage key", nil)
+	}
+
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// contextReader stops reading once ctx is done, so abandoned uploads do not
+// keep writing.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	err := r.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
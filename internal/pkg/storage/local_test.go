@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := NewLocal(filepath.Join(dir, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := l.Put(context.Background(), "1/abc", strings.NewReader("Hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), written)
+
+	f, err := l.Open(context.Background(), "1/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.Seek(1, io.SeekStart)
+	assert.NoError(t, err)
+
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "ello", string(content))
+	assert.NoError(t, f.Close())
+
+	// No temporary files are left next to stored ones.
+	entries, err := os.ReadDir(filepath.Join(dir, "files", "1"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.NoError(t, l.Delete(context.Background(), "1/abc"))
+	assert.NoError(t, l.Delete(context.Background(), "1/abc"))
+
+	_, err = l.Open(context.Background(), "1/abc")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestLocalKeys(t *testing.T) {
+	l, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		key  string
+		kind error
+	}{
+		{key: "1/abc"},
+		{key: "abc"},
+		{key: "../abc", kind: apperr.ErrValidation},
+		{key: "1/../../abc", kind: apperr.ErrValidation},
+		{key: "/etc/passwd", kind: apperr.ErrValidation},
+		{key: "", kind: apperr.ErrValidation},
+	}
+
+	for i, tc := range testCases {
+		_, err := l.Put(context.Background(), tc.key, strings.NewReader("Hello"))
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+func TestLocalCancelled(t *testing.T) {
+	l, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = l.Put(ctx, "abc", strings.NewReader("Hello"))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = l.Open(context.Background(), "abc")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+}
@@ -0,0 +1,144 @@
+// Package policy decides what a caller may do based on the roles it holds.
+//
+// Permissions are strings of the form "resource:action" or
+// "resource:action:scope". The scope of actions on owned resources is either
+// "own", for resources the caller created, or "any".
+package policy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+	RoleViewer = "viewer"
+)
+
+const (
+	PostsCreate = "posts:create"
+	PostsUpdate = "posts:update"
+	PostsDelete = "posts:delete"
+	RolesAssign = "roles:assign"
+
+	CommentsCreate = "comments:create"
+	CommentsUpdate = "comments:update"
+	CommentsDelete = "comments:delete"
+
+	CategoriesManage = "categories:manage"
+
+	ScopeOwn = "own"
+	ScopeAny = "any"
+)
+
+// Engine evaluates permissions against a role to permissions mapping. It is
+// safe for concurrent use; the mapping is never modified after New.
+type Engine struct {
+	roles map[string]map[string]bool
+}
+
+// New returns an engine for the given role to permissions mapping, usually
+// loaded from the database.
+func New(rolePermissions map[string][]string) *Engine {
+	e := Engine{roles: make(map[string]map[string]bool, len(rolePermissions))}
+
+	for role, permissions := range rolePermissions {
+		set := make(map[string]bool, len(permissions))
+		for _, permission := range permissions {
+			set[permission] = true
+		}
+		e.roles[role] = set
+	}
+
+	return &e
+}
+
+// Actions lists the actions API keys can be scoped to.
+func Actions() []string {
+	return []string{PostsCreate, PostsUpdate, PostsDelete, RolesAssign, CommentsCreate, CommentsUpdate, CommentsDelete, CategoriesManage}
+}
+
+// IsAction reports whether action is one of Actions.
+func IsAction(action string) bool {
+	for _, a := range Actions() {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows reports whether any role of identity grants permission exactly and,
+// for identities limited by scopes, whether a scope covers it.
+func (e *Engine) Allows(identity models.Identity, permission string) bool {
+	if identity.Scopes != nil && !inScope(identity.Scopes, permission) {
+		return false
+	}
+
+	for _, role := range identity.Roles {
+		if e.roles[role][permission] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsOn reports whether identity may perform action on a resource owned
+// by ownerID, which is nil for resources without an owner.
+func (e *Engine) AllowsOn(identity models.Identity, action string, ownerID *int) bool {
+	if e.Allows(identity, action+":"+ScopeAny) {
+		return true
+	}
+
+	return ownerID != nil && *ownerID == identity.UserID && e.Allows(identity, action+":"+ScopeOwn)
+}
+
+// AllowsSome reports whether identity may perform action on at least some
+// resources: unscoped, on its own ones or on any.
+func (e *Engine) AllowsSome(identity models.Identity, action string) bool {
+	return e.Allows(identity, action) ||
+		e.Allows(identity, action+":"+ScopeOwn) ||
+		e.Allows(identity, action+":"+ScopeAny)
+}
+
+// inScope reports whether permission is one of scopes or a scoped variant of
+// one, e.g. "posts:update:own" for the scope "posts:update".
+func inScope(scopes []string, permission string) bool {
+	for _, scope := range scopes {
+		if permission == scope || strings.HasPrefix(permission, scope+":") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasRole reports whether role is known to the engine.
+func (e *Engine) HasRole(role string) bool {
+	_, ok := e.roles[role]
+	return ok
+}
+
+// Roles lists the known roles with their permissions, sorted by name.
+func (e *Engine) Roles() []models.Role {
+	roles := make([]models.Role, 0, len(e.roles))
+
+	for name, set := range e.roles {
+		role := models.Role{Name: name, Permissions: make([]string, 0, len(set))}
+		for permission := range set {
+			role.Permissions = append(role.Permissions, permission)
+		}
+		sort.Strings(role.Permissions)
+		roles = append(roles, role)
+	}
+
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+	return roles
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// rolePermissions mirrors the roles seeded by the rbac migration.
+var rolePermissions = map[string][]string{
+	RoleAdmin:  {PostsCreate, PostsUpdate + ":any", PostsDelete + ":any", RolesAssign},
+	RoleEditor: {PostsCreate, PostsUpdate + ":own", PostsUpdate + ":any", PostsDelete + ":own"},
+	RoleAuthor: {PostsCreate, PostsUpdate + ":own", PostsDelete + ":own"},
+	RoleViewer: {},
+}
+
+func TestAllowsOn(t *testing.T) {
+	engine := New(rolePermissions)
+
+	own := 1
+	other := 2
+
+	testCases := []struct {
+		roles   []string
+		action  string
+		ownerID *int
+		allowed bool
+	}{
+		{roles: []string{RoleAdmin}, action: PostsUpdate, ownerID: &own, allowed: true},
+		{roles: []string{RoleAdmin}, action: PostsUpdate, ownerID: &other, allowed: true},
+		{roles: []string{RoleAdmin}, action: PostsUpdate, ownerID: nil, allowed: true},
+		{roles: []string{RoleAdmin}, action: PostsDelete, ownerID: &other, allowed: true},
+		{roles: []string{RoleEditor}, action: PostsUpdate, ownerID: &own, allowed: true},
+		{roles: []string{RoleEditor}, action: PostsUpdate, ownerID: &other, allowed: true},
+		{roles: []string{RoleEditor}, action: PostsDelete, ownerID: &own, allowed: true},
+		{roles: []string{RoleEditor}, action: PostsDelete, ownerID: &other, allowed: false},
+		{roles: []string{RoleAuthor}, action: PostsUpdate, ownerID: &own, allowed: true},
+		{roles: []string{RoleAuthor}, action: PostsUpdate, ownerID: &other, allowed: false},
+		{roles: []string{RoleAuthor}, action: PostsUpdate, ownerID: nil, allowed: false},
+		{roles: []string{RoleAuthor}, action: PostsDelete, ownerID: &own, allowed: true},
+		{roles: []string{RoleAuthor}, action: PostsDelete, ownerID: &other, allowed: false},
+		{roles: []string{RoleViewer}, action: PostsUpdate, ownerID: &own, allowed: false},
+		{roles: []string{RoleViewer}, action: PostsDelete, ownerID: &own, allowed: false},
+		{roles: nil, action: PostsUpdate, ownerID: &own, allowed: false},
+		{roles: []string{"unknown"}, action: PostsUpdate, ownerID: &own, allowed: false},
+		{roles: []string{RoleViewer, RoleAuthor}, action: PostsDelete, ownerID: &own, allowed: true},
+	}
+
+	for i, tc := range testCases {
+		identity := models.Identity{UserID: own, Roles: tc.roles}
+		assert.Equal(t, tc.allowed, engine.AllowsOn(identity, tc.action, tc.ownerID), fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestAllowsSome(t *testing.T) {
+	engine := New(rolePermissions)
+
+	roles := []string{RoleAdmin, RoleEditor, RoleAuthor, RoleViewer}
+	actions := []string{PostsCreate, PostsUpdate, PostsDelete, RolesAssign}
+
+	// expected[role] lists the actions the role may perform at least on own posts.
+	expected := map[string][]string{
+		RoleAdmin:  {PostsCreate, PostsUpdate, PostsDelete, RolesAssign},
+		RoleEditor: {PostsCreate, PostsUpdate, PostsDelete},
+		RoleAuthor: {PostsCreate, PostsUpdate, PostsDelete},
+		RoleViewer: {},
+	}
+
+	for _, role := range roles {
+		for _, action := range actions {
+			identity := models.Identity{UserID: 1, Roles: []string{role}}
+			assert.Equal(t, contains(expected[role], action), engine.AllowsSome(identity, action), fmt.Sprintf("%s %s", role, action))
+		}
+	}
+}
+
+func TestRoles(t *testing.T) {
+	engine := New(rolePermissions)
+
+	roles := engine.Roles()
+	assert.Len(t, roles, 4)
+	assert.Equal(t, RoleAdmin, roles[0].Name)
+	assert.Equal(t, []string{PostsCreate, "posts:delete:any", "posts:update:any", RolesAssign}, roles[0].Permissions)
+	assert.Equal(t, RoleViewer, roles[3].Name)
+	assert.Empty(t, roles[3].Permissions)
+
+	assert.True(t, engine.HasRole(RoleViewer))
+	assert.False(t, engine.HasRole("root"))
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestScopes(t *testing.T) {
+	engine := New(rolePermissions)
+
+	own := 1
+
+	testCases := []struct {
+		roles   []string
+		scopes  []string
+		action  string
+		allowed bool
+	}{
+		{roles: []string{RoleAdmin}, scopes: nil, action: RolesAssign, allowed: true},
+		{roles: []string{RoleAdmin}, scopes: []string{PostsCreate}, action: RolesAssign, allowed: false},
+		{roles: []string{RoleAdmin}, scopes: []string{PostsUpdate}, action: PostsUpdate, allowed: true},
+		{roles: []string{RoleAdmin}, scopes: []string{PostsUpdate}, action: PostsDelete, allowed: false},
+		{roles: []string{RoleAuthor}, scopes: []string{RolesAssign}, action: RolesAssign, allowed: false},
+		{roles: []string{RoleAuthor}, scopes: []string{PostsCreate, PostsDelete}, action: PostsDelete, allowed: true},
+		{roles: []string{RoleAuthor}, scopes: []string{}, action: PostsCreate, allowed: false},
+	}
+
+	for i, tc := range testCases {
+		identity := models.Identity{UserID: own, Roles: tc.roles, Scopes: tc.scopes}
+		assert.Equal(t, tc.allowed, engine.AllowsOn(identity, tc.action, &own) || engine.Allows(identity, tc.action), fmt.Sprintf("case %d", i))
+	}
+}
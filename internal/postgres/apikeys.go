@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	apiKeysTable = "api_keys"
+
+	apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at"
+)
+
+// apiKeyRow scans the scopes array, which models.APIKey cannot hold directly.
+type apiKeyRow struct {
+	models.APIKey
+	Scopes pq.StringArray `db:"scopes"`
+}
+
+func (r apiKeyRow) apiKey() models.APIKey {
+	key := r.APIKey
+	key.Scopes = []string(r.Scopes)
+	return key
+}
+
+// AddAPIKey stores a new API key and returns its id.
+func (p *Postgres) AddAPIKey(ctx context.Context, key models.APIKey) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("insert into %s (user_id, name, prefix, key_hash, scopes, expires_at) values ($1, $2, $3, $4, $5, $6) returning id", apiKeysTable)
+
+	err := p.db.QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "API key", "error adding API key")
+	}
+
+	return id, nil
+}
+
+// GetAPIKey returns a key of a user that has not been revoked.
+func (p *Postgres) GetAPIKey(ctx context.Context, id int, userID int) (models.APIKey, error) {
+	var row apiKeyRow
+
+	query := fmt.Sprintf("select %s from %s where id = $1 and user_id = $2 and revoked_at is null", apiKeyColumns, apiKeysTable)
+
+	err := p.db.GetContext(ctx, &row, query, id, userID)
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error getting API key")
+	}
+
+	return row.apiKey(), nil
+}
+
+// GetAPIKeys lists the keys of a user that have not been revoked, newest
+// first. Expired keys are included.
+func (p *Postgres) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	var rows []apiKeyRow
+
+	query := fmt.Sprintf("select %s from %s where user_id = $1 and revoked_at is null order by id desc", apiKeyColumns, apiKeysTable)
+
+	err := p.db.SelectContext(ctx, &rows, query, userID)
+	if err != nil {
+		return nil, wrapError(err, "API key", "error getting API keys")
+	}
+
+	keys := make([]models.APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.apiKey())
+	}
+
+	return keys, nil
+}
+
+// UseAPIKey looks up a live key by the hash of its secret and records that it
+// was used.
+func (p *Postgres) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	var row apiKeyRow
+
+	query := fmt.Sprintf("update %s set last_used_at = now() where key_hash = $1 and revoked_at is null and (expires_at is null or expires_at > now()) returning %s", apiKeysTable, apiKeyColumns)
+
+	err := p.db.GetContext(ctx, &row, query, keyHash)
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error using API key")
+	}
+
+	return row.apiKey(), nil
+}
+
+// RevokeAPIKey revokes a key of a user. Revoked keys stop working at once.
+func (p *Postgres) RevokeAPIKey(ctx context.Context, id int, userID int) error {
+	query := fmt.Sprintf("update %s set revoked_at = now() where id = $1 and user_id = $2 and revoked_at is null", apiKeysTable)
+
+	res, err := p.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return wrapError(err, "API key", "error revoking API key")
+	}
+
+	return expectAffected(res, "API key", "error revoking API key")
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	attachmentsTable = "attachments"
+)
+
+func (p *Postgres) AddAttachment(ctx context.Context, attachment models.Attachment) (int, error) {
+	var id int
+
+	query := fmt.Sprintf(`insert into %s (post_id, uploader_id, filename, content_type, size, storage_key)
+		values ($1, $2, $3, $4, $5, $6) returning id`, attachmentsTable)
+
+	err := p.db.QueryRowContext(ctx, query, attachment.PostID, attachment.UploaderID, attachment.Filename,
+		attachment.ContentType, attachment.Size, attachment.StorageKey).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "attachment", "error adding attachment")
+	}
+
+	return id, nil
+}
+
+// GetAttachments lists the attachments of a post, oldest first.
+func (p *Postgres) GetAttachments(ctx context.Context, postID int) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+
+	query := fmt.Sprintf("select * from %s where post_id = $1 order by id", attachmentsTable)
+
+	err := p.db.SelectContext(ctx, &attachments, query, postID)
+	if err != nil {
+		return attachments, wrapError(err, "attachment", "error getting attachments")
+	}
+
+	return attachments, nil
+}
+
+func (p *Postgres) GetAttachment(ctx context.Context, postID int, id int) (models.Attachment, error) {
+	var attachment models.Attachment
+
+	query := fmt.Sprintf("select * from %s where post_id = $1 and id = $2", attachmentsTable)
+
+	err := p.db.GetContext(ctx, &attachment, query, postID, id)
+	if err != nil {
+		return attachment, wrapError(err, "attachment", "error getting attachment")
+	}
+
+	return attachment, nil
+}
+
+// DetachAttachment unlinks an attachment from its post. Its file is removed
+// later, together with the files of purged posts.
+func (p *Postgres) DetachAttachment(ctx context.Context, postID int, id int) error {
+	query := fmt.Sprintf("update %s set post_id = null where post_id = $1 and id = $2", attachmentsTable)
+
+	res, err := p.db.ExecContext(ctx, query, postID, id)
+	if err != nil {
+		return wrapError(err, "attachment", "error detaching attachment")
+	}
+
+	return expectAffected(res, "attachment", "error detaching attachment")
+}
+
+// GetDetachedAttachments lists attachments that no longer belong to a post,
+// either deleted or left behind by a purged post. Their PostID is zero.
+func (p *Postgres) GetDetachedAttachments(ctx context.Context) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+
+	query := fmt.Sprintf(`select id, uploader_id, filename, content_type, size, storage_key, created_at
+		from %s where post_id is null order by id`, attachmentsTable)
+
+	err := p.db.SelectContext(ctx, &attachments, query)
+	if err != nil {
+		return attachments, wrapError(err, "attachment", "error getting detached attachments")
+	}
+
+	return attachments, nil
+}
+
+// DeleteAttachment deletes a detached attachment once its file is gone.
+func (p *Postgres) DeleteAttachment(ctx context.Context, id int) error {
+	query := fmt.Sprintf("delete from %s where id = $1 and post_id is null", attachmentsTable)
+
+	res, err := p.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError(err, "attachment", "error deleting attachment")
+	}
+
+	return expectAffected(res, "attachment", "error deleting attachment")
+}
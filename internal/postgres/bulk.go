@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+)
+
+// GetPostsByIDs returns the posts with the given ids that are not in the
+// trash, in no particular order.
+func (p *Postgres) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	posts := []models.Post{}
+
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	query, args, err := sqlx.In(fmt.Sprintf("select * from %s where id in (?) and deleted_at is null", postsTable), ids)
+	if err != nil {
+		return posts, wrapError(err, "post", "error getting posts")
+	}
+
+	err = p.db.SelectContext(ctx, &posts, p.db.Rebind(query), args...)
+	if err != nil {
+		return posts, wrapError(err, "post", "error getting posts")
+	}
+
+	err = attachTags(ctx, p.db, posts)
+	if err != nil {
+		return posts, wrapError(err, "post", "error getting posts")
+	}
+
+	return posts, nil
+}
+
+// BulkPosts applies ops in a single transaction and returns one result per
+// operation, in order. When atomic is true the first failing operation rolls
+// the whole transaction back and every other operation is reported as
+// aborted. Otherwise each operation runs in its own savepoint, so failures
+// only undo the failing operation. The returned error is only set when the
+// transaction itself fails.
+func (p *Postgres) BulkPosts(ctx context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error) {
+	results := make([]models.BulkResult, len(ops))
+	for i, op := range ops {
+		results[i] = models.BulkResult{Index: i, Op: op.Op, ID: op.Post.ID}
+	}
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, wrapError(err, "post", "error starting bulk transaction")
+	}
+	defer tx.Rollback()
+
+	for i, op := range ops {
+		if !atomic {
+			_, err = tx.ExecContext(ctx, "savepoint bulk_operation")
+			if err != nil {
+				return nil, wrapError(err, "post", "error creating savepoint")
+			}
+		}
+
+		post, err := applyBulkOperation(ctx, tx, op)
+		if err != nil {
+			results[i].Err = err
+
+			if atomic {
+				return abortBulk(results, i), nil
+			}
+
+			_, err = tx.ExecContext(ctx, "rollback to savepoint bulk_operation")
+			if err != nil {
+				return nil, wrapError(err, "post", "error rolling back to savepoint")
+			}
+			continue
+		}
+
+		results[i].ID = post.ID
+		if op.Op != models.BulkDelete {
+			results[i].Post = &post
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, wrapError(err, "post", "error committing bulk transaction")
+	}
+
+	return results, nil
+}
+
+// abortBulk marks every operation except the failed one as aborted.
+func abortBulk(results []models.BulkResult, failed int) []models.BulkResult {
+	for i := range results {
+		if i == failed {
+			continue
+		}
+		results[i].Post = nil
+		results[i].Err = apperr.Aborted("not applied because another operation failed", nil)
+	}
+
+	return results
+}
+
+func applyBulkOperation(ctx context.Context, tx *sqlx.Tx, op models.BulkOperation) (models.Post, error) {
+	var post models.Post
+
+	switch op.Op {
+	case models.BulkCreate:
+		query := fmt.Sprintf(`insert into %s (slug, title, content, content_format, content_html, author_id, category_id, status, publish_at)
+values ($1, $2, $3, coalesce(nullif($4, ''), 'plain'), $5, $6, $7, coalesce(nullif($8, ''), 'published'), $9) returning *`, postsTable)
+
+		err := tx.GetContext(ctx, &post, query, op.Post.Slug, op.Post.Title, op.Post.Content, op.Post.ContentFormat, op.Post.ContentHTML,
+			op.Post.AuthorID, op.Post.CategoryID, op.Post.Status, op.Post.PublishAt)
+		if err != nil {
+			return post, wrapError(err, "post", "error adding post")
+		}
+	case models.BulkUpdate:
+		query := fmt.Sprintf(`update %s set slug = $1, title = $2, content = $3, content_format = coalesce(nullif($4, ''), content_format), content_html = $5,
+category_id = $6, status = coalesce(nullif($7, ''), status), publish_at = $8, version = version + 1
+where id = $9 and deleted_at is null and ($10 = 0 or version = $10) returning *`, postsTable)
+
+		err := tx.GetContext(ctx, &post, query, op.Post.Slug, op.Post.Title, op.Post.Content, op.Post.ContentFormat, op.Post.ContentHTML,
+			op.Post.CategoryID, op.Post.Status, op.Post.PublishAt, op.Post.ID, op.Post.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return post, versionMismatchError(ctx, tx, op.Post.ID, "error updating post")
+		}
+		if err != nil {
+			return post, wrapError(err, "post", "error updating post")
+		}
+	case models.BulkDelete:
+		query := fmt.Sprintf("update %s set deleted_at = now() where id = $1 and deleted_at is null and ($2 = 0 or version = $2) returning *", postsTable)
+
+		err := tx.GetContext(ctx, &post, query, op.Post.ID, op.Post.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return post, versionMismatchError(ctx, tx, op.Post.ID, "error deleting post")
+		}
+		if err != nil {
+			return post, wrapError(err, "post", "error deleting post")
+		}
+	default:
+		return post, apperr.Validation("unknown operation "+op.Op, nil)
+	}
+
+	if op.Op != models.BulkDelete {
+		err := setPostTags(ctx, tx, post.ID, op.Post.Tags)
+		if err != nil {
+			return post, err
+		}
+	}
+
+	posts := []models.Post{post}
+
+	err := attachTags(ctx, tx, posts)
+	if err != nil {
+		return post, wrapError(err, "post", "error getting post tags")
+	}
+
+	return posts[0], nil
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	commentsTable = "comments"
+)
+
+func (p *Postgres) AddComment(ctx context.Context, comment models.Comment) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("insert into %s (post_id, parent_id, author_id, content) values ($1, $2, $3, $4) returning id", commentsTable)
+
+	err := p.db.QueryRowContext(ctx, query, comment.PostID, comment.ParentID, comment.AuthorID, comment.Content).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "comment", "error adding comment")
+	}
+
+	return id, nil
+}
+
+// GetComments lists every comment on a post, oldest first. Replies come after
+// the comments they answer because ids only grow.
+func (p *Postgres) GetComments(ctx context.Context, postID int) ([]models.Comment, error) {
+	comments := []models.Comment{}
+
+	query := fmt.Sprintf("select * from %s where post_id = $1 order by id", commentsTable)
+
+	err := p.db.SelectContext(ctx, &comments, query, postID)
+	if err != nil {
+		return comments, wrapError(err, "comment", "error getting comments")
+	}
+
+	return comments, nil
+}
+
+func (p *Postgres) GetComment(ctx context.Context, postID int, id int) (models.Comment, error) {
+	var comment models.Comment
+
+	query := fmt.Sprintf("select * from %s where post_id = $1 and id = $2", commentsTable)
+
+	err := p.db.GetContext(ctx, &comment, query, postID, id)
+	if err != nil {
+		return comment, wrapError(err, "comment", "error getting comment")
+	}
+
+	return comment, nil
+}
+
+// UpdateComment stores the content of comment.
+func (p *Postgres) UpdateComment(ctx context.Context, comment models.Comment) error {
+	query := fmt.Sprintf("update %s set content = $1 where post_id = $2 and id = $3", commentsTable)
+
+	res, err := p.db.ExecContext(ctx, query, comment.Content, comment.PostID, comment.ID)
+	if err != nil {
+		return wrapError(err, "comment", "error updating comment")
+	}
+
+	return expectAffected(res, "comment", "error updating comment")
+}
+
+// DeleteComment deletes a comment together with all replies to it.
+func (p *Postgres) DeleteComment(ctx context.Context, postID int, id int) error {
+	query := fmt.Sprintf("delete from %s where post_id = $1 and id = $2", commentsTable)
+
+	res, err := p.db.ExecContext(ctx, query, postID, id)
+	if err != nil {
+		return wrapError(err, "comment", "error deleting comment")
+	}
+
+	return expectAffected(res, "comment", "error deleting comment")
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+	"github.com/rostis232/prmv/internal/apperr"
+)
+
+// wrapError annotates err with op and, when the cause is recognised, tags it
+// with the matching apperr kind. resource names the entity in client-facing
+// messages, e.g. "post not found".
+func wrapError(err error, resource, op string) error {
+	wrapped := fmt.Errorf("%s: %w", op, err)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperr.NotFound(resource+" not found", wrapped)
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) {
+		return apperr.Unavailable("database is unavailable", wrapped)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505":
+			return apperr.Conflict(resource+" already exists", wrapped)
+		case pqErr.Code == "23503":
+			return apperr.Conflict(resource+" references missing data", wrapped)
+		case pqErr.Code.Class() == "22", pqErr.Code == "23502", pqErr.Code == "23514":
+			return apperr.Validation(resource+" data is invalid", wrapped)
+		case pqErr.Code.Class() == "08", pqErr.Code.Class() == "53", pqErr.Code.Class() == "57":
+			return apperr.Unavailable("database is unavailable", wrapped)
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return apperr.Unavailable("database is unavailable", wrapped)
+	}
+
+	return wrapped
+}
@@ -0,0 +1,192 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	// postChangesChannel is the channel changes to posts are announced on, see
+	// the post_changes migration.
+	postChangesChannel = "post_changes"
+	// minReconnectInterval and maxReconnectInterval bound how long the
+	// listener waits before connecting again after losing the connection.
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+	// listenerPingInterval is how often the connection is checked when no
+	// notifications arrive, so that a dead connection is noticed.
+	listenerPingInterval = 90 * time.Second
+	// subscriberBuffer is how many changes a subscriber can fall behind by.
+	subscriberBuffer = 64
+)
+
+// Listener passes the changes to posts that the database announces, whoever
+// made them, on to its subscribers. It is safe for concurrent use.
+type Listener struct {
+	dsn string
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	changes chan models.PostChange
+	// missed is set when a change could not be sent because the subscriber
+	// fell behind.
+	missed bool
+}
+
+// NewListener returns a listener for the database at dsn. It listens once Run
+// is called.
+func NewListener(dsn string) *Listener {
+	return &Listener{
+		dsn:         dsn,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe returns a channel the changes are sent to and a function that ends
+// the subscription, after which the channel is closed. Changes are dropped
+// for subscribers that fall behind; they get a change of kind
+// models.PostsMissed instead once they catch up.
+func (l *Listener) Subscribe() (<-chan models.PostChange, func()) {
+	s := &subscriber{changes: make(chan models.PostChange, subscriberBuffer)}
+
+	l.mu.Lock()
+	l.subscribers[s] = struct{}{}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return s.changes, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			delete(l.subscribers, s)
+			close(s.changes)
+		})
+	}
+}
+
+// Run listens for changes until ctx is cancelled. When the connection is lost
+// it connects again, and as changes made meanwhile are lost, subscribers get
+// a change of kind models.PostsMissed.
+func (l *Listener) Run(ctx context.Context) error {
+	listener := pq.NewListener(l.dsn, minReconnectInterval, maxReconnectInterval, logListenerEvent)
+	defer func() {
+		listener.Close()
+		// Notifications still on their way would keep the listener from
+		// shutting down.
+		for range listener.Notify {
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Listen blocks until it is connected, which Close interrupts.
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	err := listener.Listen(postChangesChannel)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("postgres: could not listen for post changes: %w", err)
+	}
+
+	go ping(ctx, listener)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification, ok := <-listener.Notify:
+			if !ok {
+				return nil
+			}
+
+			// A nil notification is sent after reconnecting.
+			if notification == nil {
+				l.publish(models.PostChange{Op: models.PostsMissed})
+				continue
+			}
+
+			var change models.PostChange
+			err := json.Unmarshal([]byte(notification.Extra), &change)
+			if err != nil {
+				log.Errorf("invalid post change %q: %v", notification.Extra, err)
+				continue
+			}
+
+			l.publish(change)
+		}
+	}
+}
+
+// ping pings the database through listener every listenerPingInterval until
+// ctx is cancelled, so that a connection lost without notice is restored. It
+// runs apart from Run's loop, as the reply to a ping arrives behind the
+// notifications sent before it, which only get through while Run receives
+// them.
+func ping(ctx context.Context, listener *pq.Listener) {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := listener.Ping()
+		if err != nil && ctx.Err() == nil {
+			log.Warnf("error pinging the database for post changes: %v", err)
+		}
+	}
+}
+
+// publish sends change to every subscriber.
+func (l *Listener) publish(change models.PostChange) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for s := range l.subscribers {
+		if s.missed {
+			select {
+			case s.changes <- models.PostChange{Op: models.PostsMissed}:
+				s.missed = false
+			default:
+				continue
+			}
+		}
+
+		select {
+		case s.changes <- change:
+		default:
+			s.missed = true
+		}
+	}
+}
+
+func logListenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventConnected:
+		log.Infof("listening for post changes")
+	case pq.ListenerEventDisconnected:
+		log.Warnf("lost connection listening for post changes: %v", err)
+	case pq.ListenerEventReconnected:
+		log.Infof("listening for post changes again")
+	case pq.ListenerEventConnectionAttemptFailed:
+		log.Errorf("failed to connect to listen for post changes: %v", err)
+	}
+}
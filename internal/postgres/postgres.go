@@ -1,14 +1,20 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/gommon/log"
 	_ "github.com/lib/pq"
+	"github.com/rostis232/prmv/internal/apperr"
 	"github.com/rostis232/prmv/models"
+	"github.com/rostis232/prmv/schema"
+	"time"
 )
 
 const (
@@ -41,9 +47,11 @@ func (p *Postgres) Migrate() error {
 	if err != nil {
 		return fmt.Errorf("postgres: could not instantiate database driver: %w", err)
 	}
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://./schema",
-		"postgres", driver)
+	source, err := iofs.New(schema.Postgres, ".")
+	if err != nil {
+		return fmt.Errorf("postgres: could not open migrations: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
 	if err != nil {
 		return fmt.Errorf("postgres: could not instantiate migrate instance: %w", err)
 	}
@@ -55,61 +63,303 @@ func (p *Postgres) Migrate() error {
 	return nil
 }
 
-func (p *Postgres) AddPost(post models.Post) (int, error) {
+// AddPost stores a post together with its tags. A post without a status is
+// published, one without a content format is plain text.
+func (p *Postgres) AddPost(ctx context.Context, post models.Post) (int, error) {
 	var id int
 
-	query := fmt.Sprintf("insert into %s (title, content) values ($1, $2) returning id", postsTable)
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, wrapError(err, "post", "error adding post")
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`insert into %s (slug, title, content, content_format, content_html, author_id, category_id, status, publish_at)
+values ($1, $2, $3, coalesce(nullif($4, ''), 'plain'), $5, $6, $7, coalesce(nullif($8, ''), 'published'), $9) returning id`, postsTable)
 
-	err := p.db.QueryRow(query, post.Title, post.Content).Scan(&id)
+	err = tx.QueryRowContext(ctx, query, post.Slug, post.Title, post.Content, post.ContentFormat, post.ContentHTML,
+		post.AuthorID, post.CategoryID, post.Status, post.PublishAt).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("error adding post: %w", err)
+		return 0, wrapError(err, "post", "error adding post")
+	}
+
+	if len(post.Tags) > 0 {
+		err = setPostTags(ctx, tx, id, post.Tags)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, wrapError(err, "post", "error adding post")
 	}
 
 	return id, nil
 }
 
-func (p *Postgres) GetAllPosts() ([]models.Post, error) {
-	posts := []models.Post{}
+func (p *Postgres) GetAllPosts(ctx context.Context, postsQuery models.PostsQuery) ([]models.Post, error) {
+	var where whereBuilder
+
+	where.add("deleted_at is null")
+
+	posts, err := p.selectPosts(ctx, postsQuery, &where)
+	if err != nil {
+		return posts, wrapError(err, "post", "error getting all posts")
+	}
 
-	query := fmt.Sprintf("select * from %s", postsTable)
+	return posts, nil
+}
+
+// GetTrash lists soft-deleted posts in the same order as GetAllPosts.
+func (p *Postgres) GetTrash(ctx context.Context, postsQuery models.PostsQuery) ([]models.Post, error) {
+	var where whereBuilder
+
+	where.add("deleted_at is not null")
 
-	err := p.db.Select(&posts, query)
+	posts, err := p.selectPosts(ctx, postsQuery, &where)
 	if err != nil {
-		return posts, fmt.Errorf("error getting all posts: %w", err)
+		return posts, wrapError(err, "post", "error getting trash")
 	}
 
 	return posts, nil
 }
-func (p *Postgres) UpdatePost(post models.Post) (int, error) {
+
+// postsConditions adds the filters of postsQuery to where. Its limit and
+// cursor are left to the caller.
+func postsConditions(where *whereBuilder, postsQuery models.PostsQuery) {
+	if postsQuery.AuthorID != nil {
+		where.add("author_id = ?", *postsQuery.AuthorID)
+	}
+
+	if postsQuery.CategoryID != nil {
+		categoryCondition(where, *postsQuery.CategoryID)
+	}
+
+	if postsQuery.Status != "" {
+		where.add("status = ?", postsQuery.Status)
+	}
+
+	tagsCondition(where, postsQuery.PostsFilter)
+}
+
+// selectPosts runs a keyset-paginated select over posts matching where.
+func (p *Postgres) selectPosts(ctx context.Context, postsQuery models.PostsQuery, where *whereBuilder) ([]models.Post, error) {
+	posts := []models.Post{}
+
+	postsConditions(where, postsQuery)
+
+	if postsQuery.After != nil {
+		where.add("(created_at, id) > (?, ?)", postsQuery.After.CreatedAt, postsQuery.After.ID)
+	}
+
+	query := fmt.Sprintf("select * from %s%s order by created_at, id limit %s", postsTable, where, where.arg(postsQuery.Limit))
+
+	err := p.db.SelectContext(ctx, &posts, query, where.args...)
+	if err != nil {
+		return posts, err
+	}
+
+	return posts, attachTags(ctx, p.db, posts)
+}
+
+// GetPostsStats summarises the posts outside the trash matching the filters
+// of postsQuery. Its limit and cursor are ignored.
+func (p *Postgres) GetPostsStats(ctx context.Context, postsQuery models.PostsQuery) (models.PostsStats, error) {
+	var stats models.PostsStats
+	var where whereBuilder
+
+	where.add("deleted_at is null")
+	postsConditions(&where, postsQuery)
+
+	query := fmt.Sprintf("select count(*) as count, coalesce(max(updated_at), 'epoch') as last_modified from %s%s", postsTable, &where)
+
+	err := p.db.GetContext(ctx, &stats, query, where.args...)
+	if err != nil {
+		return stats, wrapError(err, "post", "error getting posts stats")
+	}
+
+	return stats, nil
+}
+
+// UpdatePost stores the slug, title, content, category, status, publication
+// time and tags of post and bumps its version, but only if the stored version
+// still equals post.Version. An empty slug, content format or status keeps
+// the current one.
+func (p *Postgres) UpdatePost(ctx context.Context, post models.Post) (int, error) {
 	var id int
 
-	query := fmt.Sprintf("update %s set title = $1, content = $2 where id = $3 returning id", postsTable)
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, wrapError(err, "post", "error updating post")
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`update %s set slug = $1, title = $2, content = $3, content_format = coalesce(nullif($4, ''), content_format), content_html = $5,
+category_id = $6, status = coalesce(nullif($7, ''), status), publish_at = $8, version = version + 1
+where id = $9 and version = $10 and deleted_at is null returning id`, postsTable)
 
-	err := p.db.QueryRow(query, post.Title, post.Content, post.ID).Scan(&id)
+	err = tx.QueryRowContext(ctx, query, post.Slug, post.Title, post.Content, post.ContentFormat, post.ContentHTML,
+		post.CategoryID, post.Status, post.PublishAt, post.ID, post.Version).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, versionMismatchError(ctx, tx, post.ID, "error updating post")
+	}
 	if err != nil {
-		return 0, fmt.Errorf("error updating post: %w", err)
+		return 0, wrapError(err, "post", "error updating post")
+	}
+
+	err = setPostTags(ctx, tx, id, post.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, wrapError(err, "post", "error updating post")
 	}
 
 	return id, nil
 }
-func (p *Postgres) GetPost(id int) (models.Post, error) {
+
+func (p *Postgres) GetPost(ctx context.Context, id int) (models.Post, error) {
+	var post models.Post
+
+	query := fmt.Sprintf("select * from %s where id = $1 and deleted_at is null", postsTable)
+
+	err := p.db.GetContext(ctx, &post, query, id)
+	if err != nil {
+		return post, wrapError(err, "post", "error getting post")
+	}
+
+	return post, p.attachPostTags(ctx, &post, "error getting post")
+}
+
+// GetPostForUpdate returns the post with the given id like GetPost does. It is
+// used for reads a write depends on.
+func (p *Postgres) GetPostForUpdate(ctx context.Context, id int) (models.Post, error) {
+	return p.GetPost(ctx, id)
+}
+
+// GetTrashedPost returns a post that is in the trash.
+func (p *Postgres) GetTrashedPost(ctx context.Context, id int) (models.Post, error) {
 	var post models.Post
 
-	query := fmt.Sprintf("select * from %s where id = $1", postsTable)
+	query := fmt.Sprintf("select * from %s where id = $1 and deleted_at is not null", postsTable)
+
+	err := p.db.GetContext(ctx, &post, query, id)
+	if err != nil {
+		return post, wrapError(err, "post", "error getting trashed post")
+	}
+
+	return post, p.attachPostTags(ctx, &post, "error getting trashed post")
+}
+
+// DeletePost moves the post with the given id to the trash. A non-zero version
+// makes the delete conditional on the stored version.
+func (p *Postgres) DeletePost(ctx context.Context, id int, version int) error {
+	query := fmt.Sprintf("update %s set deleted_at = now() where id = $1 and deleted_at is null and ($2 = 0 or version = $2)", postsTable)
+
+	res, err := p.db.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return wrapError(err, "post", "error deleting post")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return wrapError(err, "post", "error deleting post")
+	}
+
+	if affected == 0 {
+		return versionMismatchError(ctx, p.db, id, "error deleting post")
+	}
+
+	return nil
+}
+
+// attachPostTags loads the tags of a single post.
+func (p *Postgres) attachPostTags(ctx context.Context, post *models.Post, op string) error {
+	posts := []models.Post{*post}
+
+	err := attachTags(ctx, p.db, posts)
+	if err != nil {
+		return wrapError(err, "post", op)
+	}
+
+	post.Tags = posts[0].Tags
 
-	err := p.db.Get(&post, query, id)
+	return nil
+}
+
+// versionMismatchError explains why a conditional write touched no rows: the
+// post is either gone or was changed by someone else in the meantime.
+func versionMismatchError(ctx context.Context, q sqlx.QueryerContext, id int, op string) error {
+	var exists bool
+
+	query := fmt.Sprintf("select exists(select 1 from %s where id = $1 and deleted_at is null)", postsTable)
+
+	err := sqlx.GetContext(ctx, q, &exists, query, id)
+	if err != nil {
+		return wrapError(err, "post", op)
+	}
+
+	if !exists {
+		return wrapError(sql.ErrNoRows, "post", op)
+	}
+
+	return apperr.PreconditionFailed("post has been modified", fmt.Errorf("%s: version mismatch", op))
+}
+
+// RestorePost takes a post out of the trash.
+func (p *Postgres) RestorePost(ctx context.Context, id int) error {
+	query := fmt.Sprintf("update %s set deleted_at = null where id = $1 and deleted_at is not null", postsTable)
+
+	res, err := p.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError(err, "post", "error restoring post")
+	}
+
+	return expectAffected(res, "post", "error restoring post")
+}
+
+// PurgePost permanently deletes a post that is in the trash.
+func (p *Postgres) PurgePost(ctx context.Context, id int) error {
+	query := fmt.Sprintf("delete from %s where id = $1 and deleted_at is not null", postsTable)
+
+	res, err := p.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return post, fmt.Errorf("error getting post: %w", err)
+		return wrapError(err, "post", "error purging post")
 	}
 
-	return post, nil
+	return expectAffected(res, "post", "error purging post")
 }
-func (p *Postgres) DeletePost(id int) error {
-	query := fmt.Sprintf("delete from %s where id = $1", postsTable)
 
-	_, err := p.db.Exec(query, id)
+// PurgeTrash permanently deletes posts trashed before the given time and
+// returns how many were removed.
+func (p *Postgres) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf("delete from %s where deleted_at < $1", postsTable)
+
+	res, err := p.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, wrapError(err, "post", "error purging trash")
+	}
+
+	purged, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("error deleting post: %w", err)
+		return 0, wrapError(err, "post", "error purging trash")
+	}
+
+	return purged, nil
+}
+
+// expectAffected turns a statement that matched no rows into a not found error.
+func expectAffected(res sql.Result, resource, op string) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return wrapError(err, resource, op)
+	}
+
+	if affected == 0 {
+		return wrapError(sql.ErrNoRows, resource, op)
 	}
 
 	return nil
@@ -1,17 +1,25 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/repotest"
 	"github.com/rostis232/prmv/models"
 	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 const (
-	testDB = "port=5434 user=gopher password=some_pass dbname=postsdb sslmode=disable"
+	testDB = "port=5434 user=gopher password=some_pass dbname=postsdb sslmode=disable timezone=UTC"
 )
 
 func TestNewPostgres(t *testing.T) {
@@ -21,24 +29,32 @@ func TestNewPostgres(t *testing.T) {
 	}
 }
 
+// migrateOnce recreates the schema of the test database through Migrate
+// once per test run, so the tests see the schema the application runs on.
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
 func prepareTestDB() (*Postgres, error) {
 	p, err := NewPostgres(testDB)
 	if err != nil {
 		return nil, err
 	}
 
-	createQuery := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-    id SERIAL PRIMARY KEY,
-    title VARCHAR(255) NOT NULL,
-    content TEXT NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`, postsTable)
-	_, err = p.db.Exec(createQuery)
-	if err != nil {
-		return nil, err
+	migrateOnce.Do(func() {
+		_, migrateErr = p.db.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public`)
+		if migrateErr != nil {
+			return
+		}
+
+		migrateErr = p.Migrate()
+	})
+	if migrateErr != nil {
+		return nil, migrateErr
 	}
 
-	truncateQuery := fmt.Sprintf(`TRUNCATE TABLE %s`, postsTable)
+	truncateQuery := fmt.Sprintf(`TRUNCATE TABLE %s, %s, %s, %s, %s RESTART IDENTITY CASCADE`, postsTable, usersTable, tagsTable, categoriesTable, attachmentsTable)
 	_, err = p.db.Exec(truncateQuery)
 	if err != nil {
 		return nil, err
@@ -47,195 +63,155 @@ func prepareTestDB() (*Postgres, error) {
 	return p, nil
 }
 
-func TestAddPost(t *testing.T) {
-	p, err := prepareTestDB()
-	if err != nil {
-		t.Error(err)
-	}
-
-	testCases := []struct {
-		post          models.Post
-		errorExpected bool
-	}{
-		{
-			post:          models.Post{Title: "Test Title", Content: "Test Content"},
-			errorExpected: false,
-		},
-		{
-			errorExpected: false,
-		},
-	}
-
-	for i, tc := range testCases {
-		id, err := p.AddPost(tc.post)
-		if tc.errorExpected {
-			assert.Error(t, err, fmt.Sprintf("case %d", i))
-		} else {
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
-			assert.NotZero(t, id, fmt.Sprintf("case %d", i))
-
-			var count int
-			query := fmt.Sprintf("select count(*) from %s where id=$1", postsTable)
-			err = p.db.Get(&count, query, id)
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
-			assert.Equal(t, 1, count, fmt.Sprintf("case %d", i))
-		}
-	}
-}
-
-func TestGetAllPosts(t *testing.T) {
-	testCases := []struct {
-		posts         []models.Post
-		errorExpected bool
-	}{
-		{
-			posts: []models.Post{
-				{Title: "Test Title 1", Content: "Test Content 1"},
-				{Title: "Test Title 2", Content: "Test Content 2"},
-			},
-			errorExpected: false,
-		},
-		{
-			posts:         []models.Post{},
-			errorExpected: false,
-		},
-	}
-
-	for i, tc := range testCases {
+func TestRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
 		p, err := prepareTestDB()
 		if err != nil {
-			t.Error(err)
-		}
-
-		for _, post := range tc.posts {
-			_, err := p.AddPost(post)
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			t.Fatal(err)
 		}
+		t.Cleanup(func() {
+			p.db.Close()
+		})
 
-		allPosts, err := p.GetAllPosts()
-
-		if tc.errorExpected {
-			assert.Error(t, err, fmt.Sprintf("case %d", i))
-		} else {
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
-		}
-		assert.Len(t, allPosts, len(tc.posts), fmt.Sprintf("case %d", i))
-	}
+		return p
+	})
 }
 
-func TestUpdatePost(t *testing.T) {
+func TestWrapError(t *testing.T) {
 	testCases := []struct {
-		post           models.Post
-		updatedTitle   string
-		updatedContent string
-		errorExpected  error
+		err  error
+		kind error
 	}{
-		{
-			post:           models.Post{Title: "Original Title", Content: "Original Content"},
-			updatedTitle:   "Updated Title",
-			updatedContent: "Updated Content",
-			errorExpected:  nil,
-		},
-		{
-			errorExpected: sql.ErrNoRows,
-		},
+		{err: sql.ErrNoRows, kind: apperr.ErrNotFound},
+		{err: &pq.Error{Code: "23505"}, kind: apperr.ErrConflict},
+		{err: &pq.Error{Code: "23503"}, kind: apperr.ErrConflict},
+		{err: &pq.Error{Code: "22001"}, kind: apperr.ErrValidation},
+		{err: &pq.Error{Code: "23502"}, kind: apperr.ErrValidation},
+		{err: &pq.Error{Code: "08006"}, kind: apperr.ErrUnavailable},
+		{err: &pq.Error{Code: "57P01"}, kind: apperr.ErrUnavailable},
+		{err: driver.ErrBadConn, kind: apperr.ErrUnavailable},
+		{err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, kind: apperr.ErrUnavailable},
 	}
 
 	for i, tc := range testCases {
-		p, err := prepareTestDB()
-		if err != nil {
-			t.Error(err)
-		}
-
-		var id int
+		err := wrapError(tc.err, "post", "error getting post")
 
-		if tc.errorExpected == nil {
-			id, err = p.AddPost(tc.post)
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
-		}
-
-		tc.post.ID = id
-		tc.post.Title = tc.updatedTitle
-		tc.post.Content = tc.updatedContent
-		updatedID, err := p.UpdatePost(tc.post)
-
-		if tc.errorExpected != nil {
-			assert.Error(t, err, fmt.Sprintf("case %d", i))
-
-			errorType := errors.Is(err, tc.errorExpected)
-			assert.Equal(t, true, errorType, fmt.Sprintf("case %d", i))
-		} else {
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.True(t, errors.Is(err, tc.kind), fmt.Sprintf("case %d", i))
+		assert.True(t, errors.Is(err, tc.err), fmt.Sprintf("case %d", i))
+	}
 
-			assert.Equal(t, id, updatedID, fmt.Sprintf("case %d", i))
+	err := wrapError(sql.ErrTxDone, "post", "error getting post")
+	_, ok := apperr.Message(err)
+	assert.False(t, ok)
+	assert.Equal(t, "error getting post: sql: transaction has already been committed or rolled back", err.Error())
+}
 
-			updatedPost, err := p.GetPost(id)
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+func TestListener(t *testing.T) {
+	p, err := prepareTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l := NewListener(testDB)
+	changes, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+
+	done := make(chan error)
+	go func() {
+		done <- l.Run(ctx)
+	}()
+
+	// Posts are added until one is announced, as it is not known when the
+	// listener starts listening.
+	var id int
+	for id == 0 {
+		postID, err := p.AddPost(ctx, models.Post{Title: "Title", Content: "Content"})
+		assert.NoError(t, err)
+
+		select {
+		case change := <-changes:
+			assert.Equal(t, models.PostInserted, change.Op)
+			id = change.ID
+		case <-time.After(100 * time.Millisecond):
+		}
 
-			assert.Equal(t, tc.post.Title, updatedPost.Title, fmt.Sprintf("case %d", i))
-			assert.Equal(t, tc.post.Content, updatedPost.Content, fmt.Sprintf("case %d", i))
+		if postID > 50 {
+			t.Fatal("no post change was announced")
 		}
 	}
-}
 
-func TestGetPost(t *testing.T) {
 	testCases := []struct {
-		post          models.Post
-		errorExpected error
+		change func() error
+		op     string
 	}{
 		{
-			post:          models.Post{Title: "Test Title", Content: "Test Content"},
-			errorExpected: nil,
+			change: func() error {
+				_, err := p.UpdatePost(ctx, models.Post{ID: id, Version: 1, Title: "Updated", Content: "Content"})
+				return err
+			},
+			op: models.PostUpdated,
+		},
+		{
+			change: func() error { return p.DeletePost(ctx, id, 0) },
+			op:     models.PostUpdated,
 		},
 		{
-			errorExpected: sql.ErrNoRows,
+			change: func() error { return p.PurgePost(ctx, id) },
+			op:     models.PostDeleted,
 		},
 	}
 
 	for i, tc := range testCases {
-		p, err := prepareTestDB()
-		if err != nil {
-			t.Error(err)
+		err := tc.change()
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		// Skip the posts added while waiting for the listener.
+		for change := range changes {
+			if change.ID == id {
+				assert.Equal(t, tc.op, change.Op, fmt.Sprintf("case %d", i))
+				break
+			}
 		}
+	}
 
-		var id int
-
-		if tc.errorExpected == nil {
-			id, err = p.AddPost(tc.post)
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
-		}
+	cancel()
+	assert.NoError(t, <-done)
+}
 
-		fetchedPost, err := p.GetPost(id)
-		if tc.errorExpected == nil {
-			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+func TestSubscribe(t *testing.T) {
+	l := NewListener(testDB)
 
-			assert.Equal(t, tc.post.Title, fetchedPost.Title, fmt.Sprintf("case %d", i))
-			assert.Equal(t, tc.post.Content, fetchedPost.Content, fmt.Sprintf("case %d", i))
-		} else {
-			assert.Error(t, err, fmt.Sprintf("case %d", i))
+	changes, unsubscribe := l.Subscribe()
+	slow, unsubscribeSlow := l.Subscribe()
 
-			errorType := errors.Is(err, tc.errorExpected)
-			assert.Equal(t, true, errorType, fmt.Sprintf("case %d", i))
-		}
+	for id := 1; id <= subscriberBuffer+1; id++ {
+		l.publish(models.PostChange{ID: id, Op: models.PostInserted})
+		<-changes
 	}
-}
 
-func TestDeletePost(t *testing.T) {
-	p, err := prepareTestDB()
-	if err != nil {
-		t.Error(err)
+	// The slow subscriber gets what fits in its buffer, and that it missed
+	// the rest.
+	for id := 1; id <= subscriberBuffer; id++ {
+		assert.Equal(t, models.PostChange{ID: id, Op: models.PostInserted}, <-slow)
 	}
 
-	post := models.Post{Title: "Test Title", Content: "Test Content"}
-	id, err := p.AddPost(post)
-	assert.NoError(t, err)
+	l.publish(models.PostChange{ID: 100, Op: models.PostUpdated})
+	assert.Equal(t, models.PostChange{ID: 100, Op: models.PostUpdated}, <-changes)
+	assert.Equal(t, models.PostChange{Op: models.PostsMissed}, <-slow)
+	assert.Equal(t, models.PostChange{ID: 100, Op: models.PostUpdated}, <-slow)
+
+	unsubscribeSlow()
+	unsubscribeSlow()
+
+	_, ok := <-slow
+	assert.False(t, ok)
 
-	err = p.DeletePost(id)
-	assert.NoError(t, err)
+	l.publish(models.PostChange{ID: 101, Op: models.PostDeleted})
+	assert.Equal(t, models.PostChange{ID: 101, Op: models.PostDeleted}, <-changes)
 
-	var count int
-	query := fmt.Sprintf("select count(*) from %s where id=$1", postsTable)
-	err = p.db.Get(&count, query, id)
-	assert.NoError(t, err)
-	assert.Equal(t, 0, count)
+	unsubscribe()
 }
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+)
+
+// whereBuilder collects SQL conditions written with ? placeholders and
+// numbers them as $1, $2, ... in the order they were added.
+type whereBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (b *whereBuilder) add(condition string, args ...any) {
+	for _, arg := range args {
+		b.args = append(b.args, arg)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(b.args)), 1)
+	}
+
+	b.conditions = append(b.conditions, condition)
+}
+
+// arg registers a value that is referenced outside the where clause, e.g. in
+// limit, and returns its placeholder.
+func (b *whereBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *whereBuilder) String() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+
+	return " where " + strings.Join(b.conditions, " and ")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the wildcards of a LIKE pattern in s, so it only
+// matches itself.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
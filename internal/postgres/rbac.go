@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	rolePermissionsTable = "role_permissions"
+	userRolesTable       = "user_roles"
+	rolesTable           = "roles"
+)
+
+// GetRolePermissions returns the permissions of every role. Roles without
+// permissions are included with an empty list.
+func (p *Postgres) GetRolePermissions(ctx context.Context) (map[string][]string, error) {
+	var rows []struct {
+		Role       string  `db:"role"`
+		Permission *string `db:"permission"`
+	}
+
+	query := fmt.Sprintf("select r.name as role, rp.permission from %s r left join %s rp on rp.role = r.name order by r.name, rp.permission", rolesTable, rolePermissionsTable)
+
+	err := p.db.SelectContext(ctx, &rows, query)
+	if err != nil {
+		return nil, wrapError(err, "role", "error getting role permissions")
+	}
+
+	permissions := make(map[string][]string)
+	for _, row := range rows {
+		if row.Permission == nil {
+			permissions[row.Role] = []string{}
+			continue
+		}
+		permissions[row.Role] = append(permissions[row.Role], *row.Permission)
+	}
+
+	return permissions, nil
+}
+
+// GetUserRoles returns the roles granted to a user, sorted by name.
+func (p *Postgres) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	roles := []string{}
+
+	query := fmt.Sprintf("select role from %s where user_id = $1 order by role", userRolesTable)
+
+	err := p.db.SelectContext(ctx, &roles, query, userID)
+	if err != nil {
+		return roles, wrapError(err, "role", "error getting user roles")
+	}
+
+	return roles, nil
+}
+
+// AssignRole grants role to a user. Granting a role twice is not an error.
+func (p *Postgres) AssignRole(ctx context.Context, userID int, role string) error {
+	query := fmt.Sprintf("insert into %s (user_id, role) values ($1, $2) on conflict do nothing", userRolesTable)
+
+	_, err := p.db.ExecContext(ctx, query, userID, role)
+	if err != nil {
+		return wrapError(err, "role", "error assigning role")
+	}
+
+	return nil
+}
+
+// RevokeRole takes role away from a user. Revoking a role the user does not
+// have is not an error.
+func (p *Postgres) RevokeRole(ctx context.Context, userID int, role string) error {
+	query := fmt.Sprintf("delete from %s where user_id = $1 and role = $2", userRolesTable)
+
+	_, err := p.db.ExecContext(ctx, query, userID, role)
+	if err != nil {
+		return wrapError(err, "role", "error revoking role")
+	}
+
+	return nil
+}
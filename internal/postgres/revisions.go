@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	postRevisionsTable = "post_revisions"
+)
+
+// GetRevisions lists the revisions of a post, newest first. Revisions are
+// recorded by a trigger on the posts table.
+func (p *Postgres) GetRevisions(ctx context.Context, postID int) ([]models.Revision, error) {
+	revisions := []models.Revision{}
+
+	query := fmt.Sprintf("select * from %s where post_id = $1 order by version desc", postRevisionsTable)
+
+	err := p.db.SelectContext(ctx, &revisions, query, postID)
+	if err != nil {
+		return revisions, wrapError(err, "revision", "error getting revisions")
+	}
+
+	return revisions, nil
+}
+
+func (p *Postgres) GetRevision(ctx context.Context, postID int, version int) (models.Revision, error) {
+	var revision models.Revision
+
+	query := fmt.Sprintf("select * from %s where post_id = $1 and version = $2", postRevisionsTable)
+
+	err := p.db.GetContext(ctx, &revision, query, postID, version)
+	if err != nil {
+		return revision, wrapError(err, "revision", "error getting revision")
+	}
+
+	return revision, nil
+}
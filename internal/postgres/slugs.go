@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	postSlugsTable = "post_slugs"
+)
+
+// GetPostBySlug returns the post that has slug now or had it before. Callers
+// tell the two apart by comparing slug with the Slug of the result.
+func (p *Postgres) GetPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	var post models.Post
+
+	query := fmt.Sprintf(`select * from %s
+where deleted_at is null and (slug = $1 or id = (select post_id from %s where slug = $1))
+order by slug = $1 desc
+limit 1`, postsTable, postSlugsTable)
+
+	err := p.db.GetContext(ctx, &post, query, slug)
+	if err != nil {
+		return post, wrapError(err, "post", "error getting post by slug")
+	}
+
+	return post, p.attachPostTags(ctx, &post, "error getting post by slug")
+}
+
+// GetTakenSlugs lists the slugs equal to base or of the form base-suffix that
+// are used, now or before, by posts other than postID.
+func (p *Postgres) GetTakenSlugs(ctx context.Context, base string, postID int) ([]string, error) {
+	slugs := []string{}
+
+	query := fmt.Sprintf(`select slug from %s where id <> $2 and (slug = $1 or slug like $3)
+union
+select slug from %s where post_id <> $2 and (slug = $1 or slug like $3)`, postsTable, postSlugsTable)
+
+	err := p.db.SelectContext(ctx, &slugs, query, base, postID, escapeLike(base)+"-%")
+	if err != nil {
+		return slugs, wrapError(err, "post", "error getting taken slugs")
+	}
+
+	return slugs, nil
+}
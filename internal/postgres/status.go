@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// PublishScheduledPosts publishes the scheduled posts outside the trash whose
+// publication time has come, as a new version, and returns how many there
+// were. The time is taken from the database clock. Running it concurrently
+// is safe: a post another transaction is publishing is locked, and once that
+// transaction commits the post is no longer scheduled and is skipped, so
+// every post is published exactly once.
+func (p *Postgres) PublishScheduledPosts(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf("update %s set status = $1, version = version + 1 where status = $2 and publish_at <= now() and deleted_at is null", postsTable)
+
+	res, err := p.db.ExecContext(ctx, query, models.StatusPublished, models.StatusScheduled)
+	if err != nil {
+		return 0, wrapError(err, "post", "error publishing scheduled posts")
+	}
+
+	published, err := res.RowsAffected()
+	if err != nil {
+		return 0, wrapError(err, "post", "error publishing scheduled posts")
+	}
+
+	return published, nil
+}
+
+// GetNextPublishAt returns the earliest publication time of the scheduled
+// posts outside the trash, or the zero time if there are none.
+func (p *Postgres) GetNextPublishAt(ctx context.Context) (time.Time, error) {
+	var next sql.NullTime
+
+	query := fmt.Sprintf("select min(publish_at) from %s where status = $1 and deleted_at is null", postsTable)
+
+	err := p.db.GetContext(ctx, &next, query, models.StatusScheduled)
+	if err != nil {
+		return time.Time{}, wrapError(err, "post", "error getting next publication time")
+	}
+
+	return next.Time, nil
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	usersTable         = "users"
+	refreshTokensTable = "refresh_tokens"
+
+	userColumns = "id, username, password_hash, created_at"
+)
+
+// AddUser stores a user together with its roles.
+func (p *Postgres) AddUser(ctx context.Context, user models.User) (int, error) {
+	var id int
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, wrapError(err, "user", "error adding user")
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("insert into %s (username, password_hash) values ($1, $2) returning id", usersTable)
+
+	err = tx.QueryRowContext(ctx, query, user.Username, user.PasswordHash).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "user", "error adding user")
+	}
+
+	if len(user.Roles) > 0 {
+		query = fmt.Sprintf("insert into %s (user_id, role) select $1, unnest($2::text[])", userRolesTable)
+
+		_, err = tx.ExecContext(ctx, query, id, pq.Array(user.Roles))
+		if err != nil {
+			return 0, wrapError(err, "user", "error adding user roles")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, wrapError(err, "user", "error adding user")
+	}
+
+	return id, nil
+}
+
+func (p *Postgres) GetUser(ctx context.Context, id int) (models.User, error) {
+	var user models.User
+
+	query := fmt.Sprintf("select %s from %s where id = $1", userColumns, usersTable)
+
+	err := p.db.GetContext(ctx, &user, query, id)
+	if err != nil {
+		return user, wrapError(err, "user", "error getting user")
+	}
+
+	return user, nil
+}
+
+func (p *Postgres) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+
+	query := fmt.Sprintf("select %s from %s where username = $1", userColumns, usersTable)
+
+	err := p.db.GetContext(ctx, &user, query, username)
+	if err != nil {
+		return user, wrapError(err, "user", "error getting user")
+	}
+
+	return user, nil
+}
+
+func (p *Postgres) AddRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	query := fmt.Sprintf("insert into %s (token_hash, user_id, expires_at) values ($1, $2, $3)", refreshTokensTable)
+
+	_, err := p.db.ExecContext(ctx, query, token.TokenHash, token.UserID, token.ExpiresAt)
+	if err != nil {
+		return wrapError(err, "refresh token", "error adding refresh token")
+	}
+
+	return nil
+}
+
+// ConsumeRefreshToken revokes a live refresh token and returns the id of the
+// user it belongs to. Each refresh token can be used only once.
+func (p *Postgres) ConsumeRefreshToken(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+
+	query := fmt.Sprintf("update %s set revoked_at = now() where token_hash = $1 and revoked_at is null and expires_at > now() returning user_id", refreshTokensTable)
+
+	err := p.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID)
+	if err != nil {
+		return 0, wrapError(err, "refresh token", "error consuming refresh token")
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,181 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// workers is how many goroutines the concurrency tests run at once.
+const workers = 8
+
+// parallel runs fn in workers goroutines and returns what each returned.
+func parallel(fn func(worker int) error) []error {
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// testConcurrentAdds checks that concurrent writers neither lose posts nor
+// trip over tags they create at the same time.
+func testConcurrentAdds(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	ids := make([]int, workers)
+	errs := parallel(func(worker int) error {
+		var err error
+		ids[worker], err = repo.AddPost(ctx, models.Post{Title: fmt.Sprintf("Post %d", worker), Content: "Content",
+			Tags: []string{"shared", fmt.Sprintf("own-%d", worker)}})
+		return err
+	})
+
+	seen := make(map[int]bool)
+	for i, err := range errs {
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.False(t, seen[ids[i]], fmt.Sprintf("case %d", i))
+		seen[ids[i]] = true
+	}
+
+	posts, err := repo.GetAllPosts(ctx, models.PostsQuery{Limit: workers + 1})
+	assert.NoError(t, err)
+	assert.Len(t, posts, workers)
+
+	tags, err := repo.GetTags(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tags, workers+1)
+	assert.Equal(t, models.Tag{Name: "shared", Count: workers}, tags[0])
+}
+
+// testConcurrentUpdates checks that of the writers updating the same version
+// of a post only one succeeds, and the others are told the post has changed.
+func testConcurrentUpdates(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	id, err := repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	errs := parallel(func(worker int) error {
+		_, err := repo.UpdatePost(ctx, models.Post{ID: id, Version: 1, Title: fmt.Sprintf("Update %d", worker), Content: "Content"})
+		return err
+	})
+
+	succeeded := 0
+	for i, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, apperr.ErrPreconditionFailed, fmt.Sprintf("case %d", i))
+	}
+	assert.Equal(t, 1, succeeded)
+
+	post, err := repo.GetPost(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, post.Version)
+
+	revisions, err := repo.GetRevisions(ctx, id)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, post.Title, revisions[0].Title)
+
+	// The same holds for deleting a version.
+	errs = parallel(func(worker int) error {
+		return repo.DeletePost(ctx, id, 2)
+	})
+
+	succeeded = 0
+	for i, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, apperr.ErrNotFound, fmt.Sprintf("case %d", i))
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+// testConcurrentPublishing checks that schedulers running at the same time
+// publish every due post exactly once.
+func testConcurrentPublishing(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	past := time.Now().UTC().Add(-time.Hour)
+
+	var ids []int
+	for i := 0; i < 3; i++ {
+		id, err := repo.AddPost(ctx, models.Post{Title: "Due", Content: "Content", Status: models.StatusScheduled, PublishAt: &past})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	published := make([]int64, workers)
+	errs := parallel(func(worker int) error {
+		var err error
+		published[worker], err = repo.PublishScheduledPosts(ctx)
+		return err
+	})
+
+	var total int64
+	for i, err := range errs {
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		total += published[i]
+	}
+	assert.Equal(t, int64(len(ids)), total)
+
+	for i, id := range ids {
+		post, err := repo.GetPost(ctx, id)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, models.StatusPublished, post.Status, fmt.Sprintf("case %d", i))
+		assert.Equal(t, 2, post.Version, fmt.Sprintf("case %d", i))
+	}
+}
+
+// testBulkPostsChangedAfterLoad checks that bulk writes carrying the version
+// a post was loaded at do not apply once someone else changed the post.
+func testBulkPostsChangedAfterLoad(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	id, err := repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	loaded, err := repo.GetPostsByIDs(ctx, []int{id})
+	assert.NoError(t, err)
+	assert.Len(t, loaded, 1)
+
+	_, err = repo.UpdatePost(ctx, models.Post{ID: id, Version: loaded[0].Version, Title: "Changed", Content: "Content"})
+	assert.NoError(t, err)
+
+	results, err := repo.BulkPosts(ctx, []models.BulkOperation{
+		{Op: models.BulkUpdate, Post: models.Post{ID: id, Version: loaded[0].Version, Title: "Stale", Content: "Content"}},
+		{Op: models.BulkDelete, Post: models.Post{ID: id, Version: loaded[0].Version}},
+	}, false)
+	assert.NoError(t, err)
+	for i, result := range results {
+		assert.ErrorIs(t, result.Err, apperr.ErrPreconditionFailed, fmt.Sprintf("case %d", i))
+	}
+
+	post, err := repo.GetPost(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Changed", post.Title)
+	assert.Equal(t, loaded[0].Version+1, post.Version)
+}
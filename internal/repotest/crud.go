@@ -0,0 +1,114 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPostLifecycle checks that every field of a post survives each step of
+// its life: creation, update, trash, restore and purge.
+func testPostLifecycle(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	authorID, err := repo.AddUser(ctx, models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	categoryID, err := repo.AddCategory(ctx, models.Category{Slug: "go", Name: "Go"})
+	assert.NoError(t, err)
+
+	id, err := repo.AddPost(ctx, models.Post{Slug: "lifecycle", Title: "Title", Content: "# Content",
+		ContentFormat: models.FormatMarkdown, ContentHTML: "<h1>Content</h1>\n", AuthorID: &authorID,
+		CategoryID: &categoryID, Tags: []string{"web", "go"}, Status: models.StatusDraft})
+	assert.NoError(t, err)
+
+	post, err := repo.GetPost(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, id, post.ID)
+	assert.Equal(t, "lifecycle", post.Slug)
+	assert.Equal(t, "Title", post.Title)
+	assert.Equal(t, "# Content", post.Content)
+	assert.Equal(t, models.FormatMarkdown, post.ContentFormat)
+	assert.Equal(t, "<h1>Content</h1>\n", post.ContentHTML)
+	assert.Equal(t, 1, post.Version)
+	assert.Equal(t, &authorID, post.AuthorID)
+	assert.Equal(t, &categoryID, post.CategoryID)
+	assert.Equal(t, []string{"go", "web"}, post.Tags)
+	assert.Equal(t, models.StatusDraft, post.Status)
+	assert.Nil(t, post.PublishAt)
+	assert.Nil(t, post.DeletedAt)
+
+	// Updating replaces what it is given, keeps the author and, without a
+	// slug, the slug.
+	updatedID, err := repo.UpdatePost(ctx, models.Post{ID: id, Version: 1, Title: "New title", Content: "New content",
+		ContentFormat: models.FormatPlain, Tags: []string{"api"}, Status: models.StatusPublished})
+	assert.NoError(t, err)
+	assert.Equal(t, id, updatedID)
+
+	post, err = repo.GetPost(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "lifecycle", post.Slug)
+	assert.Equal(t, "New title", post.Title)
+	assert.Equal(t, "New content", post.Content)
+	assert.Equal(t, models.FormatPlain, post.ContentFormat)
+	assert.Equal(t, "", post.ContentHTML)
+	assert.Equal(t, 2, post.Version)
+	assert.Equal(t, &authorID, post.AuthorID)
+	assert.Nil(t, post.CategoryID)
+	assert.Equal(t, []string{"api"}, post.Tags)
+	assert.Equal(t, models.StatusPublished, post.Status)
+	assert.NotNil(t, post.PublishAt)
+
+	posts, err := repo.GetPostsByIDs(ctx, []int{id})
+	assert.NoError(t, err)
+	assert.Len(t, posts, 1)
+	assert.Equal(t, post.Title, posts[0].Title)
+	assert.Equal(t, post.Tags, posts[0].Tags)
+
+	err = repo.DeletePost(ctx, id, 2)
+	assert.NoError(t, err)
+
+	trashed, err := repo.GetTrashedPost(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "New title", trashed.Title)
+	assert.Equal(t, []string{"api"}, trashed.Tags)
+	assert.Equal(t, 2, trashed.Version)
+	assert.NotNil(t, trashed.DeletedAt)
+
+	posts, err = repo.GetPostsByIDs(ctx, []int{id})
+	assert.NoError(t, err)
+	assert.Empty(t, posts)
+
+	// Trashed posts cannot be changed.
+	_, err = repo.UpdatePost(ctx, models.Post{ID: id, Version: 2, Title: "Trashed", Content: "Content"})
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	err = repo.RestorePost(ctx, id)
+	assert.NoError(t, err)
+
+	post, err = repo.GetPost(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "New title", post.Title)
+	assert.Equal(t, 2, post.Version)
+	assert.Nil(t, post.DeletedAt)
+
+	err = repo.DeletePost(ctx, id, 0)
+	assert.NoError(t, err)
+
+	err = repo.PurgePost(ctx, id)
+	assert.NoError(t, err)
+
+	_, err = repo.GetTrashedPost(ctx, id)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	_, err = repo.GetPostBySlug(ctx, "lifecycle")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	// The slug of a purged post is free again.
+	_, err = repo.AddPost(ctx, models.Post{Slug: "lifecycle", Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,112 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// testNotFound checks that everything looked up by something that does not
+// exist fails with a not found error.
+func testNotFound(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	// A post, a user and a key exist so that lookups by a missing id are not
+	// answered by an empty table alone.
+	postID, err := repo.AddPost(ctx, models.Post{Slug: "post", Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	userID, err := repo.AddUser(ctx, models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	keyID, err := repo.AddAPIKey(ctx, models.APIKey{UserID: userID, Name: "ci", Prefix: "prmv_aaaaaaaa",
+		KeyHash: strings.Repeat("a", 64), Scopes: []string{"posts:create"}})
+	assert.NoError(t, err)
+
+	missing := postID + userID + keyID + 100
+
+	testCases := []func() error{
+		func() error { _, err := repo.GetPost(ctx, missing); return err },
+		func() error { _, err := repo.GetTrashedPost(ctx, missing); return err },
+		func() error { _, err := repo.GetTrashedPost(ctx, postID); return err },
+		func() error { _, err := repo.GetPostBySlug(ctx, "missing"); return err },
+		func() error {
+			_, err := repo.UpdatePost(ctx, models.Post{ID: missing, Version: 1, Title: "Title", Content: "Content"})
+			return err
+		},
+		func() error { return repo.DeletePost(ctx, missing, 0) },
+		func() error { return repo.DeletePost(ctx, missing, 1) },
+		func() error { return repo.RestorePost(ctx, missing) },
+		func() error { return repo.RestorePost(ctx, postID) },
+		func() error { return repo.PurgePost(ctx, missing) },
+		func() error { return repo.PurgePost(ctx, postID) },
+		func() error { _, err := repo.GetRevision(ctx, postID, 2); return err },
+		func() error { _, err := repo.GetRevision(ctx, missing, 1); return err },
+		func() error { _, err := repo.GetUser(ctx, missing); return err },
+		func() error { _, err := repo.GetUserByUsername(ctx, "missing"); return err },
+		func() error { _, err := repo.ConsumeRefreshToken(ctx, strings.Repeat("c", 64)); return err },
+		func() error { _, err := repo.GetAPIKey(ctx, missing, userID); return err },
+		func() error { _, err := repo.UseAPIKey(ctx, strings.Repeat("c", 64)); return err },
+		func() error { return repo.RevokeAPIKey(ctx, missing, userID) },
+		func() error { return repo.RevokeAPIKey(ctx, keyID, missing) },
+		func() error { _, err := repo.GetCategory(ctx, missing); return err },
+		func() error { _, err := repo.GetCategoryBySlug(ctx, "missing"); return err },
+		func() error { _, err := repo.GetComment(ctx, postID, missing); return err },
+		func() error {
+			return repo.UpdateComment(ctx, models.Comment{ID: missing, PostID: postID, Content: "Content"})
+		},
+		func() error { return repo.DeleteComment(ctx, postID, missing) },
+		func() error { _, err := repo.GetAttachment(ctx, postID, missing); return err },
+		func() error { return repo.DetachAttachment(ctx, postID, missing) },
+		func() error { return repo.DeleteAttachment(ctx, missing) },
+	}
+
+	for i, tc := range testCases {
+		err := tc()
+		assert.ErrorIs(t, err, apperr.ErrNotFound, fmt.Sprintf("case %d", i))
+	}
+}
+
+// testEmptyLists checks that lists of nothing are empty rather than nil, so
+// that they are encoded as [] and not null.
+func testEmptyLists(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	postID, err := repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content", Status: models.StatusDraft})
+	assert.NoError(t, err)
+
+	userID, err := repo.AddUser(ctx, models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	testCases := []func() (any, error){
+		func() (any, error) {
+			return repo.GetAllPosts(ctx, models.PostsQuery{Limit: 10, PostsFilter: models.PostsFilter{Status: models.StatusPublished}})
+		},
+		func() (any, error) { return repo.GetTrash(ctx, models.PostsQuery{Limit: 10}) },
+		func() (any, error) { return repo.GetPostsByIDs(ctx, []int{postID + 1}) },
+		func() (any, error) { return repo.GetPostsByIDs(ctx, nil) },
+		func() (any, error) { return repo.GetTakenSlugs(ctx, "missing", 0) },
+		func() (any, error) { return repo.GetRevisions(ctx, postID+1) },
+		func() (any, error) { return repo.GetTags(ctx) },
+		func() (any, error) { return repo.GetCategories(ctx) },
+		func() (any, error) { return repo.GetComments(ctx, postID) },
+		func() (any, error) { return repo.GetAttachments(ctx, postID) },
+		func() (any, error) { return repo.GetDetachedAttachments(ctx) },
+		func() (any, error) { return repo.GetAPIKeys(ctx, userID) },
+		func() (any, error) { return repo.GetUserRoles(ctx, userID) },
+	}
+
+	for i, tc := range testCases {
+		list, err := tc()
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.NotNil(t, list, fmt.Sprintf("case %d", i))
+		assert.Empty(t, list, fmt.Sprintf("case %d", i))
+	}
+}
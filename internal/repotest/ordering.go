@@ -0,0 +1,175 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// testOrder checks the order of every list: posts are listed oldest first,
+// with ties broken by id, and pages continue where the previous one ended.
+func testOrder(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	var ids []int
+
+	id, err := repo.AddPost(ctx, models.Post{Title: "Single", Content: "Content"})
+	assert.NoError(t, err)
+	ids = append(ids, id)
+
+	// Posts created together may share their creation time.
+	ops := make([]models.BulkOperation, 4)
+	for i := range ops {
+		ops[i] = models.BulkOperation{Op: models.BulkCreate, Post: models.Post{Title: fmt.Sprintf("Bulk %d", i), Content: "Content"}}
+	}
+
+	results, err := repo.BulkPosts(ctx, ops, true)
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+		ids = append(ids, result.ID)
+	}
+
+	id, err = repo.AddPost(ctx, models.Post{Title: "Last", Content: "Content"})
+	assert.NoError(t, err)
+	ids = append(ids, id)
+
+	for limit := 1; limit <= len(ids)+1; limit++ {
+		var seen []int
+		query := models.PostsQuery{Limit: limit}
+
+		for page := 0; page <= len(ids); page++ {
+			posts, err := repo.GetAllPosts(ctx, query)
+			assert.NoError(t, err, fmt.Sprintf("case %d", limit))
+			assert.LessOrEqual(t, len(posts), limit, fmt.Sprintf("case %d", limit))
+
+			if len(posts) == 0 {
+				break
+			}
+
+			for _, post := range posts {
+				seen = append(seen, post.ID)
+			}
+
+			last := posts[len(posts)-1]
+			query.After = &models.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+
+		assert.Equal(t, ids, seen, fmt.Sprintf("case %d", limit))
+	}
+
+	// The trash is in the same order, whatever order posts were deleted in.
+	for i := len(ids) - 1; i >= 0; i-- {
+		err = repo.DeletePost(ctx, ids[i], 0)
+		assert.NoError(t, err)
+	}
+
+	trash, err := repo.GetTrash(ctx, models.PostsQuery{Limit: len(ids)})
+	assert.NoError(t, err)
+
+	trashed := []int{}
+	for _, post := range trash {
+		trashed = append(trashed, post.ID)
+	}
+	assert.Equal(t, ids, trashed)
+
+	postID, err := repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content", Tags: []string{"c", "a", "b"}})
+	assert.NoError(t, err)
+
+	_, err = repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content", Tags: []string{"c", "b"}})
+	assert.NoError(t, err)
+
+	_, err = repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content", Tags: []string{"b"}})
+	assert.NoError(t, err)
+
+	// Tags of a post are sorted by name.
+	post, err := repo.GetPost(ctx, postID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, post.Tags)
+
+	// Tags are listed most used first, then by name.
+	tags, err := repo.GetTags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Tag{{Name: "b", Count: 3}, {Name: "c", Count: 2}, {Name: "a", Count: 1}}, tags)
+
+	// Revisions are listed newest first.
+	for version := 1; version <= 3; version++ {
+		_, err = repo.UpdatePost(ctx, models.Post{ID: postID, Version: version, Title: fmt.Sprintf("Version %d", version+1), Content: "Content"})
+		assert.NoError(t, err)
+	}
+
+	revisions, err := repo.GetRevisions(ctx, postID)
+	assert.NoError(t, err)
+
+	versions := []int{}
+	for _, revision := range revisions {
+		versions = append(versions, revision.Version)
+	}
+	assert.Equal(t, []int{4, 3, 2, 1}, versions)
+
+	// Comments are listed oldest first.
+	var commentIDs []int
+	for _, content := range []string{"First", "Second", "Third"} {
+		id, err := repo.AddComment(ctx, models.Comment{PostID: postID, Content: content})
+		assert.NoError(t, err)
+		commentIDs = append(commentIDs, id)
+	}
+
+	comments, err := repo.GetComments(ctx, postID)
+	assert.NoError(t, err)
+
+	listed := []int{}
+	for _, comment := range comments {
+		listed = append(listed, comment.ID)
+	}
+	assert.Equal(t, commentIDs, listed)
+
+	// Categories are listed by name.
+	for _, name := range []string{"Rust", "Go", "Zig"} {
+		_, err := repo.AddCategory(ctx, models.Category{Slug: strings.ToLower(name), Name: name})
+		assert.NoError(t, err)
+	}
+
+	categories, err := repo.GetCategories(ctx)
+	assert.NoError(t, err)
+
+	names := []string{}
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+	assert.Equal(t, []string{"Go", "Rust", "Zig"}, names)
+
+	// API keys are listed newest first.
+	userID, err := repo.AddUser(ctx, models.User{Username: "gopher", PasswordHash: "hash", Roles: []string{"viewer", "author"}})
+	assert.NoError(t, err)
+
+	var keyIDs []int
+	for i, c := range []string{"a", "b", "c"} {
+		id, err := repo.AddAPIKey(ctx, models.APIKey{UserID: userID, Name: fmt.Sprintf("key %d", i), Prefix: "prmv_" + c,
+			KeyHash: strings.Repeat(c, 64), Scopes: []string{"posts:create"}})
+		assert.NoError(t, err)
+		keyIDs = append([]int{id}, keyIDs...)
+	}
+
+	keys, err := repo.GetAPIKeys(ctx, userID)
+	assert.NoError(t, err)
+
+	listed = []int{}
+	for _, key := range keys {
+		listed = append(listed, key.ID)
+	}
+	assert.Equal(t, keyIDs, listed)
+
+	// Roles of a user are sorted by name.
+	err = repo.AssignRole(ctx, userID, "admin")
+	assert.NoError(t, err)
+
+	roles, err := repo.GetUserRoles(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "author", "viewer"}, roles)
+}
@@ -0,0 +1,1041 @@
+// Package repotest is a conformance test suite for implementations of
+// service.Repository, so that every storage backend behaves the same. It
+// covers what is stored and returned, timestamps, not found errors, the order
+// of lists and concurrent use. The suite is internal to this module: it is
+// only meant for the backends in this repository and changes along with
+// service.Repository. A backend runs it from its own tests:
+//
+//	func TestRepository(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repotest.Repository {
+//			return newEmptyRepository(t)
+//		})
+//	}
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/service"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Repository is what the suite tests: the service's repository and the role
+// permissions the policy is built from.
+type Repository interface {
+	service.Repository
+	GetRolePermissions(ctx context.Context) (map[string][]string, error)
+}
+
+// Run runs the suite, each test as a subtest of t. open is called at least
+// once per test and must return a repository without posts, users, tags,
+// categories or attachments, with the admin, author and viewer roles. The
+// repository must be safe for concurrent use, and its clock must tick at
+// least every few milliseconds.
+func Run(t *testing.T, open func(t *testing.T) Repository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, open func(t *testing.T) Repository)
+	}{
+		{name: "AddPost", test: testAddPost},
+		{name: "GetAllPosts", test: testGetAllPosts},
+		{name: "GetAllPostsKeyset", test: testGetAllPostsKeyset},
+		{name: "UpdatePost", test: testUpdatePost},
+		{name: "GetPost", test: testGetPost},
+		{name: "DeletePost", test: testDeletePost},
+		{name: "DeletePostNotFound", test: testDeletePostNotFound},
+		{name: "UpdatePostVersion", test: testUpdatePostVersion},
+		{name: "GetPostsStats", test: testGetPostsStats},
+		{name: "Trash", test: testTrash},
+		{name: "Revisions", test: testRevisions},
+		{name: "Users", test: testUsers},
+		{name: "AddPostAuthor", test: testAddPostAuthor},
+		{name: "Roles", test: testRoles},
+		{name: "APIKeys", test: testAPIKeys},
+		{name: "Comments", test: testComments},
+		{name: "BulkPosts", test: testBulkPosts},
+		{name: "Tags", test: testTags},
+		{name: "Categories", test: testCategories},
+		{name: "Slugs", test: testSlugs},
+		{name: "PostStatus", test: testPostStatus},
+		{name: "ContentFormat", test: testContentFormat},
+		{name: "Attachments", test: testAttachments},
+		{name: "PostLifecycle", test: testPostLifecycle},
+		{name: "Timestamps", test: testTimestamps},
+		{name: "NotFound", test: testNotFound},
+		{name: "EmptyLists", test: testEmptyLists},
+		{name: "Order", test: testOrder},
+		{name: "ConcurrentAdds", test: testConcurrentAdds},
+		{name: "ConcurrentUpdates", test: testConcurrentUpdates},
+		{name: "ConcurrentPublishing", test: testConcurrentPublishing},
+		{name: "BulkPostsChangedAfterLoad", test: testBulkPostsChangedAfterLoad},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, open)
+		})
+	}
+}
+
+func testAddPost(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	testCases := []struct {
+		post          models.Post
+		errorExpected bool
+	}{
+		{
+			post:          models.Post{Title: "Test Title", Content: "Test Content"},
+			errorExpected: false,
+		},
+		{
+			errorExpected: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		id, err := repo.AddPost(context.Background(), tc.post)
+		if tc.errorExpected {
+			assert.Error(t, err, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.NotZero(t, id, fmt.Sprintf("case %d", i))
+
+			post, err := repo.GetPost(context.Background(), id)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, id, post.ID, fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+func testGetAllPosts(t *testing.T, open func(t *testing.T) Repository) {
+	testCases := []struct {
+		posts         []models.Post
+		errorExpected bool
+	}{
+		{
+			posts: []models.Post{
+				{Title: "Test Title 1", Content: "Test Content 1"},
+				{Title: "Test Title 2", Content: "Test Content 2"},
+			},
+			errorExpected: false,
+		},
+		{
+			posts:         []models.Post{},
+			errorExpected: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		repo := open(t)
+
+		for _, post := range tc.posts {
+			_, err := repo.AddPost(context.Background(), post)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		}
+
+		allPosts, err := repo.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10})
+
+		if tc.errorExpected {
+			assert.Error(t, err, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		}
+		assert.Len(t, allPosts, len(tc.posts), fmt.Sprintf("case %d", i))
+	}
+}
+
+func testGetAllPostsKeyset(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	for i := 0; i < 5; i++ {
+		_, err := repo.AddPost(context.Background(), models.Post{Title: fmt.Sprintf("Title %d", i), Content: "Content"})
+		assert.NoError(t, err)
+	}
+
+	var seen []int
+	query := models.PostsQuery{Limit: 2}
+
+	for {
+		page, err := repo.GetAllPosts(context.Background(), query)
+		assert.NoError(t, err)
+
+		if len(page) == 0 {
+			break
+		}
+
+		for _, post := range page {
+			seen = append(seen, post.ID)
+		}
+
+		last := page[len(page)-1]
+		query.After = &models.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	assert.Len(t, seen, 5)
+	for i := 1; i < len(seen); i++ {
+		assert.Less(t, seen[i-1], seen[i])
+	}
+}
+
+func testUpdatePost(t *testing.T, open func(t *testing.T) Repository) {
+	testCases := []struct {
+		post           models.Post
+		updatedTitle   string
+		updatedContent string
+		errorExpected  error
+	}{
+		{
+			post:           models.Post{Title: "Original Title", Content: "Original Content"},
+			updatedTitle:   "Updated Title",
+			updatedContent: "Updated Content",
+			errorExpected:  nil,
+		},
+		{
+			errorExpected: apperr.ErrNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		repo := open(t)
+
+		var (
+			id  int
+			err error
+		)
+
+		if tc.errorExpected == nil {
+			id, err = repo.AddPost(context.Background(), tc.post)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		}
+
+		tc.post.ID = id
+		tc.post.Version = 1
+		tc.post.Title = tc.updatedTitle
+		tc.post.Content = tc.updatedContent
+		updatedID, err := repo.UpdatePost(context.Background(), tc.post)
+
+		if tc.errorExpected != nil {
+			assert.Error(t, err, fmt.Sprintf("case %d", i))
+
+			errorType := errors.Is(err, tc.errorExpected)
+			assert.Equal(t, true, errorType, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+			assert.Equal(t, id, updatedID, fmt.Sprintf("case %d", i))
+
+			updatedPost, err := repo.GetPost(context.Background(), id)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+			assert.Equal(t, tc.post.Title, updatedPost.Title, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.post.Content, updatedPost.Content, fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+func testGetPost(t *testing.T, open func(t *testing.T) Repository) {
+	testCases := []struct {
+		post          models.Post
+		errorExpected error
+	}{
+		{
+			post:          models.Post{Title: "Test Title", Content: "Test Content"},
+			errorExpected: nil,
+		},
+		{
+			errorExpected: apperr.ErrNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		repo := open(t)
+
+		var (
+			id  int
+			err error
+		)
+
+		if tc.errorExpected == nil {
+			id, err = repo.AddPost(context.Background(), tc.post)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		}
+
+		fetchedPost, err := repo.GetPost(context.Background(), id)
+		if tc.errorExpected == nil {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+			assert.Equal(t, tc.post.Title, fetchedPost.Title, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.post.Content, fetchedPost.Content, fmt.Sprintf("case %d", i))
+		} else {
+			assert.Error(t, err, fmt.Sprintf("case %d", i))
+
+			errorType := errors.Is(err, tc.errorExpected)
+			assert.Equal(t, true, errorType, fmt.Sprintf("case %d", i))
+			assert.True(t, errors.Is(err, apperr.ErrNotFound), fmt.Sprintf("case %d", i))
+		}
+
+		// Reads for writes find the same post.
+		forUpdate, err := repo.GetPostForUpdate(context.Background(), id)
+		if tc.errorExpected == nil {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		} else {
+			assert.ErrorIs(t, err, tc.errorExpected, fmt.Sprintf("case %d", i))
+		}
+		assert.Equal(t, fetchedPost, forUpdate, fmt.Sprintf("case %d", i))
+	}
+}
+
+func testDeletePost(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	post := models.Post{Title: "Test Title", Content: "Test Content"}
+	id, err := repo.AddPost(context.Background(), post)
+	assert.NoError(t, err)
+
+	err = repo.DeletePost(context.Background(), id, 0)
+	assert.NoError(t, err)
+
+	trashed, err := repo.GetTrashedPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.NotNil(t, trashed.DeletedAt)
+
+	_, err = repo.GetPost(context.Background(), id)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func testDeletePostNotFound(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	err := repo.DeletePost(context.Background(), 1, 0)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func testUpdatePostVersion(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	id, err := repo.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: id, Title: "First", Content: "Content", Version: 1})
+	assert.NoError(t, err)
+
+	post, err := repo.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, post.Version)
+
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: id, Title: "Stale", Content: "Content", Version: 1})
+	assert.True(t, errors.Is(err, apperr.ErrPreconditionFailed))
+
+	err = repo.DeletePost(context.Background(), id, 1)
+	assert.True(t, errors.Is(err, apperr.ErrPreconditionFailed))
+
+	err = repo.DeletePost(context.Background(), id, 2)
+	assert.NoError(t, err)
+}
+
+// testGetPostsStats checks that stats summarise the posts outside the trash
+// that match the filters of the query.
+func testGetPostsStats(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	stats, err := repo.GetPostsStats(ctx, models.PostsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Count)
+
+	authorID, err := repo.AddUser(ctx, models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	id, err := repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content", Tags: []string{"go"}})
+	assert.NoError(t, err)
+
+	post, err := repo.GetPost(ctx, id)
+	assert.NoError(t, err)
+
+	draftID, err := repo.AddPost(ctx, models.Post{Title: "Draft", Content: "Content", AuthorID: &authorID, Status: models.StatusDraft})
+	assert.NoError(t, err)
+
+	draft, err := repo.GetPost(ctx, draftID)
+	assert.NoError(t, err)
+
+	trashedID, err := repo.AddPost(ctx, models.Post{Title: "Trashed", Content: "Content", Status: models.StatusDraft})
+	assert.NoError(t, err)
+
+	err = repo.DeletePost(ctx, trashedID, 0)
+	assert.NoError(t, err)
+
+	otherID := authorID + 1
+
+	testCases := []struct {
+		query models.PostsQuery
+		count int
+		post  *models.Post
+	}{
+		{query: models.PostsQuery{}, count: 2, post: &draft},
+		{query: models.PostsQuery{PostsFilter: models.PostsFilter{Status: models.StatusPublished}}, count: 1, post: &post},
+		{query: models.PostsQuery{PostsFilter: models.PostsFilter{Status: models.StatusDraft}}, count: 1, post: &draft},
+		{query: models.PostsQuery{AuthorID: &authorID, PostsFilter: models.PostsFilter{Status: models.StatusDraft}}, count: 1, post: &draft},
+		{query: models.PostsQuery{AuthorID: &otherID, PostsFilter: models.PostsFilter{Status: models.StatusDraft}}, count: 0},
+		{query: models.PostsQuery{PostsFilter: models.PostsFilter{Tags: []string{"go"}}}, count: 1, post: &post},
+		// The limit and the cursor are ignored.
+		{query: models.PostsQuery{Limit: 1, After: &models.Cursor{CreatedAt: draft.CreatedAt, ID: draft.ID}}, count: 2, post: &draft},
+	}
+
+	for i, tc := range testCases {
+		stats, err := repo.GetPostsStats(ctx, tc.query)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc.count, stats.Count, fmt.Sprintf("case %d", i))
+
+		if tc.post != nil {
+			assert.True(t, tc.post.UpdatedAt.Equal(stats.LastModified), fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+func testTrash(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	id, err := repo.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	err = repo.RestorePost(context.Background(), id)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	_, err = repo.GetTrashedPost(context.Background(), id)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	err = repo.DeletePost(context.Background(), id, 0)
+	assert.NoError(t, err)
+
+	trashed, err := repo.GetTrashedPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, id, trashed.ID)
+
+	err = repo.DeletePost(context.Background(), id, 0)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	posts, err := repo.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, posts, 0)
+
+	trash, err := repo.GetTrash(context.Background(), models.PostsQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, trash, 1)
+	assert.NotNil(t, trash[0].DeletedAt)
+
+	err = repo.RestorePost(context.Background(), id)
+	assert.NoError(t, err)
+
+	post, err := repo.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Nil(t, post.DeletedAt)
+
+	err = repo.PurgePost(context.Background(), id)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	err = repo.DeletePost(context.Background(), id, 0)
+	assert.NoError(t, err)
+
+	purged, err := repo.PurgeTrash(context.Background(), time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+
+	err = repo.PurgePost(context.Background(), id)
+	assert.NoError(t, err)
+
+	_, err = repo.GetPost(context.Background(), id)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func testRevisions(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	id, err := repo.AddPost(context.Background(), models.Post{Title: "First", Content: "Content", ContentFormat: models.FormatMarkdown})
+	assert.NoError(t, err)
+
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: id, Title: "Second", Content: "Content", ContentFormat: models.FormatHTML, Version: 1})
+	assert.NoError(t, err)
+
+	// Moving to the trash does not create a version.
+	err = repo.DeletePost(context.Background(), id, 0)
+	assert.NoError(t, err)
+
+	revisions, err := repo.GetRevisions(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[0].Version)
+	assert.Equal(t, "Second", revisions[0].Title)
+	assert.Equal(t, models.FormatHTML, revisions[0].ContentFormat)
+	assert.Equal(t, 1, revisions[1].Version)
+	assert.Equal(t, "First", revisions[1].Title)
+	assert.Equal(t, models.FormatMarkdown, revisions[1].ContentFormat)
+
+	revision, err := repo.GetRevision(context.Background(), id, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "First", revision.Title)
+	assert.Equal(t, models.FormatMarkdown, revision.ContentFormat)
+
+	_, err = repo.GetRevision(context.Background(), id, 3)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	err = repo.PurgePost(context.Background(), id)
+	assert.NoError(t, err)
+
+	revisions, err = repo.GetRevisions(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 0)
+}
+
+func testUsers(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	id, err := repo.AddUser(context.Background(), models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	_, err = repo.AddUser(context.Background(), models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	user, err := repo.GetUserByUsername(context.Background(), "gopher")
+	assert.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, "hash", user.PasswordHash)
+
+	_, err = repo.GetUser(context.Background(), id+1)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	hash := strings.Repeat("a", 64)
+	err = repo.AddRefreshToken(context.Background(), models.RefreshToken{TokenHash: hash, UserID: id, ExpiresAt: time.Now().UTC().Add(time.Hour)})
+	assert.NoError(t, err)
+
+	expired := strings.Repeat("b", 64)
+	err = repo.AddRefreshToken(context.Background(), models.RefreshToken{TokenHash: expired, UserID: id, ExpiresAt: time.Now().UTC().Add(-time.Hour)})
+	assert.NoError(t, err)
+
+	userID, err := repo.ConsumeRefreshToken(context.Background(), hash)
+	assert.NoError(t, err)
+	assert.Equal(t, id, userID)
+
+	_, err = repo.ConsumeRefreshToken(context.Background(), hash)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	_, err = repo.ConsumeRefreshToken(context.Background(), expired)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func testAddPostAuthor(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	authorID, err := repo.AddUser(context.Background(), models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	id, err := repo.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content", AuthorID: &authorID})
+	assert.NoError(t, err)
+
+	post, err := repo.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, &authorID, post.AuthorID)
+}
+
+func testRoles(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	permissions, err := repo.GetRolePermissions(context.Background())
+	assert.NoError(t, err)
+	assert.Contains(t, permissions["admin"], "roles:assign")
+	assert.NotContains(t, permissions["viewer"], "roles:assign")
+	assert.Contains(t, permissions, "viewer")
+
+	id, err := repo.AddUser(context.Background(), models.User{Username: "gopher", PasswordHash: "hash", Roles: []string{"author"}})
+	assert.NoError(t, err)
+
+	err = repo.AssignRole(context.Background(), id, "admin")
+	assert.NoError(t, err)
+
+	err = repo.AssignRole(context.Background(), id, "admin")
+	assert.NoError(t, err)
+
+	roles, err := repo.GetUserRoles(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "author"}, roles)
+
+	err = repo.RevokeRole(context.Background(), id, "author")
+	assert.NoError(t, err)
+
+	roles, err = repo.GetUserRoles(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, roles)
+}
+
+func testAPIKeys(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	userID, err := repo.AddUser(context.Background(), models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	hash := strings.Repeat("a", 64)
+	id, err := repo.AddAPIKey(context.Background(), models.APIKey{UserID: userID, Name: "ci", Prefix: "prmv_aaaaaaaa", KeyHash: hash, Scopes: []string{"posts:create"}})
+	assert.NoError(t, err)
+
+	expired := time.Now().UTC().Add(-time.Hour)
+	_, err = repo.AddAPIKey(context.Background(), models.APIKey{UserID: userID, Name: "old", Prefix: "prmv_bbbbbbbb", KeyHash: strings.Repeat("b", 64), Scopes: []string{"posts:create"}, ExpiresAt: &expired})
+	assert.NoError(t, err)
+
+	key, err := repo.GetAPIKey(context.Background(), id, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"posts:create"}, key.Scopes)
+	assert.Nil(t, key.LastUsedAt)
+
+	_, err = repo.GetAPIKey(context.Background(), id, userID+1)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	used, err := repo.UseAPIKey(context.Background(), hash)
+	assert.NoError(t, err)
+	assert.Equal(t, id, used.ID)
+	assert.NotNil(t, used.LastUsedAt)
+
+	_, err = repo.UseAPIKey(context.Background(), strings.Repeat("b", 64))
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	keys, err := repo.GetAPIKeys(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	err = repo.RevokeAPIKey(context.Background(), id, userID)
+	assert.NoError(t, err)
+
+	err = repo.RevokeAPIKey(context.Background(), id, userID)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	_, err = repo.UseAPIKey(context.Background(), hash)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+}
+
+func testComments(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	postID, err := repo.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	parentID, err := repo.AddComment(context.Background(), models.Comment{PostID: postID, Content: "First"})
+	assert.NoError(t, err)
+
+	replyID, err := repo.AddComment(context.Background(), models.Comment{PostID: postID, ParentID: &parentID, Content: "Reply"})
+	assert.NoError(t, err)
+
+	_, err = repo.AddComment(context.Background(), models.Comment{PostID: postID + 1, Content: "Orphan"})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	comments, err := repo.GetComments(context.Background(), postID)
+	assert.NoError(t, err)
+	assert.Len(t, comments, 2)
+	assert.Equal(t, &parentID, comments[1].ParentID)
+
+	err = repo.UpdateComment(context.Background(), models.Comment{ID: replyID, PostID: postID, Content: "Edited"})
+	assert.NoError(t, err)
+
+	reply, err := repo.GetComment(context.Background(), postID, replyID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Edited", reply.Content)
+
+	_, err = repo.GetComment(context.Background(), postID+1, replyID)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	// Deleting a comment deletes the replies to it.
+	err = repo.DeleteComment(context.Background(), postID, parentID)
+	assert.NoError(t, err)
+
+	_, err = repo.GetComment(context.Background(), postID, replyID)
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	// Purging a post deletes its comments.
+	_, err = repo.AddComment(context.Background(), models.Comment{PostID: postID, Content: "Again"})
+	assert.NoError(t, err)
+
+	err = repo.DeletePost(context.Background(), postID, 0)
+	assert.NoError(t, err)
+
+	err = repo.PurgePost(context.Background(), postID)
+	assert.NoError(t, err)
+
+	comments, err = repo.GetComments(context.Background(), postID)
+	assert.NoError(t, err)
+	assert.Empty(t, comments)
+}
+
+func testBulkPosts(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	id, err := repo.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	posts, err := repo.GetPostsByIDs(context.Background(), []int{id, id + 1})
+	assert.NoError(t, err)
+	assert.Len(t, posts, 1)
+
+	// A failing operation in atomic mode rolls back the others.
+	results, err := repo.BulkPosts(context.Background(), []models.BulkOperation{
+		{Op: models.BulkCreate, Post: models.Post{Title: "Bulk", Content: "Content"}},
+		{Op: models.BulkUpdate, Post: models.Post{ID: id, Version: 5, Title: "Stale", Content: "Content"}},
+	}, true)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(results[0].Err, apperr.ErrAborted))
+	assert.True(t, errors.Is(results[1].Err, apperr.ErrPreconditionFailed))
+
+	all, err := repo.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	// In partial mode the failing operation does not affect the others.
+	results, err = repo.BulkPosts(context.Background(), []models.BulkOperation{
+		{Op: models.BulkCreate, Post: models.Post{Title: "Bulk", Content: "Content"}},
+		{Op: models.BulkUpdate, Post: models.Post{ID: id + 100, Title: "Missing", Content: "Content"}},
+		{Op: models.BulkUpdate, Post: models.Post{ID: id, Version: 1, Title: "Updated", Content: "Content"}},
+		{Op: models.BulkDelete, Post: models.Post{ID: id}},
+	}, false)
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "Bulk", results[0].Post.Title)
+	assert.True(t, errors.Is(results[1].Err, apperr.ErrNotFound))
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, 2, results[2].Post.Version)
+	assert.NoError(t, results[3].Err)
+	assert.Nil(t, results[3].Post)
+
+	all, err = repo.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, results[0].ID, all[0].ID)
+}
+
+func testTags(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	goID, err := repo.AddPost(context.Background(), models.Post{Title: "Go", Content: "Content", Tags: []string{"go", "web"}})
+	assert.NoError(t, err)
+
+	webID, err := repo.AddPost(context.Background(), models.Post{Title: "Web", Content: "Content", Tags: []string{"web"}})
+	assert.NoError(t, err)
+
+	plainID, err := repo.AddPost(context.Background(), models.Post{Title: "Plain", Content: "Content"})
+	assert.NoError(t, err)
+
+	post, err := repo.GetPost(context.Background(), goID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"go", "web"}, post.Tags)
+
+	post, err = repo.GetPost(context.Background(), plainID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, post.Tags)
+
+	testCases := []struct {
+		filter   models.PostsFilter
+		expected []int
+	}{
+		{filter: models.PostsFilter{}, expected: []int{goID, webID, plainID}},
+		{filter: models.PostsFilter{Tags: []string{"web"}, AllTags: true}, expected: []int{goID, webID}},
+		{filter: models.PostsFilter{Tags: []string{"go", "web"}, AllTags: true}, expected: []int{goID}},
+		{filter: models.PostsFilter{Tags: []string{"go", "web"}}, expected: []int{goID, webID}},
+		{filter: models.PostsFilter{Tags: []string{"rust"}}, expected: []int{}},
+	}
+
+	for i, tc := range testCases {
+		posts, err := repo.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10, PostsFilter: tc.filter})
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		ids := []int{}
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		assert.Equal(t, tc.expected, ids, fmt.Sprintf("case %d", i))
+	}
+
+	tags, err := repo.GetTags(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Tag{{Name: "web", Count: 2}, {Name: "go", Count: 1}}, tags)
+
+	// Updating a post replaces its tags.
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: goID, Version: 1, Title: "Go", Content: "Content", Tags: []string{"go"}})
+	assert.NoError(t, err)
+
+	post, err = repo.GetPost(context.Background(), goID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"go"}, post.Tags)
+
+	// Trashed posts do not count.
+	err = repo.DeletePost(context.Background(), webID, 0)
+	assert.NoError(t, err)
+
+	tags, err = repo.GetTags(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Tag{{Name: "go", Count: 1}}, tags)
+}
+
+func testCategories(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	techID, err := repo.AddCategory(context.Background(), models.Category{Slug: "tech", Name: "Tech"})
+	assert.NoError(t, err)
+
+	goID, err := repo.AddCategory(context.Background(), models.Category{ParentID: &techID, Slug: "go", Name: "Go"})
+	assert.NoError(t, err)
+
+	genericsID, err := repo.AddCategory(context.Background(), models.Category{ParentID: &goID, Slug: "generics", Name: "Generics"})
+	assert.NoError(t, err)
+
+	newsID, err := repo.AddCategory(context.Background(), models.Category{Slug: "news", Name: "News"})
+	assert.NoError(t, err)
+
+	_, err = repo.AddCategory(context.Background(), models.Category{Slug: "tech", Name: "Tech again"})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	categories, err := repo.GetCategories(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, categories, 4)
+
+	category, err := repo.GetCategoryBySlug(context.Background(), "go")
+	assert.NoError(t, err)
+	assert.Equal(t, goID, category.ID)
+	assert.Equal(t, &techID, category.ParentID)
+
+	_, err = repo.GetCategoryBySlug(context.Background(), "missing")
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	inTech, err := repo.AddPost(context.Background(), models.Post{Title: "Tech", Content: "Content", CategoryID: &techID})
+	assert.NoError(t, err)
+
+	inGenerics, err := repo.AddPost(context.Background(), models.Post{Title: "Generics", Content: "Content", CategoryID: &genericsID})
+	assert.NoError(t, err)
+
+	inNews, err := repo.AddPost(context.Background(), models.Post{Title: "News", Content: "Content", CategoryID: &newsID})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		categoryID int
+		expected   []int
+	}{
+		{categoryID: techID, expected: []int{inTech, inGenerics}},
+		{categoryID: goID, expected: []int{inGenerics}},
+		{categoryID: genericsID, expected: []int{inGenerics}},
+		{categoryID: newsID, expected: []int{inNews}},
+	}
+
+	for i, tc := range testCases {
+		posts, err := repo.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10, CategoryID: &tc.categoryID})
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		ids := []int{}
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		assert.Equal(t, tc.expected, ids, fmt.Sprintf("case %d", i))
+	}
+
+	// Moving a post to another category takes it out of the old subtree.
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: inGenerics, Version: 1, Title: "Generics", Content: "Content", CategoryID: &newsID})
+	assert.NoError(t, err)
+
+	posts, err := repo.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10, CategoryID: &techID})
+	assert.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
+
+func testSlugs(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	id, err := repo.AddPost(context.Background(), models.Post{Slug: "first-title", Title: "First title", Content: "Content"})
+	assert.NoError(t, err)
+
+	otherID, err := repo.AddPost(context.Background(), models.Post{Slug: "first-title-2", Title: "First title", Content: "Content"})
+	assert.NoError(t, err)
+
+	_, err = repo.AddPost(context.Background(), models.Post{Slug: "first-title", Title: "First title", Content: "Content"})
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+
+	// Posts written without a slug get one from their id.
+	plainID, err := repo.AddPost(context.Background(), models.Post{Title: "Plain", Content: "Content"})
+	assert.NoError(t, err)
+
+	post, err := repo.GetPost(context.Background(), plainID)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("post-%d", plainID), post.Slug)
+
+	// Updating without a slug keeps it, a new slug moves the old one to the history.
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: id, Version: 1, Title: "Renamed", Content: "Content"})
+	assert.NoError(t, err)
+
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: id, Version: 2, Slug: "renamed", Title: "Renamed", Content: "Content"})
+	assert.NoError(t, err)
+
+	post, err = repo.GetPostBySlug(context.Background(), "renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, id, post.ID)
+	assert.Equal(t, "renamed", post.Slug)
+
+	post, err = repo.GetPostBySlug(context.Background(), "first-title")
+	assert.NoError(t, err)
+	assert.Equal(t, id, post.ID)
+	assert.Equal(t, "renamed", post.Slug)
+
+	_, err = repo.GetPostBySlug(context.Background(), "missing")
+	assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+	// Current and previous slugs of other posts are taken, the post's own are not.
+	taken, err := repo.GetTakenSlugs(context.Background(), "first-title", otherID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first-title"}, taken)
+
+	taken, err = repo.GetTakenSlugs(context.Background(), "first-title", id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first-title-2"}, taken)
+
+	// Taking a previous slug back removes it from the history.
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: id, Version: 3, Slug: "first-title", Title: "Renamed", Content: "Content"})
+	assert.NoError(t, err)
+
+	post, err = repo.GetPostBySlug(context.Background(), "renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, "first-title", post.Slug)
+}
+
+func testPostStatus(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	past, future := time.Now().UTC().Add(-time.Hour), time.Now().UTC().Add(time.Hour)
+
+	publishedID, err := repo.AddPost(context.Background(), models.Post{Title: "Published", Content: "Content"})
+	assert.NoError(t, err)
+
+	draftID, err := repo.AddPost(context.Background(), models.Post{Title: "Draft", Content: "Content", Status: models.StatusDraft})
+	assert.NoError(t, err)
+
+	dueID, err := repo.AddPost(context.Background(), models.Post{Title: "Due", Content: "Content", Status: models.StatusScheduled, PublishAt: &past})
+	assert.NoError(t, err)
+
+	_, err = repo.AddPost(context.Background(), models.Post{Title: "Later", Content: "Content", Status: models.StatusScheduled, PublishAt: &future})
+	assert.NoError(t, err)
+
+	// Posts stored as published are stamped with the time they went live.
+	post, err := repo.GetPost(context.Background(), publishedID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusPublished, post.Status)
+	assert.NotNil(t, post.PublishAt)
+
+	posts, err := repo.GetAllPosts(context.Background(), models.PostsQuery{Limit: 10, PostsFilter: models.PostsFilter{Status: models.StatusPublished}})
+	assert.NoError(t, err)
+	assert.Len(t, posts, 1)
+
+	published, err := repo.PublishScheduledPosts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), published)
+
+	// Publishing again finds nothing due.
+	published, err = repo.PublishScheduledPosts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), published)
+
+	post, err = repo.GetPost(context.Background(), dueID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusPublished, post.Status)
+	assert.Equal(t, 2, post.Version)
+	assert.WithinDuration(t, past, *post.PublishAt, time.Second)
+
+	next, err := repo.GetNextPublishAt(context.Background())
+	assert.NoError(t, err)
+	assert.WithinDuration(t, future, next, time.Second)
+
+	// An empty status keeps the current one.
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: draftID, Version: 1, Title: "Draft", Content: "Changed"})
+	assert.NoError(t, err)
+
+	post, err = repo.GetPost(context.Background(), draftID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusDraft, post.Status)
+	assert.Nil(t, post.PublishAt)
+}
+
+func testContentFormat(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	id, err := repo.AddPost(context.Background(), models.Post{Title: "Markdown", Content: "# Heading",
+		ContentFormat: models.FormatMarkdown, ContentHTML: "<h1>Heading</h1>\n"})
+	assert.NoError(t, err)
+
+	post, err := repo.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.FormatMarkdown, post.ContentFormat)
+	assert.Equal(t, "<h1>Heading</h1>\n", post.ContentHTML)
+
+	// An empty format keeps the current one.
+	_, err = repo.UpdatePost(context.Background(), models.Post{ID: id, Version: 1, Title: "Markdown", Content: "## Heading",
+		ContentHTML: "<h2>Heading</h2>\n"})
+	assert.NoError(t, err)
+
+	post, err = repo.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.FormatMarkdown, post.ContentFormat)
+	assert.Equal(t, "<h2>Heading</h2>\n", post.ContentHTML)
+}
+
+func testAttachments(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+
+	postID, err := repo.AddPost(context.Background(), models.Post{Title: "Test Title", Content: "Test Content"})
+	assert.NoError(t, err)
+
+	id, err := repo.AddAttachment(context.Background(), models.Attachment{PostID: postID, Filename: "image.png",
+		ContentType: "image/png", Size: 4, StorageKey: "1/abc"})
+	assert.NoError(t, err)
+
+	_, err = repo.AddAttachment(context.Background(), models.Attachment{PostID: postID, Filename: "copy.png",
+		ContentType: "image/png", Size: 4, StorageKey: "1/abc"})
+	assert.ErrorIs(t, err, apperr.ErrConflict)
+
+	attachment, err := repo.GetAttachment(context.Background(), postID, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "image.png", attachment.Filename)
+	assert.Equal(t, int64(4), attachment.Size)
+
+	// Only detached attachments may be deleted.
+	err = repo.DeleteAttachment(context.Background(), id)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	err = repo.DetachAttachment(context.Background(), postID, id)
+	assert.NoError(t, err)
+
+	attachments, err := repo.GetAttachments(context.Background(), postID)
+	assert.NoError(t, err)
+	assert.Empty(t, attachments)
+
+	detached, err := repo.GetDetachedAttachments(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, detached, 1)
+	assert.Equal(t, "1/abc", detached[0].StorageKey)
+
+	err = repo.DeleteAttachment(context.Background(), id)
+	assert.NoError(t, err)
+
+	// Purging a post detaches its attachments.
+	_, err = repo.AddAttachment(context.Background(), models.Attachment{PostID: postID, Filename: "notes.txt",
+		ContentType: "text/plain; charset=utf-8", Size: 5, StorageKey: "1/def"})
+	assert.NoError(t, err)
+
+	err = repo.DeletePost(context.Background(), postID, 0)
+	assert.NoError(t, err)
+
+	err = repo.PurgePost(context.Background(), postID)
+	assert.NoError(t, err)
+
+	detached, err = repo.GetDetachedAttachments(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, detached, 1)
+	assert.Equal(t, "1/def", detached[0].StorageKey)
+}
@@ -0,0 +1,131 @@
+package repotest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// clockSkew is how far the clock of a database may be from the clock of
+	// the tests.
+	clockSkew = time.Minute
+	// tick is long enough for the clock of every backend to move on.
+	tick = 10 * time.Millisecond
+)
+
+// testTimestamps checks when the timestamps of posts and everything else are
+// set. Times are compared with Equal, as backends return them in different
+// locations.
+func testTimestamps(t *testing.T, open func(t *testing.T) Repository) {
+	repo := open(t)
+	ctx := context.Background()
+
+	id, err := repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	created, err := repo.GetPost(ctx, id)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), created.CreatedAt, clockSkew)
+	assert.True(t, created.UpdatedAt.Equal(created.CreatedAt))
+	assert.True(t, created.PublishAt.Equal(created.CreatedAt))
+
+	time.Sleep(tick)
+
+	_, err = repo.UpdatePost(ctx, models.Post{ID: id, Version: 1, Title: "Updated", Content: "Content", PublishAt: created.PublishAt})
+	assert.NoError(t, err)
+
+	updated, err := repo.GetPost(ctx, id)
+	assert.NoError(t, err)
+	assert.True(t, updated.CreatedAt.Equal(created.CreatedAt))
+	assert.True(t, updated.UpdatedAt.After(created.UpdatedAt))
+	assert.True(t, updated.PublishAt.Equal(*created.PublishAt))
+
+	// Revisions are stamped with the time of the version they record.
+	revisions, err := repo.GetRevisions(ctx, id)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.True(t, revisions[0].CreatedAt.Equal(updated.UpdatedAt))
+	assert.True(t, revisions[1].CreatedAt.Equal(created.UpdatedAt))
+
+	stats, err := repo.GetPostsStats(ctx, models.PostsQuery{})
+	assert.NoError(t, err)
+	assert.True(t, stats.LastModified.Equal(updated.UpdatedAt))
+
+	time.Sleep(tick)
+
+	err = repo.DeletePost(ctx, id, 0)
+	assert.NoError(t, err)
+
+	trashed, err := repo.GetTrashedPost(ctx, id)
+	assert.NoError(t, err)
+	assert.True(t, trashed.DeletedAt.After(updated.UpdatedAt))
+	assert.True(t, trashed.UpdatedAt.Equal(*trashed.DeletedAt))
+
+	// Only posts trashed before the given time are purged.
+	purged, err := repo.PurgeTrash(ctx, trashed.DeletedAt.Add(-time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+
+	purged, err = repo.PurgeTrash(ctx, trashed.DeletedAt.Add(time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	// Users, comments, API keys and attachments are stamped too.
+	userID, err := repo.AddUser(ctx, models.User{Username: "gopher", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	user, err := repo.GetUser(ctx, userID)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), user.CreatedAt, clockSkew)
+
+	postID, err := repo.AddPost(ctx, models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	commentID, err := repo.AddComment(ctx, models.Comment{PostID: postID, Content: "First"})
+	assert.NoError(t, err)
+
+	comment, err := repo.GetComment(ctx, postID, commentID)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), comment.CreatedAt, clockSkew)
+	assert.True(t, comment.UpdatedAt.Equal(comment.CreatedAt))
+
+	time.Sleep(tick)
+
+	err = repo.UpdateComment(ctx, models.Comment{ID: commentID, PostID: postID, Content: "Edited"})
+	assert.NoError(t, err)
+
+	edited, err := repo.GetComment(ctx, postID, commentID)
+	assert.NoError(t, err)
+	assert.True(t, edited.CreatedAt.Equal(comment.CreatedAt))
+	assert.True(t, edited.UpdatedAt.After(comment.UpdatedAt))
+
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+	keyID, err := repo.AddAPIKey(ctx, models.APIKey{UserID: userID, Name: "ci", Prefix: "prmv_aaaaaaaa",
+		KeyHash: strings.Repeat("a", 64), Scopes: []string{"posts:create"}, ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	key, err := repo.GetAPIKey(ctx, keyID, userID)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), key.CreatedAt, clockSkew)
+	assert.True(t, key.ExpiresAt.Equal(expiresAt))
+	assert.Nil(t, key.LastUsedAt)
+
+	time.Sleep(tick)
+
+	used, err := repo.UseAPIKey(ctx, strings.Repeat("a", 64))
+	assert.NoError(t, err)
+	assert.True(t, used.LastUsedAt.After(key.CreatedAt))
+
+	attachmentID, err := repo.AddAttachment(ctx, models.Attachment{PostID: postID, Filename: "notes.txt",
+		ContentType: "text/plain", Size: 5, StorageKey: "1/abc"})
+	assert.NoError(t, err)
+
+	attachment, err := repo.GetAttachment(ctx, postID, attachmentID)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), attachment.CreatedAt, clockSkew)
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// CreateAPIKey issues an API key acting on behalf of the caller. Scopes must
+// be actions from policy.Actions that the caller's roles allow. The secret is
+// only returned here and cannot be retrieved later.
+func (s *Service) CreateAPIKey(ctx context.Context, key models.APIKey) (models.CreatedAPIKey, error) {
+	identity, err := s.keyOwner(ctx)
+	if err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+
+	err = s.validate.Struct(key)
+	if err != nil {
+		return models.CreatedAPIKey{}, apperr.Validation("invalid API key data", err)
+	}
+
+	for _, scope := range key.Scopes {
+		if !policy.IsAction(scope) {
+			return models.CreatedAPIKey{}, apperr.Validation("unknown scope "+scope, nil)
+		}
+		if !s.policy.AllowsSome(identity, scope) {
+			return models.CreatedAPIKey{}, apperr.Forbidden("scope "+scope+" is not allowed", nil)
+		}
+	}
+
+	if key.ExpiresAt != nil {
+		if !key.ExpiresAt.After(time.Now()) {
+			return models.CreatedAPIKey{}, apperr.Validation("expires_at must be in the future", nil)
+		}
+		expires := key.ExpiresAt.UTC()
+		key.ExpiresAt = &expires
+	}
+
+	secret, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+
+	key.UserID = identity.UserID
+	key.Prefix = prefix
+	key.KeyHash = hash
+
+	id, err := s.Repo.AddAPIKey(ctx, key)
+	if err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+
+	created, err := s.Repo.GetAPIKey(ctx, id, identity.UserID)
+	if err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+
+	return models.CreatedAPIKey{APIKey: created, Key: secret}, nil
+}
+
+// GetAPIKeys lists the caller's API keys that have not been revoked.
+func (s *Service) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	identity, err := s.keyOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repo.GetAPIKeys(ctx, identity.UserID)
+}
+
+// RevokeAPIKey revokes one of the caller's API keys.
+func (s *Service) RevokeAPIKey(ctx context.Context, id int) error {
+	identity, err := s.keyOwner(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.Repo.RevokeAPIKey(ctx, id, identity.UserID)
+}
+
+// AuthenticateAPIKey verifies an API key and returns the identity of the user
+// it belongs to, limited to the key's scopes. Like access tokens, roles are
+// read on every call.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (models.Identity, error) {
+	apiKey, err := s.Repo.UseAPIKey(ctx, auth.HashToken(key))
+	if err != nil {
+		if errors.Is(err, apperr.ErrNotFound) {
+			return models.Identity{}, apperr.Unauthorized("invalid API key", err)
+		}
+		return models.Identity{}, err
+	}
+
+	user, err := s.Repo.GetUser(ctx, apiKey.UserID)
+	if err != nil {
+		return models.Identity{}, err
+	}
+
+	roles, err := s.Repo.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return models.Identity{}, err
+	}
+
+	return models.Identity{
+		UserID:   user.ID,
+		Username: user.Username,
+		Roles:    roles,
+		Scopes:   apiKey.Scopes,
+		APIKeyID: apiKey.ID,
+	}, nil
+}
+
+// keyOwner returns the caller if it may manage API keys. Keys cannot manage
+// keys, so a leaked key cannot be used to mint new ones.
+func (s *Service) keyOwner(ctx context.Context) (models.Identity, error) {
+	identity, err := caller(ctx)
+	if err != nil {
+		return models.Identity{}, err
+	}
+
+	if identity.APIKeyID != 0 {
+		return models.Identity{}, apperr.Forbidden("API keys cannot manage API keys", nil)
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	author := auth.NewContext(context.Background(), models.Identity{UserID: 2, Roles: []string{policy.RoleAuthor}})
+	viaKey := auth.NewContext(context.Background(), models.Identity{UserID: 2, Roles: []string{policy.RoleAuthor}, Scopes: []string{policy.PostsCreate}, APIKeyID: 1})
+
+	testCases := []struct {
+		ctx  context.Context
+		key  models.APIKey
+		kind error
+	}{
+		{ctx: author, key: models.APIKey{Name: "ci", Scopes: []string{policy.PostsCreate, policy.PostsUpdate}}},
+		{ctx: author, key: models.APIKey{Name: "ci", Scopes: []string{policy.PostsCreate}, ExpiresAt: &future}},
+		{ctx: author, key: models.APIKey{Name: "ci", Scopes: []string{policy.PostsCreate}, ExpiresAt: &past}, kind: apperr.ErrValidation},
+		{ctx: author, key: models.APIKey{Name: "ci", Scopes: []string{"posts:read"}}, kind: apperr.ErrValidation},
+		{ctx: author, key: models.APIKey{Name: "ci"}, kind: apperr.ErrValidation},
+		{ctx: author, key: models.APIKey{Scopes: []string{policy.PostsCreate}}, kind: apperr.ErrValidation},
+		{ctx: author, key: models.APIKey{Name: "ci", Scopes: []string{policy.RolesAssign}}, kind: apperr.ErrForbidden},
+		{ctx: viaKey, key: models.APIKey{Name: "ci", Scopes: []string{policy.PostsCreate}}, kind: apperr.ErrForbidden},
+		{ctx: context.Background(), key: models.APIKey{Name: "ci", Scopes: []string{policy.PostsCreate}}, kind: apperr.ErrUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		var stored models.APIKey
+		if tc.kind == nil {
+			mockRepo.On("AddAPIKey", mock.Anything, mock.MatchedBy(func(key models.APIKey) bool {
+				stored = key
+				return key.UserID == 2 && key.Name == tc.key.Name && len(key.KeyHash) == 64 &&
+					strings.HasPrefix(key.Prefix, auth.APIKeyPrefix)
+			})).Return(7, nil)
+			mockRepo.On("GetAPIKey", mock.Anything, 7, 2).Return(models.APIKey{ID: 7, UserID: 2, Name: tc.key.Name}, nil)
+		}
+
+		created, err := service.CreateAPIKey(tc.ctx, tc.key)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, 7, created.ID, fmt.Sprintf("case %d", i))
+			assert.Equal(t, auth.HashToken(created.Key), stored.KeyHash, fmt.Sprintf("case %d", i))
+			assert.True(t, strings.HasPrefix(created.Key, stored.Prefix), fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	key := models.APIKey{ID: 3, UserID: 1, Scopes: []string{policy.PostsCreate}}
+
+	mockRepo.On("UseAPIKey", mock.Anything, auth.HashToken("prmv_good")).Return(key, nil)
+	mockRepo.On("UseAPIKey", mock.Anything, auth.HashToken("prmv_revoked")).Return(models.APIKey{}, apperr.NotFound("API key not found", nil))
+	mockRepo.On("GetUser", mock.Anything, 1).Return(models.User{ID: 1, Username: "gopher"}, nil)
+	mockRepo.On("GetUserRoles", mock.Anything, 1).Return([]string{policy.RoleAdmin}, nil)
+
+	identity, err := service.AuthenticateAPIKey(context.Background(), "prmv_good")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Identity{
+		UserID:   1,
+		Username: "gopher",
+		Roles:    []string{policy.RoleAdmin},
+		Scopes:   []string{policy.PostsCreate},
+		APIKeyID: 3,
+	}, identity)
+
+	// The key's scopes limit what the admin can do with it.
+	ctx := auth.NewContext(context.Background(), identity)
+	assert.NoError(t, service.Authorize(ctx, policy.PostsCreate))
+	assert.ErrorIs(t, service.Authorize(ctx, policy.RolesAssign), apperr.ErrForbidden)
+
+	_, err = service.AuthenticateAPIKey(context.Background(), "prmv_revoked")
+	assert.ErrorIs(t, err, apperr.ErrUnauthorized)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	mockRepo.On("RevokeAPIKey", mock.Anything, 5, 1).Return(apperr.NotFound("API key not found", nil))
+
+	err := service.RevokeAPIKey(testCtx, 5)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	mockRepo.AssertExpectations(t)
+}
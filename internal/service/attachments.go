@@ -0,0 +1,252 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// Storage keeps the files of attachments under opaque keys.
+type Storage interface {
+	// Put stores the content of r under key and returns its size.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open returns the file stored under key, or a NotFound error.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the file stored under key. Missing files are ignored.
+	Delete(ctx context.Context, key string) error
+}
+
+// sniffLength is how much of a file http.DetectContentType looks at.
+const sniffLength = 512
+
+// attachmentTypes are the media types files may have, as sniffed from their
+// content. Markup and scripts are left out, so attachments cannot run code
+// in the browser.
+var attachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"audio/mpeg":      true,
+	"audio/wave":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+}
+
+// SetStorage enables attachments, keeping their files in files. Files larger
+// than maxSize bytes are rejected.
+func (s *Service) SetStorage(files Storage, maxSize int64) {
+	s.files = files
+	s.maxAttachmentSize = maxSize
+}
+
+// AddAttachment stores a file read from r and attaches it to a post. The
+// content type is sniffed from the content rather than trusted from the
+// client.
+func (s *Service) AddAttachment(ctx context.Context, postID int, filename string, r io.Reader) (models.Attachment, error) {
+	if s.files == nil {
+		return models.Attachment{}, apperr.Unavailable("attachments are not configured", nil)
+	}
+
+	identity, err := caller(ctx)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	post, err := s.Repo.GetPostForUpdate(ctx, postID)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	err = s.authorizePost(ctx, policy.PostsUpdate, post)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	attachment := models.Attachment{
+		PostID:     postID,
+		UploaderID: &identity.UserID,
+		Filename:   cleanFilename(filename),
+	}
+
+	err = s.validate.Struct(attachment)
+	if err != nil {
+		return models.Attachment{}, apperr.Validation("invalid attachment data", err)
+	}
+
+	head := make([]byte, sniffLength)
+
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return models.Attachment{}, fmt.Errorf("error reading attachment: %w", err)
+	}
+	if n == 0 {
+		return models.Attachment{}, apperr.Validation("attachment is empty", nil)
+	}
+
+	attachment.ContentType = http.DetectContentType(head[:n])
+
+	mediaType, _, err := mime.ParseMediaType(attachment.ContentType)
+	if err != nil || !attachmentTypes[mediaType] {
+		return models.Attachment{}, apperr.Validation("attachments of type "+attachment.ContentType+" are not allowed", err)
+	}
+
+	attachment.StorageKey, err = newStorageKey(postID)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	// Reading one byte more than allowed tells files of exactly the maximum
+	// size from larger ones.
+	content := io.LimitReader(io.MultiReader(bytes.NewReader(head[:n]), r), s.maxAttachmentSize+1)
+
+	attachment.Size, err = s.files.Put(ctx, attachment.StorageKey, content)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	if attachment.Size > s.maxAttachmentSize {
+		err = s.files.Delete(ctx, attachment.StorageKey)
+		if err != nil {
+			return models.Attachment{}, err
+		}
+
+		return models.Attachment{}, apperr.Validation(fmt.Sprintf("attachment is larger than %d bytes", s.maxAttachmentSize), nil)
+	}
+
+	id, err := s.Repo.AddAttachment(ctx, attachment)
+	if err != nil {
+		return models.Attachment{}, errors.Join(err, s.files.Delete(ctx, attachment.StorageKey))
+	}
+
+	return s.Repo.GetAttachment(ctx, postID, id)
+}
+
+// GetAttachments lists the attachments of a post.
+func (s *Service) GetAttachments(ctx context.Context, postID int) ([]models.Attachment, error) {
+	_, err := s.readablePost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repo.GetAttachments(ctx, postID)
+}
+
+// OpenAttachment returns an attachment of a post with its file, which the
+// caller must close.
+func (s *Service) OpenAttachment(ctx context.Context, postID int, id int) (models.Attachment, io.ReadSeekCloser, error) {
+	if s.files == nil {
+		return models.Attachment{}, nil, apperr.Unavailable("attachments are not configured", nil)
+	}
+
+	_, err := s.readablePost(ctx, postID)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+
+	attachment, err := s.Repo.GetAttachment(ctx, postID, id)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+
+	file, err := s.files.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+
+	return attachment, file, nil
+}
+
+// DeleteAttachment removes an attachment from a post and deletes its file.
+func (s *Service) DeleteAttachment(ctx context.Context, postID int, id int) error {
+	post, err := s.Repo.GetPostForUpdate(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizePost(ctx, policy.PostsUpdate, post)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repo.DetachAttachment(ctx, postID, id)
+	if err != nil {
+		return err
+	}
+
+	// The attachment is gone either way; a file that cannot be deleted now is
+	// retried by PurgeTrash.
+	_ = s.removeDetachedAttachments(ctx)
+
+	return nil
+}
+
+// removeDetachedAttachments deletes the files of attachments that no longer
+// belong to a post, and then the attachments. Attachments whose file could
+// not be deleted are kept and retried on the next call.
+func (s *Service) removeDetachedAttachments(ctx context.Context) error {
+	if s.files == nil {
+		return nil
+	}
+
+	attachments, err := s.Repo.GetDetachedAttachments(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, attachment := range attachments {
+		err = s.files.Delete(ctx, attachment.StorageKey)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Another replica may have removed it first.
+		err = s.Repo.DeleteAttachment(ctx, attachment.ID)
+		if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// cleanFilename keeps the last element of a client supplied file name, which
+// may be a full path.
+func cleanFilename(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	if filename == "." || filename == "/" {
+		return ""
+	}
+
+	return strings.TrimSpace(filename)
+}
+
+// newStorageKey returns a random key for a new file of a post.
+func newStorageKey(postID int) (string, error) {
+	buf := make([]byte, 16)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("error generating storage key: %w", err)
+	}
+
+	return fmt.Sprintf("%d/%s", postID, hex.EncodeToString(buf)), nil
+}
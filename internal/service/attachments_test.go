@@ -0,0 +1,166 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// memFiles is Storage kept in memory.
+type memFiles map[string][]byte
+
+func (f memFiles) Put(_ context.Context, key string, r io.Reader) (int64, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	f[key] = content
+
+	return int64(len(content)), nil
+}
+
+func (f memFiles) Open(_ context.Context, key string) (io.ReadSeekCloser, error) {
+	content, ok := f[key]
+	if !ok {
+		return nil, apperr.NotFound("file not found", nil)
+	}
+
+	return nopCloser{bytes.NewReader(content)}, nil
+}
+
+func (f memFiles) Delete(_ context.Context, key string) error {
+	delete(f, key)
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+var pngHeader = "\x89PNG\r\n\x1a\n"
+
+func TestAddAttachment(t *testing.T) {
+	authorID := 1
+	other := auth.NewContext(context.Background(), models.Identity{UserID: 2, Roles: []string{policy.RoleAuthor}})
+
+	testCases := []struct {
+		ctx         context.Context
+		filename    string
+		content     string
+		contentType string
+		kind        error
+	}{
+		{ctx: testCtx, filename: "image.png", content: pngHeader + "data", contentType: "image/png"},
+		{ctx: testCtx, filename: `C:\Users\me\notes.txt`, content: "Hello", contentType: "text/plain; charset=utf-8"},
+		{ctx: testCtx, filename: "page.png", content: "<html><script>x()</script></html>", kind: apperr.ErrValidation},
+		{ctx: testCtx, filename: "image.png", content: pngHeader + strings.Repeat("a", 100), kind: apperr.ErrValidation},
+		{ctx: testCtx, filename: "empty.txt", content: "", kind: apperr.ErrValidation},
+		{ctx: testCtx, filename: "", content: "Hello", kind: apperr.ErrValidation},
+		{ctx: other, filename: "image.png", content: pngHeader, kind: apperr.ErrForbidden},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+		files := memFiles{}
+		service.SetStorage(files, 64)
+
+		mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(models.Post{ID: 1, AuthorID: &authorID}, nil).Once()
+		if tc.kind == nil {
+			mockRepo.On("AddAttachment", mock.Anything, mock.MatchedBy(func(a models.Attachment) bool {
+				return a.ContentType == tc.contentType && a.Size == int64(len(tc.content)) && files[a.StorageKey] != nil
+			})).Return(3, nil).Once()
+			mockRepo.On("GetAttachment", mock.Anything, 1, 3).Return(models.Attachment{ID: 3, PostID: 1}, nil).Once()
+		}
+
+		_, err := service.AddAttachment(tc.ctx, 1, tc.filename, strings.NewReader(tc.content))
+		assertKind(t, tc.kind, err, fmt.Sprintf("case %d", i))
+
+		// Rejected files are not kept.
+		if tc.kind != nil {
+			assert.Empty(t, files, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestCleanFilename(t *testing.T) {
+	testCases := []struct {
+		filename string
+		expected string
+	}{
+		{filename: "image.png", expected: "image.png"},
+		{filename: "../../etc/passwd", expected: "passwd"},
+		{filename: `C:\Users\me\notes.txt`, expected: "notes.txt"},
+		{filename: "dir/", expected: "dir"},
+		{filename: "/", expected: ""},
+		{filename: "", expected: ""},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, cleanFilename(tc.filename), fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestOpenAttachment(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+	files := memFiles{"1/abc": []byte("Hello")}
+	service.SetStorage(files, 64)
+
+	attachment := models.Attachment{ID: 3, PostID: 1, StorageKey: "1/abc"}
+	mockRepo.On("GetPost", mock.Anything, 1).Return(models.Post{ID: 1, Status: models.StatusPublished}, nil)
+	mockRepo.On("GetAttachment", mock.Anything, 1, 3).Return(attachment, nil).Once()
+	mockRepo.On("GetAttachment", mock.Anything, 1, 4).Return(models.Attachment{}, apperr.NotFound("attachment not found", nil)).Once()
+
+	result, file, err := service.OpenAttachment(context.Background(), 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", string(content))
+	assert.Equal(t, attachment, result)
+
+	_, _, err = service.OpenAttachment(context.Background(), 1, 4)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+	files := memFiles{"1/abc": []byte("Hello"), "2/def": []byte("Hello")}
+	service.SetStorage(files, 64)
+
+	mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(models.Post{ID: 1}, nil).Once()
+	mockRepo.On("DetachAttachment", mock.Anything, 1, 3).Return(nil).Once()
+	// Files of purged posts are removed along the way.
+	mockRepo.On("GetDetachedAttachments", mock.Anything).Return([]models.Attachment{{ID: 3, StorageKey: "1/abc"}, {ID: 4, StorageKey: "2/def"}}, nil).Once()
+	mockRepo.On("DeleteAttachment", mock.Anything, 3).Return(nil).Once()
+	mockRepo.On("DeleteAttachment", mock.Anything, 4).Return(apperr.NotFound("attachment not found", nil)).Once()
+
+	err := service.DeleteAttachment(testCtx, 1, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultRole is granted to every new user.
+const DefaultRole = policy.RoleAuthor
+
+// dummyPasswordHash is compared against when a login names an unknown user, so
+// that the response time does not reveal which usernames exist.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+type credentials struct {
+	Username string `validate:"required,min=3,max=50,alphanum"`
+	Password string `validate:"required,min=8,max=72"`
+}
+
+// Register creates a user with a bcrypt hash of password.
+func (s *Service) Register(ctx context.Context, username, password string) (models.User, error) {
+	err := s.validate.Struct(credentials{Username: username, Password: password})
+	if err != nil {
+		return models.User{}, apperr.Validation("invalid user data", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	id, err := s.Repo.AddUser(ctx, models.User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Roles:        []string{DefaultRole},
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := s.Repo.GetUser(ctx, id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user.Roles = []string{DefaultRole}
+
+	return user, nil
+}
+
+// Login checks the password of a user and issues a new token pair.
+func (s *Service) Login(ctx context.Context, username, password string) (models.TokenPair, error) {
+	user, err := s.Repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, apperr.ErrNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			return models.TokenPair{}, apperr.Unauthorized("invalid username or password", err)
+		}
+		return models.TokenPair{}, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return models.TokenPair{}, apperr.Unauthorized("invalid username or password", err)
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The old refresh
+// token is revoked, so it cannot be used again.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	userID, err := s.Repo.ConsumeRefreshToken(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, apperr.ErrNotFound) {
+			return models.TokenPair{}, apperr.Unauthorized("invalid refresh token", err)
+		}
+		return models.TokenPair{}, err
+	}
+
+	user, err := s.Repo.GetUser(ctx, userID)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Authenticate verifies an access token and returns the identity of the user
+// it was issued to. Roles are read from the database on every call, so role
+// changes apply to tokens that were already issued.
+func (s *Service) Authenticate(ctx context.Context, accessToken string) (models.Identity, error) {
+	user, err := s.tokens.ParseAccess(accessToken)
+	if err != nil {
+		return models.Identity{}, apperr.Unauthorized("invalid access token", err)
+	}
+
+	roles, err := s.Repo.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return models.Identity{}, err
+	}
+
+	return models.Identity{UserID: user.ID, Username: user.Username, Roles: roles}, nil
+}
+
+func (s *Service) issueTokens(ctx context.Context, user models.User) (models.TokenPair, error) {
+	access, _, err := s.tokens.Access(user)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	refresh, hash, expires, err := s.tokens.Refresh()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	err = s.Repo.AddRefreshToken(ctx, models.RefreshToken{TokenHash: hash, UserID: user.ID, ExpiresAt: expires.UTC()})
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.tokens.AccessTTL().Seconds()),
+	}, nil
+}
+
+// Authorize checks that the caller may perform action on at least some
+// resources, e.g. its own posts for policy.PostsUpdate. Checks against a
+// specific resource happen in the service methods themselves.
+func (s *Service) Authorize(ctx context.Context, action string) error {
+	identity, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !s.policy.AllowsSome(identity, action) {
+		return apperr.Forbidden("permission denied", nil)
+	}
+
+	return nil
+}
+
+// caller returns the identity the handler layer stored in ctx.
+func caller(ctx context.Context) (models.Identity, error) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return models.Identity{}, apperr.Unauthorized("authentication required", nil)
+	}
+
+	return identity, nil
+}
+
+// authorizePost checks that the caller may perform action on post.
+func (s *Service) authorizePost(ctx context.Context, action string, post models.Post) error {
+	identity, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !s.policy.AllowsOn(identity, action, post.AuthorID) {
+		return apperr.Forbidden("not allowed to change this post", nil)
+	}
+
+	return nil
+}
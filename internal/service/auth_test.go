@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegister(t *testing.T) {
+	testCases := []struct {
+		username string
+		password string
+		repoErr  error
+		kind     error
+	}{
+		{username: "gopher", password: "password1"},
+		{username: "gopher", password: "password1", repoErr: apperr.Conflict("user already exists", nil), kind: apperr.ErrConflict},
+		{username: "go", password: "password1", kind: apperr.ErrValidation},
+		{username: "go pher", password: "password1", kind: apperr.ErrValidation},
+		{username: "gopher", password: "short", kind: apperr.ErrValidation},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		if tc.kind == nil || tc.repoErr != nil {
+			mockRepo.On("AddUser", mock.Anything, mock.MatchedBy(func(user models.User) bool {
+				return user.Username == tc.username &&
+					bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(tc.password)) == nil
+			})).Return(1, tc.repoErr)
+		}
+		if tc.kind == nil {
+			mockRepo.On("GetUser", mock.Anything, 1).Return(models.User{ID: 1, Username: tc.username}, nil)
+		}
+
+		user, err := service.Register(context.Background(), tc.username, tc.password)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, 1, user.ID, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	user := models.User{ID: 1, Username: "gopher", PasswordHash: string(hash)}
+
+	testCases := []struct {
+		username string
+		password string
+		kind     error
+	}{
+		{username: "gopher", password: "password1"},
+		{username: "gopher", password: "wrong password", kind: apperr.ErrUnauthorized},
+		{username: "nobody", password: "password1", kind: apperr.ErrUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetUserByUsername", mock.Anything, "gopher").Return(user, nil).Maybe()
+		mockRepo.On("GetUserByUsername", mock.Anything, "nobody").Return(models.User{}, apperr.NotFound("user not found", nil)).Maybe()
+		if tc.kind == nil {
+			mockRepo.On("AddRefreshToken", mock.Anything, mock.MatchedBy(func(token models.RefreshToken) bool {
+				return token.UserID == 1 && len(token.TokenHash) == 64
+			})).Return(nil)
+			mockRepo.On("GetUserRoles", mock.Anything, 1).Return([]string{policy.RoleAuthor}, nil)
+		}
+
+		tokens, err := service.Login(context.Background(), tc.username, tc.password)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+			message, _ := apperr.Message(err)
+			assert.Equal(t, "invalid username or password", message, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, "Bearer", tokens.TokenType, fmt.Sprintf("case %d", i))
+			assert.Equal(t, 900, tokens.ExpiresIn, fmt.Sprintf("case %d", i))
+			assert.NotEmpty(t, tokens.RefreshToken, fmt.Sprintf("case %d", i))
+
+			authenticated, err := service.Authenticate(context.Background(), tokens.AccessToken)
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, models.Identity{UserID: 1, Username: "gopher", Roles: []string{policy.RoleAuthor}}, authenticated, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	mockRepo.On("ConsumeRefreshToken", mock.Anything, auth.HashToken("valid")).Return(1, nil)
+	mockRepo.On("ConsumeRefreshToken", mock.Anything, auth.HashToken("used")).Return(0, apperr.NotFound("refresh token not found", nil))
+	mockRepo.On("GetUser", mock.Anything, 1).Return(models.User{ID: 1, Username: "gopher"}, nil)
+	mockRepo.On("AddRefreshToken", mock.Anything, mock.Anything).Return(nil)
+
+	tokens, err := service.Refresh(context.Background(), "valid")
+	assert.NoError(t, err)
+	assert.NotEqual(t, "valid", tokens.RefreshToken)
+
+	_, err = service.Refresh(context.Background(), "used")
+	assert.ErrorIs(t, err, apperr.ErrUnauthorized)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticateInvalidToken(t *testing.T) {
+	service := NewService(new(MockRepository), testTokens, testPolicy)
+
+	_, err := service.Authenticate(context.Background(), "not a token")
+	assert.ErrorIs(t, err, apperr.ErrUnauthorized)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestPostOwnership(t *testing.T) {
+	authorID := 1
+	authored := models.Post{ID: 1, Slug: "title", Title: "Title", Content: "Content", Version: 1, AuthorID: &authorID}
+	orphaned := models.Post{ID: 1, Slug: "title", Title: "Title", Content: "Content", Version: 1}
+
+	as := func(userID int, role string) context.Context {
+		return auth.NewContext(context.Background(), models.Identity{UserID: userID, Roles: []string{role}})
+	}
+
+	testCases := []struct {
+		ctx       context.Context
+		post      models.Post
+		updateErr error
+		deleteErr error
+	}{
+		{ctx: as(1, policy.RoleAuthor), post: authored},
+		{ctx: as(2, policy.RoleAuthor), post: authored, updateErr: apperr.ErrForbidden, deleteErr: apperr.ErrForbidden},
+		{ctx: as(1, policy.RoleAuthor), post: orphaned, updateErr: apperr.ErrForbidden, deleteErr: apperr.ErrForbidden},
+		{ctx: as(1, policy.RoleEditor), post: authored},
+		{ctx: as(2, policy.RoleEditor), post: authored, deleteErr: apperr.ErrForbidden},
+		{ctx: as(2, policy.RoleEditor), post: orphaned, deleteErr: apperr.ErrForbidden},
+		{ctx: as(2, policy.RoleAdmin), post: authored},
+		{ctx: as(2, policy.RoleAdmin), post: orphaned},
+		{ctx: as(1, policy.RoleViewer), post: authored, updateErr: apperr.ErrForbidden, deleteErr: apperr.ErrForbidden},
+		{ctx: context.Background(), post: authored, updateErr: apperr.ErrUnauthorized, deleteErr: apperr.ErrUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(tc.post, nil)
+		mockRepo.On("GetTrashedPost", mock.Anything, 1).Return(tc.post, nil)
+		if tc.updateErr == nil {
+			mockRepo.On("GetPost", mock.Anything, 1).Return(tc.post, nil)
+			mockRepo.On("GetTakenSlugs", mock.Anything, "new-title", 1).Return([]string{}, nil)
+			mockRepo.On("UpdatePost", mock.Anything, mock.Anything).Return(1, nil)
+		}
+		if tc.deleteErr == nil {
+			mockRepo.On("DeletePost", mock.Anything, 1, 0).Return(nil)
+			mockRepo.On("PurgePost", mock.Anything, 1).Return(nil)
+		}
+
+		_, err := service.UpdatePost(tc.ctx, models.Post{ID: 1, Title: "New Title", Content: "New Content"})
+		assertKind(t, tc.updateErr, err, fmt.Sprintf("case %d update", i))
+
+		_, err = service.PatchPost(tc.ctx, 1, models.PostPatch{Type: "application/merge-patch+json", Document: []byte(`{"title":"New Title"}`)})
+		assertKind(t, tc.updateErr, err, fmt.Sprintf("case %d patch", i))
+
+		err = service.DeletePost(tc.ctx, 1, 0)
+		assertKind(t, tc.deleteErr, err, fmt.Sprintf("case %d delete", i))
+
+		err = service.PurgePost(tc.ctx, 1)
+		assertKind(t, tc.deleteErr, err, fmt.Sprintf("case %d purge", i))
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestAddPostPermission(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	viewer := auth.NewContext(context.Background(), models.Identity{UserID: 1, Roles: []string{policy.RoleViewer}})
+
+	_, err := service.AddPost(viewer, models.Post{Title: "Title", Content: "Content"})
+	assert.ErrorIs(t, err, apperr.ErrForbidden)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthorize(t *testing.T) {
+	service := NewService(new(MockRepository), testTokens, testPolicy)
+
+	testCases := []struct {
+		ctx    context.Context
+		action string
+		kind   error
+	}{
+		{ctx: testCtx, action: policy.RolesAssign},
+		{ctx: auth.NewContext(context.Background(), models.Identity{UserID: 1, Roles: []string{policy.RoleAuthor}}), action: policy.PostsDelete},
+		{ctx: auth.NewContext(context.Background(), models.Identity{UserID: 1, Roles: []string{policy.RoleAuthor}}), action: policy.RolesAssign, kind: apperr.ErrForbidden},
+		{ctx: auth.NewContext(context.Background(), models.Identity{UserID: 1, Roles: []string{policy.RoleViewer}}), action: policy.PostsCreate, kind: apperr.ErrForbidden},
+		{ctx: context.Background(), action: policy.PostsCreate, kind: apperr.ErrUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		assertKind(t, tc.kind, service.Authorize(tc.ctx, tc.action), fmt.Sprintf("case %d", i))
+	}
+}
+
+func assertKind(t *testing.T, kind error, err error, msg string) {
+	t.Helper()
+
+	if kind == nil {
+		assert.NoError(t, err, msg)
+	} else {
+		assert.ErrorIs(t, err, kind, msg)
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// MaxBulkOperations is the largest number of operations in one bulk request.
+const MaxBulkOperations = 1000
+
+// BulkPosts validates and authorizes every operation like the single-post
+// methods do and applies the valid ones in one transaction. In atomic mode
+// nothing is applied unless every operation succeeds; otherwise each
+// operation succeeds or fails on its own. The result of every operation is
+// returned in order.
+func (s *Service) BulkPosts(ctx context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error) {
+	identity, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ops) == 0 {
+		return nil, apperr.Validation("no operations", nil)
+	}
+
+	if len(ops) > MaxBulkOperations {
+		return nil, apperr.Validation(fmt.Sprintf("at most %d operations are allowed", MaxBulkOperations), nil)
+	}
+
+	stored, err := s.bulkTargets(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkResult, len(ops))
+	pending := make([]int, 0, len(ops))
+	pendingOps := make([]models.BulkOperation, 0, len(ops))
+	// slugs holds the slugs given out in this request, which are not stored yet.
+	slugs := make(map[string]bool)
+	now := time.Now().UTC()
+
+	for i, op := range ops {
+		results[i] = models.BulkResult{Index: i, Op: op.Op, ID: op.Post.ID}
+
+		err = s.checkBulkOperation(identity, &op, stored)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		if op.Op != models.BulkDelete {
+			var current models.Post
+			if op.Op == models.BulkUpdate {
+				current = stored[op.Post.ID]
+				replaceDefaults(&op.Post)
+			}
+
+			err = s.checkCategory(ctx, op.Post.CategoryID)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+
+			err = settleStatus(&op.Post, current, now)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+
+			err = renderContent(&op.Post, current.ContentFormat)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+
+			err = s.assignSlug(ctx, &op.Post, replacedSlug(op.Post, current.Slug), slugs)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+
+		pending = append(pending, i)
+		pendingOps = append(pendingOps, op)
+	}
+
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	if atomic && len(pending) < len(ops) {
+		for _, i := range pending {
+			results[i].Err = apperr.Aborted("not applied because another operation failed", nil)
+		}
+		return results, nil
+	}
+
+	applied, err := s.Repo.BulkPosts(ctx, pendingOps, atomic)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, result := range applied {
+		i := pending[j]
+		results[i].ID = result.ID
+		results[i].Post = result.Post
+		results[i].Err = result.Err
+
+		if ops[i].Post.Version == 0 && errors.Is(result.Err, apperr.ErrPreconditionFailed) {
+			// As in savePost, losing a race the caller did not ask to
+			// check for is a conflict.
+			results[i].Err = apperr.Conflict("post was modified concurrently", result.Err)
+		}
+
+		if result.Post != nil {
+			s.notifyScheduled(*result.Post)
+		}
+	}
+
+	return results, nil
+}
+
+// bulkTargets loads the posts that updates and deletes refer to, by id.
+func (s *Service) bulkTargets(ctx context.Context, ops []models.BulkOperation) (map[int]models.Post, error) {
+	var ids []int
+
+	for _, op := range ops {
+		if op.Op != models.BulkCreate && op.Post.ID > 0 {
+			ids = append(ids, op.Post.ID)
+		}
+	}
+
+	posts, err := s.Repo.GetPostsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[int]models.Post, len(posts))
+	for _, post := range posts {
+		stored[post.ID] = post
+	}
+
+	return stored, nil
+}
+
+// checkBulkOperation validates op and checks that identity may apply it. The
+// author of created posts and the stored version of updated and deleted posts
+// are set on op.
+func (s *Service) checkBulkOperation(identity models.Identity, op *models.BulkOperation, stored map[int]models.Post) error {
+	var action string
+
+	switch op.Op {
+	case models.BulkCreate:
+		if !s.policy.Allows(identity, policy.PostsCreate) {
+			return apperr.Forbidden("permission denied", nil)
+		}
+
+		op.Post.Tags = normalizeTags(op.Post.Tags)
+
+		err := s.validatePost(op.Post)
+		if err != nil {
+			return err
+		}
+
+		op.Post.AuthorID = &identity.UserID
+
+		return nil
+	case models.BulkUpdate:
+		op.Post.Tags = normalizeTags(op.Post.Tags)
+
+		err := s.validatePost(op.Post)
+		if err != nil {
+			return err
+		}
+
+		action = policy.PostsUpdate
+	case models.BulkDelete:
+		action = policy.PostsDelete
+	default:
+		return apperr.Validation("unknown operation "+op.Op, nil)
+	}
+
+	post, ok := stored[op.Post.ID]
+	if !ok {
+		return apperr.NotFound("post not found", nil)
+	}
+
+	if !s.policy.AllowsOn(identity, action, post.AuthorID) {
+		return apperr.Forbidden("not allowed to change this post", nil)
+	}
+
+	if op.Post.Version != 0 && op.Post.Version != post.Version {
+		return apperr.PreconditionFailed("post has been modified", nil)
+	}
+
+	// The write only goes through if the post is still the one authorized
+	// above, otherwise a concurrent change could be overwritten unchecked.
+	op.Post.Version = post.Version
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBulkPosts(t *testing.T) {
+	authorID, otherID := 1, 2
+	author := auth.NewContext(context.Background(), models.Identity{UserID: authorID, Roles: []string{policy.RoleAuthor}})
+
+	stored := []models.Post{
+		{ID: 10, Slug: "mine", Title: "Mine", Content: "Content", Version: 2, AuthorID: &authorID, Status: models.StatusPublished},
+		{ID: 11, Slug: "theirs", Title: "Theirs", Content: "Content", Version: 1, AuthorID: &otherID, Status: models.StatusPublished},
+	}
+
+	create := models.BulkOperation{Op: models.BulkCreate, Post: models.Post{Title: "New post", Content: "Content"}}
+	created := create
+	created.Post.AuthorID = &authorID
+	created.Post.Slug = "new-post"
+	created.Post.Status = models.StatusPublished
+	created.Post.ContentFormat = models.FormatPlain
+	created.Post.ContentHTML = "<p>Content</p>"
+	update := models.BulkOperation{Op: models.BulkUpdate, Post: models.Post{ID: 10, Title: "Updated", Content: "Content"}}
+	updated := update
+	// Updates replace posts like PUT does, so without a slug one is
+	// generated from the new title.
+	updated.Post.Slug = "updated"
+	// Writes are conditional on the version that was authorized.
+	updated.Post.Version = 2
+	updated.Post.Status = models.StatusPublished
+	updated.Post.ContentFormat = models.FormatPlain
+	updated.Post.ContentHTML = "<p>Content</p>"
+	remove := models.BulkOperation{Op: models.BulkDelete, Post: models.Post{ID: 10, Version: 2}}
+	categoryID, missingCategoryID := 7, 8
+	categorized := update
+	categorized.Post.CategoryID = &categoryID
+	categorizedUpdated := updated
+	categorizedUpdated.Post.CategoryID = &categoryID
+	uncategorized := create
+	uncategorized.Post.CategoryID = &missingCategoryID
+
+	testCases := []struct {
+		ops     []models.BulkOperation
+		atomic  bool
+		applied []models.BulkOperation
+		kinds   []error
+	}{
+		{
+			ops:     []models.BulkOperation{create, update, remove},
+			atomic:  true,
+			applied: []models.BulkOperation{created, updated, remove},
+			kinds:   []error{nil, nil, nil},
+		},
+		{
+			// Invalid data, missing posts, other authors' posts and stale
+			// versions fail before anything is written.
+			ops: []models.BulkOperation{
+				create,
+				{Op: models.BulkCreate, Post: models.Post{Title: "No"}},
+				{Op: models.BulkUpdate, Post: models.Post{ID: 12, Title: "Missing", Content: "Content"}},
+				{Op: models.BulkDelete, Post: models.Post{ID: 11}},
+				{Op: models.BulkDelete, Post: models.Post{ID: 10, Version: 1}},
+				{Op: "upsert"},
+			},
+			atomic:  false,
+			applied: []models.BulkOperation{created},
+			kinds:   []error{nil, apperr.ErrValidation, apperr.ErrNotFound, apperr.ErrForbidden, apperr.ErrPreconditionFailed, apperr.ErrValidation},
+		},
+		{
+			// Categories are checked like the single-post methods check them.
+			ops:     []models.BulkOperation{categorized, uncategorized, create},
+			atomic:  false,
+			applied: []models.BulkOperation{categorizedUpdated, created},
+			kinds:   []error{nil, apperr.ErrValidation, nil},
+		},
+		{
+			// In atomic mode one failure keeps the valid operations from
+			// being applied at all.
+			ops:    []models.BulkOperation{create, {Op: models.BulkDelete, Post: models.Post{ID: 11}}},
+			atomic: true,
+			kinds:  []error{apperr.ErrAborted, apperr.ErrForbidden},
+		},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetPostsByIDs", mock.Anything, mock.Anything).Return(stored, nil)
+		mockRepo.On("GetTakenSlugs", mock.Anything, "new-post", 0).Return([]string{}, nil)
+		mockRepo.On("GetTakenSlugs", mock.Anything, "updated", 10).Return([]string{}, nil).Maybe()
+		mockRepo.On("GetCategory", mock.Anything, categoryID).Return(models.Category{ID: categoryID}, nil).Maybe()
+		mockRepo.On("GetCategory", mock.Anything, missingCategoryID).Return(models.Category{}, apperr.NotFound("category not found", nil)).Maybe()
+		if tc.applied != nil {
+			applied := make([]models.BulkResult, len(tc.applied))
+			for j, op := range tc.applied {
+				applied[j] = models.BulkResult{Index: j, Op: op.Op, ID: 20 + j}
+			}
+			mockRepo.On("BulkPosts", mock.Anything, tc.applied, tc.atomic).Return(applied, nil)
+		}
+
+		results, err := service.BulkPosts(author, tc.ops, tc.atomic)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Len(t, results, len(tc.ops), fmt.Sprintf("case %d", i))
+
+		for j, result := range results {
+			assert.Equal(t, j, result.Index, fmt.Sprintf("case %d result %d", i, j))
+			assertKind(t, tc.kinds[j], result.Err, fmt.Sprintf("case %d result %d", i, j))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestBulkPostsRepositoryResults(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	ops := []models.BulkOperation{
+		{Op: models.BulkDelete, Post: models.Post{ID: 2}},
+		{Op: models.BulkUpdate, Post: models.Post{ID: 1, Slug: "title", Title: "Bad write", Content: "Content"}},
+		{Op: models.BulkCreate, Post: models.Post{Title: "Title", Content: "Content"}},
+	}
+
+	mockRepo.On("GetPostsByIDs", mock.Anything, []int{2, 1}).Return([]models.Post{{ID: 1, Slug: "title", Version: 3}}, nil)
+	mockRepo.On("GetTakenSlugs", mock.Anything, "title", 0).Return([]string{"title"}, nil)
+	mockRepo.On("BulkPosts", mock.Anything, mock.MatchedBy(func(ops []models.BulkOperation) bool {
+		return len(ops) == 2 && ops[0].Post.Version == 3
+	}), false).Return([]models.BulkResult{
+		// The post changed after it was loaded; the caller sent no version,
+		// so this is a conflict rather than a failed precondition.
+		{Index: 0, Op: models.BulkUpdate, ID: 1, Err: apperr.PreconditionFailed("post has been modified", nil)},
+		{Index: 1, Op: models.BulkCreate, ID: 5, Post: &models.Post{ID: 5}},
+	}, nil)
+
+	results, err := service.BulkPosts(testCtx, ops, false)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, apperr.ErrNotFound)
+	assert.ErrorIs(t, results[1].Err, apperr.ErrConflict)
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, 5, results[2].ID)
+	assert.Equal(t, 2, results[2].Index)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBulkPostsSlugs(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	ops := []models.BulkOperation{
+		{Op: models.BulkCreate, Post: models.Post{Title: "Release notes", Content: "Content"}},
+		{Op: models.BulkCreate, Post: models.Post{Slug: "release-notes-3", Title: "Title", Content: "Content"}},
+		{Op: models.BulkCreate, Post: models.Post{Title: "Release notes", Content: "Content"}},
+		{Op: models.BulkCreate, Post: models.Post{Slug: "release-notes", Title: "Title", Content: "Content"}},
+	}
+
+	mockRepo.On("GetPostsByIDs", mock.Anything, []int(nil)).Return([]models.Post{}, nil)
+	mockRepo.On("GetTakenSlugs", mock.Anything, mock.Anything, 0).Return([]string{"release-notes"}, nil)
+	mockRepo.On("BulkPosts", mock.Anything, mock.MatchedBy(func(ops []models.BulkOperation) bool {
+		return len(ops) == 3 && ops[0].Post.Slug == "release-notes-2" && ops[1].Post.Slug == "release-notes-3" && ops[2].Post.Slug == "release-notes-4"
+	}), false).Return([]models.BulkResult{{Index: 0}, {Index: 1}, {Index: 2}}, nil)
+
+	results, err := service.BulkPosts(testCtx, ops, false)
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.NoError(t, results[2].Err)
+	assert.ErrorIs(t, results[3].Err, apperr.ErrConflict)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBulkPostsLimits(t *testing.T) {
+	service := NewService(new(MockRepository), testTokens, testPolicy)
+
+	_, err := service.BulkPosts(testCtx, nil, true)
+	assert.ErrorIs(t, err, apperr.ErrValidation)
+
+	_, err = service.BulkPosts(testCtx, make([]models.BulkOperation, MaxBulkOperations+1), true)
+	assert.ErrorIs(t, err, apperr.ErrValidation)
+
+	_, err = service.BulkPosts(context.Background(), []models.BulkOperation{{Op: models.BulkDelete}}, true)
+	assert.ErrorIs(t, err, apperr.ErrUnauthorized)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// slugPattern matches lower-case words of letters and digits joined by
+// single hyphens.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// GetCategories returns the category tree: top-level categories ordered by
+// name, each with its subcategories nested under it.
+func (s *Service) GetCategories(ctx context.Context) ([]models.Category, error) {
+	categories, err := s.Repo.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return categoryTree(categories), nil
+}
+
+// AddCategory stores a new category below an existing parent, or at the top
+// of the tree when ParentID is nil.
+func (s *Service) AddCategory(ctx context.Context, category models.Category) (models.Category, error) {
+	identity, err := caller(ctx)
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	if !s.policy.Allows(identity, policy.CategoriesManage) {
+		return models.Category{}, apperr.Forbidden("permission denied", nil)
+	}
+
+	err = s.validate.Struct(category)
+	if err != nil {
+		return models.Category{}, apperr.Validation("invalid category data", err)
+	}
+
+	if !slugPattern.MatchString(category.Slug) {
+		return models.Category{}, apperr.Validation("slug must consist of lower-case letters, digits and hyphens", nil)
+	}
+
+	if category.ParentID != nil {
+		_, err = s.Repo.GetCategory(ctx, *category.ParentID)
+		if errors.Is(err, apperr.ErrNotFound) {
+			return models.Category{}, apperr.Validation("parent category not found", err)
+		}
+		if err != nil {
+			return models.Category{}, err
+		}
+	}
+
+	id, err := s.Repo.AddCategory(ctx, category)
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	return s.Repo.GetCategory(ctx, id)
+}
+
+// GetCategoryPosts returns one page of the published posts in the category
+// with the given slug or in any category below it. Pagination works like
+// GetAllPosts.
+func (s *Service) GetCategoryPosts(ctx context.Context, slug string, limit int, cursor string) (models.PostsPage, error) {
+	category, err := s.Repo.GetCategoryBySlug(ctx, slug)
+	if err != nil {
+		return models.PostsPage{}, err
+	}
+
+	return s.pagePosts(ctx, limit, cursor, func(ctx context.Context, query models.PostsQuery) ([]models.Post, error) {
+		query.CategoryID = &category.ID
+		query.Status = models.StatusPublished
+		return s.Repo.GetAllPosts(ctx, query)
+	})
+}
+
+// checkCategory makes sure a post is not assigned to a category that does
+// not exist.
+func (s *Service) checkCategory(ctx context.Context, categoryID *int) error {
+	if categoryID == nil {
+		return nil
+	}
+
+	_, err := s.Repo.GetCategory(ctx, *categoryID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return apperr.Validation("category not found", err)
+	}
+
+	return err
+}
+
+// categoryTree nests categories under their parents, keeping the order of
+// categories among siblings.
+func categoryTree(categories []models.Category) []models.Category {
+	children := make(map[int][]int, len(categories))
+	var roots []int
+
+	for i, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, i)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], i)
+	}
+
+	var build func(i int) models.Category
+	build = func(i int) models.Category {
+		category := categories[i]
+		for _, child := range children[category.ID] {
+			category.Children = append(category.Children, build(child))
+		}
+		return category
+	}
+
+	tree := make([]models.Category, 0, len(roots))
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+
+	return tree
+}
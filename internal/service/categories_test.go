@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetCategories(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	news, tech := 1, 2
+	mockRepo.On("GetCategories", mock.Anything).Return([]models.Category{
+		{ID: 3, ParentID: &tech, Slug: "go", Name: "Go"},
+		{ID: 1, Slug: "news", Name: "News"},
+		{ID: 4, ParentID: &news, Slug: "releases", Name: "Releases"},
+		{ID: 2, Slug: "tech", Name: "Tech"},
+		{ID: 5, ParentID: &tech, Slug: "web", Name: "Web"},
+	}, nil).Once()
+
+	tree, err := service.GetCategories(testCtx)
+	assert.NoError(t, err)
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "news", tree[0].Slug)
+	assert.Equal(t, "releases", tree[0].Children[0].Slug)
+	assert.Equal(t, "tech", tree[1].Slug)
+	assert.Len(t, tree[1].Children, 2)
+	assert.Equal(t, "go", tree[1].Children[0].Slug)
+	assert.Equal(t, "web", tree[1].Children[1].Slug)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAddCategory(t *testing.T) {
+	author := auth.NewContext(context.Background(), models.Identity{UserID: 2, Roles: []string{policy.RoleAuthor}})
+	parentID := 1
+	missingID := 9
+
+	testCases := []struct {
+		ctx      context.Context
+		category models.Category
+		kind     error
+	}{
+		{ctx: testCtx, category: models.Category{Slug: "news", Name: "News"}},
+		{ctx: testCtx, category: models.Category{ParentID: &parentID, Slug: "go-1-22", Name: "Go 1.22"}},
+		{ctx: author, category: models.Category{Slug: "news", Name: "News"}, kind: apperr.ErrForbidden},
+		{ctx: testCtx, category: models.Category{Slug: "", Name: "News"}, kind: apperr.ErrValidation},
+		{ctx: testCtx, category: models.Category{Slug: "Big News", Name: "News"}, kind: apperr.ErrValidation},
+		{ctx: testCtx, category: models.Category{Slug: "news-", Name: "News"}, kind: apperr.ErrValidation},
+		{ctx: testCtx, category: models.Category{ParentID: &missingID, Slug: "news", Name: "News"}, kind: apperr.ErrValidation},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetCategory", mock.Anything, parentID).Return(models.Category{ID: parentID}, nil).Maybe()
+		mockRepo.On("GetCategory", mock.Anything, missingID).Return(models.Category{}, apperr.NotFound("category not found", nil)).Maybe()
+		if tc.kind == nil {
+			mockRepo.On("AddCategory", mock.Anything, tc.category).Return(7, nil).Once()
+			mockRepo.On("GetCategory", mock.Anything, 7).Return(models.Category{ID: 7, Slug: tc.category.Slug}, nil).Once()
+		}
+
+		category, err := service.AddCategory(tc.ctx, tc.category)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, 7, category.ID, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestGetCategoryPosts(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	categoryID := 3
+	posts := []models.Post{{ID: 1, Title: "Test Title 1", CategoryID: &categoryID}}
+
+	mockRepo.On("GetCategoryBySlug", mock.Anything, "tech").Return(models.Category{ID: categoryID, Slug: "tech"}, nil).Once()
+	mockRepo.On("GetAllPosts", mock.Anything, models.PostsQuery{Limit: DefaultPageSize + 1, CategoryID: &categoryID, PostsFilter: models.PostsFilter{Status: models.StatusPublished}}).Return(posts, nil).Once()
+	mockRepo.On("GetCategoryBySlug", mock.Anything, "missing").Return(models.Category{}, apperr.NotFound("category not found", nil)).Once()
+
+	page, err := service.GetCategoryPosts(testCtx, "tech", 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, posts, page.Posts)
+
+	_, err = service.GetCategoryPosts(testCtx, "missing", 0, "")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAddPostCategory(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	missingID := 9
+	mockRepo.On("GetCategory", mock.Anything, missingID).Return(models.Category{}, apperr.NotFound("category not found", nil)).Once()
+
+	_, err := service.AddPost(testCtx, models.Post{Title: "Test Title", Content: "Test Content", CategoryID: &missingID})
+	assert.ErrorIs(t, err, apperr.ErrValidation)
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// GetComments returns the comments on a post as threads: top-level comments
+// oldest first, each with its replies nested under it.
+func (s *Service) GetComments(ctx context.Context, postID int) ([]models.Comment, error) {
+	_, err := s.readablePost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := s.Repo.GetComments(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	return threadComments(comments), nil
+}
+
+// AddComment stores a comment on a post written by the caller. A reply must
+// answer a comment on the same post.
+func (s *Service) AddComment(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	identity, err := caller(ctx)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if !s.policy.Allows(identity, policy.CommentsCreate) {
+		return models.Comment{}, apperr.Forbidden("permission denied", nil)
+	}
+
+	err = s.validate.Struct(comment)
+	if err != nil {
+		return models.Comment{}, apperr.Validation("invalid comment data", err)
+	}
+
+	_, err = s.readablePost(ctx, comment.PostID)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if comment.ParentID != nil {
+		_, err = s.Repo.GetComment(ctx, comment.PostID, *comment.ParentID)
+		if errors.Is(err, apperr.ErrNotFound) {
+			return models.Comment{}, apperr.Validation("parent comment not found on this post", err)
+		}
+		if err != nil {
+			return models.Comment{}, err
+		}
+	}
+
+	comment.AuthorID = &identity.UserID
+
+	id, err := s.Repo.AddComment(ctx, comment)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return s.Repo.GetComment(ctx, comment.PostID, id)
+}
+
+// UpdateComment changes the content of a comment.
+func (s *Service) UpdateComment(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	err := s.validate.Struct(comment)
+	if err != nil {
+		return models.Comment{}, apperr.Validation("invalid comment data", err)
+	}
+
+	stored, err := s.authorizeComment(ctx, policy.CommentsUpdate, comment.PostID, comment.ID)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	stored.Content = comment.Content
+
+	err = s.Repo.UpdateComment(ctx, stored)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return s.Repo.GetComment(ctx, comment.PostID, comment.ID)
+}
+
+// DeleteComment deletes a comment and all replies to it.
+func (s *Service) DeleteComment(ctx context.Context, postID int, id int) error {
+	_, err := s.authorizeComment(ctx, policy.CommentsDelete, postID, id)
+	if err != nil {
+		return err
+	}
+
+	return s.Repo.DeleteComment(ctx, postID, id)
+}
+
+// authorizeComment loads a comment on a visible post and checks that the
+// caller may perform action on it.
+func (s *Service) authorizeComment(ctx context.Context, action string, postID int, id int) (models.Comment, error) {
+	identity, err := caller(ctx)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	_, err = s.readablePost(ctx, postID)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, err := s.Repo.GetComment(ctx, postID, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if !s.policy.AllowsOn(identity, action, comment.AuthorID) {
+		return models.Comment{}, apperr.Forbidden("not allowed to change this comment", nil)
+	}
+
+	return comment, nil
+}
+
+// threadComments nests replies under the comments they answer, keeping the
+// order of comments among siblings.
+func threadComments(comments []models.Comment) []models.Comment {
+	children := make(map[int][]int, len(comments))
+	var roots []int
+
+	for i, comment := range comments {
+		if comment.ParentID == nil {
+			roots = append(roots, i)
+			continue
+		}
+		children[*comment.ParentID] = append(children[*comment.ParentID], i)
+	}
+
+	var build func(i int) models.Comment
+	build = func(i int) models.Comment {
+		comment := comments[i]
+		for _, child := range children[comment.ID] {
+			comment.Replies = append(comment.Replies, build(child))
+		}
+		return comment
+	}
+
+	threads := make([]models.Comment, 0, len(roots))
+	for _, i := range roots {
+		threads = append(threads, build(i))
+	}
+
+	return threads
+}
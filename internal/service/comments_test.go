@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetComments(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	one, two, three := 1, 2, 3
+	comments := []models.Comment{
+		{ID: 1, PostID: 1, Content: "first"},
+		{ID: 2, PostID: 1, ParentID: &one, Content: "reply to first"},
+		{ID: 3, PostID: 1, ParentID: &two, Content: "reply to reply"},
+		{ID: 4, PostID: 1, Content: "second"},
+		{ID: 5, PostID: 1, ParentID: &one, Content: "another reply to first"},
+		{ID: 6, PostID: 1, ParentID: &three, Content: "deep reply"},
+	}
+
+	mockRepo.On("GetPost", mock.Anything, 1).Return(models.Post{ID: 1, Status: models.StatusPublished}, nil)
+	mockRepo.On("GetComments", mock.Anything, 1).Return(comments, nil)
+
+	threads, err := service.GetComments(context.Background(), 1)
+	assert.NoError(t, err)
+
+	expected := []models.Comment{
+		{ID: 1, PostID: 1, Content: "first", Replies: []models.Comment{
+			{ID: 2, PostID: 1, ParentID: &one, Content: "reply to first", Replies: []models.Comment{
+				{ID: 3, PostID: 1, ParentID: &two, Content: "reply to reply", Replies: []models.Comment{
+					{ID: 6, PostID: 1, ParentID: &three, Content: "deep reply"},
+				}},
+			}},
+			{ID: 5, PostID: 1, ParentID: &one, Content: "another reply to first"},
+		}},
+		{ID: 4, PostID: 1, Content: "second"},
+	}
+	assert.Equal(t, expected, threads)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetCommentsPostNotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	mockRepo.On("GetPost", mock.Anything, 1).Return(models.Post{}, apperr.NotFound("post not found", nil))
+
+	_, err := service.GetComments(context.Background(), 1)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAddComment(t *testing.T) {
+	parentID := 3
+	viewer := auth.NewContext(context.Background(), models.Identity{UserID: 2, Roles: []string{policy.RoleViewer}})
+
+	testCases := []struct {
+		ctx       context.Context
+		comment   models.Comment
+		parentErr error
+		kind      error
+	}{
+		{ctx: viewer, comment: models.Comment{PostID: 1, Content: "Nice post"}},
+		{ctx: viewer, comment: models.Comment{PostID: 1, ParentID: &parentID, Content: "Agreed"}},
+		{ctx: viewer, comment: models.Comment{PostID: 1, ParentID: &parentID, Content: "Agreed"}, parentErr: apperr.NotFound("comment not found", nil), kind: apperr.ErrValidation},
+		{ctx: viewer, comment: models.Comment{PostID: 1}, kind: apperr.ErrValidation},
+		{ctx: context.Background(), comment: models.Comment{PostID: 1, Content: "Nice post"}, kind: apperr.ErrUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetPost", mock.Anything, 1).Return(models.Post{ID: 1, Status: models.StatusPublished}, nil).Maybe()
+		mockRepo.On("GetComment", mock.Anything, 1, parentID).Return(models.Comment{ID: parentID, PostID: 1}, tc.parentErr).Maybe()
+		if tc.kind == nil {
+			expected := tc.comment
+			authorID := 2
+			expected.AuthorID = &authorID
+			mockRepo.On("AddComment", mock.Anything, expected).Return(7, nil)
+			mockRepo.On("GetComment", mock.Anything, 1, 7).Return(models.Comment{ID: 7, PostID: 1, AuthorID: &authorID, Content: tc.comment.Content}, nil)
+		}
+
+		comment, err := service.AddComment(tc.ctx, tc.comment)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, 7, comment.ID, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestCommentOwnership(t *testing.T) {
+	authorID := 1
+	stored := models.Comment{ID: 2, PostID: 1, AuthorID: &authorID, Content: "Old"}
+
+	as := func(userID int, role string) context.Context {
+		return auth.NewContext(context.Background(), models.Identity{UserID: userID, Roles: []string{role}})
+	}
+
+	testCases := []struct {
+		ctx context.Context
+		// status is the status of the post, published if empty.
+		status    string
+		updateErr error
+		deleteErr error
+	}{
+		{ctx: as(1, policy.RoleViewer)},
+		{ctx: as(1, policy.RoleViewer), status: models.StatusDraft, updateErr: apperr.ErrNotFound, deleteErr: apperr.ErrNotFound},
+		{ctx: as(2, policy.RoleEditor), status: models.StatusDraft, updateErr: apperr.ErrForbidden},
+		{ctx: as(2, policy.RoleViewer), updateErr: apperr.ErrForbidden, deleteErr: apperr.ErrForbidden},
+		{ctx: as(2, policy.RoleAuthor), updateErr: apperr.ErrForbidden, deleteErr: apperr.ErrForbidden},
+		{ctx: as(2, policy.RoleEditor), updateErr: apperr.ErrForbidden},
+		{ctx: as(2, policy.RoleAdmin), updateErr: apperr.ErrForbidden},
+		{ctx: context.Background(), updateErr: apperr.ErrUnauthorized, deleteErr: apperr.ErrUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		status := tc.status
+		if status == "" {
+			status = models.StatusPublished
+		}
+
+		mockRepo.On("GetPost", mock.Anything, 1).Return(models.Post{ID: 1, Status: status}, nil).Maybe()
+		mockRepo.On("GetComment", mock.Anything, 1, 2).Return(stored, nil).Maybe()
+		if tc.updateErr == nil {
+			updated := stored
+			updated.Content = "New"
+			mockRepo.On("UpdateComment", mock.Anything, updated).Return(nil)
+		}
+		if tc.deleteErr == nil {
+			mockRepo.On("DeleteComment", mock.Anything, 1, 2).Return(nil)
+		}
+
+		_, err := service.UpdateComment(tc.ctx, models.Comment{ID: 2, PostID: 1, Content: "New"})
+		assertKind(t, tc.updateErr, err, fmt.Sprintf("case %d update", i))
+
+		err = service.DeleteComment(tc.ctx, 1, 2)
+		assertKind(t, tc.deleteErr, err, fmt.Sprintf("case %d delete", i))
+
+		mockRepo.AssertExpectations(t)
+	}
+}
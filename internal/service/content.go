@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/pkg/render"
+	"github.com/rostis232/prmv/models"
+)
+
+// renderContent renders the content of post to sanitized HTML. A post
+// without a content format keeps currentFormat, which is empty for new
+// posts, and new posts default to plain text.
+func renderContent(post *models.Post, currentFormat string) error {
+	var err error
+
+	if post.ContentFormat == "" {
+		post.ContentFormat = currentFormat
+	}
+
+	if post.ContentFormat == "" {
+		post.ContentFormat = models.FormatPlain
+	}
+
+	switch post.ContentFormat {
+	case models.FormatPlain:
+		post.ContentHTML = render.Plain(post.Content)
+	case models.FormatMarkdown:
+		post.ContentHTML, err = render.Markdown(post.Content)
+	case models.FormatHTML:
+		post.ContentHTML, err = render.Sanitize(post.Content)
+	default:
+		return apperr.Validation("unknown content format "+post.ContentFormat, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("error rendering post content: %w", err)
+	}
+
+	return nil
+}
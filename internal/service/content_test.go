@@ -0,0 +1,57 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRenderContent(t *testing.T) {
+	testCases := []struct {
+		post    models.Post
+		current string
+		format  string
+		html    string
+		kind    error
+	}{
+		{post: models.Post{Content: "a < b"}, format: models.FormatPlain, html: "<p>a &lt; b</p>"},
+		{post: models.Post{Content: "**bold**"}, current: models.FormatMarkdown, format: models.FormatMarkdown, html: "<p><strong>bold</strong></p>\n"},
+		{post: models.Post{Content: "**bold**", ContentFormat: models.FormatPlain}, current: models.FormatMarkdown, format: models.FormatPlain, html: "<p>**bold**</p>"},
+		{post: models.Post{Content: `<p onclick="x()">Hi</p><script>x()</script>`, ContentFormat: models.FormatHTML}, format: models.FormatHTML, html: "<p>Hi</p>"},
+		{post: models.Post{Content: "Hi", ContentFormat: "rst"}, kind: apperr.ErrValidation},
+	}
+
+	for i, tc := range testCases {
+		post := tc.post
+
+		err := renderContent(&post, tc.current)
+		assertKind(t, tc.kind, err, fmt.Sprintf("case %d", i))
+
+		if tc.kind == nil {
+			assert.Equal(t, tc.format, post.ContentFormat, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.html, post.ContentHTML, fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+func TestAddPostMarkdown(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	authorID := 1
+	stored := models.Post{Slug: "test-title", Title: "Test Title", Content: "# Heading", ContentFormat: models.FormatMarkdown,
+		ContentHTML: "<h1>Heading</h1>\n", AuthorID: &authorID, Status: models.StatusPublished}
+	mockRepo.On("GetTakenSlugs", mock.Anything, "test-title", 0).Return([]string{}, nil).Once()
+	mockRepo.On("AddPost", mock.Anything, stored).Return(1, nil).Once()
+	mockRepo.On("GetPost", mock.Anything, 1).Return(stored, nil).Once()
+
+	result, err := service.AddPost(testCtx, models.Post{Title: "Test Title", Content: "# Heading", ContentFormat: models.FormatMarkdown})
+	assert.NoError(t, err)
+	assert.Equal(t, stored.ContentHTML, result.ContentHTML)
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rostis232/prmv/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor turns a cursor into the opaque string handed out to clients.
+func EncodeCursor(cursor models.Cursor) string {
+	raw := fmt.Sprintf("%d:%d", cursor.CreatedAt.UnixNano(), cursor.ID)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a string produced by EncodeCursor.
+func DecodeCursor(s string) (models.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return models.Cursor{}, ErrInvalidCursor
+	}
+
+	nanosStr, idStr, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return models.Cursor{}, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(nanosStr, 10, 64)
+	if err != nil {
+		return models.Cursor{}, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 {
+		return models.Cursor{}, ErrInvalidCursor
+	}
+
+	return models.Cursor{
+		CreatedAt: time.Unix(0, nanos).UTC(),
+		ID:        id,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/pkg/jsonpatch"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// patchablePost is the JSON document patches are applied to. Only the fields
+// a client may change are included, so patches touching anything else fail.
+type patchablePost struct {
+	Slug          string     `json:"slug"`
+	Title         string     `json:"title"`
+	Content       string     `json:"content"`
+	ContentFormat string     `json:"content_format"`
+	CategoryID    *int       `json:"category_id"`
+	Tags          []string   `json:"tags"`
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at"`
+}
+
+// PatchPost applies a JSON Merge Patch or JSON Patch to the stored post,
+// validates the result and stores it only if nobody changed the post meanwhile.
+func (s *Service) PatchPost(ctx context.Context, id int, patch models.PostPatch) (models.Post, error) {
+	post, err := s.Repo.GetPostForUpdate(ctx, id)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.authorizePost(ctx, policy.PostsUpdate, post)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if patch.Version != 0 && patch.Version != post.Version {
+		return models.Post{}, apperr.PreconditionFailed("post has been modified", nil)
+	}
+
+	doc, err := json.Marshal(patchablePost{
+		Slug:          post.Slug,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: post.ContentFormat,
+		CategoryID:    post.CategoryID,
+		Tags:          post.Tags,
+		Status:        post.Status,
+		PublishAt:     post.PublishAt,
+	})
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	var patched []byte
+
+	switch patch.Type {
+	case jsonpatch.MergePatchType:
+		patched, err = jsonpatch.MergePatch(doc, patch.Document)
+	case jsonpatch.JSONPatchType:
+		patched, err = jsonpatch.Apply(doc, patch.Document)
+	default:
+		return models.Post{}, apperr.Validation("unsupported patch type", nil)
+	}
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return models.Post{}, apperr.Conflict("patch test operation failed", err)
+		}
+		return models.Post{}, apperr.Validation("patch cannot be applied", err)
+	}
+
+	var fields patchablePost
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&fields)
+	if err != nil {
+		return models.Post{}, apperr.Validation("patch changes fields that cannot be modified", err)
+	}
+
+	current := post
+
+	post.Slug = fields.Slug
+	post.Title = fields.Title
+	post.Content = fields.Content
+	post.ContentFormat = fields.ContentFormat
+	post.CategoryID = fields.CategoryID
+	post.Tags = normalizeTags(fields.Tags)
+	post.Status = fields.Status
+	post.PublishAt = fields.PublishAt
+
+	err = s.validatePost(post)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = settleStatus(&post, current, time.Now().UTC())
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = renderContent(&post, current.ContentFormat)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.assignSlug(ctx, &post, current.Slug, nil)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return s.savePost(ctx, post, patch.Version)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/pkg/diff"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// GetRevisions returns the revisions of a post, newest first. The latest
+// revision always matches the current post.
+func (s *Service) GetRevisions(ctx context.Context, id int) ([]models.Revision, error) {
+	_, err := s.readablePost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.Repo.GetRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetRevision returns the post as it was at the given version.
+func (s *Service) GetRevision(ctx context.Context, id int, version int) (models.Revision, error) {
+	_, err := s.readablePost(ctx, id)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	revision, err := s.Repo.GetRevision(ctx, id, version)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	return revision, nil
+}
+
+// RevertPost restores the title, content and content format of the given
+// revision. The revert is stored as a new version, so it can be reverted
+// itself. A non-zero expectedVersion must match the stored version.
+func (s *Service) RevertPost(ctx context.Context, id int, revision int, expectedVersion int) (models.Post, error) {
+	post, err := s.Repo.GetPostForUpdate(ctx, id)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.authorizePost(ctx, policy.PostsUpdate, post)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if expectedVersion != 0 && expectedVersion != post.Version {
+		return models.Post{}, apperr.PreconditionFailed("post has been modified", nil)
+	}
+
+	rev, err := s.Repo.GetRevision(ctx, id, revision)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post.Title = rev.Title
+	post.Content = rev.Content
+	post.ContentFormat = rev.ContentFormat
+
+	err = renderContent(&post, models.FormatPlain)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return s.savePost(ctx, post, expectedVersion)
+}
+
+// DiffRevisions returns a unified diff from revision from to revision to of a
+// post. A zero to means the current version.
+func (s *Service) DiffRevisions(ctx context.Context, id int, from int, to int) (string, error) {
+	post, err := s.readablePost(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if to == 0 {
+		to = post.Version
+	}
+
+	fromRev, err := s.Repo.GetRevision(ctx, id, from)
+	if err != nil {
+		return "", err
+	}
+
+	toRev, err := s.Repo.GetRevision(ctx, id, to)
+	if err != nil {
+		return "", err
+	}
+
+	return diff.Unified(revisionName(fromRev), revisionName(toRev), revisionText(fromRev), revisionText(toRev)), nil
+}
+
+func revisionName(rev models.Revision) string {
+	return fmt.Sprintf("posts/%d/revisions/%d", rev.PostID, rev.Version)
+}
+
+// revisionText renders a revision as the text that is diffed: the title, a
+// blank line and the content.
+func revisionText(rev models.Revision) string {
+	return rev.Title + "\n\n" + rev.Content + "\n"
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// GetRoles lists the known roles and their permissions.
+func (s *Service) GetRoles(ctx context.Context) ([]models.Role, error) {
+	err := s.authorizeRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.policy.Roles(), nil
+}
+
+// GetUserRoles returns the roles granted to a user.
+func (s *Service) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	err := s.authorizeRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.Repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.Repo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// AssignRole grants role to a user and returns the user's roles.
+func (s *Service) AssignRole(ctx context.Context, userID int, role string) ([]string, error) {
+	err := s.checkRoleChange(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Repo.AssignRole(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repo.GetUserRoles(ctx, userID)
+}
+
+// RevokeRole takes role away from a user and returns the user's roles. Admins
+// cannot revoke their own admin role, so there is always someone left to
+// manage roles.
+func (s *Service) RevokeRole(ctx context.Context, userID int, role string) ([]string, error) {
+	err := s.checkRoleChange(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, _ := caller(ctx)
+	if identity.UserID == userID && role == policy.RoleAdmin {
+		return nil, apperr.Conflict("cannot revoke own admin role", nil)
+	}
+
+	err = s.Repo.RevokeRole(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repo.GetUserRoles(ctx, userID)
+}
+
+func (s *Service) checkRoleChange(ctx context.Context, userID int, role string) error {
+	err := s.authorizeRoles(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !s.policy.HasRole(role) {
+		return apperr.NotFound("role not found", nil)
+	}
+
+	_, err = s.Repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) authorizeRoles(ctx context.Context) error {
+	identity, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !s.policy.Allows(identity, policy.RolesAssign) {
+		return apperr.Forbidden("permission denied", nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetRoles(t *testing.T) {
+	service := NewService(new(MockRepository), testTokens, testPolicy)
+
+	roles, err := service.GetRoles(testCtx)
+	assert.NoError(t, err)
+	assert.Len(t, roles, 4)
+	assert.Equal(t, policy.RoleAdmin, roles[0].Name)
+
+	author := auth.NewContext(context.Background(), models.Identity{UserID: 2, Roles: []string{policy.RoleAuthor}})
+	_, err = service.GetRoles(author)
+	assert.ErrorIs(t, err, apperr.ErrForbidden)
+}
+
+func TestAssignRole(t *testing.T) {
+	author := auth.NewContext(context.Background(), models.Identity{UserID: 2, Roles: []string{policy.RoleAuthor}})
+
+	testCases := []struct {
+		ctx     context.Context
+		userID  int
+		role    string
+		userErr error
+		kind    error
+	}{
+		{ctx: testCtx, userID: 2, role: policy.RoleEditor},
+		{ctx: testCtx, userID: 2, role: "root", kind: apperr.ErrNotFound},
+		{ctx: testCtx, userID: 3, role: policy.RoleEditor, userErr: apperr.NotFound("user not found", nil), kind: apperr.ErrNotFound},
+		{ctx: author, userID: 2, role: policy.RoleAdmin, kind: apperr.ErrForbidden},
+		{ctx: context.Background(), userID: 2, role: policy.RoleEditor, kind: apperr.ErrUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetUser", mock.Anything, tc.userID).Return(models.User{ID: tc.userID}, tc.userErr).Maybe()
+		if tc.kind == nil {
+			mockRepo.On("AssignRole", mock.Anything, tc.userID, tc.role).Return(nil)
+			mockRepo.On("GetUserRoles", mock.Anything, tc.userID).Return([]string{policy.RoleAuthor, tc.role}, nil)
+		}
+
+		roles, err := service.AssignRole(tc.ctx, tc.userID, tc.role)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, []string{policy.RoleAuthor, tc.role}, roles, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestRevokeRole(t *testing.T) {
+	testCases := []struct {
+		userID int
+		role   string
+		kind   error
+	}{
+		{userID: 2, role: policy.RoleAdmin},
+		{userID: 1, role: policy.RoleEditor},
+		{userID: 1, role: policy.RoleAdmin, kind: apperr.ErrConflict},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetUser", mock.Anything, tc.userID).Return(models.User{ID: tc.userID}, nil)
+		if tc.kind == nil {
+			mockRepo.On("RevokeRole", mock.Anything, tc.userID, tc.role).Return(nil)
+			mockRepo.On("GetUserRoles", mock.Anything, tc.userID).Return([]string{policy.RoleAuthor}, nil)
+		}
+
+		_, err := service.RevokeRole(testCtx, tc.userID, tc.role)
+		assertKind(t, tc.kind, err, fmt.Sprintf("case %d", i))
+
+		mockRepo.AssertExpectations(t)
+	}
+}
@@ -1,34 +1,149 @@
 package service
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
 	"github.com/rostis232/prmv/models"
 )
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
 type Service struct {
-	Repo Repository
+	Repo     Repository
+	validate *validator.Validate
+	tokens   *auth.Tokens
+	policy   *policy.Engine
+	// scheduled wakes up the scheduler when a post is scheduled.
+	scheduled chan struct{}
+	// files keeps the files of attachments, see SetStorage.
+	files             Storage
+	maxAttachmentSize int64
 }
 
 type Repository interface {
-	AddPost(post models.Post) (int, error)
-	GetAllPosts() ([]models.Post, error)
-	UpdatePost(post models.Post) (int, error)
-	GetPost(id int) (models.Post, error)
-	DeletePost(id int) error
+	AddPost(ctx context.Context, post models.Post) (int, error)
+	GetAllPosts(ctx context.Context, query models.PostsQuery) ([]models.Post, error)
+	GetPostsStats(ctx context.Context, query models.PostsQuery) (models.PostsStats, error)
+	UpdatePost(ctx context.Context, post models.Post) (int, error)
+	GetPost(ctx context.Context, id int) (models.Post, error)
+	// GetPostForUpdate is GetPost for reads a write depends on, which must
+	// never be answered from a cache.
+	GetPostForUpdate(ctx context.Context, id int) (models.Post, error)
+	GetPostBySlug(ctx context.Context, slug string) (models.Post, error)
+	GetTakenSlugs(ctx context.Context, base string, postID int) ([]string, error)
+	DeletePost(ctx context.Context, id int, version int) error
+	GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error)
+	BulkPosts(ctx context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error)
+	GetTrash(ctx context.Context, query models.PostsQuery) ([]models.Post, error)
+	GetTrashedPost(ctx context.Context, id int) (models.Post, error)
+	RestorePost(ctx context.Context, id int) error
+	PurgePost(ctx context.Context, id int) error
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+	PublishScheduledPosts(ctx context.Context) (int64, error)
+	GetNextPublishAt(ctx context.Context) (time.Time, error)
+	GetRevisions(ctx context.Context, postID int) ([]models.Revision, error)
+	GetRevision(ctx context.Context, postID int, version int) (models.Revision, error)
+	AddUser(ctx context.Context, user models.User) (int, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (models.User, error)
+	AddRefreshToken(ctx context.Context, token models.RefreshToken) error
+	ConsumeRefreshToken(ctx context.Context, tokenHash string) (int, error)
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	AssignRole(ctx context.Context, userID int, role string) error
+	RevokeRole(ctx context.Context, userID int, role string) error
+	AddAPIKey(ctx context.Context, key models.APIKey) (int, error)
+	GetAPIKey(ctx context.Context, id int, userID int) (models.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int, userID int) error
+	GetTags(ctx context.Context) ([]models.Tag, error)
+	AddCategory(ctx context.Context, category models.Category) (int, error)
+	GetCategories(ctx context.Context) ([]models.Category, error)
+	GetCategory(ctx context.Context, id int) (models.Category, error)
+	GetCategoryBySlug(ctx context.Context, slug string) (models.Category, error)
+	AddComment(ctx context.Context, comment models.Comment) (int, error)
+	GetComments(ctx context.Context, postID int) ([]models.Comment, error)
+	GetComment(ctx context.Context, postID int, id int) (models.Comment, error)
+	UpdateComment(ctx context.Context, comment models.Comment) error
+	DeleteComment(ctx context.Context, postID int, id int) error
+	AddAttachment(ctx context.Context, attachment models.Attachment) (int, error)
+	GetAttachments(ctx context.Context, postID int) ([]models.Attachment, error)
+	GetAttachment(ctx context.Context, postID int, id int) (models.Attachment, error)
+	DetachAttachment(ctx context.Context, postID int, id int) error
+	GetDetachedAttachments(ctx context.Context) ([]models.Attachment, error)
+	DeleteAttachment(ctx context.Context, id int) error
 }
 
-func NewService(repo Repository) *Service {
+func NewService(repo Repository, tokens *auth.Tokens, engine *policy.Engine) *Service {
 	return &Service{
-		Repo: repo,
+		Repo:     repo,
+		validate: validator.New(),
+		tokens:   tokens,
+		policy:   engine,
+
+		scheduled: make(chan struct{}, 1),
 	}
 }
 
-func (s *Service) AddPost(newPost models.Post) (models.Post, error) {
-	id, err := s.Repo.AddPost(newPost)
+// AddPost stores a new post written by the caller. It is published unless it
+// has another status.
+func (s *Service) AddPost(ctx context.Context, newPost models.Post) (models.Post, error) {
+	identity, err := caller(ctx)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if !s.policy.Allows(identity, policy.PostsCreate) {
+		return models.Post{}, apperr.Forbidden("permission denied", nil)
+	}
+
+	newPost.Tags = normalizeTags(newPost.Tags)
+
+	err = s.validatePost(newPost)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.checkCategory(ctx, newPost.CategoryID)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = settleStatus(&newPost, models.Post{}, time.Now().UTC())
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = renderContent(&newPost, "")
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.assignSlug(ctx, &newPost, "", nil)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	newPost.AuthorID = &identity.UserID
+
+	id, err := s.Repo.AddPost(ctx, newPost)
 	if err != nil {
 		return models.Post{}, err
 	}
 
-	post, err := s.Repo.GetPost(id)
+	s.notifyScheduled(newPost)
+
+	post, err := s.Repo.GetPost(ctx, id)
 	if err != nil {
 		return models.Post{}, err
 	}
@@ -36,35 +151,230 @@ func (s *Service) AddPost(newPost models.Post) (models.Post, error) {
 	return post, nil
 }
 
-func (s *Service) GetAllPosts() ([]models.Post, error) {
-	posts, err := s.Repo.GetAllPosts()
+// GetAllPosts returns one page of posts matching filter, ordered by creation
+// time. The limit is clamped to MaxPageSize and cursor is the next_cursor of
+// the previous page. Only published posts are listed unless filter asks for
+// another status, which needs permission to update posts; callers that may
+// only update their own posts then only see their own.
+func (s *Service) GetAllPosts(ctx context.Context, limit int, cursor string, filter models.PostsFilter) (models.PostsPage, error) {
+	listing, err := s.listingQuery(ctx, filter)
+	if err != nil {
+		return models.PostsPage{}, err
+	}
+
+	return s.pagePosts(ctx, limit, cursor, func(ctx context.Context, query models.PostsQuery) ([]models.Post, error) {
+		query.PostsFilter = listing.PostsFilter
+		query.AuthorID = listing.AuthorID
+		return s.Repo.GetAllPosts(ctx, query)
+	})
+}
+
+// listingQuery returns the query for the posts GetAllPosts lists for filter,
+// after checking that the caller may see them.
+func (s *Service) listingQuery(ctx context.Context, filter models.PostsFilter) (models.PostsQuery, error) {
+	filter.Tags = normalizeTags(filter.Tags)
+
+	if filter.Status == "" {
+		filter.Status = models.StatusPublished
+	}
+
+	query := models.PostsQuery{PostsFilter: filter}
+
+	if filter.Status != models.StatusPublished {
+		identity, err := caller(ctx)
+		if err != nil {
+			return models.PostsQuery{}, err
+		}
+
+		if !s.policy.AllowsSome(identity, policy.PostsUpdate) {
+			return models.PostsQuery{}, apperr.Forbidden("permission denied", nil)
+		}
+
+		if !s.policy.Allows(identity, policy.PostsUpdate+":"+policy.ScopeAny) {
+			query.AuthorID = &identity.UserID
+		}
+	}
+
+	return query, nil
+}
+
+// pagePosts fetches one page through list, asking for one extra post to find
+// out whether there is a next page.
+func (s *Service) pagePosts(ctx context.Context, limit int, cursor string,
+	list func(context.Context, models.PostsQuery) ([]models.Post, error)) (models.PostsPage, error) {
+	if limit < 1 {
+		limit = DefaultPageSize
+	}
+
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	query := models.PostsQuery{Limit: limit + 1}
+
+	if cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return models.PostsPage{}, err
+		}
+		query.After = &after
+	}
+
+	posts, err := list(ctx, query)
+	if err != nil {
+		return models.PostsPage{}, err
+	}
+
+	page := models.PostsPage{Posts: posts}
+
+	if len(posts) > limit {
+		page.Posts = posts[:limit]
+		last := page.Posts[limit-1]
+		page.NextCursor = EncodeCursor(models.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	return page, nil
+}
+
+// GetPostsStats returns the size and last modification time of the posts
+// GetAllPosts lists for cursor and filter, after the same checks of the
+// caller and the cursor. It is cheap compared to GetAllPosts and serves as a
+// validator for conditional requests.
+func (s *Service) GetPostsStats(ctx context.Context, cursor string, filter models.PostsFilter) (models.PostsStats, error) {
+	query, err := s.listingQuery(ctx, filter)
+	if err != nil {
+		return models.PostsStats{}, err
+	}
+
+	if cursor != "" {
+		_, err = DecodeCursor(cursor)
+		if err != nil {
+			return models.PostsStats{}, err
+		}
+	}
+
+	stats, err := s.Repo.GetPostsStats(ctx, query)
 	if err != nil {
-		return []models.Post{}, err
+		return models.PostsStats{}, err
 	}
 
-	return posts, nil
+	return stats, nil
 }
 
-func (s *Service) UpdatePost(updatedPost models.Post) (models.Post, error) {
-	post, err := s.Repo.GetPost(updatedPost.ID)
+// UpdatePost replaces the stored post with updatedPost. A slug, content format
+// or status left out of updatedPost is set as for a new post rather than kept.
+// A non-zero updatedPost.Version must match the stored version, otherwise
+// apperr.ErrPreconditionFailed is returned.
+func (s *Service) UpdatePost(ctx context.Context, updatedPost models.Post) (models.Post, error) {
+	updatedPost.Tags = normalizeTags(updatedPost.Tags)
+
+	err := s.validatePost(updatedPost)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err := s.Repo.GetPostForUpdate(ctx, updatedPost.ID)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.authorizePost(ctx, policy.PostsUpdate, post)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if updatedPost.Version != 0 && updatedPost.Version != post.Version {
+		return models.Post{}, apperr.PreconditionFailed("post has been modified", nil)
+	}
+
+	current := post
+
+	post.Slug = updatedPost.Slug
+	post.Title = updatedPost.Title
+	post.Content = updatedPost.Content
+	post.ContentFormat = updatedPost.ContentFormat
+	post.CategoryID = updatedPost.CategoryID
+	post.Tags = updatedPost.Tags
+	post.Status = updatedPost.Status
+	post.PublishAt = updatedPost.PublishAt
+
+	replaceDefaults(&post)
+
+	err = settleStatus(&post, current, time.Now().UTC())
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = renderContent(&post, current.ContentFormat)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.assignSlug(ctx, &post, replacedSlug(post, current.Slug), nil)
 	if err != nil {
 		return models.Post{}, err
 	}
 
-	if updatedPost.Title != "" && post.Title != updatedPost.Title {
-		post.Title = updatedPost.Title
+	return s.savePost(ctx, post, updatedPost.Version)
+}
+
+// replaceDefaults gives the content format and status a full replacement
+// leaves out the defaults of a new post instead of the stored ones.
+func replaceDefaults(post *models.Post) {
+	post.ContentFormat = cmp.Or(post.ContentFormat, models.FormatPlain)
+	post.Status = cmp.Or(post.Status, models.StatusPublished)
+}
+
+// replacedSlug is the current slug to hand assignSlug for a full replacement:
+// none if post has no slug, so that it gets one generated from its title like
+// a new post instead of keeping the stored one.
+func replacedSlug(post models.Post, current string) string {
+	if post.Slug == "" {
+		return ""
+	}
+
+	return current
+}
+
+func (s *Service) savePost(ctx context.Context, post models.Post, expectedVersion int) (models.Post, error) {
+	err := s.checkCategory(ctx, post.CategoryID)
+	if err != nil {
+		return models.Post{}, err
 	}
 
-	if updatedPost.Content != "" && post.Content != updatedPost.Content {
-		post.Content = updatedPost.Content
+	id, err := s.Repo.UpdatePost(ctx, post)
+	if err != nil {
+		if expectedVersion == 0 && errors.Is(err, apperr.ErrPreconditionFailed) {
+			// The caller did not ask for a version check, so losing the race
+			// against another writer is a conflict rather than a failed precondition.
+			return models.Post{}, apperr.Conflict("post was modified concurrently", err)
+		}
+		return models.Post{}, err
 	}
 
-	id, err := s.Repo.UpdatePost(post)
+	s.notifyScheduled(post)
+
+	post, err = s.Repo.GetPost(ctx, id)
 	if err != nil {
 		return models.Post{}, err
 	}
 
-	post, err = s.Repo.GetPost(id)
+	return post, nil
+}
+
+func (s *Service) validatePost(post models.Post) error {
+	err := s.validate.Struct(post)
+	if err != nil {
+		return apperr.Validation("invalid post data", err)
+	}
+
+	return nil
+}
+
+// GetPost returns the post with the given id. Posts that are not published
+// are only found by callers who may edit them.
+func (s *Service) GetPost(ctx context.Context, id int) (models.Post, error) {
+	post, err := s.readablePost(ctx, id)
 	if err != nil {
 		return models.Post{}, err
 	}
@@ -72,8 +382,16 @@ func (s *Service) UpdatePost(updatedPost models.Post) (models.Post, error) {
 	return post, nil
 }
 
-func (s *Service) GetPost(id int) (models.Post, error) {
-	post, err := s.Repo.GetPost(id)
+// GetPostBySlug returns the post with the given slug. A slug the post had
+// before also finds it; the result then carries a different, current slug.
+// Like GetPost, it hides posts that are not published.
+func (s *Service) GetPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	post, err := s.Repo.GetPostBySlug(ctx, slug)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.checkReadable(ctx, post)
 	if err != nil {
 		return models.Post{}, err
 	}
@@ -81,8 +399,20 @@ func (s *Service) GetPost(id int) (models.Post, error) {
 	return post, nil
 }
 
-func (s *Service) DeletePost(id int) error {
-	err := s.Repo.DeletePost(id)
+// DeletePost moves the post to the trash. A non-zero version must match the
+// stored one.
+func (s *Service) DeletePost(ctx context.Context, id int, version int) error {
+	post, err := s.Repo.GetPostForUpdate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizePost(ctx, policy.PostsDelete, post)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repo.DeletePost(ctx, id, version)
 	if err != nil {
 		return err
 	}
@@ -1,155 +1,847 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/pkg/jsonpatch"
+	"github.com/rostis232/prmv/internal/policy"
 	"github.com/rostis232/prmv/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+var testTokens = auth.NewTokens(auth.Config{
+	Secret:     []byte("test secret"),
+	AccessTTL:  15 * time.Minute,
+	RefreshTTL: time.Hour,
+})
+
+// testPolicy mirrors the roles seeded by the migrations.
+var testPolicy = policy.New(map[string][]string{
+	policy.RoleAdmin: {policy.PostsCreate, "posts:update:any", "posts:delete:any", policy.RolesAssign,
+		policy.CommentsCreate, "comments:update:own", "comments:delete:any", policy.CategoriesManage},
+	policy.RoleEditor: {policy.PostsCreate, "posts:update:own", "posts:update:any", "posts:delete:own",
+		policy.CommentsCreate, "comments:update:own", "comments:delete:any", policy.CategoriesManage},
+	policy.RoleAuthor: {policy.PostsCreate, "posts:update:own", "posts:delete:own",
+		policy.CommentsCreate, "comments:update:own", "comments:delete:own"},
+	policy.RoleViewer: {policy.CommentsCreate, "comments:update:own", "comments:delete:own"},
+})
+
+// publishedOnly is the filter public listings apply.
+var publishedOnly = models.PostsFilter{Status: models.StatusPublished}
+
+// testCtx carries an admin identity, so ownership checks always pass.
+var testCtx = auth.NewContext(context.Background(), models.Identity{UserID: 1, Roles: []string{policy.RoleAdmin}})
+
 type MockRepository struct {
 	mock.Mock
 }
 
-func (m *MockRepository) AddPost(post models.Post) (int, error) {
-	args := m.Called(post)
+func (m *MockRepository) AddPost(ctx context.Context, post models.Post) (int, error) {
+	args := m.Called(ctx, post)
 	return args.Int(0), args.Error(1)
 }
 
-func (m *MockRepository) GetAllPosts() ([]models.Post, error) {
-	args := m.Called()
+func (m *MockRepository) GetAllPosts(ctx context.Context, query models.PostsQuery) ([]models.Post, error) {
+	args := m.Called(ctx, query)
 	return args.Get(0).([]models.Post), args.Error(1)
 }
 
-func (m *MockRepository) UpdatePost(post models.Post) (int, error) {
-	args := m.Called(post)
+func (m *MockRepository) GetPostsStats(ctx context.Context, query models.PostsQuery) (models.PostsStats, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(models.PostsStats), args.Error(1)
+}
+
+func (m *MockRepository) UpdatePost(ctx context.Context, post models.Post) (int, error) {
+	args := m.Called(ctx, post)
 	return args.Int(0), args.Error(1)
 }
 
-func (m *MockRepository) GetPost(id int) (models.Post, error) {
-	args := m.Called(id)
+func (m *MockRepository) GetPost(ctx context.Context, id int) (models.Post, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Post), args.Error(1)
+}
+
+func (m *MockRepository) GetPostForUpdate(ctx context.Context, id int) (models.Post, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Post), args.Error(1)
+}
+
+func (m *MockRepository) DeletePost(ctx context.Context, id int, version int) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetTrash(ctx context.Context, query models.PostsQuery) ([]models.Post, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]models.Post), args.Error(1)
+}
+
+func (m *MockRepository) GetTrashedPost(ctx context.Context, id int) (models.Post, error) {
+	args := m.Called(ctx, id)
 	return args.Get(0).(models.Post), args.Error(1)
 }
 
-func (m *MockRepository) DeletePost(id int) error {
-	args := m.Called(id)
+func (m *MockRepository) RestorePost(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockRepository) PurgePost(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) PublishScheduledPosts(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetNextPublishAt(ctx context.Context) (time.Time, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockRepository) GetRevisions(ctx context.Context, postID int) ([]models.Revision, error) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).([]models.Revision), args.Error(1)
+}
+
+func (m *MockRepository) GetRevision(ctx context.Context, postID int, version int) (models.Revision, error) {
+	args := m.Called(ctx, postID, version)
+	return args.Get(0).(models.Revision), args.Error(1)
+}
+
+func (m *MockRepository) AddUser(ctx context.Context, user models.User) (int, error) {
+	args := m.Called(ctx, user)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetUser(ctx context.Context, id int) (models.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockRepository) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	args := m.Called(ctx, username)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockRepository) AddRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ConsumeRefreshToken(ctx context.Context, tokenHash string) (int, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepository) AssignRole(ctx context.Context, userID int, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeRole(ctx context.Context, userID int, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockRepository) AddAPIKey(ctx context.Context, key models.APIKey) (int, error) {
+	args := m.Called(ctx, key)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetAPIKey(ctx context.Context, id int, userID int) (models.APIKey, error) {
+	args := m.Called(ctx, id, userID)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func (m *MockRepository) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockRepository) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func (m *MockRepository) RevokeAPIKey(ctx context.Context, id int, userID int) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) AddComment(ctx context.Context, comment models.Comment) (int, error) {
+	args := m.Called(ctx, comment)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetComments(ctx context.Context, postID int) ([]models.Comment, error) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *MockRepository) GetComment(ctx context.Context, postID int, id int) (models.Comment, error) {
+	args := m.Called(ctx, postID, id)
+	return args.Get(0).(models.Comment), args.Error(1)
+}
+
+func (m *MockRepository) UpdateComment(ctx context.Context, comment models.Comment) error {
+	args := m.Called(ctx, comment)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteComment(ctx context.Context, postID int, id int) error {
+	args := m.Called(ctx, postID, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) AddAttachment(ctx context.Context, attachment models.Attachment) (int, error) {
+	args := m.Called(ctx, attachment)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetAttachments(ctx context.Context, postID int) ([]models.Attachment, error) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).([]models.Attachment), args.Error(1)
+}
+
+func (m *MockRepository) GetAttachment(ctx context.Context, postID int, id int) (models.Attachment, error) {
+	args := m.Called(ctx, postID, id)
+	return args.Get(0).(models.Attachment), args.Error(1)
+}
+
+func (m *MockRepository) DetachAttachment(ctx context.Context, postID int, id int) error {
+	args := m.Called(ctx, postID, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetDetachedAttachments(ctx context.Context) ([]models.Attachment, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Attachment), args.Error(1)
+}
+
+func (m *MockRepository) DeleteAttachment(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]models.Post), args.Error(1)
+}
+
+func (m *MockRepository) BulkPosts(ctx context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error) {
+	args := m.Called(ctx, ops, atomic)
+	return args.Get(0).([]models.BulkResult), args.Error(1)
+}
+
+func (m *MockRepository) GetPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	args := m.Called(ctx, slug)
+	return args.Get(0).(models.Post), args.Error(1)
+}
+
+func (m *MockRepository) GetTakenSlugs(ctx context.Context, base string, postID int) ([]string, error) {
+	args := m.Called(ctx, base, postID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepository) GetTags(ctx context.Context) ([]models.Tag, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Tag), args.Error(1)
+}
+
+func (m *MockRepository) AddCategory(ctx context.Context, category models.Category) (int, error) {
+	args := m.Called(ctx, category)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetCategories(ctx context.Context) ([]models.Category, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
+func (m *MockRepository) GetCategory(ctx context.Context, id int) (models.Category, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Category), args.Error(1)
+}
+
+func (m *MockRepository) GetCategoryBySlug(ctx context.Context, slug string) (models.Category, error) {
+	args := m.Called(ctx, slug)
+	return args.Get(0).(models.Category), args.Error(1)
+}
+
 func TestAddPost(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, testTokens, testPolicy)
 
 	post := models.Post{Title: "Test Title", Content: "Test Content"}
-	mockRepo.On("AddPost", post).Return(1, nil)
-	mockRepo.On("GetPost", 1).Return(post, nil)
+	authorID := 1
+	stored := models.Post{Slug: "test-title", Title: "Test Title", Content: "Test Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Test Content</p>", AuthorID: &authorID, Status: models.StatusPublished}
+	mockRepo.On("GetTakenSlugs", mock.Anything, "test-title", 0).Return([]string{}, nil)
+	mockRepo.On("AddPost", mock.Anything, stored).Return(1, nil)
+	mockRepo.On("GetPost", mock.Anything, 1).Return(stored, nil)
 
-	result, err := service.AddPost(post)
+	result, err := service.AddPost(testCtx, post)
 	assert.NoError(t, err)
 	assert.Equal(t, post.Title, result.Title)
 	assert.Equal(t, post.Content, result.Content)
+	assert.Equal(t, &authorID, result.AuthorID)
+
+	_, err = service.AddPost(context.Background(), post)
+	assert.ErrorIs(t, err, apperr.ErrUnauthorized)
 
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetAllPosts(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, testTokens, testPolicy)
 
 	posts := []models.Post{
 		{Title: "Test Title 1", Content: "Test Content 1"},
 		{Title: "Test Title 2", Content: "Test Content 2"},
 	}
 
-	mockRepo.On("GetAllPosts").Return(posts, nil)
+	mockRepo.On("GetAllPosts", mock.Anything, models.PostsQuery{Limit: DefaultPageSize + 1, PostsFilter: publishedOnly}).Return(posts, nil)
+
+	result, err := service.GetAllPosts(testCtx, 0, "", models.PostsFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, posts, result.Posts)
+	assert.Empty(t, result.NextCursor)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAllPostsPagination(t *testing.T) {
+	created := time.Date(2024, 6, 1, 12, 0, 0, 123456000, time.UTC)
+	posts := []models.Post{
+		{ID: 1, Title: "Test Title 1", CreatedAt: created},
+		{ID: 2, Title: "Test Title 2", CreatedAt: created},
+		{ID: 3, Title: "Test Title 3", CreatedAt: created.Add(time.Second)},
+	}
+
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	mockRepo.On("GetAllPosts", mock.Anything, models.PostsQuery{Limit: 3, PostsFilter: publishedOnly}).Return(posts, nil).Once()
 
-	result, err := service.GetAllPosts()
+	first, err := service.GetAllPosts(testCtx, 2, "", models.PostsFilter{})
 	assert.NoError(t, err)
-	assert.Equal(t, posts, result)
+	assert.Equal(t, posts[:2], first.Posts)
+	assert.NotEmpty(t, first.NextCursor)
+
+	cursor, err := DecodeCursor(first.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Cursor{CreatedAt: created, ID: 2}, cursor)
+
+	mockRepo.On("GetAllPosts", mock.Anything, models.PostsQuery{Limit: 3, After: &cursor, PostsFilter: publishedOnly}).Return(posts[2:], nil).Once()
+
+	second, err := service.GetAllPosts(testCtx, 2, first.NextCursor, models.PostsFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, posts[2:], second.Posts)
+	assert.Empty(t, second.NextCursor)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAllPostsLimits(t *testing.T) {
+	testCases := []struct {
+		limit         int
+		expectedLimit int
+	}{
+		{limit: -1, expectedLimit: DefaultPageSize},
+		{limit: 0, expectedLimit: DefaultPageSize},
+		{limit: 5, expectedLimit: 5},
+		{limit: MaxPageSize + 1, expectedLimit: MaxPageSize},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetAllPosts", mock.Anything, models.PostsQuery{Limit: tc.expectedLimit + 1, PostsFilter: publishedOnly}).Return([]models.Post{}, nil).Once()
+
+		_, err := service.GetAllPosts(testCtx, tc.limit, "", models.PostsFilter{})
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestGetAllPostsInvalidCursor(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	for _, cursor := range []string{"!!!", "bm9jb2xvbg", "MDow"} {
+		_, err := service.GetAllPosts(testCtx, 10, cursor, models.PostsFilter{})
+		assert.ErrorIs(t, err, ErrInvalidCursor, cursor)
+	}
 
 	mockRepo.AssertExpectations(t)
 }
 
 func TestUpdatePost(t *testing.T) {
+	original := models.Post{ID: 1, Slug: "original-title", Status: models.StatusPublished, Title: "Original Title", Content: "Original Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Original Content</p>", Version: 2}
+	draft := models.Post{ID: 1, Slug: "custom", Status: models.StatusDraft, Title: "Original Title", Content: "Original Content", ContentFormat: models.FormatMarkdown, ContentHTML: "<p>Original Content</p>", Version: 2}
+
 	testCases := []struct {
 		originalPost models.Post
 		updatedPost  models.Post
-		expectedPost models.Post
+		// slug is the slug generated for the post, empty if it is given.
+		slug     string
+		replaced models.Post
 	}{
 		{
-			originalPost: models.Post{ID: 1, Title: "Original Title", Content: "Original Content"},
+			originalPost: original,
+			updatedPost:  models.Post{ID: 1, Slug: "original-title", Title: "Updated Title", Content: "Updated Content"},
+			replaced:     models.Post{ID: 1, Slug: "original-title", Status: models.StatusPublished, Title: "Updated Title", Content: "Updated Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Updated Content</p>", Version: 2},
+		},
+		{
+			// PUT replaces the whole post, so the slug, format and status it
+			// leaves out are those of a new post rather than the stored ones.
+			originalPost: draft,
 			updatedPost:  models.Post{ID: 1, Title: "Updated Title", Content: "Updated Content"},
-			expectedPost: models.Post{ID: 1, Title: "Updated Title", Content: "Updated Content"},
+			slug:         "updated-title",
+			replaced:     models.Post{ID: 1, Slug: "updated-title", Status: models.StatusPublished, Title: "Updated Title", Content: "Updated Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Updated Content</p>", Version: 2},
 		},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(tc.originalPost, nil).Once()
+		if tc.slug != "" {
+			mockRepo.On("GetTakenSlugs", mock.Anything, tc.slug, 1).Return([]string{}, nil).Once()
+		}
+		mockRepo.On("UpdatePost", mock.Anything, tc.replaced).Return(1, nil).Once()
+		expected := tc.replaced
+		expected.Version++
+		mockRepo.On("GetPost", mock.Anything, 1).Return(expected, nil).Once()
+
+		result, err := service.UpdatePost(testCtx, tc.updatedPost)
+		assert.NoError(t, err, fmt.Sprintf("case %d", i))
+		assert.Equal(t, expected, result, fmt.Sprintf("case %d", i))
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestUpdatePostValidation(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	invalidPosts := []models.Post{
+		{ID: 1, Title: "", Content: ""},
+		{ID: 1, Title: "", Content: "Updated Content"},
+		{ID: 1, Title: "Updated Title", Content: ""},
+		{ID: 1, Title: "Up", Content: "Updated Content"},
+	}
+
+	for i, invalidPost := range invalidPosts {
+		_, err := service.UpdatePost(testCtx, invalidPost)
+
+		assert.Error(t, err, fmt.Sprintf("case %d", i))
+		assert.ErrorIs(t, err, apperr.ErrValidation, fmt.Sprintf("case %d", i))
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetPost(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	post := models.Post{ID: 1, Title: "Test Title", Content: "Test Content"}
+
+	mockRepo.On("GetPost", mock.Anything, 1).Return(post, nil)
+
+	result, err := service.GetPost(testCtx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, post, result)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeletePost(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(models.Post{ID: 1}, nil)
+	mockRepo.On("DeletePost", mock.Anything, 1, 0).Return(nil)
+
+	err := service.DeletePost(testCtx, 1, 0)
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestErrorsPassThrough(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	notFound := apperr.NotFound("post not found", nil)
+	unavailable := apperr.Unavailable("database is unavailable", nil)
+
+	mockRepo.On("GetPost", mock.Anything, 1).Return(models.Post{}, notFound).Once()
+	mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(models.Post{}, notFound).Once()
+	mockRepo.On("GetPostForUpdate", mock.Anything, 2).Return(models.Post{ID: 2}, nil).Once()
+	mockRepo.On("DeletePost", mock.Anything, 2, 0).Return(unavailable).Once()
+
+	_, err := service.GetPost(testCtx, 1)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	_, err = service.UpdatePost(testCtx, models.Post{ID: 1, Title: "Title", Content: "Content"})
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	err = service.DeletePost(testCtx, 2, 0)
+	assert.ErrorIs(t, err, apperr.ErrUnavailable)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdatePostVersion(t *testing.T) {
+	stored := models.Post{ID: 1, Slug: "original-title", Status: models.StatusPublished, Title: "Original Title", Content: "Original Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Original Content</p>", Version: 2}
+	modified := apperr.PreconditionFailed("post has been modified", nil)
+
+	testCases := []struct {
+		version     int
+		repoError   error
+		callsUpdate bool
+		kind        error
+	}{
+		{version: 1, callsUpdate: false, kind: apperr.ErrPreconditionFailed},
+		{version: 2, callsUpdate: true, repoError: modified, kind: apperr.ErrPreconditionFailed},
+		{version: 0, callsUpdate: true, repoError: modified, kind: apperr.ErrConflict},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(stored, nil).Once()
+		if tc.callsUpdate {
+			expected := stored
+			expected.Title = "Updated Title"
+			mockRepo.On("UpdatePost", mock.Anything, expected).Return(0, tc.repoError).Once()
+		}
+
+		_, err := service.UpdatePost(testCtx, models.Post{ID: 1, Slug: stored.Slug, Title: "Updated Title", Content: stored.Content, Version: tc.version})
+		assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestGetPostsStats(t *testing.T) {
+	authorID := 2
+	author := auth.NewContext(context.Background(), models.Identity{UserID: authorID, Roles: []string{policy.RoleAuthor}})
+	drafts := models.PostsFilter{Status: models.StatusDraft}
+	stats := models.PostsStats{Count: 3, LastModified: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+
+	testCases := []struct {
+		ctx    context.Context
+		cursor string
+		filter models.PostsFilter
+		query  models.PostsQuery
+		kind   error
+	}{
+		{ctx: context.Background(), query: models.PostsQuery{PostsFilter: publishedOnly}},
+		{ctx: testCtx, filter: models.PostsFilter{Tags: []string{"Go"}},
+			query: models.PostsQuery{PostsFilter: models.PostsFilter{Tags: []string{"go"}, Status: models.StatusPublished}}},
+		{ctx: testCtx, filter: drafts, query: models.PostsQuery{PostsFilter: drafts}},
+		{ctx: author, filter: drafts, query: models.PostsQuery{AuthorID: &authorID, PostsFilter: drafts}},
+		{ctx: context.Background(), filter: drafts, kind: apperr.ErrUnauthorized},
+		{ctx: context.Background(), cursor: "!!!", kind: ErrInvalidCursor},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		if tc.kind == nil {
+			mockRepo.On("GetPostsStats", mock.Anything, tc.query).Return(stats, nil).Once()
+		}
+
+		result, err := service.GetPostsStats(tc.ctx, tc.cursor, tc.filter)
+		assertKind(t, tc.kind, err, fmt.Sprintf("case %d", i))
+		if tc.kind == nil {
+			assert.Equal(t, stats, result, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestPatchPost(t *testing.T) {
+	stored := models.Post{ID: 1, Slug: "original-title", Status: models.StatusPublished, Title: "Original Title", Content: "Original Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Original Content</p>", Version: 2}
+
+	testCases := []struct {
+		patch    models.PostPatch
+		expected models.Post
+		kind     error
+	}{
 		{
-			originalPost: models.Post{ID: 1, Title: "Original Title", Content: "Original Content"},
-			updatedPost:  models.Post{ID: 1, Title: "", Content: "Updated Content"},
-			expectedPost: models.Post{ID: 1, Title: "Original Title", Content: "Updated Content"},
+			patch:    models.PostPatch{Type: jsonpatch.MergePatchType, Document: []byte(`{"title":"Patched Title"}`)},
+			expected: models.Post{ID: 1, Slug: "original-title", Status: models.StatusPublished, Title: "Patched Title", Content: "Original Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Original Content</p>", Version: 2},
 		},
 		{
-			originalPost: models.Post{ID: 1, Title: "Original Title", Content: "Original Content"},
-			updatedPost:  models.Post{ID: 1, Title: "Updated Title", Content: ""},
-			expectedPost: models.Post{ID: 1, Title: "Updated Title", Content: "Original Content"},
+			patch:    models.PostPatch{Type: jsonpatch.JSONPatchType, Document: []byte(`[{"op":"replace","path":"/content","value":"Patched Content"}]`), Version: 2},
+			expected: models.Post{ID: 1, Slug: "original-title", Status: models.StatusPublished, Title: "Original Title", Content: "Patched Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Patched Content</p>", Version: 2},
 		},
 		{
-			originalPost: models.Post{ID: 1, Title: "Original Title", Content: "Original Content"},
-			updatedPost:  models.Post{ID: 1, Title: "", Content: ""},
-			expectedPost: models.Post{ID: 1, Title: "Original Title", Content: "Original Content"},
+			patch: models.PostPatch{Type: jsonpatch.MergePatchType, Document: []byte(`{"title":null}`)},
+			kind:  apperr.ErrValidation,
+		},
+		{
+			patch: models.PostPatch{Type: jsonpatch.MergePatchType, Document: []byte(`{"id":5}`)},
+			kind:  apperr.ErrValidation,
+		},
+		{
+			patch: models.PostPatch{Type: jsonpatch.JSONPatchType, Document: []byte(`[{"op":"remove","path":"/missing"}]`)},
+			kind:  apperr.ErrValidation,
+		},
+		{
+			patch: models.PostPatch{Type: jsonpatch.JSONPatchType, Document: []byte(`[{"op":"test","path":"/title","value":"Other"}]`)},
+			kind:  apperr.ErrConflict,
+		},
+		{
+			patch: models.PostPatch{Type: jsonpatch.MergePatchType, Document: []byte(`{"title":"Patched Title"}`), Version: 1},
+			kind:  apperr.ErrPreconditionFailed,
+		},
+		{
+			patch: models.PostPatch{Type: "application/xml", Document: []byte(`<post/>`)},
+			kind:  apperr.ErrValidation,
 		},
 	}
 
 	for i, tc := range testCases {
 		mockRepo := new(MockRepository)
-		service := NewService(mockRepo)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(stored, nil).Once()
+		if tc.kind == nil {
+			mockRepo.On("UpdatePost", mock.Anything, tc.expected).Return(1, nil).Once()
+			mockRepo.On("GetPost", mock.Anything, 1).Return(tc.expected, nil).Once()
+		}
+
+		result, err := service.PatchPost(testCtx, 1, tc.patch)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.expected, result, fmt.Sprintf("case %d", i))
+		}
 
-		mockRepo.On("GetPost", 1).Return(tc.originalPost, nil).Once()
-		mockRepo.On("UpdatePost", mock.AnythingOfType("models.Post")).Return(1, nil).Once()
-		mockRepo.On("GetPost", 1).Return(tc.expectedPost, nil).Once()
+		mockRepo.AssertExpectations(t)
+	}
+}
 
-		result, err := service.UpdatePost(tc.updatedPost)
-		assert.NoError(t, err, fmt.Sprintf("case %d", i))
-		assert.Equal(t, tc.expectedPost, result, fmt.Sprintf("case %d", i))
+func TestGetTrash(t *testing.T) {
+	deleted := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	posts := []models.Post{
+		{ID: 1, Title: "Test Title 1", DeletedAt: &deleted},
+		{ID: 2, Title: "Test Title 2", DeletedAt: &deleted},
+	}
+
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	mockRepo.On("GetTrash", mock.Anything, models.PostsQuery{Limit: 2}).Return(posts, nil)
+
+	page, err := service.GetTrash(testCtx, 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, posts[:1], page.Posts)
+	assert.NotEmpty(t, page.NextCursor)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTrashOwn(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	authorID := 2
+	ctx := auth.NewContext(context.Background(), models.Identity{UserID: authorID, Roles: []string{policy.RoleAuthor}})
+
+	mockRepo.On("GetTrash", mock.Anything, models.PostsQuery{Limit: 11, AuthorID: &authorID}).Return([]models.Post{}, nil)
+
+	_, err := service.GetTrash(ctx, 10, "")
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestorePost(t *testing.T) {
+	post := models.Post{ID: 1, Title: "Test Title", Content: "Test Content", Version: 1}
+
+	testCases := []struct {
+		repoError error
+		kind      error
+	}{
+		{repoError: nil},
+		{repoError: apperr.NotFound("post not found in trash", nil), kind: apperr.ErrNotFound},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetTrashedPost", mock.Anything, 1).Return(post, nil)
+		mockRepo.On("RestorePost", mock.Anything, 1).Return(tc.repoError)
+		if tc.repoError == nil {
+			mockRepo.On("GetPost", mock.Anything, 1).Return(post, nil)
+		}
+
+		result, err := service.RestorePost(testCtx, 1)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, post, result, fmt.Sprintf("case %d", i))
+		}
 
 		mockRepo.AssertExpectations(t)
 	}
 }
 
-func TestUpdatePostValidation(t *testing.T) {
+func TestPurgeTrash(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, testTokens, testPolicy)
 
-	invalidPost := models.Post{ID: 1, Title: "", Content: ""}
+	retention := 24 * time.Hour
+	start := time.Now().UTC()
 
-	_, err := service.UpdatePost(invalidPost)
+	mockRepo.On("GetTrashedPost", mock.Anything, 1).Return(models.Post{ID: 1}, nil)
+	mockRepo.On("PurgePost", mock.Anything, 1).Return(nil)
+	mockRepo.On("PurgeTrash", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return !before.After(start.Add(-retention).Add(time.Minute)) && !before.Before(start.Add(-retention))
+	})).Return(int64(3), nil)
 
-	assert.Error(t, err)
+	err := service.PurgePost(testCtx, 1)
+	assert.NoError(t, err)
+
+	purged, err := service.PurgeTrash(testCtx, retention)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+
+	mockRepo.AssertExpectations(t)
 }
 
-func TestGetPost(t *testing.T) {
+func TestGetRevisions(t *testing.T) {
+	post := models.Post{ID: 1, Title: "Second Title", Content: "Content", Version: 2}
+	revisions := []models.Revision{
+		{PostID: 1, Version: 2, Title: "Second Title", Content: "Content"},
+		{PostID: 1, Version: 1, Title: "First Title", Content: "Content"},
+	}
+
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, testTokens, testPolicy)
 
-	post := models.Post{ID: 1, Title: "Test Title", Content: "Test Content"}
+	mockRepo.On("GetPost", mock.Anything, 1).Return(post, nil)
+	mockRepo.On("GetPost", mock.Anything, 2).Return(models.Post{}, apperr.NotFound("post not found", nil))
+	mockRepo.On("GetRevisions", mock.Anything, 1).Return(revisions, nil)
+	mockRepo.On("GetRevision", mock.Anything, 1, 1).Return(revisions[1], nil)
 
-	mockRepo.On("GetPost", 1).Return(post, nil)
+	result, err := service.GetRevisions(testCtx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, revisions, result)
 
-	result, err := service.GetPost(1)
+	revision, err := service.GetRevision(testCtx, 1, 1)
 	assert.NoError(t, err)
-	assert.Equal(t, post, result)
+	assert.Equal(t, revisions[1], revision)
+
+	// Revisions of trashed or missing posts are hidden.
+	_, err = service.GetRevisions(testCtx, 2)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	_, err = service.GetRevision(testCtx, 2, 1)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
 
 	mockRepo.AssertExpectations(t)
 }
 
-func TestDeletePost(t *testing.T) {
+func TestRevertPost(t *testing.T) {
+	// The revision is rendered in its own format, not in that of the post.
+	stored := models.Post{ID: 1, Title: "Second Title", Content: "Second Content", ContentFormat: models.FormatHTML, Version: 2}
+	revision := models.Revision{PostID: 1, Version: 1, Title: "First Title", Content: "First Content", ContentFormat: models.FormatPlain}
+	reverted := models.Post{ID: 1, Title: "First Title", Content: "First Content", ContentFormat: models.FormatPlain,
+		ContentHTML: "<p>First Content</p>", Version: 2}
+
+	testCases := []struct {
+		version     int
+		revisionErr error
+		callsUpdate bool
+		kind        error
+	}{
+		{version: 0, callsUpdate: true},
+		{version: 2, callsUpdate: true},
+		{version: 1, kind: apperr.ErrPreconditionFailed},
+		{version: 0, revisionErr: apperr.NotFound("revision not found", nil), kind: apperr.ErrNotFound},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetPostForUpdate", mock.Anything, 1).Return(stored, nil).Once()
+		if tc.version != 1 {
+			mockRepo.On("GetRevision", mock.Anything, 1, 1).Return(revision, tc.revisionErr)
+		}
+		if tc.callsUpdate {
+			mockRepo.On("UpdatePost", mock.Anything, reverted).Return(1, nil)
+			mockRepo.On("GetPost", mock.Anything, 1).Return(models.Post{ID: 1, Title: "First Title", Content: "First Content", Version: 3}, nil).Once()
+		}
+
+		post, err := service.RevertPost(testCtx, 1, 1, tc.version)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, 3, post.Version, fmt.Sprintf("case %d", i))
+			assert.Equal(t, revision.Title, post.Title, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	post := models.Post{ID: 1, Title: "Title", Content: "line 1\nline 2", Version: 2}
+	first := models.Revision{PostID: 1, Version: 1, Title: "Title", Content: "line 1"}
+	second := models.Revision{PostID: 1, Version: 2, Title: "Title", Content: "line 1\nline 2"}
+
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, testTokens, testPolicy)
 
-	mockRepo.On("DeletePost", 1).Return(nil)
+	mockRepo.On("GetPost", mock.Anything, 1).Return(post, nil)
+	mockRepo.On("GetRevision", mock.Anything, 1, 1).Return(first, nil)
+	mockRepo.On("GetRevision", mock.Anything, 1, 2).Return(second, nil)
+
+	result, err := service.DiffRevisions(testCtx, 1, 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "--- posts/1/revisions/1\n+++ posts/1/revisions/2\n@@ -1,3 +1,4 @@\n Title\n \n line 1\n+line 2\n", result)
 
-	err := service.DeletePost(1)
+	result, err = service.DiffRevisions(testCtx, 1, 2, 2)
 	assert.NoError(t, err)
+	assert.Empty(t, result)
 
 	mockRepo.AssertExpectations(t)
 }
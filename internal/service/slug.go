@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxSlugLength is the longest slug a post can have.
+	MaxSlugLength = 100
+	// maxBaseSlugLength leaves room for a collision suffix in generated slugs.
+	maxBaseSlugLength = 90
+)
+
+// cyrillic transliterates Ukrainian letters following the official
+// Ukrainian national system, and the few other Cyrillic letters found in
+// Russian and Belarusian titles.
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "h", 'ґ': "g", 'д': "d", 'е': "e",
+	'є': "ie", 'ж': "zh", 'з': "z", 'и': "y", 'і': "i", 'ї': "i", 'й': "i",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch",
+	'ш': "sh", 'щ': "shch", 'ь': "", 'ю': "iu", 'я': "ia",
+	'ё': "io", 'ъ': "", 'ы': "y", 'э': "e", 'ў': "u",
+}
+
+// cyrillicWordStart holds the letters transliterated differently at the
+// start of a word, e.g. "Юрій" becomes "yurii".
+var cyrillicWordStart = map[rune]string{
+	'є': "ye", 'ї': "yi", 'й': "y", 'ю': "yu", 'я': "ya",
+}
+
+// latin holds letters that do not decompose into a base letter and marks.
+var latin = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// slugify turns title into a URL-friendly slug: Latin letters and digits in
+// lower case, with every other run of characters replaced by a hyphen.
+// Cyrillic is transliterated and diacritics are dropped. Long titles are cut
+// at a word boundary. The result is empty if title has nothing to keep.
+func slugify(title string) string {
+	var latinTitle strings.Builder
+	var prev rune
+
+	for _, r := range norm.NFC.String(strings.ToLower(title)) {
+		if isApostrophe(r) {
+			continue
+		}
+		latinTitle.WriteString(transliterate(r, prev))
+		prev = r
+	}
+
+	var slug strings.Builder
+	separate := false
+
+	for _, r := range latinTitle.String() {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			separate = slug.Len() > 0
+			continue
+		}
+		if separate {
+			slug.WriteByte('-')
+			separate = false
+		}
+		slug.WriteRune(r)
+	}
+
+	return truncateSlug(slug.String(), maxBaseSlugLength)
+}
+
+func transliterate(r rune, prev rune) string {
+	if s, ok := cyrillicWordStart[r]; ok && !unicode.IsLetter(prev) {
+		return s
+	}
+
+	// "зг" is written "zgh" so it is not read as "zh".
+	if r == 'г' && prev == 'з' {
+		return "gh"
+	}
+
+	if s, ok := cyrillic[r]; ok {
+		return s
+	}
+
+	if s, ok := latin[r]; ok {
+		return s
+	}
+
+	var base strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			base.WriteRune(d)
+		}
+	}
+
+	return base.String()
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’' || r == 'ʼ' || r == '`'
+}
+
+// truncateSlug shortens slug to at most max bytes, preferably at a hyphen.
+func truncateSlug(slug string, max int) string {
+	if len(slug) <= max {
+		return slug
+	}
+
+	slug = slug[:max]
+	if i := strings.LastIndexByte(slug, '-'); i > 0 {
+		slug = slug[:i]
+	}
+
+	return strings.TrimRight(slug, "-")
+}
+
+// assignSlug settles the slug post is stored with. An empty post.Slug keeps
+// current, or is generated from the title for new posts, with a numeric
+// suffix if another post uses or used it. A slug given by the client is used
+// as is and must be free. Slugs in reserved are treated as taken and the
+// chosen one is added, so posts created together get distinct slugs.
+func (s *Service) assignSlug(ctx context.Context, post *models.Post, current string, reserved map[string]bool) error {
+	if post.Slug == "" && current != "" {
+		post.Slug = current
+		return nil
+	}
+
+	if post.Slug != "" {
+		if post.Slug == current {
+			return nil
+		}
+
+		if len(post.Slug) > MaxSlugLength || !slugPattern.MatchString(post.Slug) {
+			return apperr.Validation("slug must consist of lower-case letters, digits and hyphens", nil)
+		}
+
+		taken, err := s.Repo.GetTakenSlugs(ctx, post.Slug, post.ID)
+		if err != nil {
+			return err
+		}
+
+		if reserved[post.Slug] || contains(taken, post.Slug) {
+			return apperr.Conflict("slug is already taken", nil)
+		}
+
+		reserve(reserved, post.Slug)
+		return nil
+	}
+
+	base := slugify(post.Title)
+	if base == "" {
+		base = "post"
+	}
+
+	taken, err := s.Repo.GetTakenSlugs(ctx, base, post.ID)
+	if err != nil {
+		return err
+	}
+
+	post.Slug = freeSlug(base, taken, reserved)
+	reserve(reserved, post.Slug)
+
+	return nil
+}
+
+// freeSlug returns base, or base with the lowest suffix from 2 up, that is
+// neither taken nor reserved.
+func freeSlug(base string, taken []string, reserved map[string]bool) string {
+	used := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		used[slug] = true
+	}
+
+	slug := base
+	for n := 2; used[slug] || reserved[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	return slug
+}
+
+func reserve(reserved map[string]bool, slug string) {
+	if reserved != nil {
+		reserved[slug] = true
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSlugify(t *testing.T) {
+	testCases := []struct {
+		title    string
+		expected string
+	}{
+		{title: "Hello, World!", expected: "hello-world"},
+		{title: "  Go 1.22 released  ", expected: "go-1-22-released"},
+		{title: "Don't panic", expected: "dont-panic"},
+		{title: "Crème brûlée à la française", expected: "creme-brulee-a-la-francaise"},
+		{title: "Straße", expected: "strasse"},
+		{title: "Привіт, світе", expected: "pryvit-svite"},
+		{title: "Щастя і Ґанок", expected: "shchastia-i-ganok"},
+		{title: "Юрій Їжакевич", expected: "yurii-yizhakevych"},
+		{title: "Знам'янка", expected: "znamianka"},
+		{title: "Згорани", expected: "zghorany"},
+		{title: "Єнакієве", expected: "yenakiieve"},
+		{title: "???", expected: ""},
+		{title: strings.Repeat("word ", 30), expected: strings.TrimSuffix(strings.Repeat("word-", 18), "-")},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, slugify(tc.title), fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestAssignSlug(t *testing.T) {
+	testCases := []struct {
+		post     models.Post
+		current  string
+		taken    []string
+		expected string
+		kind     error
+	}{
+		// New posts get a slug from the title, with a suffix if it is taken.
+		{post: models.Post{Title: "Release notes"}, taken: []string{}, expected: "release-notes"},
+		{post: models.Post{Title: "Release notes"}, taken: []string{"release-notes", "release-notes-2"}, expected: "release-notes-3"},
+		{post: models.Post{Title: "!!!"}, taken: []string{"post"}, expected: "post-2"},
+		// Updates without a slug keep the current one, even if the title changed.
+		{post: models.Post{ID: 1, Title: "New title"}, current: "old-title", expected: "old-title"},
+		{post: models.Post{ID: 1, Slug: "old-title", Title: "New title"}, current: "old-title", expected: "old-title"},
+		// Slugs given by the client are used as is.
+		{post: models.Post{ID: 1, Slug: "new-title", Title: "New title"}, current: "old-title", taken: []string{}, expected: "new-title"},
+		{post: models.Post{ID: 1, Slug: "taken", Title: "New title"}, current: "old-title", taken: []string{"taken"}, kind: apperr.ErrConflict},
+		{post: models.Post{Slug: "Not a slug", Title: "Title"}, kind: apperr.ErrValidation},
+		{post: models.Post{Slug: strings.Repeat("a", MaxSlugLength+1), Title: "Title"}, kind: apperr.ErrValidation},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		if tc.taken != nil {
+			mockRepo.On("GetTakenSlugs", mock.Anything, mock.Anything, tc.post.ID).Return(tc.taken, nil).Once()
+		}
+
+		post := tc.post
+		err := service.assignSlug(testCtx, &post, tc.current, nil)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, fmt.Sprintf("case %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.expected, post.Slug, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestGetPostBySlug(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	post := models.Post{ID: 1, Slug: "current", Title: "Title"}
+	mockRepo.On("GetPostBySlug", mock.Anything, "previous").Return(post, nil).Once()
+
+	result, err := service.GetPostBySlug(testCtx, "previous")
+	assert.NoError(t, err)
+	assert.Equal(t, post, result)
+
+	mockRepo.AssertExpectations(t)
+}
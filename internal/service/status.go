@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// settleStatus fills in the status and publication time post is stored with,
+// given the stored current post, which is empty for new posts. An empty status
+// keeps the current one, and new posts are published unless asked otherwise.
+// Scheduled posts need a publication time, which must be in the future when it
+// is set or changed. Published posts keep the time they went live, or get one
+// when stored; other posts have none.
+func settleStatus(post *models.Post, current models.Post, now time.Time) error {
+	if post.Status == "" {
+		post.Status = current.Status
+	}
+
+	if post.Status == "" {
+		post.Status = models.StatusPublished
+	}
+
+	switch post.Status {
+	case models.StatusScheduled:
+		rescheduled := true
+
+		if post.PublishAt == nil && current.Status == models.StatusScheduled {
+			post.PublishAt = current.PublishAt
+		}
+
+		if post.PublishAt == nil {
+			return apperr.Validation("publish_at is required for scheduled posts", nil)
+		}
+
+		publishAt := post.PublishAt.UTC()
+		post.PublishAt = &publishAt
+
+		if current.Status == models.StatusScheduled && current.PublishAt != nil {
+			rescheduled = !publishAt.Equal(*current.PublishAt)
+		}
+
+		if rescheduled && !publishAt.After(now) {
+			return apperr.Validation("publish_at must be in the future", nil)
+		}
+	case models.StatusPublished:
+		post.PublishAt = nil
+
+		if current.Status == models.StatusPublished {
+			post.PublishAt = current.PublishAt
+		}
+	default:
+		post.PublishAt = nil
+	}
+
+	return nil
+}
+
+// checkReadable hides posts that are not published from callers who may not
+// edit them, as if they did not exist.
+func (s *Service) checkReadable(ctx context.Context, post models.Post) error {
+	if post.Status == models.StatusPublished {
+		return nil
+	}
+
+	err := s.authorizePost(ctx, policy.PostsUpdate, post)
+	if err != nil {
+		return apperr.NotFound("post not found", err)
+	}
+
+	return nil
+}
+
+// readablePost loads the post with the given id if the caller may read it,
+// see checkReadable.
+func (s *Service) readablePost(ctx context.Context, id int) (models.Post, error) {
+	post, err := s.Repo.GetPost(ctx, id)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.checkReadable(ctx, post)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return post, nil
+}
+
+// PublishScheduledPosts publishes the scheduled posts that are due and
+// returns how many there were, together with the publication time of the
+// next scheduled post, which is zero if there is none. Several replicas may
+// call it at the same time; every post is still published once.
+func (s *Service) PublishScheduledPosts(ctx context.Context) (int64, time.Time, error) {
+	published, err := s.Repo.PublishScheduledPosts(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	next, err := s.Repo.GetNextPublishAt(ctx)
+	if err != nil {
+		return published, time.Time{}, err
+	}
+
+	return published, next, nil
+}
+
+// Scheduled returns a channel that receives a value whenever a post is
+// scheduled, so a scheduler can reconsider when to run next.
+func (s *Service) Scheduled() <-chan struct{} {
+	return s.scheduled
+}
+
+// notifyScheduled tells the scheduler about post if it is scheduled, without
+// waiting for it.
+func (s *Service) notifyScheduled(post models.Post) {
+	if post.Status != models.StatusScheduled {
+		return
+	}
+
+	select {
+	case s.scheduled <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/auth"
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSettleStatus(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	past, future, later := now.Add(-time.Hour), now.Add(time.Hour), now.Add(2*time.Hour)
+	kyiv := time.FixedZone("EEST", 3*60*60)
+	futureInKyiv := future.In(kyiv)
+
+	testCases := []struct {
+		post      models.Post
+		current   models.Post
+		status    string
+		publishAt *time.Time
+		kind      error
+	}{
+		// New posts are published unless asked otherwise.
+		{post: models.Post{}, status: models.StatusPublished},
+		{post: models.Post{Status: models.StatusDraft, PublishAt: &future}, status: models.StatusDraft},
+		{post: models.Post{Status: models.StatusScheduled, PublishAt: &futureInKyiv}, status: models.StatusScheduled, publishAt: &future},
+		{post: models.Post{Status: models.StatusScheduled}, kind: apperr.ErrValidation},
+		{post: models.Post{Status: models.StatusScheduled, PublishAt: &past}, kind: apperr.ErrValidation},
+		// An empty status keeps the current one.
+		{
+			post:      models.Post{},
+			current:   models.Post{Status: models.StatusScheduled, PublishAt: &future},
+			status:    models.StatusScheduled,
+			publishAt: &future,
+		},
+		{
+			post:    models.Post{},
+			current: models.Post{Status: models.StatusArchived},
+			status:  models.StatusArchived,
+		},
+		// A due post that is still scheduled may be saved unchanged, but not
+		// moved to another time in the past.
+		{
+			post:      models.Post{Status: models.StatusScheduled, PublishAt: &past},
+			current:   models.Post{Status: models.StatusScheduled, PublishAt: &past},
+			status:    models.StatusScheduled,
+			publishAt: &past,
+		},
+		{
+			post:    models.Post{Status: models.StatusScheduled, PublishAt: &past},
+			current: models.Post{Status: models.StatusScheduled, PublishAt: &future},
+			kind:    apperr.ErrValidation,
+		},
+		{
+			post:      models.Post{Status: models.StatusScheduled, PublishAt: &later},
+			current:   models.Post{Status: models.StatusScheduled, PublishAt: &future},
+			status:    models.StatusScheduled,
+			publishAt: &later,
+		},
+		// Published posts keep the time they went live and get one otherwise.
+		{
+			post:      models.Post{Status: models.StatusPublished, PublishAt: &future},
+			current:   models.Post{Status: models.StatusPublished, PublishAt: &past},
+			status:    models.StatusPublished,
+			publishAt: &past,
+		},
+		{
+			post:    models.Post{Status: models.StatusPublished},
+			current: models.Post{Status: models.StatusScheduled, PublishAt: &future},
+			status:  models.StatusPublished,
+		},
+		{
+			post:    models.Post{Status: models.StatusDraft},
+			current: models.Post{Status: models.StatusPublished, PublishAt: &past},
+			status:  models.StatusDraft,
+		},
+	}
+
+	for i, tc := range testCases {
+		post := tc.post
+
+		err := settleStatus(&post, tc.current, now)
+		assertKind(t, tc.kind, err, fmt.Sprintf("case %d", i))
+
+		if tc.kind == nil {
+			assert.Equal(t, tc.status, post.Status, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tc.publishAt, post.PublishAt, fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+func TestGetPostVisibility(t *testing.T) {
+	authorID, otherID := 1, 2
+	author := auth.NewContext(context.Background(), models.Identity{UserID: authorID, Roles: []string{policy.RoleAuthor}})
+	other := auth.NewContext(context.Background(), models.Identity{UserID: otherID, Roles: []string{policy.RoleAuthor}})
+	editor := auth.NewContext(context.Background(), models.Identity{UserID: otherID, Roles: []string{policy.RoleEditor}})
+
+	published := models.Post{ID: 1, Status: models.StatusPublished, AuthorID: &authorID}
+	draft := models.Post{ID: 1, Status: models.StatusDraft, AuthorID: &authorID}
+
+	testCases := []struct {
+		ctx  context.Context
+		post models.Post
+		kind error
+	}{
+		{ctx: context.Background(), post: published},
+		{ctx: context.Background(), post: draft, kind: apperr.ErrNotFound},
+		{ctx: other, post: draft, kind: apperr.ErrNotFound},
+		{ctx: author, post: draft},
+		{ctx: editor, post: draft},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		mockRepo.On("GetPost", mock.Anything, 1).Return(tc.post, nil).Once()
+
+		result, err := service.GetPost(tc.ctx, 1)
+		assertKind(t, tc.kind, err, fmt.Sprintf("case %d", i))
+
+		if tc.kind == nil {
+			assert.Equal(t, tc.post, result, fmt.Sprintf("case %d", i))
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestGetAllPostsByStatus(t *testing.T) {
+	authorID := 1
+	author := auth.NewContext(context.Background(), models.Identity{UserID: authorID, Roles: []string{policy.RoleAuthor}})
+	viewer := auth.NewContext(context.Background(), models.Identity{UserID: 2, Roles: []string{policy.RoleViewer}})
+	drafts := models.PostsFilter{Status: models.StatusDraft}
+
+	testCases := []struct {
+		ctx      context.Context
+		authorID *int
+		kind     error
+	}{
+		{ctx: testCtx},
+		{ctx: author, authorID: &authorID},
+		{ctx: viewer, kind: apperr.ErrForbidden},
+		{ctx: context.Background(), kind: apperr.ErrUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, testTokens, testPolicy)
+
+		if tc.kind == nil {
+			query := models.PostsQuery{Limit: DefaultPageSize + 1, AuthorID: tc.authorID, PostsFilter: drafts}
+			mockRepo.On("GetAllPosts", mock.Anything, query).Return([]models.Post{}, nil).Once()
+		}
+
+		_, err := service.GetAllPosts(tc.ctx, 0, "", drafts)
+		assertKind(t, tc.kind, err, fmt.Sprintf("case %d", i))
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestAddPostScheduled(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	authorID := 1
+	publishAt := time.Now().UTC().Add(time.Hour)
+	stored := models.Post{Slug: "test-title", Title: "Test Title", Content: "Test Content", ContentFormat: models.FormatPlain,
+		ContentHTML: "<p>Test Content</p>", AuthorID: &authorID, Status: models.StatusScheduled, PublishAt: &publishAt}
+	mockRepo.On("GetTakenSlugs", mock.Anything, "test-title", 0).Return([]string{}, nil).Once()
+	mockRepo.On("AddPost", mock.Anything, stored).Return(1, nil).Once()
+	mockRepo.On("GetPost", mock.Anything, 1).Return(stored, nil).Once()
+
+	_, err := service.AddPost(testCtx, models.Post{Title: "Test Title", Content: "Test Content",
+		Status: models.StatusScheduled, PublishAt: &publishAt})
+	assert.NoError(t, err)
+
+	select {
+	case <-service.Scheduled():
+	default:
+		t.Error("scheduler was not notified")
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPublishScheduledPosts(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	next := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo.On("PublishScheduledPosts", mock.Anything).Return(int64(2), nil).Once()
+	mockRepo.On("GetNextPublishAt", mock.Anything).Return(next, nil).Once()
+
+	published, result, err := service.PublishScheduledPosts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), published)
+	assert.Equal(t, next, result)
+
+	mockRepo.AssertExpectations(t)
+}
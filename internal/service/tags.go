@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// GetTags lists the tags in use with the number of posts carrying each.
+func (s *Service) GetTags(ctx context.Context) ([]models.Tag, error) {
+	tags, err := s.Repo.GetTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// normalizeTags trims and lower-cases tags, drops empty ones and duplicates
+// and sorts the rest, so "Go" and " go" are the same tag.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	sort.Strings(normalized)
+
+	return normalized
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	testCases := []struct {
+		tags     []string
+		expected []string
+	}{
+		{tags: nil, expected: nil},
+		{tags: []string{" ", ""}, expected: nil},
+		{tags: []string{"Web", " go", "GO", "api "}, expected: []string{"api", "go", "web"}},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, normalizeTags(tc.tags), fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestGetAllPostsByTags(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	posts := []models.Post{{ID: 1, Title: "Test Title 1", Tags: []string{"go", "web"}}}
+	query := models.PostsQuery{
+		Limit:       DefaultPageSize + 1,
+		PostsFilter: models.PostsFilter{Tags: []string{"go", "web"}, AllTags: true, Status: models.StatusPublished},
+	}
+
+	mockRepo.On("GetAllPosts", mock.Anything, query).Return(posts, nil).Once()
+
+	result, err := service.GetAllPosts(testCtx, 0, "", models.PostsFilter{Tags: []string{"Web", "go", "go"}, AllTags: true})
+	assert.NoError(t, err)
+	assert.Equal(t, posts, result.Posts)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAddPostTags(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	authorID := 1
+	stored := models.Post{Slug: "test-title", Title: "Test Title", Content: "Test Content", ContentFormat: models.FormatPlain, ContentHTML: "<p>Test Content</p>", AuthorID: &authorID, Tags: []string{"go", "web"}, Status: models.StatusPublished}
+	mockRepo.On("GetTakenSlugs", mock.Anything, "test-title", 0).Return([]string{}, nil).Once()
+	mockRepo.On("AddPost", mock.Anything, stored).Return(1, nil).Once()
+	mockRepo.On("GetPost", mock.Anything, 1).Return(stored, nil).Once()
+
+	result, err := service.AddPost(testCtx, models.Post{Title: "Test Title", Content: "Test Content", Tags: []string{" Web", "go", "GO"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"go", "web"}, result.Tags)
+
+	_, err = service.AddPost(testCtx, models.Post{Title: "Test Title", Content: "Test Content", Tags: []string{strings.Repeat("a", 51)}})
+	assert.ErrorIs(t, err, apperr.ErrValidation)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTags(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, testTokens, testPolicy)
+
+	tags := []models.Tag{{Name: "go", Count: 2}}
+	mockRepo.On("GetTags", mock.Anything).Return(tags, nil).Once()
+
+	result, err := service.GetTags(testCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, tags, result)
+
+	mockRepo.AssertExpectations(t)
+}
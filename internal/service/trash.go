@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rostis232/prmv/internal/policy"
+	"github.com/rostis232/prmv/models"
+)
+
+// GetTrash returns one page of deleted posts, paginated like GetAllPosts.
+// Callers that may only delete their own posts only see their own trash.
+func (s *Service) GetTrash(ctx context.Context, limit int, cursor string) (models.PostsPage, error) {
+	identity, err := caller(ctx)
+	if err != nil {
+		return models.PostsPage{}, err
+	}
+
+	list := s.Repo.GetTrash
+
+	if !s.policy.Allows(identity, policy.PostsDelete+":"+policy.ScopeAny) {
+		list = func(ctx context.Context, query models.PostsQuery) ([]models.Post, error) {
+			query.AuthorID = &identity.UserID
+			return s.Repo.GetTrash(ctx, query)
+		}
+	}
+
+	return s.pagePosts(ctx, limit, cursor, list)
+}
+
+// RestorePost takes a post out of the trash and returns it.
+func (s *Service) RestorePost(ctx context.Context, id int) (models.Post, error) {
+	err := s.authorizeTrashedPost(ctx, id)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.Repo.RestorePost(ctx, id)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err := s.Repo.GetPost(ctx, id)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return post, nil
+}
+
+// PurgePost permanently deletes a post from the trash.
+func (s *Service) PurgePost(ctx context.Context, id int) error {
+	err := s.authorizeTrashedPost(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repo.PurgePost(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Files of the post that cannot be deleted now are retried by PurgeTrash.
+	_ = s.removeDetachedAttachments(ctx)
+
+	return nil
+}
+
+func (s *Service) authorizeTrashedPost(ctx context.Context, id int) error {
+	post, err := s.Repo.GetTrashedPost(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.authorizePost(ctx, policy.PostsDelete, post)
+}
+
+// PurgeTrash permanently deletes posts that have been in the trash for longer
+// than retention, unless retention is zero, and the files of deleted
+// attachments.
+func (s *Service) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	var purged int64
+
+	if retention > 0 {
+		var err error
+
+		purged, err = s.Repo.PurgeTrash(ctx, time.Now().UTC().Add(-retention))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err := s.removeDetachedAttachments(ctx)
+	if err != nil {
+		return purged, fmt.Errorf("error removing attachment files: %w", err)
+	}
+
+	return purged, nil
+}
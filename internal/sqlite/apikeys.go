@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	apiKeysTable = "api_keys"
+
+	apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at"
+)
+
+// apiKeyRow scans the JSON array of scopes, which models.APIKey cannot hold
+// directly.
+type apiKeyRow struct {
+	models.APIKey
+	Scopes string `db:"scopes"`
+}
+
+func (r apiKeyRow) apiKey() (models.APIKey, error) {
+	key := r.APIKey
+	err := json.Unmarshal([]byte(r.Scopes), &key.Scopes)
+	return key, err
+}
+
+// AddAPIKey stores a new API key and returns its id.
+func (s *SQLite) AddAPIKey(ctx context.Context, key models.APIKey) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("insert into %s (user_id, name, prefix, key_hash, scopes, expires_at) values (?, ?, ?, ?, ?, ?) returning id", apiKeysTable)
+
+	err := s.db.QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash, jsonArray(key.Scopes), nullTimestamp(key.ExpiresAt)).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "API key", "error adding API key")
+	}
+
+	return id, nil
+}
+
+// GetAPIKey returns a key of a user that has not been revoked.
+func (s *SQLite) GetAPIKey(ctx context.Context, id int, userID int) (models.APIKey, error) {
+	var row apiKeyRow
+
+	query := fmt.Sprintf("select %s from %s where id = ? and user_id = ? and revoked_at is null", apiKeyColumns, apiKeysTable)
+
+	err := s.db.GetContext(ctx, &row, query, id, userID)
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error getting API key")
+	}
+
+	key, err := row.apiKey()
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error getting API key")
+	}
+
+	return key, nil
+}
+
+// GetAPIKeys lists the keys of a user that have not been revoked, newest
+// first. Expired keys are included.
+func (s *SQLite) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	var rows []apiKeyRow
+
+	query := fmt.Sprintf("select %s from %s where user_id = ? and revoked_at is null order by id desc", apiKeyColumns, apiKeysTable)
+
+	err := s.db.SelectContext(ctx, &rows, query, userID)
+	if err != nil {
+		return nil, wrapError(err, "API key", "error getting API keys")
+	}
+
+	keys := make([]models.APIKey, 0, len(rows))
+	for _, row := range rows {
+		key, err := row.apiKey()
+		if err != nil {
+			return nil, wrapError(err, "API key", "error getting API keys")
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// UseAPIKey looks up a live key by the hash of its secret and records that it
+// was used. The key is read back after the update because returning does not
+// tell the driver which columns hold timestamps.
+func (s *SQLite) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	var row apiKeyRow
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error using API key")
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("update %[1]s set last_used_at = %[2]s where key_hash = ? and revoked_at is null and (expires_at is null or expires_at > %[2]s) returning id", apiKeysTable, now)
+
+	var id int
+
+	err = tx.QueryRowContext(ctx, query, keyHash).Scan(&id)
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error using API key")
+	}
+
+	query = fmt.Sprintf("select %s from %s where id = ?", apiKeyColumns, apiKeysTable)
+
+	err = tx.GetContext(ctx, &row, query, id)
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error using API key")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error using API key")
+	}
+
+	key, err := row.apiKey()
+	if err != nil {
+		return models.APIKey{}, wrapError(err, "API key", "error using API key")
+	}
+
+	return key, nil
+}
+
+// RevokeAPIKey revokes a key of a user. Revoked keys stop working at once.
+func (s *SQLite) RevokeAPIKey(ctx context.Context, id int, userID int) error {
+	query := fmt.Sprintf("update %s set revoked_at = %s where id = ? and user_id = ? and revoked_at is null", apiKeysTable, now)
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return wrapError(err, "API key", "error revoking API key")
+	}
+
+	return expectAffected(res, "API key", "error revoking API key")
+}
@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+)
+
+// GetPostsByIDs returns the posts with the given ids that are not in the
+// trash, in no particular order.
+func (s *SQLite) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	posts := []models.Post{}
+
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	query := fmt.Sprintf("select * from %s where id in (select value from json_each(?)) and deleted_at is null", postsTable)
+
+	err := s.db.SelectContext(ctx, &posts, query, jsonArray(ids))
+	if err != nil {
+		return posts, wrapError(err, "post", "error getting posts")
+	}
+
+	err = attachTags(ctx, s.db, posts)
+	if err != nil {
+		return posts, wrapError(err, "post", "error getting posts")
+	}
+
+	return posts, nil
+}
+
+// BulkPosts applies ops in a single transaction and returns one result per
+// operation, in order. When atomic is true the first failing operation rolls
+// the whole transaction back and every other operation is reported as
+// aborted. Otherwise each operation runs in its own savepoint, so failures
+// only undo the failing operation. The returned error is only set when the
+// transaction itself fails.
+func (s *SQLite) BulkPosts(ctx context.Context, ops []models.BulkOperation, atomic bool) ([]models.BulkResult, error) {
+	results := make([]models.BulkResult, len(ops))
+	for i, op := range ops {
+		results[i] = models.BulkResult{Index: i, Op: op.Op, ID: op.Post.ID}
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, wrapError(err, "post", "error starting bulk transaction")
+	}
+	defer tx.Rollback()
+
+	for i, op := range ops {
+		if !atomic {
+			_, err = tx.ExecContext(ctx, "savepoint bulk_operation")
+			if err != nil {
+				return nil, wrapError(err, "post", "error creating savepoint")
+			}
+		}
+
+		post, err := applyBulkOperation(ctx, tx, op)
+		if err != nil {
+			results[i].Err = err
+
+			if atomic {
+				return abortBulk(results, i), nil
+			}
+
+			_, err = tx.ExecContext(ctx, "rollback to savepoint bulk_operation")
+			if err != nil {
+				return nil, wrapError(err, "post", "error rolling back to savepoint")
+			}
+			continue
+		}
+
+		if !atomic {
+			_, err = tx.ExecContext(ctx, "release savepoint bulk_operation")
+			if err != nil {
+				return nil, wrapError(err, "post", "error releasing savepoint")
+			}
+		}
+
+		results[i].ID = post.ID
+		if op.Op != models.BulkDelete {
+			results[i].Post = &post
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, wrapError(err, "post", "error committing bulk transaction")
+	}
+
+	return results, nil
+}
+
+// abortBulk marks every operation except the failed one as aborted.
+func abortBulk(results []models.BulkResult, failed int) []models.BulkResult {
+	for i := range results {
+		if i == failed {
+			continue
+		}
+		results[i].Post = nil
+		results[i].Err = apperr.Aborted("not applied because another operation failed", nil)
+	}
+
+	return results
+}
+
+// applyBulkOperation writes one operation and reads the post back, as
+// returning does not see the changes made by triggers.
+func applyBulkOperation(ctx context.Context, tx *sqlx.Tx, op models.BulkOperation) (models.Post, error) {
+	var (
+		post models.Post
+		id   int
+		err  error
+	)
+
+	switch op.Op {
+	case models.BulkCreate:
+		id, err = insertPost(ctx, tx, op.Post)
+	case models.BulkUpdate:
+		id, err = updatePost(ctx, tx, op.Post, "(? = 0 or version = ?)", op.Post.Version, op.Post.Version)
+	case models.BulkDelete:
+		id, err = op.Post.ID, deletePost(ctx, tx, op.Post.ID, op.Post.Version)
+	default:
+		return post, apperr.Validation("unknown operation "+op.Op, nil)
+	}
+	if err != nil {
+		return post, err
+	}
+
+	query := fmt.Sprintf("select * from %s where id = ?", postsTable)
+
+	err = tx.GetContext(ctx, &post, query, id)
+	if err != nil {
+		return post, wrapError(err, "post", "error getting post")
+	}
+
+	posts := []models.Post{post}
+
+	err = attachTags(ctx, tx, posts)
+	if err != nil {
+		return post, wrapError(err, "post", "error getting post tags")
+	}
+
+	return posts[0], nil
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	categoriesTable = "categories"
+)
+
+// AddCategory stores a category under its parent, or at the top of the tree
+// when it has none.
+func (s *SQLite) AddCategory(ctx context.Context, category models.Category) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("insert into %s (parent_id, slug, name) values (?, ?, ?) returning id", categoriesTable)
+
+	err := s.db.QueryRowContext(ctx, query, category.ParentID, category.Slug, category.Name).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "category", "error adding category")
+	}
+
+	return id, nil
+}
+
+// GetCategories lists every category, ordered by name, without building the
+// tree.
+func (s *SQLite) GetCategories(ctx context.Context) ([]models.Category, error) {
+	categories := []models.Category{}
+
+	query := fmt.Sprintf("select * from %s order by name, id", categoriesTable)
+
+	err := s.db.SelectContext(ctx, &categories, query)
+	if err != nil {
+		return categories, wrapError(err, "category", "error getting categories")
+	}
+
+	return categories, nil
+}
+
+func (s *SQLite) GetCategory(ctx context.Context, id int) (models.Category, error) {
+	var category models.Category
+
+	query := fmt.Sprintf("select * from %s where id = ?", categoriesTable)
+
+	err := s.db.GetContext(ctx, &category, query, id)
+	if err != nil {
+		return category, wrapError(err, "category", "error getting category")
+	}
+
+	return category, nil
+}
+
+func (s *SQLite) GetCategoryBySlug(ctx context.Context, slug string) (models.Category, error) {
+	var category models.Category
+
+	query := fmt.Sprintf("select * from %s where slug = ?", categoriesTable)
+
+	err := s.db.GetContext(ctx, &category, query, slug)
+	if err != nil {
+		return category, wrapError(err, "category", "error getting category")
+	}
+
+	return category, nil
+}
+
+// categoryCondition matches posts in the category or any category below it.
+// The union stops the recursion even if the tree were to contain a cycle.
+func categoryCondition(where *whereBuilder, categoryID int) {
+	where.add(fmt.Sprintf(`category_id in (with recursive subtree (id) as (
+    select id from %[1]s where id = ?
+    union
+    select c.id from %[1]s c join subtree s on c.parent_id = s.id
+) select id from subtree)`, categoriesTable), categoryID)
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	commentsTable = "comments"
+)
+
+func (s *SQLite) AddComment(ctx context.Context, comment models.Comment) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("insert into %s (post_id, parent_id, author_id, content) values (?, ?, ?, ?) returning id", commentsTable)
+
+	err := s.db.QueryRowContext(ctx, query, comment.PostID, comment.ParentID, comment.AuthorID, comment.Content).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "comment", "error adding comment")
+	}
+
+	return id, nil
+}
+
+// GetComments lists every comment on a post, oldest first. Replies come after
+// the comments they answer because ids only grow.
+func (s *SQLite) GetComments(ctx context.Context, postID int) ([]models.Comment, error) {
+	comments := []models.Comment{}
+
+	query := fmt.Sprintf("select * from %s where post_id = ? order by id", commentsTable)
+
+	err := s.db.SelectContext(ctx, &comments, query, postID)
+	if err != nil {
+		return comments, wrapError(err, "comment", "error getting comments")
+	}
+
+	return comments, nil
+}
+
+func (s *SQLite) GetComment(ctx context.Context, postID int, id int) (models.Comment, error) {
+	var comment models.Comment
+
+	query := fmt.Sprintf("select * from %s where post_id = ? and id = ?", commentsTable)
+
+	err := s.db.GetContext(ctx, &comment, query, postID, id)
+	if err != nil {
+		return comment, wrapError(err, "comment", "error getting comment")
+	}
+
+	return comment, nil
+}
+
+// UpdateComment stores the content of comment.
+func (s *SQLite) UpdateComment(ctx context.Context, comment models.Comment) error {
+	query := fmt.Sprintf("update %s set content = ? where post_id = ? and id = ?", commentsTable)
+
+	res, err := s.db.ExecContext(ctx, query, comment.Content, comment.PostID, comment.ID)
+	if err != nil {
+		return wrapError(err, "comment", "error updating comment")
+	}
+
+	return expectAffected(res, "comment", "error updating comment")
+}
+
+// DeleteComment deletes a comment together with all replies to it.
+func (s *SQLite) DeleteComment(ctx context.Context, postID int, id int) error {
+	query := fmt.Sprintf("delete from %s where post_id = ? and id = ?", commentsTable)
+
+	res, err := s.db.ExecContext(ctx, query, postID, id)
+	if err != nil {
+		return wrapError(err, "comment", "error deleting comment")
+	}
+
+	return expectAffected(res, "comment", "error deleting comment")
+}
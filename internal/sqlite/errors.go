@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/rostis232/prmv/internal/apperr"
+)
+
+// wrapError annotates err with op and, when the cause is recognised, tags it
+// with the matching apperr kind like the postgres package does. resource
+// names the entity in client-facing messages, e.g. "post not found".
+func wrapError(err error, resource, op string) error {
+	wrapped := fmt.Errorf("%s: %w", op, err)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperr.NotFound(resource+" not found", wrapped)
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) {
+		return apperr.Unavailable("database is unavailable", wrapped)
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch {
+		case sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique, sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey:
+			return apperr.Conflict(resource+" already exists", wrapped)
+		case sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey:
+			return apperr.Conflict(resource+" references missing data", wrapped)
+		case sqliteErr.Code == sqlite3.ErrConstraint, sqliteErr.Code == sqlite3.ErrTooBig, sqliteErr.Code == sqlite3.ErrMismatch:
+			return apperr.Validation(resource+" data is invalid", wrapped)
+		case sqliteErr.Code == sqlite3.ErrBusy, sqliteErr.Code == sqlite3.ErrLocked, sqliteErr.Code == sqlite3.ErrIoErr,
+			sqliteErr.Code == sqlite3.ErrCantOpen, sqliteErr.Code == sqlite3.ErrFull:
+			return apperr.Unavailable("database is unavailable", wrapped)
+		}
+	}
+
+	return wrapped
+}
@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// now is the current time the way the schema stores it. It evaluates to the
+// same time throughout a statement and its triggers.
+const now = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+
+// timeFormat is how timestamps are stored: UTC to the millisecond, with a
+// fixed width, so comparing the text compares the times.
+const timeFormat = "2006-01-02 15:04:05.000"
+
+// timestamp formats t for storing or comparing with stored timestamps.
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// nullTimestamp is timestamp for optional times.
+func nullTimestamp(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return timestamp(*t)
+}
+
+// parseTimestamp reads a timestamp computed by a query, which the driver
+// cannot recognise as one.
+func parseTimestamp(s string) (time.Time, error) {
+	return time.ParseInLocation(timeFormat, s, time.UTC)
+}
+
+// jsonArray encodes values for json_each, which stands in for the arrays of
+// the postgres package.
+func jsonArray[T any](values []T) string {
+	if values == nil {
+		values = []T{}
+	}
+
+	encoded, _ := json.Marshal(values)
+
+	return string(encoded)
+}
+
+// whereBuilder collects SQL conditions written with ? placeholders in the
+// order of their arguments.
+type whereBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (b *whereBuilder) add(condition string, args ...any) {
+	b.conditions = append(b.conditions, condition)
+	b.args = append(b.args, args...)
+}
+
+func (b *whereBuilder) String() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+
+	return " where " + strings.Join(b.conditions, " and ")
+}
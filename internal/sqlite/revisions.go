@@ -0,0 +1,40 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	postRevisionsTable = "post_revisions"
+)
+
+// GetRevisions lists the revisions of a post, newest first. Revisions are
+// recorded by a trigger on the posts table.
+func (s *SQLite) GetRevisions(ctx context.Context, postID int) ([]models.Revision, error) {
+	revisions := []models.Revision{}
+
+	query := fmt.Sprintf("select * from %s where post_id = ? order by version desc", postRevisionsTable)
+
+	err := s.db.SelectContext(ctx, &revisions, query, postID)
+	if err != nil {
+		return revisions, wrapError(err, "revision", "error getting revisions")
+	}
+
+	return revisions, nil
+}
+
+func (s *SQLite) GetRevision(ctx context.Context, postID int, version int) (models.Revision, error) {
+	var revision models.Revision
+
+	query := fmt.Sprintf("select * from %s where post_id = ? and version = ?", postRevisionsTable)
+
+	err := s.db.GetContext(ctx, &revision, query, postID, version)
+	if err != nil {
+		return revision, wrapError(err, "revision", "error getting revision")
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,433 @@
+// Package sqlite is a repository stored in a SQLite database, for small
+// deployments that do not run PostgreSQL. It behaves like the postgres
+// package and has its own migrations in schema/sqlite, which are built into
+// the binary.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/gommon/log"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/models"
+	"github.com/rostis232/prmv/schema"
+)
+
+const (
+	postsTable = "posts"
+)
+
+type SQLite struct {
+	db *sqlx.DB
+}
+
+// NewSQLite opens the database file at path, creating it if it does not
+// exist. ":memory:" opens a database that lives as long as the SQLite value.
+func NewSQLite(path string) (*SQLite, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	db, err := sqlx.Open("sqlite3", path+separator+"_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows one writer at a time, and an in-memory database exists
+	// once per connection, so all queries share one connection.
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Migrate() error {
+	log.Infof("migrating database")
+	driver, err := sqlite3.WithInstance(s.db.DB, &sqlite3.Config{})
+	if err != nil {
+		return fmt.Errorf("sqlite: could not instantiate database driver: %w", err)
+	}
+	source, err := iofs.New(schema.SQLite, "sqlite")
+	if err != nil {
+		return fmt.Errorf("sqlite: could not open migrations: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite3", driver)
+	if err != nil {
+		return fmt.Errorf("sqlite: could not instantiate migrate instance: %w", err)
+	}
+	err = m.Up()
+	if err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("sqlite: could not run migrations: %w", err)
+	}
+	log.Infof("migrated database")
+	return nil
+}
+
+// AddPost stores a post together with its tags. A post without a status is
+// published, one without a content format is plain text.
+func (s *SQLite) AddPost(ctx context.Context, post models.Post) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, wrapError(err, "post", "error adding post")
+	}
+	defer tx.Rollback()
+
+	id, err := insertPost(ctx, tx, post)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, wrapError(err, "post", "error adding post")
+	}
+
+	return id, nil
+}
+
+func insertPost(ctx context.Context, tx *sqlx.Tx, post models.Post) (int, error) {
+	var id int
+
+	query := fmt.Sprintf(`insert into %s (slug, title, content, content_format, content_html, author_id, category_id, status, publish_at)
+values (?, ?, ?, coalesce(nullif(?, ''), 'plain'), ?, ?, ?, coalesce(nullif(?, ''), 'published'), ?) returning id`, postsTable)
+
+	err := tx.QueryRowContext(ctx, query, post.Slug, post.Title, post.Content, post.ContentFormat, post.ContentHTML,
+		post.AuthorID, post.CategoryID, post.Status, nullTimestamp(post.PublishAt)).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "post", "error adding post")
+	}
+
+	err = setPostTags(ctx, tx, id, post.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLite) GetAllPosts(ctx context.Context, postsQuery models.PostsQuery) ([]models.Post, error) {
+	var where whereBuilder
+
+	where.add("deleted_at is null")
+
+	posts, err := s.selectPosts(ctx, postsQuery, &where)
+	if err != nil {
+		return posts, wrapError(err, "post", "error getting all posts")
+	}
+
+	return posts, nil
+}
+
+// GetTrash lists soft-deleted posts in the same order as GetAllPosts.
+func (s *SQLite) GetTrash(ctx context.Context, postsQuery models.PostsQuery) ([]models.Post, error) {
+	var where whereBuilder
+
+	where.add("deleted_at is not null")
+
+	posts, err := s.selectPosts(ctx, postsQuery, &where)
+	if err != nil {
+		return posts, wrapError(err, "post", "error getting trash")
+	}
+
+	return posts, nil
+}
+
+// postsConditions adds the filters of postsQuery to where. Its limit and
+// cursor are left to the caller.
+func postsConditions(where *whereBuilder, postsQuery models.PostsQuery) {
+	if postsQuery.AuthorID != nil {
+		where.add("author_id = ?", *postsQuery.AuthorID)
+	}
+
+	if postsQuery.CategoryID != nil {
+		categoryCondition(where, *postsQuery.CategoryID)
+	}
+
+	if postsQuery.Status != "" {
+		where.add("status = ?", postsQuery.Status)
+	}
+
+	tagsCondition(where, postsQuery.PostsFilter)
+}
+
+// selectPosts runs a keyset-paginated select over posts matching where.
+func (s *SQLite) selectPosts(ctx context.Context, postsQuery models.PostsQuery, where *whereBuilder) ([]models.Post, error) {
+	posts := []models.Post{}
+
+	postsConditions(where, postsQuery)
+
+	if postsQuery.After != nil {
+		where.add("(created_at, id) > (?, ?)", timestamp(postsQuery.After.CreatedAt), postsQuery.After.ID)
+	}
+
+	query := fmt.Sprintf("select * from %s%s order by created_at, id limit ?", postsTable, where)
+
+	err := s.db.SelectContext(ctx, &posts, query, append(where.args, postsQuery.Limit)...)
+	if err != nil {
+		return posts, err
+	}
+
+	return posts, attachTags(ctx, s.db, posts)
+}
+
+// GetPostsStats summarises the posts outside the trash matching the filters
+// of postsQuery. Its limit and cursor are ignored.
+func (s *SQLite) GetPostsStats(ctx context.Context, postsQuery models.PostsQuery) (models.PostsStats, error) {
+	var row struct {
+		Count        int            `db:"count"`
+		LastModified sql.NullString `db:"last_modified"`
+	}
+	var where whereBuilder
+
+	where.add("deleted_at is null")
+	postsConditions(&where, postsQuery)
+
+	query := fmt.Sprintf("select count(*) as count, max(updated_at) as last_modified from %s%s", postsTable, &where)
+
+	err := s.db.GetContext(ctx, &row, query, where.args...)
+	if err != nil {
+		return models.PostsStats{}, wrapError(err, "post", "error getting posts stats")
+	}
+
+	stats := models.PostsStats{Count: row.Count, LastModified: time.Unix(0, 0).UTC()}
+	if row.LastModified.Valid {
+		stats.LastModified, err = parseTimestamp(row.LastModified.String)
+		if err != nil {
+			return models.PostsStats{}, wrapError(err, "post", "error getting posts stats")
+		}
+	}
+
+	return stats, nil
+}
+
+// UpdatePost stores the slug, title, content, category, status, publication
+// time and tags of post and bumps its version, but only if the stored version
+// still equals post.Version. An empty slug, content format or status keeps
+// the current one.
+func (s *SQLite) UpdatePost(ctx context.Context, post models.Post) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, wrapError(err, "post", "error updating post")
+	}
+	defer tx.Rollback()
+
+	id, err := updatePost(ctx, tx, post, "version = ?", post.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, wrapError(err, "post", "error updating post")
+	}
+
+	return id, nil
+}
+
+// updatePost runs the update of UpdatePost on posts outside the trash that
+// also match condition.
+func updatePost(ctx context.Context, tx *sqlx.Tx, post models.Post, condition string, args ...any) (int, error) {
+	var id int
+
+	query := fmt.Sprintf(`update %s set slug = coalesce(nullif(?, ''), slug), title = ?, content = ?, content_format = coalesce(nullif(?, ''), content_format),
+content_html = ?, category_id = ?, status = coalesce(nullif(?, ''), status), publish_at = ?, version = version + 1
+where id = ? and deleted_at is null and %s returning id`, postsTable, condition)
+
+	args = append([]any{post.Slug, post.Title, post.Content, post.ContentFormat, post.ContentHTML,
+		post.CategoryID, post.Status, nullTimestamp(post.PublishAt), post.ID}, args...)
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, versionMismatchError(ctx, tx, post.ID, "error updating post")
+	}
+	if err != nil {
+		return 0, wrapError(err, "post", "error updating post")
+	}
+
+	err = setPostTags(ctx, tx, id, post.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLite) GetPost(ctx context.Context, id int) (models.Post, error) {
+	var post models.Post
+
+	query := fmt.Sprintf("select * from %s where id = ? and deleted_at is null", postsTable)
+
+	err := s.db.GetContext(ctx, &post, query, id)
+	if err != nil {
+		return post, wrapError(err, "post", "error getting post")
+	}
+
+	return post, s.attachPostTags(ctx, &post, "error getting post")
+}
+
+// GetPostForUpdate returns the post with the given id like GetPost does. It is
+// used for reads a write depends on.
+func (s *SQLite) GetPostForUpdate(ctx context.Context, id int) (models.Post, error) {
+	return s.GetPost(ctx, id)
+}
+
+// GetTrashedPost returns a post that is in the trash.
+func (s *SQLite) GetTrashedPost(ctx context.Context, id int) (models.Post, error) {
+	var post models.Post
+
+	query := fmt.Sprintf("select * from %s where id = ? and deleted_at is not null", postsTable)
+
+	err := s.db.GetContext(ctx, &post, query, id)
+	if err != nil {
+		return post, wrapError(err, "post", "error getting trashed post")
+	}
+
+	return post, s.attachPostTags(ctx, &post, "error getting trashed post")
+}
+
+// DeletePost moves the post with the given id to the trash. A non-zero version
+// makes the delete conditional on the stored version.
+func (s *SQLite) DeletePost(ctx context.Context, id int, version int) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return wrapError(err, "post", "error deleting post")
+	}
+	defer tx.Rollback()
+
+	err = deletePost(ctx, tx, id, version)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return wrapError(err, "post", "error deleting post")
+	}
+
+	return nil
+}
+
+func deletePost(ctx context.Context, tx *sqlx.Tx, id int, version int) error {
+	query := fmt.Sprintf("update %s set deleted_at = %s where id = ?1 and deleted_at is null and (?2 = 0 or version = ?2)", postsTable, now)
+
+	res, err := tx.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return wrapError(err, "post", "error deleting post")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return wrapError(err, "post", "error deleting post")
+	}
+
+	if affected == 0 {
+		return versionMismatchError(ctx, tx, id, "error deleting post")
+	}
+
+	return nil
+}
+
+// attachPostTags loads the tags of a single post.
+func (s *SQLite) attachPostTags(ctx context.Context, post *models.Post, op string) error {
+	posts := []models.Post{*post}
+
+	err := attachTags(ctx, s.db, posts)
+	if err != nil {
+		return wrapError(err, "post", op)
+	}
+
+	post.Tags = posts[0].Tags
+
+	return nil
+}
+
+// versionMismatchError explains why a conditional write touched no rows: the
+// post is either gone or was changed by someone else in the meantime.
+func versionMismatchError(ctx context.Context, q sqlx.QueryerContext, id int, op string) error {
+	var exists bool
+
+	query := fmt.Sprintf("select exists(select 1 from %s where id = ? and deleted_at is null)", postsTable)
+
+	err := sqlx.GetContext(ctx, q, &exists, query, id)
+	if err != nil {
+		return wrapError(err, "post", op)
+	}
+
+	if !exists {
+		return wrapError(sql.ErrNoRows, "post", op)
+	}
+
+	return apperr.PreconditionFailed("post has been modified", fmt.Errorf("%s: version mismatch", op))
+}
+
+// RestorePost takes a post out of the trash.
+func (s *SQLite) RestorePost(ctx context.Context, id int) error {
+	query := fmt.Sprintf("update %s set deleted_at = null where id = ? and deleted_at is not null", postsTable)
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError(err, "post", "error restoring post")
+	}
+
+	return expectAffected(res, "post", "error restoring post")
+}
+
+// PurgePost permanently deletes a post that is in the trash.
+func (s *SQLite) PurgePost(ctx context.Context, id int) error {
+	query := fmt.Sprintf("delete from %s where id = ? and deleted_at is not null", postsTable)
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError(err, "post", "error purging post")
+	}
+
+	return expectAffected(res, "post", "error purging post")
+}
+
+// PurgeTrash permanently deletes posts trashed before the given time and
+// returns how many were removed.
+func (s *SQLite) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf("delete from %s where deleted_at < ?", postsTable)
+
+	res, err := s.db.ExecContext(ctx, query, timestamp(before))
+	if err != nil {
+		return 0, wrapError(err, "post", "error purging trash")
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, wrapError(err, "post", "error purging trash")
+	}
+
+	return purged, nil
+}
+
+// expectAffected turns a statement that matched no rows into a not found error.
+func expectAffected(res sql.Result, resource, op string) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return wrapError(err, resource, op)
+	}
+
+	if affected == 0 {
+		return wrapError(sql.ErrNoRows, resource, op)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	sqlite3migrate "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/mattn/go-sqlite3"
+	"github.com/rostis232/prmv/internal/apperr"
+	"github.com/rostis232/prmv/internal/repotest"
+	"github.com/rostis232/prmv/models"
+	"github.com/rostis232/prmv/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+// prepareTestDB returns a migrated database in a file that is removed after
+// the test.
+func prepareTestDB(t *testing.T) *SQLite {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.db.Close()
+	})
+
+	err = s.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return prepareTestDB(t)
+	})
+}
+
+func TestNewSQLiteInMemory(t *testing.T) {
+	s, err := NewSQLite(":memory:")
+	assert.NoError(t, err)
+
+	err = s.Migrate()
+	assert.NoError(t, err)
+
+	permissions, err := s.GetRolePermissions(context.Background())
+	assert.NoError(t, err)
+	assert.Contains(t, permissions, "admin")
+}
+
+func TestUpdatedAt(t *testing.T) {
+	s := prepareTestDB(t)
+
+	id, err := s.AddPost(context.Background(), models.Post{Title: "Title", Content: "Content"})
+	assert.NoError(t, err)
+
+	post, err := s.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, post.CreatedAt, post.UpdatedAt)
+
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = s.UpdatePost(context.Background(), models.Post{ID: id, Version: 1, Title: "Updated", Content: "Content"})
+	assert.NoError(t, err)
+
+	updated, err := s.GetPost(context.Background(), id)
+	assert.NoError(t, err)
+	assert.True(t, updated.UpdatedAt.After(post.UpdatedAt))
+	assert.Equal(t, post.CreatedAt, updated.CreatedAt)
+
+	// Revisions are stamped with the time of the version they record.
+	revision, err := s.GetRevision(context.Background(), id, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, updated.UpdatedAt, revision.CreatedAt)
+}
+
+func TestRevisionContentFormatMigration(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.db.Close()
+	})
+
+	// A database created before revisions had a format.
+	driver, err := sqlite3migrate.WithInstance(s.db.DB, &sqlite3migrate.Config{})
+	assert.NoError(t, err)
+	source, err := iofs.New(schema.SQLite, "sqlite")
+	assert.NoError(t, err)
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite3", driver)
+	assert.NoError(t, err)
+	err = m.Migrate(1)
+	assert.NoError(t, err)
+
+	_, err = s.db.Exec("insert into posts (slug, title, content, content_format) values ('title', 'Title', '## Content', 'markdown')")
+	assert.NoError(t, err)
+
+	err = s.Migrate()
+	assert.NoError(t, err)
+
+	revisions, err := s.GetRevisions(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 1)
+	assert.Equal(t, models.FormatMarkdown, revisions[0].ContentFormat)
+
+	_, err = s.UpdatePost(context.Background(), models.Post{ID: 1, Version: 1, Title: "Title", Content: "<p>Content</p>", ContentFormat: models.FormatHTML})
+	assert.NoError(t, err)
+
+	revision, err := s.GetRevision(context.Background(), 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, models.FormatHTML, revision.ContentFormat)
+}
+
+func TestWrapError(t *testing.T) {
+	testCases := []struct {
+		err  error
+		kind error
+	}{
+		{err: sql.ErrNoRows, kind: apperr.ErrNotFound},
+		{err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, kind: apperr.ErrConflict},
+		{err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey}, kind: apperr.ErrConflict},
+		{err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey}, kind: apperr.ErrConflict},
+		{err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintCheck}, kind: apperr.ErrValidation},
+		{err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintNotNull}, kind: apperr.ErrValidation},
+		{err: sqlite3.Error{Code: sqlite3.ErrBusy}, kind: apperr.ErrUnavailable},
+		{err: sqlite3.Error{Code: sqlite3.ErrCantOpen}, kind: apperr.ErrUnavailable},
+		{err: driver.ErrBadConn, kind: apperr.ErrUnavailable},
+	}
+
+	for i, tc := range testCases {
+		err := wrapError(tc.err, "post", "error getting post")
+
+		assert.True(t, errors.Is(err, tc.kind), fmt.Sprintf("case %d", i))
+		assert.True(t, errors.Is(err, tc.err), fmt.Sprintf("case %d", i))
+	}
+
+	err := wrapError(sql.ErrTxDone, "post", "error getting post")
+	_, ok := apperr.Message(err)
+	assert.False(t, ok)
+	assert.Equal(t, "error getting post: sql: transaction has already been committed or rolled back", err.Error())
+}
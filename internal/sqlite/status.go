@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rostis232/prmv/models"
+)
+
+// PublishScheduledPosts publishes the scheduled posts outside the trash whose
+// publication time has come, as a new version, and returns how many there
+// were. SQLite runs one write at a time, so concurrent calls publish every
+// post once.
+func (s *SQLite) PublishScheduledPosts(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf("update %s set status = ?, version = version + 1 where status = ? and publish_at <= %s and deleted_at is null", postsTable, now)
+
+	res, err := s.db.ExecContext(ctx, query, models.StatusPublished, models.StatusScheduled)
+	if err != nil {
+		return 0, wrapError(err, "post", "error publishing scheduled posts")
+	}
+
+	published, err := res.RowsAffected()
+	if err != nil {
+		return 0, wrapError(err, "post", "error publishing scheduled posts")
+	}
+
+	return published, nil
+}
+
+// GetNextPublishAt returns the earliest publication time of the scheduled
+// posts outside the trash, or the zero time if there are none.
+func (s *SQLite) GetNextPublishAt(ctx context.Context) (time.Time, error) {
+	var next sql.NullString
+
+	query := fmt.Sprintf("select min(publish_at) from %s where status = ? and deleted_at is null", postsTable)
+
+	err := s.db.GetContext(ctx, &next, query, models.StatusScheduled)
+	if err != nil {
+		return time.Time{}, wrapError(err, "post", "error getting next publication time")
+	}
+
+	if !next.Valid {
+		return time.Time{}, nil
+	}
+
+	nextPublishAt, err := parseTimestamp(next.String)
+	if err != nil {
+		return time.Time{}, wrapError(err, "post", "error getting next publication time")
+	}
+
+	return nextPublishAt, nil
+}
@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	tagsTable     = "tags"
+	postTagsTable = "post_tags"
+)
+
+// GetTags lists the tags used by published posts outside the trash, most used
+// first.
+func (s *SQLite) GetTags(ctx context.Context) ([]models.Tag, error) {
+	tags := []models.Tag{}
+
+	query := fmt.Sprintf(`select t.name, count(*) as count from %s t
+join %s pt on pt.tag_id = t.id
+join %s p on p.id = pt.post_id and p.deleted_at is null and p.status = ?
+group by t.name
+order by count desc, t.name`, tagsTable, postTagsTable, postsTable)
+
+	err := s.db.SelectContext(ctx, &tags, query, models.StatusPublished)
+	if err != nil {
+		return tags, wrapError(err, "tag", "error getting tags")
+	}
+
+	return tags, nil
+}
+
+// setPostTags replaces the tags of a post, creating tags that do not exist
+// yet.
+func setPostTags(ctx context.Context, tx *sqlx.Tx, postID int, tags []string) error {
+	names := jsonArray(tags)
+
+	query := fmt.Sprintf("insert into %s (name) select value from json_each(?) where true on conflict do nothing", tagsTable)
+
+	_, err := tx.ExecContext(ctx, query, names)
+	if err != nil {
+		return wrapError(err, "tag", "error adding tags")
+	}
+
+	query = fmt.Sprintf("delete from %s where post_id = ? and tag_id not in (select id from %s where name in (select value from json_each(?)))", postTagsTable, tagsTable)
+
+	_, err = tx.ExecContext(ctx, query, postID, names)
+	if err != nil {
+		return wrapError(err, "tag", "error removing post tags")
+	}
+
+	query = fmt.Sprintf("insert into %s (post_id, tag_id) select ?, id from %s where name in (select value from json_each(?)) on conflict do nothing", postTagsTable, tagsTable)
+
+	_, err = tx.ExecContext(ctx, query, postID, names)
+	if err != nil {
+		return wrapError(err, "tag", "error adding post tags")
+	}
+
+	return nil
+}
+
+// attachTags loads the tags of posts with a single query. Posts without tags
+// get an empty list.
+func attachTags(ctx context.Context, q sqlx.QueryerContext, posts []models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	var rows []struct {
+		PostID int    `db:"post_id"`
+		Name   string `db:"name"`
+	}
+
+	query := fmt.Sprintf("select pt.post_id, t.name from %s pt join %s t on t.id = pt.tag_id where pt.post_id in (select value from json_each(?)) order by t.name", postTagsTable, tagsTable)
+
+	err := sqlx.SelectContext(ctx, q, &rows, query, jsonArray(ids))
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[int][]string, len(posts))
+	for _, row := range rows {
+		tags[row.PostID] = append(tags[row.PostID], row.Name)
+	}
+
+	for i := range posts {
+		posts[i].Tags = tags[posts[i].ID]
+		if posts[i].Tags == nil {
+			posts[i].Tags = []string{}
+		}
+	}
+
+	return nil
+}
+
+// tagsCondition matches posts carrying any or all of filter's tags.
+func tagsCondition(where *whereBuilder, filter models.PostsFilter) {
+	if len(filter.Tags) == 0 {
+		return
+	}
+
+	if filter.AllTags {
+		where.add(fmt.Sprintf("id in (select pt.post_id from %s pt join %s t on t.id = pt.tag_id where t.name in (select value from json_each(?)) group by pt.post_id having count(*) = ?)", postTagsTable, tagsTable),
+			jsonArray(filter.Tags), len(filter.Tags))
+		return
+	}
+
+	where.add(fmt.Sprintf("exists (select 1 from %s pt join %s t on t.id = pt.tag_id where pt.post_id = %s.id and t.name in (select value from json_each(?)))", postTagsTable, tagsTable, postsTable),
+		jsonArray(filter.Tags))
+}
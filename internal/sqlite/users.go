@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rostis232/prmv/models"
+)
+
+const (
+	usersTable         = "users"
+	refreshTokensTable = "refresh_tokens"
+
+	userColumns = "id, username, password_hash, created_at"
+)
+
+// AddUser stores a user together with its roles.
+func (s *SQLite) AddUser(ctx context.Context, user models.User) (int, error) {
+	var id int
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, wrapError(err, "user", "error adding user")
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("insert into %s (username, password_hash) values (?, ?) returning id", usersTable)
+
+	err = tx.QueryRowContext(ctx, query, user.Username, user.PasswordHash).Scan(&id)
+	if err != nil {
+		return 0, wrapError(err, "user", "error adding user")
+	}
+
+	if len(user.Roles) > 0 {
+		query = fmt.Sprintf("insert into %s (user_id, role) select ?, value from json_each(?)", userRolesTable)
+
+		_, err = tx.ExecContext(ctx, query, id, jsonArray(user.Roles))
+		if err != nil {
+			return 0, wrapError(err, "user", "error adding user roles")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, wrapError(err, "user", "error adding user")
+	}
+
+	return id, nil
+}
+
+func (s *SQLite) GetUser(ctx context.Context, id int) (models.User, error) {
+	var user models.User
+
+	query := fmt.Sprintf("select %s from %s where id = ?", userColumns, usersTable)
+
+	err := s.db.GetContext(ctx, &user, query, id)
+	if err != nil {
+		return user, wrapError(err, "user", "error getting user")
+	}
+
+	return user, nil
+}
+
+func (s *SQLite) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+
+	query := fmt.Sprintf("select %s from %s where username = ?", userColumns, usersTable)
+
+	err := s.db.GetContext(ctx, &user, query, username)
+	if err != nil {
+		return user, wrapError(err, "user", "error getting user")
+	}
+
+	return user, nil
+}
+
+func (s *SQLite) AddRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	query := fmt.Sprintf("insert into %s (token_hash, user_id, expires_at) values (?, ?, ?)", refreshTokensTable)
+
+	_, err := s.db.ExecContext(ctx, query, token.TokenHash, token.UserID, timestamp(token.ExpiresAt))
+	if err != nil {
+		return wrapError(err, "refresh token", "error adding refresh token")
+	}
+
+	return nil
+}
+
+// ConsumeRefreshToken revokes a live refresh token and returns the id of the
+// user it belongs to. Each refresh token can be used only once.
+func (s *SQLite) ConsumeRefreshToken(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+
+	query := fmt.Sprintf("update %[1]s set revoked_at = %[2]s where token_hash = ? and revoked_at is null and expires_at > %[2]s returning user_id", refreshTokensTable, now)
+
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID)
+	if err != nil {
+		return 0, wrapError(err, "refresh token", "error consuming refresh token")
+	}
+
+	return userID, nil
+}
@@ -5,9 +5,266 @@ import (
 )
 
 type Post struct {
+	ID            int        `db:"id" json:"id"`
+	Slug          string     `db:"slug" json:"slug"`
+	Title         string     `db:"title" json:"title" validate:"required,min=3,max=100"`
+	Content       string     `db:"content" json:"content" validate:"required,min=3"`
+	ContentFormat string     `db:"content_format" json:"content_format" validate:"omitempty,oneof=plain markdown html"`
+	ContentHTML   string     `db:"content_html" json:"content_html"`
+	Version       int        `db:"version" json:"version"`
+	AuthorID      *int       `db:"author_id" json:"author_id"`
+	CategoryID    *int       `db:"category_id" json:"category_id" validate:"omitempty,min=1"`
+	Tags          []string   `db:"-" json:"tags" validate:"max=20,dive,min=1,max=50"`
+	Status        string     `db:"status" json:"status" validate:"omitempty,oneof=draft scheduled published archived"`
+	PublishAt     *time.Time `db:"publish_at" json:"publish_at"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
+	DeletedAt     *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+}
+
+// Post statuses. Only published posts are shown to the public. The PublishAt
+// of a scheduled post is when it goes live; for a published post it is when
+// it went live.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Formats of post content. The ContentHTML of a post is its Content rendered
+// to sanitized HTML according to its ContentFormat when the post is stored.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// Cursor identifies the position of a post in the (created_at, id) ordering
+// used for keyset pagination.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// PostsQuery describes a single page request for the posts listing.
+// After is nil for the first page.
+type PostsQuery struct {
+	Limit int
+	After *Cursor
+	// AuthorID, when set, only matches posts by that author.
+	AuthorID *int
+	// CategoryID, when set, only matches posts in that category or any of
+	// its descendants.
+	CategoryID *int
+	PostsFilter
+}
+
+// PostsFilter narrows down the posts listing.
+type PostsFilter struct {
+	// Tags, when not empty, only matches posts with any of these tags, or
+	// with all of them if AllTags is set.
+	Tags    []string
+	AllTags bool
+	// Status only matches posts with that status.
+	Status string
+}
+
+// Tag is a label posts are organised by. Count is the number of published
+// posts outside the trash that carry it.
+type Tag struct {
+	Name  string `db:"name" json:"name"`
+	Count int    `db:"count" json:"count"`
+}
+
+// Category is a node of the category tree. Children is only filled in when
+// the tree is built.
+type Category struct {
+	ID        int        `db:"id" json:"id"`
+	ParentID  *int       `db:"parent_id" json:"parent_id"`
+	Slug      string     `db:"slug" json:"slug" validate:"required,max=100"`
+	Name      string     `db:"name" json:"name" validate:"required,max=100"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	Children  []Category `db:"-" json:"children,omitempty"`
+}
+
+type PostsPage struct {
+	Posts      []Post `json:"posts"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// PostsStats summarises the posts collection, enough to tell whether it has
+// changed without loading it.
+type PostsStats struct {
+	Count        int       `db:"count"`
+	LastModified time.Time `db:"last_modified"`
+}
+
+// Kinds of post changes. PostsMissed means changes may have been missed, so
+// any post may have changed.
+const (
+	PostInserted = "insert"
+	PostUpdated  = "update"
+	PostDeleted  = "delete"
+	PostsMissed  = "missed"
+)
+
+// PostChange tells that the post with ID was inserted, updated or deleted.
+// Changes of kind PostsMissed have no ID.
+type PostChange struct {
+	ID int    `json:"id"`
+	Op string `json:"op"`
+}
+
+// PostPatch is a patch document for a post. Type is the media type of the
+// document, either a JSON Merge Patch or a JSON Patch. A non-zero Version
+// makes the patch conditional on the stored version.
+type PostPatch struct {
+	Type     string
+	Document []byte
+	Version  int
+}
+
+// Kinds of bulk operations.
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkOperation is one step of a bulk request. Creates and updates use the
+// title and content of Post, updates and deletes its ID and, when non-zero,
+// Version.
+type BulkOperation struct {
+	Op   string
+	Post Post
+}
+
+// BulkResult is the outcome of one BulkOperation. Post is the stored post
+// after a create or update; Err is set when the operation was not applied.
+type BulkResult struct {
+	Index int
+	Op    string
+	ID    int
+	Post  *Post
+	Err   error
+}
+
+// Revision is the title and content a post had at one of its versions, with
+// the format the content was written in.
+type Revision struct {
+	PostID        int       `db:"post_id" json:"post_id"`
+	Version       int       `db:"version" json:"version"`
+	Title         string    `db:"title" json:"title"`
+	Content       string    `db:"content" json:"content"`
+	ContentFormat string    `db:"content_format" json:"content_format"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// Comment is a reader's comment on a post. Replies point at the comment they
+// answer with ParentID.
+type Comment struct {
 	ID        int       `db:"id" json:"id"`
-	Title     string    `db:"title" json:"title"`
-	Content   string    `db:"content" json:"content"`
+	PostID    int       `db:"post_id" json:"post_id"`
+	ParentID  *int      `db:"parent_id" json:"parent_id"`
+	AuthorID  *int      `db:"author_id" json:"author_id"`
+	Content   string    `db:"content" json:"content" validate:"required,max=10000"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	Replies   []Comment `db:"-" json:"replies,omitempty"`
+}
+
+// Attachment is a file attached to a post. The file itself is kept in
+// storage under StorageKey; ContentType is sniffed from its content.
+type Attachment struct {
+	ID          int       `db:"id" json:"id"`
+	PostID      int       `db:"post_id" json:"post_id"`
+	UploaderID  *int      `db:"uploader_id" json:"uploader_id"`
+	Filename    string    `db:"filename" json:"filename" validate:"required,max=255"`
+	ContentType string    `db:"content_type" json:"content_type"`
+	Size        int64     `db:"size" json:"size"`
+	StorageKey  string    `db:"storage_key" json:"-"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// User is an account that can authenticate against the API. PasswordHash is
+// never serialised.
+type User struct {
+	ID           int       `db:"id" json:"id"`
+	Username     string    `db:"username" json:"username"`
+	PasswordHash string    `db:"password_hash" json:"-"`
+	Roles        []string  `db:"-" json:"roles,omitempty"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+// RefreshToken is a stored refresh token. Only the hash of the token is kept.
+type RefreshToken struct {
+	TokenHash string    `db:"token_hash"`
+	UserID    int       `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// TokenPair is returned on login and refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn is the lifetime of the access token in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
+
+// Identity is the caller of a request as established by the handler layer.
+type Identity struct {
+	UserID   int
+	Username string
+	Roles    []string
+	// Scopes limits what the caller may do on top of its roles. It is nil
+	// unless the caller authenticated with an API key.
+	Scopes []string
+	// APIKeyID is the key the caller authenticated with, or 0.
+	APIKeyID int
+}
+
+// HasRole reports whether the identity was granted role.
+func (i Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Role is a named set of permissions.
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserRoles lists the roles granted to a user.
+type UserRoles struct {
+	UserID int      `json:"user_id"`
+	Roles  []string `json:"roles"`
+}
+
+// APIKey lets a machine client act on behalf of a user, limited to Scopes.
+// Only the hash of the secret is stored; Prefix tells keys apart in listings.
+type APIKey struct {
+	ID         int        `db:"id" json:"id"`
+	UserID     int        `db:"user_id" json:"user_id"`
+	Name       string     `db:"name" json:"name" validate:"required,max=100"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	KeyHash    string     `db:"key_hash" json:"-"`
+	Scopes     []string   `db:"-" json:"scopes" validate:"required,min=1"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// CreatedAPIKey is returned once, when a key is created. The secret in Key
+// cannot be retrieved again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
 }
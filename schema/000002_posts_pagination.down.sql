@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS posts_created_at_id_idx;
+
+ALTER TABLE posts ALTER COLUMN created_at DROP NOT NULL;
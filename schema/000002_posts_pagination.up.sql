@@ -0,0 +1,5 @@
+UPDATE posts SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+
+ALTER TABLE posts ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS posts_created_at_id_idx ON posts (created_at, id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS posts_updated_at_idx;
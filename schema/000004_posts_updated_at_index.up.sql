@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS posts_updated_at_idx ON posts (updated_at);
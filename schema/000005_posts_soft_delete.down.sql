@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS posts_deleted_at_idx;
+
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
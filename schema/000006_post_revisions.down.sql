@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS record_posts_revision ON posts;
+
+DROP FUNCTION IF EXISTS record_post_revision();
+
+DROP TABLE IF EXISTS post_revisions;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, version)
+);
+
+INSERT INTO post_revisions (post_id, version, title, content, created_at)
+SELECT id, version, title, content, updated_at FROM posts
+ON CONFLICT DO NOTHING;
+
+-- Every stored version of a post gets a revision, so revision numbers are
+-- post versions and the latest revision is the current post.
+CREATE OR REPLACE FUNCTION record_post_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.version = OLD.version THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO post_revisions (post_id, version, title, content, created_at)
+    VALUES (NEW.id, NEW.version, NEW.title, NEW.content, NEW.updated_at)
+    ON CONFLICT DO NOTHING;
+RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_posts_revision
+    AFTER INSERT OR UPDATE ON posts
+    FOR EACH ROW
+    EXECUTE FUNCTION record_post_revision();
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS refresh_tokens;
+
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
+
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,3 @@
+ALTER TABLE posts ADD COLUMN author_id INTEGER NULL REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS posts_author_id_idx ON posts (author_id);
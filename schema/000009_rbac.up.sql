@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Manages all posts and user roles'),
+    ('editor', 'Writes posts and edits posts of others'),
+    ('author', 'Writes and manages own posts'),
+    ('viewer', 'Reads posts only');
+
+INSERT INTO permissions (name, description) VALUES
+    ('posts:create', 'Create posts'),
+    ('posts:update:own', 'Update own posts'),
+    ('posts:update:any', 'Update posts of any author'),
+    ('posts:delete:own', 'Delete, restore and purge own posts'),
+    ('posts:delete:any', 'Delete, restore and purge posts of any author'),
+    ('roles:assign', 'Assign and revoke user roles');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'posts:create'),
+    ('admin', 'posts:update:any'),
+    ('admin', 'posts:delete:any'),
+    ('admin', 'roles:assign'),
+    ('editor', 'posts:create'),
+    ('editor', 'posts:update:own'),
+    ('editor', 'posts:update:any'),
+    ('editor', 'posts:delete:own'),
+    ('author', 'posts:create'),
+    ('author', 'posts:update:own'),
+    ('author', 'posts:delete:own');
+
+-- Existing users keep the rights they had before roles were introduced.
+INSERT INTO user_roles (user_id, role) SELECT id, 'author' FROM users;
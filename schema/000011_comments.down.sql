@@ -0,0 +1,5 @@
+UPDATE roles SET description = 'Reads posts only' WHERE name = 'viewer';
+
+DELETE FROM permissions WHERE name LIKE 'comments:%';
+
+DROP TABLE IF EXISTS comments;
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    parent_id INTEGER NULL REFERENCES comments (id) ON DELETE CASCADE,
+    author_id INTEGER NULL REFERENCES users (id) ON DELETE SET NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS comments_post_id_idx ON comments (post_id, id);
+CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id);
+
+CREATE TRIGGER update_comments_updated_at
+    BEFORE UPDATE ON comments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+INSERT INTO permissions (name, description) VALUES
+    ('comments:create', 'Comment on posts'),
+    ('comments:update:own', 'Edit own comments'),
+    ('comments:delete:own', 'Delete own comments'),
+    ('comments:delete:any', 'Delete comments of any author');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'comments:create'),
+    ('admin', 'comments:update:own'),
+    ('admin', 'comments:delete:any'),
+    ('editor', 'comments:create'),
+    ('editor', 'comments:update:own'),
+    ('editor', 'comments:delete:any'),
+    ('author', 'comments:create'),
+    ('author', 'comments:update:own'),
+    ('author', 'comments:delete:own'),
+    ('viewer', 'comments:create'),
+    ('viewer', 'comments:update:own'),
+    ('viewer', 'comments:delete:own');
+
+UPDATE roles SET description = 'Reads posts and comments on them' WHERE name = 'viewer';
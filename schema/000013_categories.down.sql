@@ -0,0 +1,7 @@
+DELETE FROM permissions WHERE name = 'categories:manage';
+
+DROP INDEX IF EXISTS posts_category_id_idx;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    parent_id INTEGER NULL REFERENCES categories (id) ON DELETE RESTRICT,
+    slug VARCHAR(100) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id);
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS category_id INTEGER NULL REFERENCES categories (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS posts_category_id_idx ON posts (category_id, created_at, id);
+
+INSERT INTO permissions (name, description) VALUES
+    ('categories:manage', 'Create categories');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'categories:manage'),
+    ('editor', 'categories:manage');
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS maintain_posts_slug ON posts;
+
+DROP FUNCTION IF EXISTS maintain_post_slug();
+
+DROP TABLE IF EXISTS post_slugs;
+
+DROP INDEX IF EXISTS posts_slug_idx;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,47 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug VARCHAR(100) NULL;
+
+UPDATE posts
+SET slug = coalesce(nullif(trim(both '-' from left(regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g'), 80)), ''), 'post') || '-' || id
+WHERE slug IS NULL;
+
+ALTER TABLE posts ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS posts_slug_idx ON posts (slug);
+
+-- Slugs a post had before, so links using them can be redirected.
+CREATE TABLE IF NOT EXISTS post_slugs (
+    slug VARCHAR(100) PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS post_slugs_post_id_idx ON post_slugs (post_id);
+
+-- Posts written without a slug get one derived from their id, and updates
+-- without a slug keep the current one. A replaced slug moves to post_slugs;
+-- a slug taken back by its post leaves it.
+CREATE OR REPLACE FUNCTION maintain_post_slug()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.slug IS NULL OR NEW.slug = '' THEN
+        IF TG_OP = 'UPDATE' THEN
+            NEW.slug := OLD.slug;
+        ELSE
+            NEW.slug := 'post-' || NEW.id;
+        END IF;
+    END IF;
+
+    IF TG_OP = 'UPDATE' AND NEW.slug <> OLD.slug THEN
+        DELETE FROM post_slugs WHERE slug = NEW.slug;
+
+        INSERT INTO post_slugs (slug, post_id) VALUES (OLD.slug, OLD.id)
+        ON CONFLICT (slug) DO UPDATE SET post_id = EXCLUDED.post_id, created_at = CURRENT_TIMESTAMP;
+    END IF;
+RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER maintain_posts_slug
+    BEFORE INSERT OR UPDATE ON posts
+    FOR EACH ROW
+    EXECUTE FUNCTION maintain_post_slug();
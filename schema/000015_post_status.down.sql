@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS stamp_posts_publish_at ON posts;
+
+DROP FUNCTION IF EXISTS stamp_post_publish_at();
+
+DROP INDEX IF EXISTS posts_scheduled_idx;
+
+DROP INDEX IF EXISTS posts_status_idx;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_scheduled_publish_at_check;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS publish_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS status;
@@ -0,0 +1,31 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published', 'archived'));
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP NULL;
+
+UPDATE posts SET publish_at = created_at WHERE publish_at IS NULL;
+
+ALTER TABLE posts ADD CONSTRAINT posts_scheduled_publish_at_check
+    CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS posts_status_idx ON posts (status, created_at, id);
+
+-- The scheduler looks for the scheduled posts that are due.
+CREATE INDEX IF NOT EXISTS posts_scheduled_idx ON posts (publish_at) WHERE status = 'scheduled';
+
+-- Posts going live are stamped with the time they do, unless they were
+-- scheduled for it.
+CREATE OR REPLACE FUNCTION stamp_post_publish_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status = 'published' AND NEW.publish_at IS NULL THEN
+        NEW.publish_at := NOW();
+    END IF;
+RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER stamp_posts_publish_at
+    BEFORE INSERT OR UPDATE ON posts
+    FOR EACH ROW
+    EXECUTE FUNCTION stamp_post_publish_at();
@@ -0,0 +1,3 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS content_html;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS content_format;
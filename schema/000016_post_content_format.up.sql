@@ -0,0 +1,15 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_format VARCHAR(20) NOT NULL DEFAULT 'plain'
+    CHECK (content_format IN ('plain', 'markdown', 'html'));
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html TEXT NOT NULL DEFAULT '';
+
+-- Existing posts are plain text. Render them the way the service does:
+-- escaped, one p element per paragraph and br elements for line breaks.
+UPDATE posts SET content_html = coalesce((
+    SELECT string_agg('<p>' || replace(paragraph, E'\n', E'<br>\n') || '</p>', E'\n' ORDER BY n)
+    FROM regexp_split_to_table(
+        trim(both E'\n' FROM replace(replace(replace(replace(replace(replace(
+            content, E'\r\n', E'\n'), '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')),
+        E'\n\\s*\n') WITH ORDINALITY AS paragraphs (paragraph, n)
+    WHERE btrim(paragraph, E' \t\n\r\f\v') <> ''
+), '');
@@ -0,0 +1,15 @@
+-- Attachments of purged posts are detached rather than deleted, so that their
+-- files can be removed from storage before the rows go.
+CREATE TABLE IF NOT EXISTS attachments (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NULL REFERENCES posts (id) ON DELETE SET NULL,
+    uploader_id INTEGER NULL REFERENCES users (id) ON DELETE SET NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS attachments_post_id_idx ON attachments (post_id, id);
+CREATE INDEX IF NOT EXISTS attachments_detached_idx ON attachments (id) WHERE post_id IS NULL;
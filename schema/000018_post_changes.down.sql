@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS notify_posts_changed ON posts;
+
+DROP FUNCTION IF EXISTS notify_post_change();
@@ -0,0 +1,20 @@
+-- Every change to a post is announced on the post_changes channel as
+-- {"id": ..., "op": "insert" | "update" | "delete"}, so that instances of
+-- the app can drop what they cached of it. Notifications are delivered when
+-- the transaction commits.
+CREATE OR REPLACE FUNCTION notify_post_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('post_changes', json_build_object('id', OLD.id, 'op', 'delete')::text);
+    ELSE
+        PERFORM pg_notify('post_changes', json_build_object('id', NEW.id, 'op', lower(TG_OP))::text);
+    END IF;
+RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER notify_posts_changed
+    AFTER INSERT OR UPDATE OR DELETE ON posts
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_post_change();
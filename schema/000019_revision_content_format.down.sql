@@ -0,0 +1,15 @@
+CREATE OR REPLACE FUNCTION record_post_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.version = OLD.version THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO post_revisions (post_id, version, title, content, created_at)
+    VALUES (NEW.id, NEW.version, NEW.title, NEW.content, NEW.updated_at)
+    ON CONFLICT DO NOTHING;
+RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+ALTER TABLE post_revisions DROP COLUMN IF EXISTS content_format;
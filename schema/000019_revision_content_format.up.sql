@@ -0,0 +1,23 @@
+-- Revisions keep the format their content is written in, so that reverting
+-- renders it the way it was. Revisions recorded before are assumed to be in
+-- the format their post has now.
+ALTER TABLE post_revisions ADD COLUMN IF NOT EXISTS content_format VARCHAR(20) NOT NULL DEFAULT 'plain'
+    CHECK (content_format IN ('plain', 'markdown', 'html'));
+
+UPDATE post_revisions SET content_format = posts.content_format
+FROM posts
+WHERE posts.id = post_revisions.post_id;
+
+CREATE OR REPLACE FUNCTION record_post_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.version = OLD.version THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO post_revisions (post_id, version, title, content, content_format, created_at)
+    VALUES (NEW.id, NEW.version, NEW.title, NEW.content, NEW.content_format, NEW.updated_at)
+    ON CONFLICT DO NOTHING;
+RETURN NEW;
+END;
+$$ language 'plpgsql';
@@ -0,0 +1,14 @@
+// Package schema holds the migrations that are built into the binary.
+package schema
+
+import "embed"
+
+// Postgres holds the migrations of the postgres repository.
+//
+//go:embed *.sql
+var Postgres embed.FS
+
+// SQLite holds the migrations of the SQLite repository.
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
@@ -0,0 +1,286 @@
+-- The schema of the postgres migrations in ../, in one step for SQLite.
+-- Timestamps are UTC text with millisecond precision, which sorts like the
+-- times it holds. Lengths of VARCHAR columns are enforced by checks, as
+-- SQLite ignores them.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) NOT NULL UNIQUE CHECK (length(username) <= 50),
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL CHECK (length(name) <= 100),
+    prefix VARCHAR(16) NOT NULL CHECK (length(prefix) <= 16),
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    -- A JSON array of scope names.
+    scopes TEXT NOT NULL,
+    expires_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    parent_id INTEGER NULL REFERENCES categories (id) ON DELETE RESTRICT,
+    slug VARCHAR(100) NOT NULL UNIQUE CHECK (length(slug) <= 100),
+    name VARCHAR(100) NOT NULL CHECK (length(name) <= 100),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug VARCHAR(100) NOT NULL UNIQUE CHECK (length(slug) <= 100),
+    title VARCHAR(255) NOT NULL CHECK (length(title) <= 255),
+    content TEXT NOT NULL,
+    content_format VARCHAR(20) NOT NULL DEFAULT 'plain' CHECK (content_format IN ('plain', 'markdown', 'html')),
+    content_html TEXT NOT NULL DEFAULT '',
+    version INTEGER NOT NULL DEFAULT 1,
+    author_id INTEGER NULL REFERENCES users (id) ON DELETE SET NULL,
+    category_id INTEGER NULL REFERENCES categories (id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'scheduled', 'published', 'archived')),
+    publish_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    deleted_at TIMESTAMP NULL,
+    CHECK (status <> 'scheduled' OR publish_at IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS posts_created_at_id_idx ON posts (created_at, id);
+CREATE INDEX IF NOT EXISTS posts_updated_at_idx ON posts (updated_at);
+CREATE INDEX IF NOT EXISTS posts_deleted_at_idx ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS posts_author_id_idx ON posts (author_id);
+CREATE INDEX IF NOT EXISTS posts_category_id_idx ON posts (category_id, created_at, id);
+CREATE INDEX IF NOT EXISTS posts_status_idx ON posts (status, created_at, id);
+CREATE INDEX IF NOT EXISTS posts_scheduled_idx ON posts (publish_at) WHERE status = 'scheduled';
+
+-- SQLite triggers cannot change the row being written, so the triggers of
+-- the postgres schema become updates of it after the write. Every
+-- strftime('now') of a statement and its triggers returns the same time.
+
+CREATE TRIGGER IF NOT EXISTS update_posts_updated_at
+    AFTER UPDATE ON posts
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE posts SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+-- Posts written without a slug get one derived from their id. Updates
+-- without a slug keep the current one, see the sqlite package.
+CREATE TRIGGER IF NOT EXISTS default_posts_slug
+    AFTER INSERT ON posts
+    FOR EACH ROW
+    WHEN NEW.slug = ''
+BEGIN
+    UPDATE posts SET slug = 'post-' || NEW.id WHERE id = NEW.id;
+END;
+
+-- Slugs a post had before, so links using them can be redirected. A
+-- replaced slug moves to post_slugs; a slug taken back by its post leaves it.
+CREATE TABLE IF NOT EXISTS post_slugs (
+    slug VARCHAR(100) PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS post_slugs_post_id_idx ON post_slugs (post_id);
+
+CREATE TRIGGER IF NOT EXISTS maintain_posts_slug
+    AFTER UPDATE OF slug ON posts
+    FOR EACH ROW
+    WHEN NEW.slug <> OLD.slug AND OLD.slug <> ''
+BEGIN
+    DELETE FROM post_slugs WHERE slug = NEW.slug;
+    INSERT OR REPLACE INTO post_slugs (slug, post_id) VALUES (OLD.slug, OLD.id);
+END;
+
+-- Posts going live are stamped with the time they do, unless they were
+-- scheduled for it.
+CREATE TRIGGER IF NOT EXISTS stamp_inserted_posts_publish_at
+    AFTER INSERT ON posts
+    FOR EACH ROW
+    WHEN NEW.status = 'published' AND NEW.publish_at IS NULL
+BEGIN
+    UPDATE posts SET publish_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS stamp_updated_posts_publish_at
+    AFTER UPDATE ON posts
+    FOR EACH ROW
+    WHEN NEW.status = 'published' AND NEW.publish_at IS NULL
+BEGIN
+    UPDATE posts SET publish_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+-- Every stored version of a post gets a revision, so revision numbers are
+-- post versions and the latest revision is the current post.
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (post_id, version)
+);
+
+CREATE TRIGGER IF NOT EXISTS record_inserted_posts_revision
+    AFTER INSERT ON posts
+    FOR EACH ROW
+BEGIN
+    INSERT OR IGNORE INTO post_revisions (post_id, version, title, content) VALUES (NEW.id, NEW.version, NEW.title, NEW.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS record_updated_posts_revision
+    AFTER UPDATE ON posts
+    FOR EACH ROW
+    WHEN NEW.version <> OLD.version
+BEGIN
+    INSERT OR IGNORE INTO post_revisions (post_id, version, title, content) VALUES (NEW.id, NEW.version, NEW.title, NEW.content);
+END;
+
+CREATE TABLE IF NOT EXISTS comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    parent_id INTEGER NULL REFERENCES comments (id) ON DELETE CASCADE,
+    author_id INTEGER NULL REFERENCES users (id) ON DELETE SET NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS comments_post_id_idx ON comments (post_id, id);
+CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id);
+
+CREATE TRIGGER IF NOT EXISTS update_comments_updated_at
+    AFTER UPDATE ON comments
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE comments SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_users_updated_at
+    AFTER UPDATE ON users
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(50) NOT NULL UNIQUE CHECK (length(name) <= 50)
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS post_tags_tag_id_idx ON post_tags (tag_id, post_id);
+
+-- Attachments of purged posts are detached rather than deleted, so that their
+-- files can be removed from storage before the rows go.
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NULL REFERENCES posts (id) ON DELETE SET NULL,
+    uploader_id INTEGER NULL REFERENCES users (id) ON DELETE SET NULL,
+    filename VARCHAR(255) NOT NULL CHECK (length(filename) <= 255),
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS attachments_post_id_idx ON attachments (post_id, id);
+CREATE INDEX IF NOT EXISTS attachments_detached_idx ON attachments (id) WHERE post_id IS NULL;
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Manages all posts and user roles'),
+    ('editor', 'Writes posts and edits posts of others'),
+    ('author', 'Writes and manages own posts'),
+    ('viewer', 'Reads posts and comments on them');
+
+INSERT INTO permissions (name, description) VALUES
+    ('posts:create', 'Create posts'),
+    ('posts:update:own', 'Update own posts'),
+    ('posts:update:any', 'Update posts of any author'),
+    ('posts:delete:own', 'Delete, restore and purge own posts'),
+    ('posts:delete:any', 'Delete, restore and purge posts of any author'),
+    ('roles:assign', 'Assign and revoke user roles'),
+    ('comments:create', 'Comment on posts'),
+    ('comments:update:own', 'Edit own comments'),
+    ('comments:delete:own', 'Delete own comments'),
+    ('comments:delete:any', 'Delete comments of any author'),
+    ('categories:manage', 'Create categories');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'posts:create'),
+    ('admin', 'posts:update:any'),
+    ('admin', 'posts:delete:any'),
+    ('admin', 'roles:assign'),
+    ('admin', 'comments:create'),
+    ('admin', 'comments:update:own'),
+    ('admin', 'comments:delete:any'),
+    ('admin', 'categories:manage'),
+    ('editor', 'posts:create'),
+    ('editor', 'posts:update:own'),
+    ('editor', 'posts:update:any'),
+    ('editor', 'posts:delete:own'),
+    ('editor', 'comments:create'),
+    ('editor', 'comments:update:own'),
+    ('editor', 'comments:delete:any'),
+    ('editor', 'categories:manage'),
+    ('author', 'posts:create'),
+    ('author', 'posts:update:own'),
+    ('author', 'posts:delete:own'),
+    ('author', 'comments:create'),
+    ('author', 'comments:update:own'),
+    ('author', 'comments:delete:own'),
+    ('viewer', 'comments:create'),
+    ('viewer', 'comments:update:own'),
+    ('viewer', 'comments:delete:own');
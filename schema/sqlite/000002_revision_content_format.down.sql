@@ -0,0 +1,20 @@
+DROP TRIGGER IF EXISTS record_inserted_posts_revision;
+
+CREATE TRIGGER record_inserted_posts_revision
+    AFTER INSERT ON posts
+    FOR EACH ROW
+BEGIN
+    INSERT OR IGNORE INTO post_revisions (post_id, version, title, content) VALUES (NEW.id, NEW.version, NEW.title, NEW.content);
+END;
+
+DROP TRIGGER IF EXISTS record_updated_posts_revision;
+
+CREATE TRIGGER record_updated_posts_revision
+    AFTER UPDATE ON posts
+    FOR EACH ROW
+    WHEN NEW.version <> OLD.version
+BEGIN
+    INSERT OR IGNORE INTO post_revisions (post_id, version, title, content) VALUES (NEW.id, NEW.version, NEW.title, NEW.content);
+END;
+
+ALTER TABLE post_revisions DROP COLUMN content_format;
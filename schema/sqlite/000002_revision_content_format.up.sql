@@ -0,0 +1,28 @@
+-- Revisions keep the format their content is written in, so that reverting
+-- renders it the way it was. Revisions recorded before are assumed to be in
+-- the format their post has now.
+ALTER TABLE post_revisions ADD COLUMN content_format VARCHAR(20) NOT NULL DEFAULT 'plain'
+    CHECK (content_format IN ('plain', 'markdown', 'html'));
+
+UPDATE post_revisions SET content_format = (SELECT content_format FROM posts WHERE posts.id = post_revisions.post_id);
+
+DROP TRIGGER IF EXISTS record_inserted_posts_revision;
+
+CREATE TRIGGER record_inserted_posts_revision
+    AFTER INSERT ON posts
+    FOR EACH ROW
+BEGIN
+    INSERT OR IGNORE INTO post_revisions (post_id, version, title, content, content_format)
+    VALUES (NEW.id, NEW.version, NEW.title, NEW.content, NEW.content_format);
+END;
+
+DROP TRIGGER IF EXISTS record_updated_posts_revision;
+
+CREATE TRIGGER record_updated_posts_revision
+    AFTER UPDATE ON posts
+    FOR EACH ROW
+    WHEN NEW.version <> OLD.version
+BEGIN
+    INSERT OR IGNORE INTO post_revisions (post_id, version, title, content, content_format)
+    VALUES (NEW.id, NEW.version, NEW.title, NEW.content, NEW.content_format);
+END;